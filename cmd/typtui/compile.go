@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+
+	"github.com/dyuri/typtui/internal/compiler"
+	"github.com/dyuri/typtui/internal/config"
+	"github.com/dyuri/typtui/internal/parser"
+)
+
+// runCompile compiles a TYP file with mkgmap, set up in the config file
+// or by flags, and prints its output with the types it is about. With
+// -native the built-in encoder compiles it instead.
+func runCompile(args []string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "typtui compile: %v, using the default settings\n", err)
+	}
+
+	flags := flag.NewFlagSet("typtui compile", flag.ContinueOnError)
+	output := flags.String("o", "", "write the compiled file here, by default next to the file")
+	java := flags.String("java", cfg.Mkgmap.Java, "the java command")
+	jar := flags.String("jar", cfg.Mkgmap.Jar, "path of mkgmap.jar")
+	familyID := flags.Int("family-id", cfg.Mkgmap.FamilyID, "family ID, 0 keeps the FID of the file")
+	productID := flags.Int("product-id", cfg.Mkgmap.ProductID, "product ID, 0 keeps the product code of the file")
+	native := flags.Bool("native", false, "compile with the built-in encoder, without mkgmap or a JVM")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: typtui compile [-native] [-o out.typ] [-jar mkgmap.jar] [-java java] [-family-id n] [-product-id n] file.typ [-- mkgmap options]\n")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	rest := flags.Args()
+	if len(rest) == 0 || len(rest) > 1 && rest[1] != "--" {
+		flags.Usage()
+		return errors.New("a file is required, mkgmap options go after --")
+	}
+
+	opts := compiler.Options{
+		Java:      *java,
+		Jar:       *jar,
+		Args:      cfg.Mkgmap.Options,
+		FamilyID:  *familyID,
+		ProductID: *productID,
+	}
+	if len(rest) > 2 {
+		opts.Args = rest[2:]
+	}
+	path := rest[0]
+	if *output == "" {
+		*output = compiler.DefaultOutput(path)
+	}
+	if compiler.SamePath(*output, path) {
+		return fmt.Errorf("the compiled file would overwrite %s, use -o to write it elsewhere", path)
+	}
+
+	typFile, err := parser.ParseFile(path)
+	if err != nil {
+		return err
+	}
+	if *native {
+		if len(rest) > 1 {
+			return errors.New("mkgmap options cannot be used with -native")
+		}
+		return compileNative(typFile, path, *output, *familyID, *productID)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	result, err := compiler.Run(ctx, typFile, *output, opts, func(msg compiler.Message) {
+		fmt.Println(msg)
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Compiled %s to %s\n", path, result.Output)
+	return nil
+}
+
+// compileNative compiles a TYP text file with the built-in encoder
+func compileNative(typFile *parser.TYPFile, path, output string, familyID, productID int) error {
+	if typFile.Binary {
+		return fmt.Errorf("%s is already compiled", path)
+	}
+	if familyID != 0 {
+		typFile.Header.FID = familyID
+	}
+	if productID != 0 {
+		typFile.Header.ProductCode = productID
+	}
+	if err := parser.WriteBinaryFile(typFile, output); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Compiled %s to %s\n", path, output)
+	return nil
+}
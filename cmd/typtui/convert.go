@@ -0,0 +1,57 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/dyuri/typtui/internal/parser"
+)
+
+// runConvert switches a TYP file to another code page. Labels the target
+// code page cannot hold are listed and nothing is written. Compiled files
+// are not written back, as the decoder does not keep everything they hold;
+// they are converted to a text file given with -o.
+func runConvert(args []string) error {
+	flags := flag.NewFlagSet("typtui convert", flag.ContinueOnError)
+	to := flags.Int("to", 0, "target code page: 1250-1258, or 65001 for UTF-8")
+	output := flags.String("o", "", "write to this file instead of replacing the input")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: typtui convert -to <code page> [-o out.typ] file.typ\n")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 || *to == 0 {
+		flags.Usage()
+		return errors.New("a target code page and one file are required")
+	}
+
+	input := flags.Arg(0)
+	typFile, err := parser.ParseFile(input)
+	if err != nil {
+		return err
+	}
+	if typFile.Binary && (*output == "" || filepath.Clean(*output) == filepath.Clean(input)) {
+		return fmt.Errorf("%s is a compiled TYP file, use -o to write the converted file as text", input)
+	}
+
+	if problems := parser.CheckLabels(typFile, *to); len(problems) > 0 {
+		for _, problem := range problems {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", input, problem)
+		}
+		return fmt.Errorf("%d labels cannot be written in code page %d", len(problems), *to)
+	}
+	if err := parser.ConvertCodePage(typFile, *to); err != nil {
+		return err
+	}
+
+	target := input
+	if *output != "" {
+		target = *output
+	}
+	return parser.WriteFile(typFile, target)
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/dyuri/typtui/internal/cvd"
+	"github.com/dyuri/typtui/internal/parser"
+)
+
+// runCVD lists the pairs of line or polygon types that colour-blind
+// readers cannot tell apart
+func runCVD(args []string) error {
+	flags := flag.NewFlagSet("typtui cvd", flag.ContinueOnError)
+	threshold := flags.Float64("threshold", cvd.DefaultThreshold, "CIE76 colour difference below which types look alike")
+	only := flags.String("only", "", "comma separated deficiencies to check: protan, deutan, tritan or achromat; all by default")
+	night := flags.Bool("night", false, "compare the night colours")
+	asJSON := flags.Bool("json", false, "print the pairs as JSON")
+	exitCode := flags.Bool("exit-code", false, "fail when some types look alike")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: typtui cvd [-threshold 10] [-only deficiencies] [-night] [-json] [-exit-code] file.typ\n")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return errors.New("one file is required")
+	}
+	if *threshold <= 0 {
+		return errors.New("the threshold must be positive")
+	}
+
+	opts := cvd.Options{Threshold: *threshold, Night: *night}
+	for _, name := range strings.Split(*only, ",") {
+		if strings.TrimSpace(name) == "" {
+			continue
+		}
+		d, err := cvd.ParseDeficiency(name)
+		if err != nil {
+			return err
+		}
+		opts.Deficiencies = append(opts.Deficiencies, d)
+	}
+
+	typFile, err := parser.ParseFile(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	pairs := cvd.Check(typFile, opts)
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(pairs); err != nil {
+			return err
+		}
+	} else {
+		printCVDPairs(os.Stdout, pairs)
+	}
+
+	if *exitCode && len(pairs) > 0 {
+		return fmt.Errorf("%d pairs of types look alike with a colour vision deficiency", len(pairs))
+	}
+	return nil
+}
+
+// printCVDPairs lists the pairs that look alike by deficiency
+func printCVDPairs(w io.Writer, pairs []cvd.Pair) {
+	if len(pairs) == 0 {
+		fmt.Fprintln(w, "All line and polygon types stay apart")
+		return
+	}
+	var last cvd.Deficiency
+	for _, p := range pairs {
+		if p.Deficiency != last {
+			if last != "" {
+				fmt.Fprintln(w)
+			}
+			fmt.Fprintf(w, "%s:\n", p.Deficiency)
+			last = p.Deficiency
+		}
+		fmt.Fprintf(w, "  %-7s %s %s and %s %s look %s and %s, ΔE %.1f (normally %.1f)\n",
+			p.Kind, p.A.Type, p.A.Color, p.B.Type, p.B.Color, p.A.Seen, p.B.Seen, p.Simulated, p.Normal)
+	}
+}
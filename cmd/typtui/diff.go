@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/dyuri/typtui/internal/parser"
+	"github.com/dyuri/typtui/internal/typdiff"
+)
+
+// runDiff compares two TYP files type by type and prints what was added,
+// removed and changed, or the same as JSON. Like diff(1) it exits with 1
+// when the files differ and 2 when they cannot be compared.
+func runDiff(args []string) error {
+	differ, err := diffFiles(args)
+	switch {
+	case err != nil:
+		return &exitError{status: 2, err: err}
+	case differ:
+		return &exitError{status: 1}
+	}
+	return nil
+}
+
+// diffFiles prints the differences of two files and reports whether there
+// are any
+func diffFiles(args []string) (bool, error) {
+	flags := flag.NewFlagSet("typtui diff", flag.ContinueOnError)
+	asJSON := flags.Bool("json", false, "print the differences as JSON")
+	colorMode := flags.String("color", "auto", "draw icons in colour: auto, always or never")
+	icons := flags.Bool("icons", true, "draw changed icons side by side")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: typtui diff [-json] [-color auto|always|never] [-icons=false] old.typ new.typ\n")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return false, err
+	}
+	if flags.NArg() != 2 {
+		flags.Usage()
+		return false, errors.New("two files are required")
+	}
+
+	var color bool
+	switch *colorMode {
+	case "auto":
+		color = isTerminal(os.Stdout) && os.Getenv("NO_COLOR") == "" && os.Getenv("TERM") != "dumb"
+	case "always":
+		color = true
+	case "never":
+	default:
+		return false, fmt.Errorf("invalid -color %q, use auto, always or never", *colorMode)
+	}
+
+	a, err := parser.ParseFile(flags.Arg(0))
+	if err != nil {
+		return false, err
+	}
+	b, err := parser.ParseFile(flags.Arg(1))
+	if err != nil {
+		return false, err
+	}
+	d := typdiff.Compare(a, b)
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(d); err != nil {
+			return false, err
+		}
+	} else {
+		printDiff(os.Stdout, d, *icons, color)
+	}
+	return !d.Empty(), nil
+}
+
+// changeMarks start the line of each changed type
+var changeMarks = map[typdiff.Change]string{
+	typdiff.Added:   "+",
+	typdiff.Removed: "-",
+	typdiff.Changed: "~",
+}
+
+// printDiff writes a diff for reading
+func printDiff(w io.Writer, d *typdiff.Diff, icons, color bool) {
+	fmt.Fprintf(w, "--- %s\n+++ %s\n", d.Old, d.New)
+	for _, p := range d.Header {
+		fmt.Fprintf(w, "~ header %s: %s → %s\n", p.Name, p.Old, p.New)
+	}
+
+	for _, t := range d.Types {
+		fmt.Fprintf(w, "%s %s %s", changeMarks[t.Change], t.Kind, t.Type)
+		if t.Label != "" {
+			fmt.Fprintf(w, " %q", t.Label)
+		}
+		fmt.Fprintln(w)
+		if t.Change != typdiff.Changed {
+			continue
+		}
+
+		for _, p := range t.Properties {
+			switch {
+			case p.Old == "":
+				fmt.Fprintf(w, "    + %s: %s\n", p.Name, p.New)
+			case p.New == "":
+				fmt.Fprintf(w, "    - %s: %s\n", p.Name, p.Old)
+			case p.Detail != "":
+				fmt.Fprintf(w, "    %s: %s\n", p.Name, p.Detail)
+			default:
+				fmt.Fprintf(w, "    %s: %s → %s\n", p.Name, p.Old, p.New)
+			}
+			if icons && p.Icon() {
+				for _, line := range typdiff.SideBySide(p.OldIcon, p.NewIcon, color) {
+					fmt.Fprintf(w, "      %s\n", line)
+				}
+			}
+		}
+	}
+
+	if d.Empty() {
+		fmt.Fprintln(w, "no differences")
+		return
+	}
+	fmt.Fprintf(w, "%d changed, %d added, %d removed\n", d.Summary.Changed, d.Summary.Added, d.Summary.Removed)
+}
+
+// isTerminal reports whether f is a terminal rather than a file or pipe
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
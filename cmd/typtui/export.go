@@ -0,0 +1,74 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/dyuri/typtui/internal/parser"
+	"github.com/dyuri/typtui/internal/xpmimage"
+)
+
+// runExport writes the icon of one type to a PNG, or without a type a
+// sprite sheet of all icons of a kind with a JSON index
+func runExport(args []string) error {
+	flags := flag.NewFlagSet("typtui export", flag.ContinueOnError)
+	output := flags.String("o", "", "PNG file to write, by default named after the type or kind")
+	scale := flags.Int("scale", 1, "size of an icon pixel in PNG pixels")
+	night := flags.Bool("night", false, "export the night icons")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: typtui export [-o out.png] [-scale n] [-night] file.typ point|line|polygon [type]\n")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 2 && flags.NArg() != 3 {
+		flags.Usage()
+		return errors.New("a file and a kind are required")
+	}
+
+	typFile, err := parser.ParseFile(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	kind := strings.TrimSuffix(flags.Arg(1), "s")
+	entries, err := xpmimage.Entries(typFile, kind, *night)
+	if err != nil {
+		return err
+	}
+
+	if flags.NArg() == 2 {
+		path := *output
+		if path == "" {
+			path = kind + "s.png"
+		}
+		if _, err := xpmimage.ExportSheet(path, entries, *scale); err != nil {
+			return err
+		}
+		fmt.Printf("Wrote %s and its index %s\n", path, xpmimage.IndexPath(path))
+		return nil
+	}
+
+	// Find the type by its code in any spelling
+	want := flags.Arg(2)
+	if code, err := parser.ParseTypeCode(want, ""); err == nil {
+		want = code.String()
+	}
+	for _, e := range entries {
+		if e.Type != want {
+			continue
+		}
+		path := *output
+		if path == "" {
+			path = kind + "-" + e.Type + ".png"
+		}
+		if err := xpmimage.ExportFile(path, e.Icon, *scale); err != nil {
+			return err
+		}
+		fmt.Printf("Wrote %s\n", path)
+		return nil
+	}
+	return fmt.Errorf("%s has no %s %s with an icon", flags.Arg(0), kind, flags.Arg(2))
+}
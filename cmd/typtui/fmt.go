@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/dyuri/typtui/internal/atomicfile"
+	"github.com/dyuri/typtui/internal/parser"
+)
+
+// runFmt rewrites TYP files in canonical form. With -check nothing is
+// written; the files that are not formatted are listed and the command
+// fails. Without files it formats stdin to stdout.
+func runFmt(args []string) error {
+	flags := flag.NewFlagSet("typtui fmt", flag.ContinueOnError)
+	check := flags.Bool("check", false, "list files that are not formatted and exit non-zero, without changing them")
+	sortTypes := flags.Bool("sort", false, "sort points, lines and polygons by type code")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: typtui fmt [-check] [-sort] [file.typ ...]\n")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	opts := parser.FormatOptions{SortTypes: *sortTypes}
+
+	if flags.NArg() == 0 {
+		source, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return err
+		}
+		formatted, err := formatSource(source, "<stdin>", opts)
+		if err != nil {
+			return err
+		}
+		if *check {
+			if !bytes.Equal(source, formatted) {
+				return errors.New("<stdin> is not formatted")
+			}
+			return nil
+		}
+		_, err = os.Stdout.Write(formatted)
+		return err
+	}
+
+	unformatted := 0
+	for _, path := range flags.Args() {
+		source, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		formatted, err := formatSource(source, path, opts)
+		if err != nil {
+			return err
+		}
+		if bytes.Equal(source, formatted) {
+			continue
+		}
+		if *check {
+			fmt.Println(path)
+			unformatted++
+			continue
+		}
+		if err := atomicfile.WriteFile(path, formatted, 0644); err != nil {
+			return err
+		}
+	}
+
+	if unformatted > 0 {
+		return fmt.Errorf("%d of %d files are not formatted", unformatted, flags.NArg())
+	}
+	return nil
+}
+
+// formatSource returns TYP text in canonical form
+func formatSource(source []byte, name string, opts parser.FormatOptions) ([]byte, error) {
+	typFile, err := parser.ParseReader(bytes.NewReader(source), name)
+	if err != nil {
+		return nil, err
+	}
+	if typFile.Binary {
+		return nil, fmt.Errorf("%s: compiled files have no text to format", name)
+	}
+
+	var buf bytes.Buffer
+	if err := parser.Format(&buf, typFile, opts); err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/dyuri/typtui/internal/atomicfile"
+	"github.com/dyuri/typtui/internal/legend"
+	"github.com/dyuri/typtui/internal/parser"
+)
+
+// runLegend writes the catalogue of a TYP file as a self-contained HTML
+// page, to standard output unless a file is given
+func runLegend(args []string) error {
+	flags := flag.NewFlagSet("typtui legend", flag.ContinueOnError)
+	output := flags.String("o", "", "write the page to this file instead of standard output")
+	title := flags.String("title", "", "title of the page, by default the file name")
+	scale := flags.Int("scale", 2, "size of an icon pixel in screen pixels")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: typtui legend [-o legend.html] [-title title] [-scale n] file.typ\n")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return errors.New("a file is required")
+	}
+	if *scale < 1 {
+		return fmt.Errorf("the scale must be at least 1, not %d", *scale)
+	}
+
+	typFile, err := parser.ParseFile(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	opts := legend.Options{Title: *title, Scale: *scale}
+
+	if *output == "" {
+		return legend.Write(os.Stdout, typFile, opts)
+	}
+	return atomicfile.Write(*output, 0o644, func(w io.Writer) error {
+		return legend.Write(w, typFile, opts)
+	})
+}
@@ -0,0 +1,114 @@
+// Command typtui is a terminal editor for Garmin TYP files. Without a
+// command it opens the editor; commands work on files from the shell.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/dyuri/typtui/internal/config"
+	"github.com/dyuri/typtui/internal/tui"
+	"github.com/dyuri/typtui/internal/validate"
+)
+
+// command is a subcommand run from the shell
+type command struct {
+	name    string
+	summary string
+	run     func(args []string) error
+}
+
+// exitError ends a command with an exit status other than 1. Without an
+// error nothing is printed, as when diff(1) finds differences.
+type exitError struct {
+	status int
+	err    error
+}
+
+func (e *exitError) Error() string {
+	if e.err == nil {
+		return fmt.Sprintf("exit status %d", e.status)
+	}
+	return e.err.Error()
+}
+
+func (e *exitError) Unwrap() error {
+	return e.err
+}
+
+// commands lists the subcommands in usage order
+var commands = []command{
+	{"compile", "compile a TYP file with mkgmap", runCompile},
+	{"convert", "convert a TYP file to another code page", runConvert},
+	{"cvd", "list line and polygon types colour-blind readers cannot tell apart", runCVD},
+	{"diff", "compare two TYP files type by type", runDiff},
+	{"export", "export icons to PNG files or sprite sheets", runExport},
+	{"fmt", "rewrite TYP files in canonical form", runFmt},
+	{"legend", "write a self-contained HTML legend of the types", runLegend},
+	{"merge", "merge two TYP files type by type, as a git merge driver", runMerge},
+	{"night", "generate night colours from day colours", runNight},
+	{"resolve", "resolve the conflicts of a TYP file merge in the editor", runResolve},
+	{"style", "cross-check a TYP file with the rules of an mkgmap style", runStyle},
+	{"types", "look up standard type codes by code, name or OSM tag", runTypes},
+	{"validate", "check TYP files for problems mkgmap or a device would trip over", runValidate},
+}
+
+func main() {
+	if len(os.Args) > 1 {
+		for _, cmd := range commands {
+			if os.Args[1] == cmd.name {
+				if err := cmd.run(os.Args[2:]); err != nil {
+					status := 1
+					var exit *exitError
+					if errors.As(err, &exit) {
+						status = exit.status
+						err = exit.err
+					}
+					if err != nil {
+						fmt.Fprintf(os.Stderr, "typtui %s: %v\n", cmd.name, err)
+					}
+					os.Exit(status)
+				}
+				return
+			}
+		}
+	}
+
+	flags := flag.NewFlagSet("typtui", flag.ExitOnError)
+	flags.Usage = usage
+	flags.Parse(os.Args[1:])
+	if flags.NArg() > 1 {
+		usage()
+		os.Exit(2)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "typtui: %v, using the default settings\n", err)
+	}
+	if err := validate.CheckConfig(cfg.Rules); err != nil {
+		fmt.Fprintf(os.Stderr, "typtui: %v\n", err)
+	}
+
+	p := tea.NewProgram(tui.NewModel(flags.Arg(0), cfg), tea.WithAltScreen())
+	final, err := p.Run()
+	if m, ok := final.(tui.Model); ok {
+		m.Close()
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "typtui: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// usage prints the command line help
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage:\n  typtui [file.typ]\n  typtui <command> [options] file.typ\n\nCommands:\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", cmd.name, cmd.summary)
+	}
+}
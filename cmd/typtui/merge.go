@@ -0,0 +1,223 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/dyuri/typtui/internal/config"
+	"github.com/dyuri/typtui/internal/parser"
+	"github.com/dyuri/typtui/internal/tui"
+	"github.com/dyuri/typtui/internal/typmerge"
+)
+
+// mergeDriver is the git merge driver command: %O is the base, %A ours and
+// the result, %B theirs and %P the path in the work tree. Git quotes %P
+// itself, so it must not be quoted again or paths with spaces get split.
+const mergeDriver = "typtui merge -name %P %O %A %B"
+
+// runMerge merges the changes made in two TYP files to a common base type
+// by type. It is a git merge driver: the result replaces ours, and it
+// fails while conflicts are left for typtui resolve.
+func runMerge(args []string) error {
+	flags := flag.NewFlagSet("typtui merge", flag.ContinueOnError)
+	output := flags.String("o", "", "write the result to this file instead of ours")
+	asJSON := flags.Bool("json", false, "print the conflicts as JSON")
+	name := flags.String("name", "", "path of the file shown in messages")
+	install := flags.Bool("install", false, "set typtui up as the git merge driver for *.typ files and exit")
+	global := flags.Bool("global", false, "with -install, set the driver up for all repositories")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: typtui merge [-o out.typ] [-json] base.typ ours.typ theirs.typ\n       typtui merge -install [-global]\n")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *install {
+		return installMergeDriver(*global)
+	}
+	if flags.NArg() != 3 {
+		flags.Usage()
+		return errors.New("the base, our and their files are required")
+	}
+
+	var files [3]*parser.TYPFile
+	for i, path := range flags.Args() {
+		typFile, err := parser.ParseFile(path)
+		if err != nil {
+			return err
+		}
+		if typFile.Binary {
+			return fmt.Errorf("%s is a compiled TYP file, only text files can be merged", path)
+		}
+		files[i] = typFile
+	}
+	r := typmerge.Merge(files[0], files[1], files[2])
+
+	target := *output
+	if target == "" {
+		target = flags.Arg(1)
+	}
+	if err := parser.WriteFile(r.File(), target); err != nil {
+		return err
+	}
+
+	if *name == "" {
+		*name = target
+	}
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(struct {
+			File      string               `json:"file"`
+			Conflicts []*typmerge.Conflict `json:"conflicts"`
+		}{*name, append([]*typmerge.Conflict{}, r.Conflicts...)}); err != nil {
+			return err
+		}
+	} else {
+		for _, c := range r.Conflicts {
+			fmt.Fprintf(os.Stderr, "CONFLICT %s: %s\n", *name, c)
+		}
+	}
+
+	switch n := len(r.Conflicts); {
+	case n == 1:
+		return fmt.Errorf("1 conflict in %s kept our version, run typtui resolve %s", *name, *name)
+	case n > 1:
+		return fmt.Errorf("%d conflicts in %s kept our version, run typtui resolve %s", n, *name, *name)
+	}
+	return nil
+}
+
+// installMergeDriver registers the merge driver in the git config and,
+// for a repository, marks *.typ files to use it in .gitattributes
+func installMergeDriver(global bool) error {
+	scope := "--local"
+	if global {
+		scope = "--global"
+	}
+	for _, setting := range [][2]string{
+		{"merge.typtui.name", "typtui TYP file merge"},
+		{"merge.typtui.driver", mergeDriver},
+	} {
+		if out, err := exec.Command("git", "config", scope, setting[0], setting[1]).CombinedOutput(); err != nil {
+			return fmt.Errorf("git config %s: %v: %s", setting[0], err, bytes.TrimSpace(out))
+		}
+	}
+	if global {
+		fmt.Println("Installed the merge driver, add \"*.typ merge=typtui\" to .gitattributes to use it")
+		return nil
+	}
+
+	out, err := exec.Command("git", "rev-parse", "--show-toplevel").Output()
+	if err != nil {
+		return fmt.Errorf("not in a git repository: %v", err)
+	}
+	path := filepath.Join(strings.TrimSpace(string(out)), ".gitattributes")
+	const attribute = "*.typ merge=typtui"
+
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.TrimSpace(line) == attribute {
+			fmt.Println("Installed the merge driver")
+			return nil
+		}
+	}
+	if len(data) > 0 && !bytes.HasSuffix(data, []byte("\n")) {
+		data = append(data, '\n')
+	}
+	data = append(data, attribute+"\n"...)
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return err
+	}
+	fmt.Printf("Installed the merge driver and added %q to %s\n", attribute, path)
+	return nil
+}
+
+// runResolve opens the editor to resolve the conflicts of merging a TYP
+// file. The versions come from the git index unless they are given.
+func runResolve(args []string) error {
+	flags := flag.NewFlagSet("typtui resolve", flag.ContinueOnError)
+	basePath := flags.String("base", "", "the common base version instead of git's")
+	oursPath := flags.String("ours", "", "our version instead of git's")
+	theirsPath := flags.String("theirs", "", "their version instead of git's")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: typtui resolve [-base base.typ -ours ours.typ -theirs theirs.typ] file.typ\n")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return errors.New("the file to resolve is required")
+	}
+	path := flags.Arg(0)
+
+	// Git keeps the base, ours and theirs of a conflicted file in stages
+	// 1, 2 and 3 of the index
+	var files [3]*parser.TYPFile
+	for i, given := range []string{*basePath, *oursPath, *theirsPath} {
+		var typFile *parser.TYPFile
+		var err error
+		if given != "" {
+			typFile, err = parser.ParseFile(given)
+		} else {
+			typFile, err = parseStage(path, i+1)
+		}
+		if err != nil {
+			return err
+		}
+		files[i] = typFile
+	}
+	r := typmerge.Merge(files[0], files[1], files[2])
+
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "typtui resolve: %v, using the default settings\n", err)
+	}
+	p := tea.NewProgram(tui.NewMergeModel(path, cfg, r), tea.WithAltScreen())
+	final, err := p.Run()
+	if m, ok := final.(tui.Model); ok {
+		m.Close()
+	}
+	return err
+}
+
+// parseStage parses a version of path from the git index. A missing base
+// is an empty file, as when both sides added the file.
+func parseStage(path string, stage int) (*parser.TYPFile, error) {
+	// Paths starting with ./ are relative to the current directory
+	rel := path
+	if filepath.IsAbs(path) {
+		if wd, err := os.Getwd(); err == nil {
+			if r, err := filepath.Rel(wd, path); err == nil {
+				rel = r
+			}
+		}
+	}
+	spec := fmt.Sprintf(":%d:./%s", stage, filepath.ToSlash(rel))
+	var stderr bytes.Buffer
+	cmd := exec.Command("git", "show", spec)
+	cmd.Stderr = &stderr
+	data, err := cmd.Output()
+	if err != nil {
+		if stage == 1 {
+			return &parser.TYPFile{}, nil
+		}
+		return nil, fmt.Errorf("%s is not being merged by git: %s", path, bytes.TrimSpace(stderr.Bytes()))
+	}
+	return parser.ParseReader(bytes.NewReader(data), path)
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/dyuri/typtui/internal/config"
+	"github.com/dyuri/typtui/internal/night"
+	"github.com/dyuri/typtui/internal/parser"
+)
+
+// runNight generates night icons and colours from the day ones for every
+// type that has none. Without -w or -o it only shows what would change.
+// Compiled files are written as text, so only -o to another file is
+// allowed for them.
+func runNight(args []string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "typtui night: %v, using the default settings\n", err)
+	}
+
+	flags := flag.NewFlagSet("typtui night", flag.ContinueOnError)
+	strategy := flags.String("strategy", cfg.Night.Strategy, "lab, hsl, invert or palette")
+	amount := flags.Float64("amount", cfg.Night.Amount, "how much darker, from 0 to 1")
+	palette := flags.String("palette", strings.Join(cfg.Night.Palette, ","), "comma separated #RRGGBB colours of the palette strategy, empty for the built-in one")
+	replace := flags.Bool("replace", false, "also regenerate the night versions types already have")
+	write := flags.Bool("w", false, "write the night versions into the file")
+	output := flags.String("o", "", "write the file with night versions here")
+	jsonOutput := flags.Bool("json", false, "list the changes as JSON")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: typtui night [-strategy lab|hsl|invert|palette] [-amount 0.6] [-palette colours] [-replace] [-json] [-w | -o out.typ] file.typ\n")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return errors.New("one file is required")
+	}
+
+	opts := night.Options{Amount: *amount, Replace: *replace}
+	if opts.Strategy, err = night.ParseStrategy(*strategy); err != nil {
+		return err
+	}
+	for _, hex := range strings.Split(*palette, ",") {
+		if hex = strings.TrimSpace(hex); hex != "" {
+			opts.Palette = append(opts.Palette, hex)
+		}
+	}
+	g, err := night.New(opts)
+	if err != nil {
+		return err
+	}
+
+	input := flags.Arg(0)
+	typFile, err := parser.ParseFile(input)
+	if err != nil {
+		return err
+	}
+	if typFile.Binary && (*write || *output != "" && filepath.Clean(*output) == filepath.Clean(input)) {
+		return fmt.Errorf("%s is a compiled TYP file, use -o to write the night versions as text", input)
+	}
+	changes := g.File(typFile)
+
+	if *jsonOutput {
+		if changes == nil {
+			changes = []night.Change{}
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(changes); err != nil {
+			return err
+		}
+	} else {
+		printNightChanges(changes)
+	}
+
+	target := *output
+	if *write {
+		target = input
+	}
+	if target == "" || len(changes) == 0 && target == input {
+		return nil
+	}
+	if err := parser.WriteFile(typFile, target); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Wrote night versions of %d types to %s\n", len(changes), target)
+	return nil
+}
+
+// printNightChanges lists the night fields generated for each type with
+// the colours they use
+func printNightChanges(changes []night.Change) {
+	if len(changes) == 0 {
+		fmt.Println("Every type has its night versions")
+		return
+	}
+	for _, change := range changes {
+		fmt.Printf("%s %s: %s\n", change.Kind, change.Type, strings.Join(change.Fields, ", "))
+		for _, c := range change.Colors {
+			fmt.Printf("  %s → %s\n", c.Day, c.Night)
+		}
+	}
+}
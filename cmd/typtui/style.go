@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/dyuri/typtui/internal/mkstyle"
+	"github.com/dyuri/typtui/internal/parser"
+)
+
+// runStyle cross-checks a TYP file with the rules of an mkgmap style:
+// style types without a TYP definition, TYP types the style never
+// produces and types used as the wrong kind
+func runStyle(args []string) error {
+	flags := flag.NewFlagSet("typtui style", flag.ContinueOnError)
+	asJSON := flags.Bool("json", false, "print the report as JSON")
+	exitCode := flags.Bool("exit-code", false, "fail when the style and the file disagree")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: typtui style [-json] [-exit-code] file.typ style-dir|style-file\n")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 2 {
+		flags.Usage()
+		return errors.New("a TYP file and a style are required")
+	}
+
+	typFile, err := parser.ParseFile(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	refs, err := mkstyle.Load(flags.Arg(1))
+	if err != nil {
+		return err
+	}
+	report := mkstyle.Check(typFile, refs)
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			return err
+		}
+	} else {
+		printStyleReport(os.Stdout, report, len(refs))
+	}
+
+	if *exitCode && report.Count() > 0 {
+		return fmt.Errorf("%d types differ between the style and the file", report.Count())
+	}
+	return nil
+}
+
+// printStyleReport prints a cross-check report section by section
+func printStyleReport(w io.Writer, r mkstyle.Report, rules int) {
+	section := func(title string, issues []mkstyle.Issue, detail func(mkstyle.Issue) string) {
+		if len(issues) == 0 {
+			return
+		}
+		fmt.Fprintf(w, "%s:\n", title)
+		for _, issue := range issues {
+			name := ""
+			if issue.Name != "" {
+				name = " (" + issue.Name + ")"
+			}
+			fmt.Fprintf(w, "  %-7s %s%s%s\n", issue.Kind, issue.Code, name, detail(issue))
+		}
+		fmt.Fprintln(w)
+	}
+	rulesOf := func(issue mkstyle.Issue) string {
+		s := " used by " + issue.Refs[0].String()
+		if len(issue.Refs) > 1 {
+			s += fmt.Sprintf(" and %d more", len(issue.Refs)-1)
+		}
+		return s
+	}
+
+	section("Style types without a TYP definition", r.Missing, rulesOf)
+	section("Style types defined as another kind in the TYP file", r.WrongKind, func(issue mkstyle.Issue) string {
+		return rulesOf(issue) + ", defined as a " + issue.Defined
+	})
+	section("TYP types the style never produces", r.Unused, func(mkstyle.Issue) string { return "" })
+
+	if r.Count() == 0 {
+		fmt.Fprintf(w, "The style and the file agree on all types of %d rules\n", rules)
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/dyuri/typtui/internal/typedb"
+)
+
+// runTypes lists the standard Garmin type codes of the built-in catalogue,
+// all of them or those matching a code, a name or an OSM tag
+func runTypes(args []string) error {
+	flags := flag.NewFlagSet("typtui types", flag.ContinueOnError)
+	kind := flags.String("kind", "", "only list types of this kind: point, line or polygon")
+	asJSON := flags.Bool("json", false, "print the types as JSON")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: typtui types [-kind kind] [-json] [code | name | key=value]\n")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() > 1 {
+		flags.Usage()
+		return errors.New("too many arguments, quote a name with spaces")
+	}
+	switch *kind {
+	case "", "point", "line", "polygon":
+	default:
+		return fmt.Errorf("unknown kind %q, want point, line or polygon", *kind)
+	}
+
+	var list []typedb.TypeInfo
+	if query := flags.Arg(0); query != "" {
+		list = typedb.Search(*kind, query)
+		if len(list) == 0 {
+			return fmt.Errorf("no types match %q", query)
+		}
+	} else {
+		for _, k := range []string{"point", "line", "polygon"} {
+			if *kind == "" || *kind == k {
+				list = append(list, typedb.Types(k)...)
+			}
+		}
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(list)
+	}
+	for _, t := range list {
+		fmt.Printf("%-8s %-8s %-34s %-26s %s\n", t.Kind, t.Code, t.Name, t.Category, t.Tags())
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/dyuri/typtui/internal/config"
+	"github.com/dyuri/typtui/internal/parser"
+	"github.com/dyuri/typtui/internal/validate"
+)
+
+// runValidate checks TYP files with the validation rules turned on in the
+// config file and lists the problems. It fails when a file has errors, or
+// with -strict any problem.
+func runValidate(args []string) error {
+	flags := flag.NewFlagSet("typtui validate", flag.ContinueOnError)
+	strict := flags.Bool("strict", false, "fail on warnings too")
+	list := flags.Bool("rules", false, "list the validation rules and exit")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: typtui validate [-strict] file.typ ...\n       typtui validate -rules\n")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "typtui validate: %v, using the default settings\n", err)
+	}
+	if err := validate.CheckConfig(cfg.Rules); err != nil {
+		fmt.Fprintf(os.Stderr, "typtui validate: %v\n", err)
+	}
+
+	if *list {
+		for _, rule := range validate.Rules() {
+			state := ""
+			if on, ok := cfg.Rules[rule.Name]; ok && !on {
+				state = " (off)"
+			}
+			fmt.Printf("%-16s %-8s %s%s\n", rule.Name, rule.Severity, rule.Summary, state)
+		}
+		return nil
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return errors.New("no files to validate")
+	}
+
+	failed := 0
+	for _, path := range flags.Args() {
+		typFile, err := parser.ParseFile(path)
+		if err != nil {
+			return err
+		}
+		findings := validate.Run(typFile, cfg.Rules)
+		for _, f := range findings {
+			fmt.Printf("%s: %v\n", path, f)
+		}
+		errs, warnings := validate.Count(findings)
+		if errs > 0 || (*strict && warnings > 0) {
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d files have problems", failed, flags.NArg())
+	}
+	return nil
+}
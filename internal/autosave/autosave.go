@@ -0,0 +1,84 @@
+// Package autosave keeps crash-recovery copies of files being edited in the
+// XDG state directory, $XDG_STATE_HOME/typtui/autosave (by default
+// ~/.local/state/typtui/autosave). Each edited file has one journal, named
+// after a hash of its absolute path.
+package autosave
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/dyuri/typtui/internal/atomicfile"
+)
+
+// Dir returns the directory journals are kept in
+func Dir() (string, error) {
+	state := os.Getenv("XDG_STATE_HOME")
+	if state == "" || !filepath.IsAbs(state) {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("cannot find the state directory: %w", err)
+		}
+		state = filepath.Join(home, ".local", "state")
+	}
+	return filepath.Join(state, "typtui", "autosave"), nil
+}
+
+// Journal is the autosave copy of one file
+type Journal struct {
+	Path string // the journal
+	File string // the file being edited, absolute
+}
+
+// For returns the journal of a file. The journal need not exist.
+func For(file string) (Journal, error) {
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return Journal{}, err
+	}
+	dir, err := Dir()
+	if err != nil {
+		return Journal{}, err
+	}
+	sum := sha256.Sum256([]byte(abs))
+	name := hex.EncodeToString(sum[:8]) + "-" + filepath.Base(abs)
+	return Journal{Path: filepath.Join(dir, name), File: abs}, nil
+}
+
+// Write replaces the journal with data
+func (j Journal) Write(data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(j.Path), 0700); err != nil {
+		return fmt.Errorf("failed to autosave: %w", err)
+	}
+	if err := atomicfile.WriteFile(j.Path, data, 0600); err != nil {
+		return fmt.Errorf("failed to autosave: %w", err)
+	}
+	return nil
+}
+
+// Read returns the journal contents and when they were written. Without a
+// journal the error matches fs.ErrNotExist.
+func (j Journal) Read() ([]byte, time.Time, error) {
+	info, err := os.Stat(j.Path)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	data, err := os.ReadFile(j.Path)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	return data, info.ModTime(), nil
+}
+
+// Remove deletes the journal, if there is one
+func (j Journal) Remove() error {
+	if err := os.Remove(j.Path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
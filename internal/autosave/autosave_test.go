@@ -0,0 +1,43 @@
+package autosave
+
+import (
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestJournal(t *testing.T) {
+	state := t.TempDir()
+	t.Setenv("XDG_STATE_HOME", state)
+
+	j, err := For("style.typ")
+	if err != nil {
+		t.Fatalf("For failed: %v", err)
+	}
+	if !strings.HasPrefix(j.Path, filepath.Join(state, "typtui", "autosave")) || !strings.HasSuffix(j.Path, "-style.typ") {
+		t.Errorf("Unexpected journal path %s", j.Path)
+	}
+	if other, _ := For(filepath.Join("other", "style.typ")); other.Path == j.Path {
+		t.Error("Files with the same name share a journal")
+	}
+
+	if _, _, err := j.Read(); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Expected no journal yet, got %v", err)
+	}
+	if err := j.Write([]byte("[_id]\n[end]\n")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	data, saved, err := j.Read()
+	if err != nil || string(data) != "[_id]\n[end]\n" || saved.IsZero() {
+		t.Errorf("Read returned %q, %v, %v", data, saved, err)
+	}
+
+	if err := j.Remove(); err != nil {
+		t.Fatalf("Remove failed: %v", err)
+	}
+	if err := j.Remove(); err != nil {
+		t.Errorf("Removing a missing journal failed: %v", err)
+	}
+}
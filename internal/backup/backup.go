@@ -0,0 +1,124 @@
+// Package backup keeps timestamped copies of a file next to it, named
+// <file>.<yyyymmdd-hhmmss.mmm>.bak, and removes the oldest ones beyond a
+// limit.
+package backup
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dyuri/typtui/internal/atomicfile"
+)
+
+// timeLayout is the timestamp in backup names. Milliseconds keep quick
+// successive saves apart.
+const timeLayout = "20060102-150405.000"
+
+// Backup is a copy of a file
+type Backup struct {
+	Path string
+	Time time.Time
+	Size int64
+}
+
+// now is the clock used for backup names, replaced in tests
+var now = time.Now
+
+// Create copies the current contents of path to a new backup and removes
+// the oldest backups so that at most keep remain. Nothing is done when keep
+// is 0 or the file does not exist yet. It returns the new backup's path.
+func Create(path string, keep int) (string, error) {
+	if keep <= 0 {
+		return "", nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to back up %s: %w", path, err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to back up %s: %w", path, err)
+	}
+
+	name := path + "." + now().Format(timeLayout) + ".bak"
+	if err := atomicfile.WriteFile(name, data, info.Mode().Perm()); err != nil {
+		return "", fmt.Errorf("failed to back up %s: %w", path, err)
+	}
+
+	return name, prune(path, keep)
+}
+
+// List returns the backups of path, newest first
+func List(path string) ([]Backup, error) {
+	dir, base := filepath.Split(path)
+	if dir == "" {
+		dir = "."
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var backups []Backup
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasPrefix(name, base+".") || !strings.HasSuffix(name, ".bak") {
+			continue
+		}
+		stamp := strings.TrimSuffix(strings.TrimPrefix(name, base+"."), ".bak")
+		t, err := time.ParseInLocation(timeLayout, stamp, time.Local)
+		if err != nil {
+			continue // some other file, such as map.typ.old.bak
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		backups = append(backups, Backup{Path: filepath.Join(dir, name), Time: t, Size: info.Size()})
+	}
+
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].Time.After(backups[j].Time)
+	})
+	return backups, nil
+}
+
+// Restore replaces path with the contents of a backup. The current file is
+// backed up first, so a restore can itself be undone.
+func Restore(path string, b Backup, keep int) error {
+	data, err := os.ReadFile(b.Path)
+	if err != nil {
+		return fmt.Errorf("failed to read backup: %w", err)
+	}
+	if _, err := Create(path, keep); err != nil {
+		return err
+	}
+	if err := atomicfile.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to restore %s: %w", path, err)
+	}
+	return nil
+}
+
+// prune removes all but the newest keep backups of path
+func prune(path string, keep int) error {
+	backups, err := List(path)
+	if err != nil {
+		return err
+	}
+	for i := keep; i < len(backups); i++ {
+		if err := os.Remove(backups[i].Path); err != nil {
+			return fmt.Errorf("failed to remove old backup: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package backup
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// tick makes now return a new second on every call
+func tick(t *testing.T) {
+	clock := time.Date(2026, 10, 16, 12, 0, 0, 0, time.Local)
+	now = func() time.Time {
+		clock = clock.Add(time.Second)
+		return clock
+	}
+	t.Cleanup(func() { now = time.Now })
+}
+
+func TestCreateRotates(t *testing.T) {
+	tick(t)
+	path := filepath.Join(t.TempDir(), "style.typ")
+
+	// No file yet, nothing to back up
+	if name, err := Create(path, 2); err != nil || name != "" {
+		t.Fatalf("Expected no backup of a missing file, got %q, %v", name, err)
+	}
+
+	for _, version := range []string{"v1", "v2", "v3"} {
+		if err := os.WriteFile(path, []byte(version), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Create(path, 2); err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+	}
+
+	backups, err := List(path)
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(backups) != 2 {
+		t.Fatalf("Expected 2 backups, got %d", len(backups))
+	}
+	for i, want := range []string{"v3", "v2"} {
+		data, _ := os.ReadFile(backups[i].Path)
+		if string(data) != want {
+			t.Errorf("Backup %d holds %q, want %q", i, data, want)
+		}
+	}
+	if info, _ := os.Stat(backups[0].Path); info.Mode().Perm() != 0600 {
+		t.Errorf("Backup mode %v, want 0600", info.Mode().Perm())
+	}
+}
+
+func TestRestore(t *testing.T) {
+	tick(t)
+	path := filepath.Join(t.TempDir(), "style.typ")
+	os.WriteFile(path, []byte("good"), 0644)
+	Create(path, 3)
+	os.WriteFile(path, []byte("broken"), 0644)
+
+	backups, _ := List(path)
+	if err := Restore(path, backups[0], 3); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "good" {
+		t.Errorf("Expected restored contents, got %q", data)
+	}
+
+	// The replaced version is kept as the newest backup
+	backups, _ = List(path)
+	if data, _ := os.ReadFile(backups[0].Path); len(backups) != 2 || string(data) != "broken" {
+		t.Errorf("Expected the replaced file to be backed up, got %d backups", len(backups))
+	}
+}
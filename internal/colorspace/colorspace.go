@@ -0,0 +1,208 @@
+// Package colorspace converts palette colours between sRGB, HSL and CIE
+// L*a*b* (D65), and measures how far apart two colours look.
+package colorspace
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// RGB is an sRGB colour with channels from 0 to 1
+type RGB struct {
+	R, G, B float64
+}
+
+// ParseHex parses a "#RRGGBB" colour
+func ParseHex(hex string) (RGB, bool) {
+	digits, ok := strings.CutPrefix(hex, "#")
+	if !ok || len(digits) != 6 {
+		return RGB{}, false
+	}
+	v, err := strconv.ParseUint(digits, 16, 32)
+	if err != nil {
+		return RGB{}, false
+	}
+	return RGB{
+		R: float64(v>>16&0xff) / 255,
+		G: float64(v>>8&0xff) / 255,
+		B: float64(v&0xff) / 255,
+	}, true
+}
+
+// Hex formats the colour as "#RRGGBB", clamping channels out of range
+func (c RGB) Hex() string {
+	channel := func(v float64) int {
+		return int(math.Round(clamp(v, 0, 1) * 255))
+	}
+	return fmt.Sprintf("#%02X%02X%02X", channel(c.R), channel(c.G), channel(c.B))
+}
+
+// inGamut reports whether all channels are from 0 to 1, allowing for
+// rounding
+func (c RGB) inGamut() bool {
+	const e = 1e-6
+	return c.R >= -e && c.R <= 1+e && c.G >= -e && c.G <= 1+e && c.B >= -e && c.B <= 1+e
+}
+
+func clamp(v, lo, hi float64) float64 {
+	return math.Max(lo, math.Min(hi, v))
+}
+
+// Linear returns the channels without the sRGB gamma, proportional to
+// light intensity
+func (c RGB) Linear() [3]float64 {
+	f := func(v float64) float64 {
+		if v <= 0.04045 {
+			return v / 12.92
+		}
+		return math.Pow((v+0.055)/1.055, 2.4)
+	}
+	return [3]float64{f(c.R), f(c.G), f(c.B)}
+}
+
+// FromLinear applies the sRGB gamma to linear channels
+func FromLinear(l [3]float64) RGB {
+	f := func(v float64) float64 {
+		if v <= 0.0031308 {
+			return v * 12.92
+		}
+		return 1.055*math.Pow(v, 1/2.4) - 0.055
+	}
+	return RGB{f(l[0]), f(l[1]), f(l[2])}
+}
+
+// Lab is a CIE L*a*b* colour: L from 0 (black) to 100 (white), A from
+// green to red and B from blue to yellow
+type Lab struct {
+	L, A, B float64
+}
+
+// D65 white point
+const (
+	whiteX = 0.95047
+	whiteY = 1.0
+	whiteZ = 1.08883
+)
+
+// Lab converts the colour to CIE L*a*b*
+func (c RGB) Lab() Lab {
+	l := c.Linear()
+	x := 0.4124564*l[0] + 0.3575761*l[1] + 0.1804375*l[2]
+	y := 0.2126729*l[0] + 0.7151522*l[1] + 0.0721750*l[2]
+	z := 0.0193339*l[0] + 0.1191920*l[1] + 0.9503041*l[2]
+
+	f := func(t float64) float64 {
+		const d = 6.0 / 29
+		if t > d*d*d {
+			return math.Cbrt(t)
+		}
+		return t/(3*d*d) + 4.0/29
+	}
+	fx, fy, fz := f(x/whiteX), f(y/whiteY), f(z/whiteZ)
+	return Lab{L: 116*fy - 16, A: 500 * (fx - fy), B: 200 * (fy - fz)}
+}
+
+// rgb converts the colour to sRGB, with channels out of range if sRGB
+// cannot show it
+func (c Lab) rgb() RGB {
+	finv := func(t float64) float64 {
+		const d = 6.0 / 29
+		if t > d {
+			return t * t * t
+		}
+		return 3 * d * d * (t - 4.0/29)
+	}
+	fy := (c.L + 16) / 116
+	x := whiteX * finv(fy+c.A/500)
+	y := whiteY * finv(fy)
+	z := whiteZ * finv(fy-c.B/200)
+
+	return FromLinear([3]float64{
+		3.2404542*x - 1.5371385*y - 0.4985314*z,
+		-0.9692660*x + 1.8760108*y + 0.0415560*z,
+		0.0556434*x - 0.2040259*y + 1.0572252*z,
+	})
+}
+
+// RGB converts the colour to sRGB. A colour sRGB cannot show keeps its
+// lightness and hue and loses as little chroma as needed to fit.
+func (c Lab) RGB() RGB {
+	c.L = clamp(c.L, 0, 100)
+	if rgb := c.rgb(); rgb.inGamut() {
+		return rgb
+	}
+	lo, hi := 0.0, 1.0
+	for range 24 {
+		mid := (lo + hi) / 2
+		if (Lab{c.L, c.A * mid, c.B * mid}).rgb().inGamut() {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	return Lab{c.L, c.A * lo, c.B * lo}.rgb()
+}
+
+// DeltaE returns the CIE76 colour difference: about 2.3 is just
+// noticeable, and colours less than 10 apart are easily confused
+func DeltaE(a, b Lab) float64 {
+	return math.Sqrt((a.L-b.L)*(a.L-b.L) + (a.A-b.A)*(a.A-b.A) + (a.B-b.B)*(a.B-b.B))
+}
+
+// HSL is a colour by hue (0 to 360), saturation and lightness (0 to 1)
+type HSL struct {
+	H, S, L float64
+}
+
+// HSL converts the colour to hue, saturation and lightness
+func (c RGB) HSL() HSL {
+	hi := math.Max(c.R, math.Max(c.G, c.B))
+	lo := math.Min(c.R, math.Min(c.G, c.B))
+	l := (hi + lo) / 2
+	if hi == lo {
+		return HSL{L: l}
+	}
+
+	d := hi - lo
+	s := d / (1 - math.Abs(2*l-1))
+	var h float64
+	switch hi {
+	case c.R:
+		h = math.Mod((c.G-c.B)/d, 6)
+	case c.G:
+		h = (c.B-c.R)/d + 2
+	default:
+		h = (c.R-c.G)/d + 4
+	}
+	h *= 60
+	if h < 0 {
+		h += 360
+	}
+	return HSL{H: h, S: s, L: l}
+}
+
+// RGB converts the colour to sRGB
+func (c HSL) RGB() RGB {
+	chroma := (1 - math.Abs(2*c.L-1)) * c.S
+	h := math.Mod(c.H, 360) / 60
+	x := chroma * (1 - math.Abs(math.Mod(h, 2)-1))
+	var r, g, b float64
+	switch {
+	case h < 1:
+		r, g = chroma, x
+	case h < 2:
+		r, g = x, chroma
+	case h < 3:
+		g, b = chroma, x
+	case h < 4:
+		g, b = x, chroma
+	case h < 5:
+		r, b = x, chroma
+	default:
+		r, b = chroma, x
+	}
+	m := c.L - chroma/2
+	return RGB{r + m, g + m, b + m}
+}
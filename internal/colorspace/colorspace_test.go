@@ -0,0 +1,70 @@
+package colorspace
+
+import (
+	"math"
+	"testing"
+)
+
+func TestParseHex(t *testing.T) {
+	c, ok := ParseHex("#FF8000")
+	if !ok || c.R != 1 || c.B != 0 || math.Abs(c.G-128.0/255) > 1e-9 {
+		t.Errorf("ParseHex(#FF8000) = %+v, %v", c, ok)
+	}
+	if c.Hex() != "#FF8000" {
+		t.Errorf("Hex() = %s", c.Hex())
+	}
+	for _, bad := range []string{"", "none", "FF8000", "#FF80", "#GG8000"} {
+		if _, ok := ParseHex(bad); ok {
+			t.Errorf("ParseHex(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestLab(t *testing.T) {
+	tests := []struct {
+		hex     string
+		l, a, b float64
+	}{
+		{"#000000", 0, 0, 0},
+		{"#FFFFFF", 100, 0, 0},
+		{"#FF0000", 53.24, 80.09, 67.20},
+		{"#0000FF", 32.30, 79.19, -107.86},
+	}
+	for _, tt := range tests {
+		c, _ := ParseHex(tt.hex)
+		lab := c.Lab()
+		if math.Abs(lab.L-tt.l) > 0.05 || math.Abs(lab.A-tt.a) > 0.05 || math.Abs(lab.B-tt.b) > 0.05 {
+			t.Errorf("%s: got %+v", tt.hex, lab)
+		}
+		if got := lab.RGB().Hex(); got != tt.hex {
+			t.Errorf("%s: converted back to %s", tt.hex, got)
+		}
+	}
+
+	// Out of gamut colours keep their lightness
+	dark := Lab{L: 20, A: 80, B: 60}.RGB()
+	if !dark.inGamut() || math.Abs(dark.Lab().L-20) > 0.5 {
+		t.Errorf("Expected a dark red in gamut, got %+v", dark)
+	}
+}
+
+func TestHSL(t *testing.T) {
+	for _, hex := range []string{"#000000", "#FFFFFF", "#FF0000", "#12AB34", "#8040C0", "#C0C000"} {
+		c, _ := ParseHex(hex)
+		if got := c.HSL().RGB().Hex(); got != hex {
+			t.Errorf("%s: converted back to %s", hex, got)
+		}
+	}
+	c, _ := ParseHex("#FF0000")
+	if hsl := c.HSL(); hsl.H != 0 || hsl.S != 1 || hsl.L != 0.5 {
+		t.Errorf("Unexpected HSL of red: %+v", hsl)
+	}
+}
+
+func TestDeltaE(t *testing.T) {
+	black, _ := ParseHex("#000000")
+	white, _ := ParseHex("#FFFFFF")
+	if d := DeltaE(black.Lab(), white.Lab()); math.Abs(d-100) > 0.01 {
+		t.Errorf("DeltaE(black, white) = %f", d)
+	}
+}
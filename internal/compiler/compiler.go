@@ -0,0 +1,170 @@
+// Package compiler compiles TYP files with mkgmap. The file is written to
+// a temporary directory as the text mkgmap reads, mkgmap is run on it, and
+// its output is passed on line by line as it comes, with warnings and
+// errors traced back to the type and line they are about.
+package compiler
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/dyuri/typtui/internal/atomicfile"
+	"github.com/dyuri/typtui/internal/parser"
+)
+
+// Options is the mkgmap command line
+type Options struct {
+	Java      string   // the java command, "java" if empty
+	Jar       string   // path of mkgmap.jar
+	Args      []string // more mkgmap options
+	FamilyID  int      // passed as --family-id unless 0
+	ProductID int      // passed as --product-id unless 0
+}
+
+// Result is the outcome of a compilation
+type Result struct {
+	Output   string
+	Messages []Message
+}
+
+// Errors returns the number of error messages
+func (r Result) Errors() int {
+	n := 0
+	for _, m := range r.Messages {
+		if m.Severity == SeverityError {
+			n++
+		}
+	}
+	return n
+}
+
+// DefaultOutput returns where the compiled version of a file goes: a .txt
+// file becomes .typ, as mkgmap names it, and other files get a -compiled
+// suffix so the source is not overwritten
+func DefaultOutput(path string) string {
+	ext := filepath.Ext(path)
+	base := strings.TrimSuffix(path, ext)
+	if strings.EqualFold(ext, ".txt") {
+		return base + ".typ"
+	}
+	return base + "-compiled.typ"
+}
+
+// SamePath reports whether two paths name the same file once made absolute
+// and cleaned, so a compiled file does not replace its source
+func SamePath(a, b string) bool {
+	if absA, err := filepath.Abs(a); err == nil {
+		a = absA
+	}
+	if absB, err := filepath.Abs(b); err == nil {
+		b = absB
+	}
+	return filepath.Clean(a) == filepath.Clean(b)
+}
+
+// args returns the arguments of java for compiling a source file into a
+// directory
+func (o Options) args(source, outputDir string) []string {
+	args := []string{"-jar", o.Jar}
+	if o.FamilyID != 0 {
+		args = append(args, fmt.Sprintf("--family-id=%d", o.FamilyID))
+	}
+	if o.ProductID != 0 {
+		args = append(args, fmt.Sprintf("--product-id=%d", o.ProductID))
+	}
+	args = append(args, o.Args...)
+	return append(args, "--output-dir="+outputDir, source)
+}
+
+// Run compiles a TYP file with mkgmap into output. Each line mkgmap
+// prints is passed to log, if not nil, as soon as it is read. Cancelling
+// ctx stops mkgmap. Run fails when mkgmap fails or writes no file; the
+// result holds the messages either way.
+func Run(ctx context.Context, t *parser.TYPFile, output string, opts Options, log func(Message)) (*Result, error) {
+	result := &Result{Output: output}
+	if opts.Jar == "" {
+		return result, errors.New("no mkgmap jar is set")
+	}
+	java := opts.Java
+	if java == "" {
+		java = "java"
+	}
+
+	dir, err := os.MkdirTemp("", "typtui-mkgmap-")
+	if err != nil {
+		return result, err
+	}
+	defer os.RemoveAll(dir)
+
+	// mkgmap compiles TYP files with a .txt extension, into a .typ file
+	// of the same name
+	name := strings.TrimSuffix(filepath.Base(output), filepath.Ext(output))
+	if name == "" || name == "." {
+		name = "typ"
+	}
+	source := filepath.Join(dir, name+".txt")
+	var text bytes.Buffer
+	if err := parser.Write(&text, t); err != nil {
+		return result, err
+	}
+	if err := os.WriteFile(source, text.Bytes(), 0o644); err != nil {
+		return result, err
+	}
+	lines := mapLines(text.Bytes())
+	codes := typeCodes(t)
+
+	cmd := exec.CommandContext(ctx, java, opts.args(source, dir)...)
+	cmd.Dir = dir
+	pr, pw := io.Pipe()
+	cmd.Stdout, cmd.Stderr = pw, pw
+	if err := cmd.Start(); err != nil {
+		return result, fmt.Errorf("cannot run mkgmap: %w", err)
+	}
+	done := make(chan error, 1)
+	go func() {
+		err := cmd.Wait()
+		pw.Close()
+		done <- err
+	}()
+
+	scanner := bufio.NewScanner(pr)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		// The temporary directory means nothing to the user
+		line := strings.ReplaceAll(scanner.Text(), dir+string(filepath.Separator), "")
+		msg := parseMessage(line, lines, codes)
+		result.Messages = append(result.Messages, msg)
+		if log != nil {
+			log(msg)
+		}
+	}
+	// Keep mkgmap from blocking on a full pipe if the output was too long
+	io.Copy(io.Discard, pr)
+
+	if err := <-done; err != nil {
+		if ctx.Err() != nil {
+			return result, ctx.Err()
+		}
+		return result, fmt.Errorf("mkgmap failed: %w", err)
+	}
+
+	compiled, err := os.ReadFile(filepath.Join(dir, name+".typ"))
+	if errors.Is(err, os.ErrNotExist) {
+		return result, errors.New("mkgmap wrote no TYP file")
+	}
+	if err != nil {
+		return result, err
+	}
+	if err := atomicfile.WriteFile(output, compiled, 0o644); err != nil {
+		return result, err
+	}
+	return result, nil
+}
@@ -0,0 +1,174 @@
+package compiler
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/dyuri/typtui/internal/parser"
+)
+
+// stub stands in for java running mkgmap: it prints its arguments, a
+// warning about the line of the car dealer and an error naming the
+// polygon, then writes the source as the compiled file
+const stub = `#!/bin/sh
+echo "args: $*"
+out=""
+src=""
+for a in "$@"; do
+	case "$a" in
+	--output-dir=*) out="${a#--output-dir=}" ;;
+	*) src="$a" ;;
+	esac
+done
+n=$(grep -n "Type=0x2f07" "$src" | cut -d: -f1)
+echo "Time started: now"
+echo "WARNING (TypTextReader): $src:$n: Unknown colour name"
+echo "SEVERE (TypCompiler): Too many colours in type 0x13" >&2
+if [ -n "$FAIL" ]; then
+	exit 3
+fi
+cp "$src" "$out/$(basename "$src" .txt).typ"
+`
+
+func load(t *testing.T) *parser.TYPFile {
+	t.Helper()
+	typFile, err := parser.ParseFile("../../testdata/compiler/map.typ")
+	if err != nil {
+		t.Fatalf("Failed to parse map.typ: %v", err)
+	}
+	return typFile
+}
+
+func writeStub(t *testing.T) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("the mkgmap stub is a shell script")
+	}
+	path := filepath.Join(t.TempDir(), "java")
+	if err := os.WriteFile(path, []byte(stub), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestRun(t *testing.T) {
+	opts := Options{Java: writeStub(t), Jar: "mkgmap.jar", Args: []string{"--code-page=1252"}, FamilyID: 42}
+	output := filepath.Join(t.TempDir(), "map.typ")
+
+	var logged []Message
+	result, err := Run(context.Background(), load(t), output, opts, func(m Message) { logged = append(logged, m) })
+	if err != nil {
+		t.Fatalf("Failed to compile: %v, %+v", err, result)
+	}
+	if len(logged) != 4 || len(result.Messages) != 4 {
+		t.Fatalf("Expected 4 messages, logged %+v", logged)
+	}
+
+	args := logged[0].Text
+	if !strings.HasPrefix(args, "args: -jar mkgmap.jar --family-id=42 --code-page=1252 --output-dir=") ||
+		strings.Contains(args, "--product-id") || !strings.HasSuffix(args, "map.txt") {
+		t.Errorf("Unexpected arguments: %s", args)
+	}
+
+	warning := logged[2]
+	if warning.Severity != SeverityWarning || warning.Kind != "point" || warning.Type != "0x2f07" || warning.Index != 1 {
+		t.Errorf("Unexpected warning: %+v", warning)
+	}
+	if strings.Contains(warning.Text, os.TempDir()) || !strings.Contains(warning.Text, "map.txt:") {
+		t.Errorf("The warning shows the temporary directory: %s", warning.Text)
+	}
+	severe := logged[3]
+	if severe.Severity != SeverityError || severe.Kind != "polygon" || severe.Index != 0 || result.Errors() != 1 {
+		t.Errorf("Unexpected error: %+v", severe)
+	}
+
+	compiled, err := os.ReadFile(output)
+	if err != nil || !strings.Contains(string(compiled), "Type=0x2f07") {
+		t.Errorf("Unexpected output %q, %v", compiled, err)
+	}
+}
+
+func TestRunFailure(t *testing.T) {
+	opts := Options{Java: writeStub(t), Jar: "mkgmap.jar"}
+	output := filepath.Join(t.TempDir(), "map.typ")
+	t.Setenv("FAIL", "1")
+
+	result, err := Run(context.Background(), load(t), output, opts, nil)
+	if err == nil || !strings.Contains(err.Error(), "mkgmap failed") {
+		t.Errorf("Expected mkgmap to fail, got %v", err)
+	}
+	if len(result.Messages) != 4 {
+		t.Errorf("Expected the messages of the failed run, got %+v", result.Messages)
+	}
+	if _, err := os.Stat(output); !os.IsNotExist(err) {
+		t.Errorf("Expected no output, got %v", err)
+	}
+
+	if _, err := Run(context.Background(), load(t), output, Options{}, nil); err == nil {
+		t.Error("Expected an error without a jar")
+	}
+}
+
+func TestParseMessage(t *testing.T) {
+	typFile := load(t)
+	var text strings.Builder
+	if err := parser.Write(&text, typFile); err != nil {
+		t.Fatal(err)
+	}
+	lines := mapLines([]byte(text.String()))
+	codes := typeCodes(typFile)
+
+	bank := 0
+	for i, line := range strings.Split(text.String(), "\n") {
+		if line == "String=0x04,Bank" {
+			bank = i + 1
+		}
+	}
+
+	tests := []struct {
+		text     string
+		severity Severity
+		kind     string
+		index    int
+	}{
+		{"Error in map.txt, line " + strconv.Itoa(bank) + ": bad label", SeverityError, "point", 0},
+		{"WARNING: map.txt:" + strconv.Itoa(bank) + ": odd", SeverityWarning, "point", 0},
+		{"WARNING: map.txt:2: FID", SeverityWarning, "", -1},
+		{"SEVERE: 0x2f07 is odd", SeverityError, "point", 1},
+		{"Number of types 0x13", SeverityInfo, "", -1},
+	}
+	for _, tt := range tests {
+		got := parseMessage(tt.text, lines, codes)
+		if got.Severity != tt.severity || got.Kind != tt.kind || got.Index != tt.index {
+			t.Errorf("parseMessage(%q) = %+v", tt.text, got)
+		}
+	}
+}
+
+func TestSamePath(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"map.typ", "map.typ", true},
+		{"map.typ", "./map.typ", true},
+		{"map.typ", filepath.Join(wd, "map.typ"), true},
+		{"dir/../map.typ", "map.typ", true},
+		{"map.typ", "map-compiled.typ", false},
+		{"map.txt", "map.typ", false},
+	}
+	for _, tt := range tests {
+		if got := SamePath(tt.a, tt.b); got != tt.want {
+			t.Errorf("SamePath(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
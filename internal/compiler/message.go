@@ -0,0 +1,219 @@
+package compiler
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/dyuri/typtui/internal/parser"
+)
+
+// Severity tells how serious a Message is
+type Severity int
+
+const (
+	// SeverityInfo marks progress and other output
+	SeverityInfo Severity = iota
+	// SeverityWarning marks a problem mkgmap worked around
+	SeverityWarning
+	// SeverityError marks a problem that stops the compilation
+	SeverityError
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityInfo:
+		return "info"
+	case SeverityWarning:
+		return "warning"
+	case SeverityError:
+		return "error"
+	}
+	return fmt.Sprintf("Severity(%d)", int(s))
+}
+
+// Message is a line of mkgmap output
+type Message struct {
+	Text     string
+	Severity Severity
+	Line     int    // of the text mkgmap compiled, 0 if not known
+	Kind     string // "point", "line" or "polygon" of the type it is about, "" if none
+	Type     string // the type code in mkgmap form
+	Index    int    // of the type among the types of its kind, -1 if none
+}
+
+// String formats the message with the type it is about
+func (m Message) String() string {
+	if m.Kind == "" {
+		return m.Text
+	}
+	return fmt.Sprintf("%s (%s %s)", m.Text, m.Kind, m.Type)
+}
+
+// section is the type a line of the compiled text belongs to
+type section struct {
+	kind, code string
+	index      int
+}
+
+// sectionKinds maps TYP section names to the kinds of their types
+var sectionKinds = map[string]string{
+	"[_point]":   "point",
+	"[_line]":    "line",
+	"[_polygon]": "polygon",
+}
+
+// mapLines finds the type of each line of the compiled text, nil for
+// lines outside types. Types are told apart by kind, code and occurrence.
+func mapLines(text []byte) []*section {
+	var lines []*section
+	var current *section
+	var typ, subType string
+	seen := make(map[string]int)
+
+	scanner := bufio.NewScanner(bytes.NewReader(text))
+	scanner.Buffer(make([]byte, 64*1024), len(text)+1)
+	var open []*section // lines of the current type, coded once it closes
+	finish := func() {
+		if current == nil {
+			return
+		}
+		if code, err := parser.ParseTypeCode(typ, subType); err == nil {
+			current.code = code.String()
+		}
+		key := current.kind + " " + current.code
+		current.index = seen[key]
+		seen[key]++
+		for _, s := range open {
+			*s = *current
+		}
+		current, open, typ, subType = nil, nil, "", ""
+	}
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		lower := strings.ToLower(line)
+		if kind, ok := sectionKinds[lower]; ok {
+			finish()
+			current = &section{kind: kind}
+		}
+		if current == nil {
+			lines = append(lines, nil)
+			continue
+		}
+
+		s := &section{}
+		open = append(open, s)
+		lines = append(lines, s)
+		if key, value, ok := strings.Cut(line, "="); ok {
+			switch strings.ToLower(strings.TrimSpace(key)) {
+			case "type":
+				typ = strings.TrimSpace(value)
+			case "subtype":
+				subType = strings.TrimSpace(value)
+			}
+		}
+		if lower == "[end]" || lower == "[_end]" {
+			finish()
+		}
+	}
+	finish()
+	return lines
+}
+
+// typeCodes lists the codes of the types of each kind in file order, to
+// find a type by code and occurrence
+func typeCodes(t *parser.TYPFile) map[string][]string {
+	codes := make(map[string][]string)
+	add := func(kind, typ, subType string) {
+		code := typ
+		if c, err := parser.ParseTypeCode(typ, subType); err == nil {
+			code = c.String()
+		}
+		codes[kind] = append(codes[kind], code)
+	}
+	for _, p := range t.Points {
+		add("point", p.Type, p.SubType)
+	}
+	for _, l := range t.Lines {
+		add("line", l.Type, "")
+	}
+	for _, p := range t.Polygons {
+		add("polygon", p.Type, "")
+	}
+	return codes
+}
+
+// indexOf returns the index of the nth type of a kind with a code, or -1
+func indexOf(codes map[string][]string, kind, code string, nth int) int {
+	for i, c := range codes[kind] {
+		if c == code {
+			if nth == 0 {
+				return i
+			}
+			nth--
+		}
+	}
+	return -1
+}
+
+var (
+	// mkgmap reports positions as "file.txt:12:" or "line 12"
+	filePosition = regexp.MustCompile(`\.txt:(\d+)`)
+	linePosition = regexp.MustCompile(`(?i)\bline:?\s*(\d+)`)
+	typeCode     = regexp.MustCompile(`\b0x[0-9a-fA-F]+\b`)
+)
+
+// parseMessage reads a line of mkgmap output and finds the type it is
+// about: by its position in the compiled text, or by a type code it
+// mentions that only one kind of type of the file has
+func parseMessage(text string, lines []*section, codes map[string][]string) Message {
+	msg := Message{Text: text, Index: -1}
+
+	switch {
+	case strings.HasPrefix(text, "SEVERE") || strings.Contains(text, "Error") ||
+		strings.Contains(text, "ERROR") || strings.Contains(text, "Exception"):
+		msg.Severity = SeverityError
+	case strings.Contains(text, "WARNING") || strings.Contains(text, "Warning"):
+		msg.Severity = SeverityWarning
+	}
+
+	match := filePosition.FindStringSubmatch(text)
+	if match == nil {
+		match = linePosition.FindStringSubmatch(text)
+	}
+	if match != nil {
+		msg.Line, _ = strconv.Atoi(match[1])
+		if msg.Line > 0 && msg.Line <= len(lines) && lines[msg.Line-1] != nil {
+			s := lines[msg.Line-1]
+			msg.Kind, msg.Type = s.kind, s.code
+			msg.Index = indexOf(codes, s.kind, s.code, s.index)
+			return msg
+		}
+	}
+
+	if msg.Severity == SeverityInfo {
+		return msg
+	}
+	for _, found := range typeCode.FindAllString(text, -1) {
+		code, err := parser.ParseTypeCode(found, "")
+		if err != nil {
+			continue
+		}
+		var kinds []string
+		for _, kind := range []string{"point", "line", "polygon"} {
+			if indexOf(codes, kind, code.String(), 0) >= 0 {
+				kinds = append(kinds, kind)
+			}
+		}
+		if len(kinds) == 1 {
+			msg.Kind, msg.Type = kinds[0], code.String()
+			msg.Index = indexOf(codes, kinds[0], code.String(), 0)
+			return msg
+		}
+	}
+	return msg
+}
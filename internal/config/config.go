@@ -0,0 +1,108 @@
+// Package config loads the user settings of typtui from
+// $XDG_CONFIG_HOME/typtui/config.json. Settings missing from the file keep
+// their defaults, and a missing file means all defaults.
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Config holds the user settings
+type Config struct {
+	// Backups is the number of timestamped .bak copies kept next to a file
+	// when it is saved; 0 turns backups off
+	Backups int `json:"backups"`
+
+	// AutosaveSeconds is how often unsaved edits are copied to the
+	// crash-recovery journal; 0 turns autosave off
+	AutosaveSeconds int `json:"autosave_seconds"`
+
+	// Rules turns validation rules on or off by name; rules not listed
+	// are on
+	Rules map[string]bool `json:"rules"`
+
+	// Style is the mkgmap style directory or rule file the editor
+	// cross-checks files with; "" asks for one
+	Style string `json:"style"`
+
+	// Mkgmap is how the editor runs mkgmap to compile files
+	Mkgmap Mkgmap `json:"mkgmap"`
+
+	// Night is how night colours are generated from day colours
+	Night Night `json:"night"`
+}
+
+// Mkgmap holds the mkgmap command line
+type Mkgmap struct {
+	Java      string   `json:"java"`       // the java command, "java" from the PATH by default
+	Jar       string   `json:"jar"`        // path of mkgmap.jar
+	Options   []string `json:"options"`    // more mkgmap options, such as --code-page=1252
+	FamilyID  int      `json:"family_id"`  // overrides the FID of the file, 0 keeps it
+	ProductID int      `json:"product_id"` // overrides the product code of the file, 0 keeps it
+}
+
+// Night holds the defaults of the night colour generator
+type Night struct {
+	Strategy string   `json:"strategy"` // "lab", "hsl", "invert" or "palette"
+	Amount   float64  `json:"amount"`   // how much darker, from 0 to 1
+	Palette  []string `json:"palette"`  // the dark base palette of the "palette" strategy
+}
+
+// Default returns the settings used when there is no config file
+func Default() Config {
+	return Config{
+		Backups:         3,
+		AutosaveSeconds: 30,
+		Mkgmap:          Mkgmap{Java: "java"},
+		Night:           Night{Strategy: "lab", Amount: 0.6},
+	}
+}
+
+// Path returns the location of the config file
+func Path() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "typtui", "config.json"), nil
+}
+
+// Load reads the config file. On error the defaults are returned together
+// with the error, so callers can warn and carry on.
+func Load() (Config, error) {
+	path, err := Path()
+	if err != nil {
+		return Default(), err
+	}
+	return LoadFile(path)
+}
+
+// LoadFile reads settings from a file
+func LoadFile(path string) (Config, error) {
+	cfg := Default()
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return cfg, nil
+	}
+	if err != nil {
+		return cfg, err
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return Default(), fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+	if cfg.Backups < 0 || cfg.AutosaveSeconds < 0 {
+		return Default(), fmt.Errorf("invalid config file %s: backups and autosave_seconds must not be negative", path)
+	}
+	if cfg.Mkgmap.FamilyID < 0 || cfg.Mkgmap.ProductID < 0 {
+		return Default(), fmt.Errorf("invalid config file %s: mkgmap family_id and product_id must not be negative", path)
+	}
+	if cfg.Night.Amount < 0 || cfg.Night.Amount > 1 {
+		return Default(), fmt.Errorf("invalid config file %s: night amount must be from 0 to 1", path)
+	}
+	return cfg, nil
+}
@@ -0,0 +1,48 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLoadFile(t *testing.T) {
+	dir := t.TempDir()
+
+	cfg, err := LoadFile(filepath.Join(dir, "missing.json"))
+	if err != nil || !reflect.DeepEqual(cfg, Default()) {
+		t.Errorf("Expected defaults for a missing file, got %+v, %v", cfg, err)
+	}
+
+	path := filepath.Join(dir, "config.json")
+	os.WriteFile(path, []byte(`{"backups": 0}`), 0644)
+	if cfg, err := LoadFile(path); err != nil || cfg.Backups != 0 {
+		t.Errorf("Expected backups off, got %+v, %v", cfg, err)
+	}
+
+	os.WriteFile(path, []byte(`{"rules": {"point-subtype": false}}`), 0644)
+	if cfg, err := LoadFile(path); err != nil || cfg.Rules["point-subtype"] || cfg.Backups != 3 {
+		t.Errorf("Expected a rule turned off and default backups, got %+v, %v", cfg, err)
+	}
+
+	os.WriteFile(path, []byte(`{"mkgmap": {"jar": "/opt/mkgmap/mkgmap.jar", "family_id": 1234}}`), 0644)
+	if cfg, err := LoadFile(path); err != nil || cfg.Mkgmap.Jar != "/opt/mkgmap/mkgmap.jar" || cfg.Mkgmap.Java != "java" || cfg.Mkgmap.FamilyID != 1234 {
+		t.Errorf("Expected the mkgmap jar with the default java, got %+v, %v", cfg, err)
+	}
+
+	os.WriteFile(path, []byte(`{"night": {"strategy": "palette"}}`), 0644)
+	if cfg, err := LoadFile(path); err != nil || cfg.Night.Strategy != "palette" || cfg.Night.Amount != 0.6 {
+		t.Errorf("Expected the palette strategy with the default amount, got %+v, %v", cfg.Night, err)
+	}
+
+	os.WriteFile(path, []byte(`{"night": {"amount": 2}}`), 0644)
+	if _, err := LoadFile(path); err == nil {
+		t.Error("Expected an error for a night amount over 1")
+	}
+
+	os.WriteFile(path, []byte(`{"backups": -1}`), 0644)
+	if _, err := LoadFile(path); err == nil {
+		t.Error("Expected an error for a negative backup count")
+	}
+}
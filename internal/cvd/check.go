@@ -0,0 +1,144 @@
+package cvd
+
+import (
+	"cmp"
+	"slices"
+
+	"github.com/dyuri/typtui/internal/colorspace"
+	"github.com/dyuri/typtui/internal/parser"
+)
+
+// DefaultThreshold is the CIE76 difference below which colours are easily
+// confused on a map
+const DefaultThreshold = 10.0
+
+// Options choose what Check looks at
+type Options struct {
+	Threshold    float64      // colours closer than this look alike, DefaultThreshold if 0
+	Night        bool         // compare the night colours instead of the day ones
+	Deficiencies []Deficiency // all of them if empty
+}
+
+// Swatch is the main colour of a type
+type Swatch struct {
+	Index int    `json:"index"` // among the types of its kind
+	Type  string `json:"type"`  // the type code in canonical form
+	Color string `json:"color"` // the colour as drawn
+	Seen  string `json:"seen"`  // the colour as seen with the deficiency
+}
+
+// Pair is two types that look different with normal vision and alike with
+// a deficiency
+type Pair struct {
+	Kind       string     `json:"kind"` // "line" or "polygon"
+	Deficiency Deficiency `json:"deficiency"`
+	A          Swatch     `json:"a"`
+	B          Swatch     `json:"b"`
+	Normal     float64    `json:"normal_delta_e"`    // colour difference with normal vision
+	Simulated  float64    `json:"simulated_delta_e"` // colour difference with the deficiency
+}
+
+// swatch is a type with the colour it is compared by
+type swatch struct {
+	Swatch
+	rgb colorspace.RGB
+}
+
+// mainColor returns the opaque colour most pixels of an icon have, or
+// the first opaque colour of an icon without pixels, such as a solid
+// "0 0 1 0" fill
+func mainColor(xpm *parser.XPMIcon) (colorspace.RGB, bool) {
+	if xpm == nil {
+		return colorspace.RGB{}, false
+	}
+	counts := make(map[string]int)
+	for _, row := range xpm.Data {
+		for _, pixel := range xpm.Pixels(row) {
+			counts[pixel]++
+		}
+	}
+	var best colorspace.RGB
+	found, bestCount := false, -1
+	for _, entry := range xpm.Palette {
+		rgb, ok := colorspace.ParseHex(entry.Color.Hex)
+		if ok && counts[entry.Key] > bestCount {
+			best, found, bestCount = rgb, true, counts[entry.Key]
+		}
+	}
+	return best, found
+}
+
+// swatches returns the line and polygon types that have a colour. At
+// night a type without a night icon is drawn with its day one.
+func swatches(t *parser.TYPFile, night bool) map[string][]swatch {
+	icon := func(day, nightIcon *parser.XPMIcon) *parser.XPMIcon {
+		if night && nightIcon != nil {
+			return nightIcon
+		}
+		return day
+	}
+	result := make(map[string][]swatch)
+	add := func(kind string, index int, typ string, xpm *parser.XPMIcon) {
+		rgb, ok := mainColor(xpm)
+		if !ok {
+			return
+		}
+		code := typ
+		if c, err := parser.ParseTypeCode(typ, ""); err == nil {
+			code = c.String()
+		}
+		result[kind] = append(result[kind], swatch{Swatch{Index: index, Type: code, Color: rgb.Hex()}, rgb})
+	}
+	for i, line := range t.Lines {
+		add("line", i, line.Type, icon(line.DayXpm, line.NightXpm))
+	}
+	for i, polygon := range t.Polygons {
+		add("polygon", i, polygon.Type, icon(polygon.DayXpm, polygon.NightXpm))
+	}
+	return result
+}
+
+// Check lists the pairs of line types and of polygon types whose main
+// colours fall below the threshold with a deficiency. Pairs that look
+// alike with normal vision too are left out, as they are alike for
+// everyone. Pairs are listed by deficiency and kind, closest first.
+func Check(t *parser.TYPFile, opts Options) []Pair {
+	threshold := opts.Threshold
+	if threshold == 0 {
+		threshold = DefaultThreshold
+	}
+	deficiencies := opts.Deficiencies
+	if len(deficiencies) == 0 {
+		deficiencies = Deficiencies
+	}
+
+	types := swatches(t, opts.Night)
+	pairs := []Pair{}
+	for _, d := range deficiencies {
+		for _, kind := range []string{"line", "polygon"} {
+			list := types[kind]
+			seen := make([]colorspace.RGB, len(list))
+			for i, s := range list {
+				seen[i] = d.Simulate(s.rgb)
+			}
+			var found []Pair
+			for i := range list {
+				for j := i + 1; j < len(list); j++ {
+					normal := colorspace.DeltaE(list[i].rgb.Lab(), list[j].rgb.Lab())
+					simulated := colorspace.DeltaE(seen[i].Lab(), seen[j].Lab())
+					if normal < threshold || simulated >= threshold {
+						continue
+					}
+					a, b := list[i].Swatch, list[j].Swatch
+					a.Seen, b.Seen = seen[i].Hex(), seen[j].Hex()
+					found = append(found, Pair{Kind: kind, Deficiency: d, A: a, B: b, Normal: normal, Simulated: simulated})
+				}
+			}
+			slices.SortStableFunc(found, func(a, b Pair) int {
+				return cmp.Compare(a.Simulated, b.Simulated)
+			})
+			pairs = append(pairs, found...)
+		}
+	}
+	return pairs
+}
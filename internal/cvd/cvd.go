@@ -0,0 +1,113 @@
+// Package cvd simulates colour vision deficiencies, to check that map
+// types stay apart for colour-blind readers. Protanopia, deuteranopia and
+// tritanopia use the full-severity matrices of Machado, Oliveira and
+// Fernandes (2009) on linear RGB; achromatopsia keeps the luminance only.
+package cvd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/dyuri/typtui/internal/colorspace"
+	"github.com/dyuri/typtui/internal/parser"
+)
+
+// Deficiency is a kind of colour blindness
+type Deficiency string
+
+const (
+	// Protanopia is red blindness, missing L cones
+	Protanopia Deficiency = "protanopia"
+	// Deuteranopia is green blindness, missing M cones, the most common
+	Deuteranopia Deficiency = "deuteranopia"
+	// Tritanopia is blue blindness, missing S cones
+	Tritanopia Deficiency = "tritanopia"
+	// Achromatopsia is total colour blindness
+	Achromatopsia Deficiency = "achromatopsia"
+)
+
+// Deficiencies lists the deficiencies that can be simulated
+var Deficiencies = []Deficiency{Protanopia, Deuteranopia, Tritanopia, Achromatopsia}
+
+// shortNames are the names deficiencies are also known by
+var shortNames = map[string]Deficiency{
+	"protan":   Protanopia,
+	"deutan":   Deuteranopia,
+	"tritan":   Tritanopia,
+	"achromat": Achromatopsia,
+}
+
+// ParseDeficiency returns the deficiency of a name, which may be
+// shortened to "protan", "deutan", "tritan" or "achromat"
+func ParseDeficiency(name string) (Deficiency, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if d, ok := shortNames[name]; ok {
+		return d, nil
+	}
+	for _, d := range Deficiencies {
+		if name == string(d) {
+			return d, nil
+		}
+	}
+	return "", fmt.Errorf("unknown deficiency %q, use protanopia, deuteranopia, tritanopia or achromatopsia", name)
+}
+
+// matrices are applied to linear RGB
+var matrices = map[Deficiency][3][3]float64{
+	Protanopia: {
+		{0.152286, 1.052583, -0.204868},
+		{0.114503, 0.786281, 0.099216},
+		{-0.003882, -0.048116, 1.051998},
+	},
+	Deuteranopia: {
+		{0.367322, 0.860646, -0.227968},
+		{0.280085, 0.672501, 0.047413},
+		{-0.011820, 0.042940, 0.968881},
+	},
+	Tritanopia: {
+		{1.255528, -0.076749, -0.178779},
+		{-0.078411, 0.930809, 0.147602},
+		{0.004733, 0.691367, 0.303900},
+	},
+}
+
+// Simulate returns how a colour looks with the deficiency
+func (d Deficiency) Simulate(c colorspace.RGB) colorspace.RGB {
+	l := c.Linear()
+	if d == Achromatopsia {
+		y := 0.2126*l[0] + 0.7152*l[1] + 0.0722*l[2]
+		return colorspace.FromLinear([3]float64{y, y, y})
+	}
+	m, ok := matrices[d]
+	if !ok {
+		return c
+	}
+	var out [3]float64
+	for i := range out {
+		out[i] = m[i][0]*l[0] + m[i][1]*l[1] + m[i][2]*l[2]
+		out[i] = min(max(out[i], 0), 1)
+	}
+	return colorspace.FromLinear(out)
+}
+
+// Color returns how a palette colour looks with the deficiency. none and
+// colours that are not "#RRGGBB" are returned as they are.
+func (d Deficiency) Color(c parser.Color) parser.Color {
+	rgb, ok := colorspace.ParseHex(c.Hex)
+	if !ok {
+		return c
+	}
+	return parser.Color{Hex: d.Simulate(rgb).Hex(), Day: c.Day}
+}
+
+// Icon returns a copy of an icon as it looks with the deficiency
+func (d Deficiency) Icon(xpm *parser.XPMIcon) *parser.XPMIcon {
+	if xpm == nil {
+		return nil
+	}
+	seen := xpm.Clone()
+	for i := range seen.Palette {
+		seen.Palette[i].Color = d.Color(seen.Palette[i].Color)
+	}
+	return seen
+}
@@ -0,0 +1,80 @@
+package cvd
+
+import (
+	"testing"
+
+	"github.com/dyuri/typtui/internal/colorspace"
+	"github.com/dyuri/typtui/internal/parser"
+)
+
+func TestParseDeficiency(t *testing.T) {
+	for name, want := range map[string]Deficiency{
+		"protanopia": Protanopia,
+		"deutan":     Deuteranopia,
+		"Tritan":     Tritanopia,
+		"achromat":   Achromatopsia,
+	} {
+		if got, err := ParseDeficiency(name); got != want || err != nil {
+			t.Errorf("ParseDeficiency(%q) = %q, %v", name, got, err)
+		}
+	}
+	for _, bad := range []string{"", "p", "red"} {
+		if _, err := ParseDeficiency(bad); err == nil {
+			t.Errorf("ParseDeficiency(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestSimulate(t *testing.T) {
+	red, _ := colorspace.ParseHex("#FF0000")
+	green, _ := colorspace.ParseHex("#00FF00")
+	white, _ := colorspace.ParseHex("#FFFFFF")
+
+	for _, d := range Deficiencies {
+		if got := d.Simulate(white).Hex(); got != "#FFFFFF" {
+			t.Errorf("%s: white looks %s", d, got)
+		}
+	}
+	if got := Achromatopsia.Simulate(red); got.R != got.G || got.G != got.B {
+		t.Errorf("achromatopsia: red looks %s", got.Hex())
+	}
+	normal := colorspace.DeltaE(red.Lab(), green.Lab())
+	deutan := colorspace.DeltaE(Deuteranopia.Simulate(red).Lab(), Deuteranopia.Simulate(green).Lab())
+	if deutan > normal/2 {
+		t.Errorf("deuteranopia: red and green are %.1f apart, normally %.1f", deutan, normal)
+	}
+
+	icon := Deuteranopia.Icon(&parser.XPMIcon{Palette: parser.Palette{{Key: "a", Color: parser.Color{Hex: "#FF0000"}}, {Key: "b", Color: parser.Color{Hex: "none"}}}})
+	if icon.Palette[0].Color.Hex == "#FF0000" || icon.Palette[1].Color.Hex != "none" {
+		t.Errorf("Unexpected simulated palette: %+v", icon.Palette)
+	}
+}
+
+// In map.typ red and green areas differ for everyone but red-green blind
+// readers; the blue one stands apart for them
+func TestCheck(t *testing.T) {
+	typFile, err := parser.ParseFile("../../testdata/cvd/map.typ")
+	if err != nil {
+		t.Fatalf("Failed to parse map.typ: %v", err)
+	}
+
+	pairs := Check(typFile, Options{Deficiencies: []Deficiency{Deuteranopia}, Threshold: 15})
+	if len(pairs) != 1 {
+		t.Fatalf("Expected the red and green polygons to look alike, got %+v", pairs)
+	}
+	p := pairs[0]
+	if p.Kind != "polygon" || p.A.Type != "0x13" || p.B.Type != "0x14" || p.B.Index != 1 ||
+		p.B.Color != "#5A8A20" || p.Simulated >= 15 || p.Normal < 15 {
+		t.Errorf("Unexpected pair: %+v", p)
+	}
+
+	// At night the green polygon is dark grey and apart from the red one
+	if pairs := Check(typFile, Options{Deficiencies: []Deficiency{Deuteranopia}, Threshold: 15, Night: true}); len(pairs) != 0 {
+		t.Errorf("Expected no pairs at night, got %+v", pairs)
+	}
+
+	// Without colours every polygon is a shade of grey
+	if pairs := Check(typFile, Options{Deficiencies: []Deficiency{Achromatopsia}, Threshold: 15}); len(pairs) == 0 {
+		t.Error("Expected pairs without colour vision")
+	}
+}
@@ -0,0 +1,57 @@
+// Package filestamp records which version of a file was loaded, so that
+// changes made on disk by someone else can be noticed before they are
+// overwritten.
+package filestamp
+
+import (
+	"crypto/sha256"
+	"os"
+	"time"
+)
+
+// Stamp identifies the contents of a file at some point
+type Stamp struct {
+	ModTime time.Time
+	Size    int64
+	Hash    [sha256.Size]byte
+}
+
+// Read reads a file and stamps the contents read
+func Read(path string) ([]byte, Stamp, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, Stamp{}, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, Stamp{}, err
+	}
+	return data, Stamp{ModTime: info.ModTime(), Size: int64(len(data)), Hash: sha256.Sum256(data)}, nil
+}
+
+// IsZero reports whether the stamp was never taken
+func (s Stamp) IsZero() bool {
+	return s.ModTime.IsZero() && s.Size == 0
+}
+
+// Changed reports whether the file no longer has the stamped contents. The
+// file is only read when its modification time or size differ, and a file
+// that was only touched is not changed. A removed file gives an error
+// matching fs.ErrNotExist.
+func (s Stamp) Changed(path string) (bool, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return false, err
+	}
+	if info.ModTime().Equal(s.ModTime) && info.Size() == s.Size {
+		return false, nil
+	}
+	if info.Size() != s.Size {
+		return true, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false, err
+	}
+	return sha256.Sum256(data) != s.Hash, nil
+}
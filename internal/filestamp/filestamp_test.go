@@ -0,0 +1,55 @@
+package filestamp
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestChanged(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "style.typ")
+	if err := os.WriteFile(path, []byte("[_id]\nFID=1\n[end]\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	data, stamp, err := Read(path)
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if string(data) != "[_id]\nFID=1\n[end]\n" || stamp.IsZero() {
+		t.Fatalf("Unexpected read: %q, %+v", data, stamp)
+	}
+	if changed, err := stamp.Changed(path); changed || err != nil {
+		t.Errorf("Expected an untouched file to be unchanged, got %v, %v", changed, err)
+	}
+
+	// Touching the file keeps the contents
+	later := stamp.ModTime.Add(time.Minute)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if changed, err := stamp.Changed(path); changed || err != nil {
+		t.Errorf("Expected a touched file to be unchanged, got %v, %v", changed, err)
+	}
+
+	// Same size, other contents
+	if err := os.WriteFile(path, []byte("[_id]\nFID=2\n[end]\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if changed, err := stamp.Changed(path); !changed || err != nil {
+		t.Errorf("Expected a rewritten file to be changed, got %v, %v", changed, err)
+	}
+
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stamp.Changed(path); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Expected a not exist error for a removed file, got %v", err)
+	}
+}
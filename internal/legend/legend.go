@@ -0,0 +1,240 @@
+// Package legend writes the catalogue of a TYP file as one HTML page: every
+// point icon, line sample and polygon swatch with its type code and
+// labels, day and night side by side. Images are embedded as data URIs,
+// so the page needs no other files.
+package legend
+
+import (
+	"cmp"
+	"fmt"
+	"html/template"
+	"io"
+	"path/filepath"
+	"slices"
+
+	"github.com/dyuri/typtui/internal/parser"
+	"github.com/dyuri/typtui/internal/typedb"
+)
+
+// Options control how the legend looks
+type Options struct {
+	Title string // defaults to the name of the file
+	Scale int    // size of an icon pixel in screen pixels, at least 1
+}
+
+// Label is a label of a type in one language
+type Label struct {
+	Code     string
+	Language string
+	Text     string
+}
+
+// Entry is a type on the legend
+type Entry struct {
+	Type       string
+	Labels     []Label
+	Day, Night template.URL // data URIs, "" without an image
+	NightAsDay bool         // the type has no night variant
+
+	code parser.TypeCode
+}
+
+// Group is the types of one category
+type Group struct {
+	Name    string
+	Entries []Entry
+}
+
+// Section is the groups of one kind of type
+type Section struct {
+	Name   string
+	Groups []Group
+}
+
+// page is the data of the template
+type page struct {
+	Title    string
+	File     string
+	Header   parser.Header
+	Sections []Section
+}
+
+// Build collects the sections of the legend of a file
+func Build(t *parser.TYPFile, scale int) []Section {
+	scale = max(scale, 1)
+
+	var points, lines, polygons []Entry
+	for _, p := range t.Points {
+		e := newEntry(p.Type, p.SubType, p.Labels)
+		e.Day = dataURI(pointIcon(p.DayXpm, scale))
+		e.Night, e.NightAsDay = e.Day, true
+		if p.NightXpm != nil {
+			e.Night, e.NightAsDay = dataURI(pointIcon(p.NightXpm, scale)), false
+		}
+		points = append(points, e)
+	}
+	for _, l := range t.Lines {
+		e := newEntry(l.Type, "", l.Labels)
+		e.Day = dataURI(lineSample(l.DayXpm, l.DayColors, l.LineWidth, l.BorderWidth, scale))
+		e.Night, e.NightAsDay = e.Day, true
+		if l.NightXpm != nil || len(l.NightColors) > 0 || l.NightLineWidth > 0 {
+			width, border := l.LineWidth, l.BorderWidth
+			if l.NightLineWidth > 0 {
+				width, border = l.NightLineWidth, l.NightBorderWidth
+			}
+			xpm, colors := l.NightXpm, l.NightColors
+			if xpm == nil && len(colors) == 0 {
+				xpm, colors = l.DayXpm, l.DayColors
+			}
+			e.Night, e.NightAsDay = dataURI(lineSample(xpm, colors, width, border, scale)), false
+		}
+		lines = append(lines, e)
+	}
+	for _, p := range t.Polygons {
+		e := newEntry(p.Type, "", p.Labels)
+		e.Day = dataURI(polygonSwatch(p.DayXpm, p.DayColors, scale))
+		e.Night, e.NightAsDay = e.Day, true
+		if p.NightXpm != nil || len(p.NightColors) > 0 {
+			e.Night, e.NightAsDay = dataURI(polygonSwatch(p.NightXpm, p.NightColors, scale)), false
+		}
+		polygons = append(polygons, e)
+	}
+
+	var sections []Section
+	for _, s := range []struct {
+		name, kind string
+		entries    []Entry
+	}{
+		{"Points", "point", points},
+		{"Lines", "line", lines},
+		{"Polygons", "polygon", polygons},
+	} {
+		if len(s.entries) > 0 {
+			sections = append(sections, Section{Name: s.name, Groups: group(s.kind, s.entries)})
+		}
+	}
+	return sections
+}
+
+// newEntry starts the entry of a type with its labels in language order
+func newEntry(typ, subType string, labels map[string]string) Entry {
+	e := Entry{Type: typ}
+	if code, err := parser.ParseTypeCode(typ, subType); err == nil {
+		e.Type, e.code = code.String(), code
+	}
+	for _, code := range parser.LabelCodes(labels) {
+		language, ok := parser.LanguageName(code)
+		if !ok {
+			language = code
+		}
+		e.Labels = append(e.Labels, Label{Code: code, Language: language, Text: labels[code]})
+	}
+	return e
+}
+
+// category names the group a type is listed in: its category in the type
+// catalogue, or for codes the catalogue does not know, points by their
+// type and lines and polygons by whether they are extended types
+func category(kind string, code parser.TypeCode) string {
+	if info, ok := typedb.Lookup(kind, code.String(), ""); ok {
+		return info.Category
+	}
+	switch {
+	case kind == "point" && code.Extended:
+		return fmt.Sprintf("Extended type 0x1%02x", code.Type)
+	case kind == "point":
+		return fmt.Sprintf("Type 0x%02x", code.Type)
+	case code.Extended:
+		return "Extended types"
+	}
+	return "Standard types"
+}
+
+// group sorts entries into their categories, each in type code order
+func group(kind string, entries []Entry) []Group {
+	byName := make(map[string]*Group)
+	var groups []*Group
+	for _, e := range entries {
+		name := category(kind, e.code)
+		g, ok := byName[name]
+		if !ok {
+			g = &Group{Name: name}
+			byName[name] = g
+			groups = append(groups, g)
+		}
+		g.Entries = append(g.Entries, e)
+	}
+
+	// Standard types come before extended ones
+	order := func(c parser.TypeCode) int {
+		n := c.Type<<8 | c.SubType
+		if c.Extended {
+			n += 1 << 16
+		}
+		return n
+	}
+	sorted := make([]Group, len(groups))
+	for i, g := range groups {
+		slices.SortStableFunc(g.Entries, func(a, b Entry) int { return cmp.Compare(order(a.code), order(b.code)) })
+		sorted[i] = *g
+	}
+	slices.SortStableFunc(sorted, func(a, b Group) int {
+		return cmp.Compare(order(a.Entries[0].code), order(b.Entries[0].code))
+	})
+	return sorted
+}
+
+// Write writes the legend of a file as an HTML page
+func Write(w io.Writer, t *parser.TYPFile, opts Options) error {
+	p := page{
+		Title:    opts.Title,
+		File:     filepath.Base(t.FilePath),
+		Header:   t.Header,
+		Sections: Build(t, opts.Scale),
+	}
+	if p.Title == "" {
+		p.Title = p.File
+	}
+	return pageTemplate.Execute(w, p)
+}
+
+var pageTemplate = template.Must(template.New("legend").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+h1 { margin-bottom: 0.2em; }
+.meta { color: #666; margin-bottom: 2em; }
+table { border-collapse: collapse; margin-bottom: 1.5em; }
+th, td { padding: 0.4em 0.8em; border-bottom: 1px solid #ddd; text-align: left; vertical-align: middle; }
+th { background: #f4f4f4; }
+code { font-size: 0.95em; }
+td.day { background: repeating-conic-gradient(#eee 0 25%, #fff 0 50%) 0 0 / 12px 12px; }
+td.night { background: #1e1e24; color: #aaa; }
+img { image-rendering: pixelated; display: block; }
+.lang { color: #888; }
+.none { color: #999; font-style: italic; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<div class="meta">{{.File}}{{with .Header.FID}} · FID {{.}}{{end}}{{with .Header.ProductCode}} · product {{.}}{{end}}{{with .Header.CodePage}} · code page {{.}}{{end}}</div>
+{{range .Sections}}
+<h2>{{.Name}}</h2>
+{{range .Groups}}
+<h3>{{.Name}}</h3>
+<table>
+<tr><th>Type</th><th>Day</th><th>Night</th><th>Labels</th></tr>
+{{range .Entries}}<tr>
+<td><code>{{.Type}}</code></td>
+<td class="day">{{if .Day}}<img src="{{.Day}}" alt="{{.Type}} day">{{else}}<span class="none">none</span>{{end}}</td>
+<td class="night">{{if .Night}}<img src="{{.Night}}" alt="{{.Type}} night">{{if .NightAsDay}}<span class="none">as day</span>{{end}}{{else}}<span class="none">none</span>{{end}}</td>
+<td>{{range .Labels}}<div><span class="lang">{{.Language}}:</span> {{.Text}}</div>{{end}}</td>
+</tr>
+{{end}}</table>
+{{end}}{{end}}
+</body>
+</html>
+`))
@@ -0,0 +1,76 @@
+package legend
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/dyuri/typtui/internal/parser"
+)
+
+func TestBuild(t *testing.T) {
+	typFile, err := parser.ParseFile("../../testdata/legend/legend.typ")
+	if err != nil {
+		t.Fatalf("Failed to parse legend.typ: %v", err)
+	}
+	sections := Build(typFile, 2)
+
+	var got []string
+	for _, s := range sections {
+		for _, g := range s.Groups {
+			line := s.Name + "/" + g.Name + ":"
+			for _, e := range g.Entries {
+				line += " " + e.Type
+			}
+			got = append(got, line)
+		}
+	}
+	want := []string{
+		"Points/Services: 0x2f06 0x2f07",
+		"Points/Marine: 0x10101",
+		"Lines/Roads: 0x01",
+		"Polygons/Man-made: 0x13",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("Unexpected groups:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	bank := sections[0].Groups[0].Entries[0]
+	if bank.Day == "" || bank.Night == "" || bank.Day == bank.Night || bank.NightAsDay {
+		t.Errorf("Expected different day and night icons: %+v", bank)
+	}
+	if len(bank.Labels) != 2 || bank.Labels[0].Language != "French" || bank.Labels[1].Text != "Bank" {
+		t.Errorf("Unexpected labels %+v", bank.Labels)
+	}
+	road := sections[1].Groups[0].Entries[0]
+	if road.Day == "" || !road.NightAsDay {
+		t.Errorf("Expected a line sample used day and night: %+v", road)
+	}
+}
+
+func TestWrite(t *testing.T) {
+	typFile, err := parser.ParseFile("../../testdata/legend/legend.typ")
+	if err != nil {
+		t.Fatalf("Failed to parse legend.typ: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := Write(&buf, typFile, Options{Title: "My map", Scale: 1}); err != nil {
+		t.Fatalf("Failed to write: %v", err)
+	}
+
+	html := buf.String()
+	for _, want := range []string{
+		"<title>My map</title>",
+		"FID 1234",
+		`src="data:image/png;base64,`,
+		"Post &lt;office&gt;",
+		"<code>0x10101</code>",
+	} {
+		if !strings.Contains(html, want) {
+			t.Errorf("Legend is missing %q", want)
+		}
+	}
+	if strings.Contains(html, "ZgotmplZ") {
+		t.Error("Data URIs were rejected by the template")
+	}
+}
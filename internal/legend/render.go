@@ -0,0 +1,140 @@
+package legend
+
+import (
+	"bytes"
+	"encoding/base64"
+	"html/template"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+
+	"github.com/dyuri/typtui/internal/parser"
+	"github.com/dyuri/typtui/internal/xpmimage"
+)
+
+// Sizes of the samples in icon pixels, before scaling
+const (
+	lineSampleWidth = 64
+	swatchSize      = 64
+)
+
+// hasPixels reports whether an icon has a bitmap, rather than just colours
+func hasPixels(xpm *parser.XPMIcon) bool {
+	return xpm != nil && xpm.Width > 0 && xpm.Height > 0 && len(xpm.Data) > 0
+}
+
+// solidColors returns the colours of an icon without a bitmap, or the
+// custom colours of the type when it has no icon
+func solidColors(xpm *parser.XPMIcon, custom []parser.Color) []color.NRGBA {
+	var colors []color.NRGBA
+	if xpm != nil {
+		for _, entry := range xpm.Palette {
+			if c, ok := xpmimage.ParseColor(entry.Color.Hex); ok {
+				colors = append(colors, c)
+			}
+		}
+	}
+	if len(colors) == 0 {
+		for _, c := range custom {
+			if c, ok := xpmimage.ParseColor(c.Hex); ok {
+				colors = append(colors, c)
+			}
+		}
+	}
+	return colors
+}
+
+// pointIcon draws a point icon, or returns nil when there is none
+func pointIcon(xpm *parser.XPMIcon, scale int) image.Image {
+	if !hasPixels(xpm) {
+		return nil
+	}
+	img, err := xpmimage.ToImage(xpm, scale)
+	if err != nil {
+		return nil
+	}
+	return img
+}
+
+// lineSample draws a stretch of a line: its bitmap repeated along the
+// line, or a band of the line colour with the border colour on both sides
+func lineSample(xpm *parser.XPMIcon, custom []parser.Color, width, border, scale int) image.Image {
+	if hasPixels(xpm) {
+		return tile(xpm, lineSampleWidth, xpm.Height, scale)
+	}
+
+	colors := solidColors(xpm, custom)
+	if len(colors) == 0 {
+		return nil
+	}
+	width = max(width, 1)
+	if len(colors) < 2 {
+		border = 0
+	}
+	img := image.NewNRGBA(image.Rect(0, 0, lineSampleWidth, width+2*border))
+	if border > 0 {
+		draw.Draw(img, img.Bounds(), image.NewUniform(colors[1]), image.Point{}, draw.Src)
+	}
+	draw.Draw(img, image.Rect(0, border, lineSampleWidth, border+width), image.NewUniform(colors[0]), image.Point{}, draw.Src)
+	return enlarge(img, scale)
+}
+
+// polygonSwatch draws a square of an area: its pattern tiled, or its
+// fill colour
+func polygonSwatch(xpm *parser.XPMIcon, custom []parser.Color, scale int) image.Image {
+	if hasPixels(xpm) {
+		return tile(xpm, swatchSize, swatchSize, scale)
+	}
+
+	colors := solidColors(xpm, custom)
+	if len(colors) == 0 {
+		return nil
+	}
+	img := image.NewNRGBA(image.Rect(0, 0, swatchSize, swatchSize))
+	draw.Draw(img, img.Bounds(), image.NewUniform(colors[0]), image.Point{}, draw.Src)
+	return enlarge(img, scale)
+}
+
+// tile repeats the bitmap of an icon over width x height pixels
+func tile(xpm *parser.XPMIcon, width, height, scale int) image.Image {
+	pattern, err := xpmimage.ToImage(xpm, 1)
+	if err != nil {
+		return nil
+	}
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	pw, ph := pattern.Bounds().Dx(), pattern.Bounds().Dy()
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.SetNRGBA(x, y, pattern.NRGBAAt(x%pw, y%ph))
+		}
+	}
+	return enlarge(img, scale)
+}
+
+// enlarge scales an image up by repeating pixels
+func enlarge(img *image.NRGBA, scale int) image.Image {
+	if scale <= 1 {
+		return img
+	}
+	b := img.Bounds()
+	big := image.NewNRGBA(image.Rect(0, 0, b.Dx()*scale, b.Dy()*scale))
+	for y := 0; y < b.Dy()*scale; y++ {
+		for x := 0; x < b.Dx()*scale; x++ {
+			big.SetNRGBA(x, y, img.NRGBAAt(x/scale, y/scale))
+		}
+	}
+	return big
+}
+
+// dataURI encodes an image as a PNG data URI, or "" for no image
+func dataURI(img image.Image) template.URL {
+	if img == nil {
+		return ""
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return ""
+	}
+	return template.URL("data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes()))
+}
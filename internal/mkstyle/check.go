@@ -0,0 +1,122 @@
+package mkstyle
+
+import (
+	"slices"
+
+	"github.com/dyuri/typtui/internal/parser"
+	"github.com/dyuri/typtui/internal/typedb"
+)
+
+// Issue is a type the style and the TYP file disagree about
+type Issue struct {
+	Kind    string `json:"kind"`              // the kind the style uses, or the TYP kind of an unused type
+	Code    string `json:"code"`              // in the form mkgmap writes it
+	Name    string `json:"name,omitempty"`    // the name in the type catalogue
+	Defined string `json:"defined,omitempty"` // the kind the TYP file defines the code as, "" if it does not
+	Index   int    `json:"-"`                 // of the type among the TYP types of its kind, -1 if not defined
+	Refs    []Ref  `json:"refs,omitempty"`    // the rules producing the type
+}
+
+// Report is the result of a cross-check
+type Report struct {
+	// Missing lists style types without a TYP definition; devices draw
+	// them with their built-in style
+	Missing []Issue `json:"missing"`
+	// WrongKind lists style types the TYP file defines only as another
+	// kind, such as a polygon code used by a line rule
+	WrongKind []Issue `json:"wrong_kind"`
+	// Unused lists TYP types no style rule produces
+	Unused []Issue `json:"unused"`
+}
+
+// Count returns the number of issues
+func (r Report) Count() int {
+	return len(r.Missing) + len(r.WrongKind) + len(r.Unused)
+}
+
+// typKey is a type by kind and code
+type typKey struct{ kind, code string }
+
+// Check cross-checks the types a style produces with the types a TYP file
+// defines. A style type the TYP file defines only as another kind is
+// reported as used in the wrong kind, unless the code is a standard type
+// of the kind the style uses, which devices draw anyway.
+func Check(t *parser.TYPFile, refs []Ref) Report {
+	defined := make(map[typKey]int)
+	var order []typKey
+	add := func(kind, typ, subType string, index int) {
+		code, err := parser.ParseTypeCode(typ, subType)
+		if err != nil {
+			return
+		}
+		key := typKey{kind, code.String()}
+		if _, ok := defined[key]; !ok {
+			defined[key] = index
+			order = append(order, key)
+		}
+	}
+	for i, p := range t.Points {
+		add("point", p.Type, p.SubType, i)
+	}
+	for i, l := range t.Lines {
+		add("line", l.Type, "", i)
+	}
+	for i, p := range t.Polygons {
+		add("polygon", p.Type, "", i)
+	}
+
+	used := make(map[typKey][]Ref)
+	var usedOrder []typKey
+	for _, ref := range refs {
+		key := typKey{ref.Kind, ref.Code}
+		if _, ok := used[key]; !ok {
+			usedOrder = append(usedOrder, key)
+		}
+		used[key] = append(used[key], ref)
+	}
+
+	// Empty lists rather than null in JSON
+	r := Report{Missing: []Issue{}, WrongKind: []Issue{}, Unused: []Issue{}}
+	claimed := make(map[typKey]bool) // TYP types reported as used in the wrong kind
+	for _, key := range usedOrder {
+		if _, ok := defined[key]; ok {
+			continue
+		}
+		issue := Issue{Kind: key.kind, Code: key.code, Index: -1, Refs: used[key]}
+		if info, ok := typedb.Lookup(key.kind, key.code, ""); ok {
+			issue.Name = info.Name
+			r.Missing = append(r.Missing, issue)
+			continue
+		}
+		for _, other := range []string{"point", "line", "polygon"} {
+			if index, ok := defined[typKey{other, key.code}]; ok && other != key.kind {
+				issue.Defined, issue.Index = other, index
+				claimed[typKey{other, key.code}] = true
+				break
+			}
+		}
+		if issue.Defined != "" {
+			r.WrongKind = append(r.WrongKind, issue)
+		} else {
+			r.Missing = append(r.Missing, issue)
+		}
+	}
+
+	for _, key := range order {
+		if _, ok := used[key]; ok || claimed[key] {
+			continue
+		}
+		issue := Issue{Kind: key.kind, Code: key.code, Defined: key.kind, Index: defined[key]}
+		if info, ok := typedb.Lookup(key.kind, key.code, ""); ok {
+			issue.Name = info.Name
+		}
+		r.Unused = append(r.Unused, issue)
+	}
+
+	// Points first, then lines and polygons, keeping the file order within
+	kinds := []string{"point", "line", "polygon"}
+	byKind := func(a, b Issue) int { return slices.Index(kinds, a.Kind) - slices.Index(kinds, b.Kind) }
+	slices.SortStableFunc(r.Missing, byKind)
+	slices.SortStableFunc(r.WrongKind, byKind)
+	return r
+}
@@ -0,0 +1,310 @@
+// Package mkstyle reads the type references of mkgmap style rules and
+// cross-checks them against a TYP file.
+//
+// A style maps OSM data to Garmin types with rules such as
+//
+//	amenity=bank [0x2f06 resolution 24]
+//	highway=motorway {name '${ref}'} [0x01 road_class=4 resolution 15]
+//
+// in the points, lines and polygons files of a style directory. Only the
+// type element in square brackets matters here; conditions and actions
+// are kept as text for the report. Include statements are followed.
+package mkstyle
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/dyuri/typtui/internal/parser"
+)
+
+// files maps the rule files of a style directory to the kind of type
+// their rules produce
+var files = []struct{ name, kind string }{
+	{"points", "point"},
+	{"lines", "line"},
+	{"polygons", "polygon"},
+}
+
+// maxIncludeDepth stops include cycles
+const maxIncludeDepth = 10
+
+// Ref is a type a style rule produces
+type Ref struct {
+	Kind string `json:"kind"`
+	Code string `json:"code"` // in the form mkgmap writes it
+	File string `json:"file"`
+	Line int    `json:"line"` // where the rule starts
+	Rule string `json:"rule"` // the condition and actions, on one line
+}
+
+// String returns the position of the rule
+func (r Ref) String() string {
+	return fmt.Sprintf("%s:%d", r.File, r.Line)
+}
+
+// Load reads the type references of a style: a style directory with
+// points, lines and polygons files, or one of those files
+func Load(path string) ([]Ref, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if !info.IsDir() {
+		for _, f := range files {
+			if filepath.Base(path) == f.name {
+				return loadFile(path, filepath.Dir(path), f.kind, 0)
+			}
+		}
+		return nil, fmt.Errorf("%s is not a points, lines or polygons style file", path)
+	}
+
+	var refs []Ref
+	found := false
+	for _, f := range files {
+		file := filepath.Join(path, f.name)
+		if _, err := os.Stat(file); errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		found = true
+		r, err := loadFile(file, path, f.kind, 0)
+		if err != nil {
+			return nil, err
+		}
+		refs = append(refs, r...)
+	}
+	if !found {
+		return nil, fmt.Errorf("no points, lines or polygons file in %s", path)
+	}
+	return refs, nil
+}
+
+// loadFile reads a rule file and the files it includes, which are
+// relative to the style directory
+func loadFile(path, dir, kind string, depth int) ([]Ref, error) {
+	if depth > maxIncludeDepth {
+		return nil, fmt.Errorf("%s: includes nested too deeply", path)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	refs, includes, err := parse(f, path, kind)
+	if err != nil {
+		return nil, err
+	}
+	for _, inc := range includes {
+		file := filepath.Join(dir, inc.file)
+		incDir := dir
+		if inc.style != "" {
+			// A file of another style in the same location
+			incDir = filepath.Join(filepath.Dir(dir), inc.style)
+			file = filepath.Join(incDir, inc.file)
+		}
+		r, err := loadFile(file, incDir, kind, depth+1)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, inc.line, err)
+		}
+		refs = append(refs, r...)
+	}
+	return refs, nil
+}
+
+// ParseReader reads the type references of the rules of one kind
+func ParseReader(r io.Reader, name, kind string) ([]Ref, error) {
+	refs, _, err := parse(r, name, kind)
+	return refs, err
+}
+
+// include is an include statement of a rule file
+type include struct {
+	file  string
+	style string // the style the file is in, "" for the same style
+	line  int
+}
+
+// scanner splits rule files into rules
+type scanner struct {
+	name, kind string
+	line       int
+
+	rule     strings.Builder // text of the rule so far, outside its type element
+	ruleLine int
+	element  strings.Builder // text of the type element
+	quote    rune            // the open quote, 0 outside strings
+	braces   int             // depth of action blocks
+	bracket  bool            // inside a type element
+	comment  bool
+	actions  bool // an action block just closed; the rule ends unless a type element follows
+
+	refs     []Ref
+	includes []include
+}
+
+// parse reads the type references and include statements of a rule file
+func parse(r io.Reader, name, kind string) ([]Ref, []include, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	s := &scanner{name: name, kind: kind, line: 1}
+	for _, c := range string(data) {
+		if err := s.next(c); err != nil {
+			return nil, nil, fmt.Errorf("%s:%d: %w", name, s.line, err)
+		}
+		if c == '\n' {
+			s.line++
+		}
+	}
+
+	switch {
+	case s.quote != 0:
+		return nil, nil, fmt.Errorf("%s:%d: unterminated string", name, s.ruleLine)
+	case s.bracket:
+		return nil, nil, fmt.Errorf("%s:%d: unterminated type element", name, s.ruleLine)
+	case s.braces > 0:
+		return nil, nil, fmt.Errorf("%s:%d: unterminated action block", name, s.ruleLine)
+	}
+	return s.refs, s.includes, nil
+}
+
+// next handles one character of a rule file
+func (s *scanner) next(c rune) error {
+	if s.comment {
+		s.comment = c != '\n'
+		return nil
+	}
+
+	if s.quote != 0 {
+		if c == s.quote {
+			s.quote = 0
+		}
+		s.write(c)
+		return nil
+	}
+
+	if s.actions && !isSpace(c) && c != '#' {
+		s.actions = false
+		if c != '[' {
+			// A rule with actions only
+			s.reset()
+		}
+	}
+
+	switch {
+	case c == '#':
+		s.comment = true
+	case c == '\'' || c == '"':
+		s.quote = c
+		s.write(c)
+	case s.bracket && c == ']':
+		return s.endElement()
+	case s.bracket:
+		s.write(c)
+	case c == '{':
+		s.braces++
+		s.write(c)
+	case c == '}' && s.braces > 0:
+		s.braces--
+		s.write(c)
+		s.actions = s.braces == 0
+	case c == '[' && s.braces == 0:
+		s.bracket = true
+		if s.rule.Len() == 0 {
+			s.ruleLine = s.line
+		}
+	case c == ';' && s.braces == 0:
+		return s.endStatement()
+	default:
+		s.write(c)
+	}
+	return nil
+}
+
+// write adds a character to the rule or its type element
+func (s *scanner) write(c rune) {
+	if s.bracket {
+		s.element.WriteRune(c)
+		return
+	}
+	if s.rule.Len() == 0 {
+		if isSpace(c) {
+			return
+		}
+		s.ruleLine = s.line
+	}
+	s.rule.WriteRune(c)
+}
+
+// reset starts a new rule
+func (s *scanner) reset() {
+	s.rule.Reset()
+	s.element.Reset()
+}
+
+// endElement records the type of the rule once its type element closes
+func (s *scanner) endElement() error {
+	s.bracket = false
+	fields := strings.Fields(s.element.String())
+	if len(fields) == 0 {
+		return errors.New("empty type element")
+	}
+	code, err := parser.ParseTypeCode(fields[0], "")
+	if err != nil {
+		return err
+	}
+
+	rule := strings.Join(strings.Fields(s.rule.String()), " ")
+	rule = strings.TrimSpace(strings.TrimPrefix(rule, "<finalize>"))
+	s.refs = append(s.refs, Ref{
+		Kind: s.kind,
+		Code: code.String(),
+		File: s.name,
+		Line: s.ruleLine,
+		Rule: rule,
+	})
+	s.reset()
+	return nil
+}
+
+// endStatement handles a statement ended by a semicolon, which outside
+// action blocks is an include
+func (s *scanner) endStatement() error {
+	fields := strings.Fields(s.rule.String())
+	s.reset()
+	if len(fields) == 0 || fields[0] != "include" {
+		return fmt.Errorf("unexpected ';'")
+	}
+
+	inc := include{line: s.ruleLine}
+	switch {
+	case len(fields) == 2:
+	case len(fields) == 4 && fields[2] == "from":
+		inc.style = unquote(fields[3])
+	default:
+		return errors.New("invalid include statement")
+	}
+	inc.file = unquote(fields[1])
+	s.includes = append(s.includes, inc)
+	return nil
+}
+
+// unquote removes the quotes around a string of a rule file
+func unquote(s string) string {
+	if len(s) >= 2 && (s[0] == '\'' || s[0] == '"') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
+
+func isSpace(c rune) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
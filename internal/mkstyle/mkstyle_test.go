@@ -0,0 +1,158 @@
+package mkstyle
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dyuri/typtui/internal/parser"
+)
+
+const pointRules = `# Banks and money
+amenity=bank [0x2f06 resolution 24]
+amenity=atm {name '${operator} #ATM]'} [0x2f06 resolution 24 continue]
+
+# Actions only
+tourism=* {set tourist=yes}
+
+include 'inc/food';
+
+shop=bakery
+  & name ~ '[A-Z].*'
+  [0x2e0a resolution 24]
+
+<finalize>
+name=* {name '${name}'}
+`
+
+const foodRules = `amenity=restaurant [0x2a00 resolution 24]
+`
+
+const lineRules = `highway=motorway [0x01 road_class=4 road_speed=7 resolution 15]
+natural=water [0x3c resolution 20] # should be a polygon
+`
+
+const polygonRules = `natural=wood | landuse=forest [0x50 resolution 20]
+`
+
+func writeStyle(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	files := map[string]string{
+		"points":   pointRules,
+		"inc/food": foodRules,
+		"lines":    lineRules,
+		"polygons": polygonRules,
+	}
+	for name, text := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(text), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestLoad(t *testing.T) {
+	dir := writeStyle(t)
+	refs, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Failed to load: %v", err)
+	}
+
+	var got []string
+	for _, r := range refs {
+		got = append(got, fmt.Sprintf("%s %s %s:%d %s", r.Kind, r.Code, filepath.Base(r.File), r.Line, r.Rule))
+	}
+	want := []string{
+		"point 0x2f06 points:2 amenity=bank",
+		"point 0x2f06 points:3 amenity=atm {name '${operator} #ATM]'}",
+		"point 0x2e0a points:10 shop=bakery & name ~ '[A-Z].*'",
+		"point 0x2a food:1 amenity=restaurant",
+		"line 0x01 lines:1 highway=motorway",
+		"line 0x3c lines:2 natural=water",
+		"polygon 0x50 polygons:1 natural=wood | landuse=forest",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Unexpected refs:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	if refs, err := Load(filepath.Join(dir, "lines")); err != nil || len(refs) != 2 {
+		t.Errorf("Loading one file gave %d refs, %v", len(refs), err)
+	}
+	if _, err := Load(filepath.Join(dir, "inc")); err == nil {
+		t.Error("Expected an error for a directory without rule files")
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := map[string]string{
+		"amenity=bank [0x2f06":           "unterminated type element",
+		"amenity=bank [zz]":              "invalid type code",
+		"name='x [0x01]":                 "unterminated string",
+		"amenity=bank;":                  "unexpected ';'",
+		"a=b {name 'x'":                  "unterminated action block",
+		"amenity=bank []":                "empty type element",
+		"include 'a' 'b';":               "invalid include statement",
+		"x=y [0x01]\nshop=*\n[0x123456]": "test.style:3: type code \"0x123456\" out of range",
+	}
+	for text, want := range tests {
+		_, err := ParseReader(strings.NewReader(text), "test.style", "point")
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("ParseReader(%q) = %v, want %q", text, err, want)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	typFile, err := parser.ParseReader(strings.NewReader(`[_point]
+Type=0x2f
+SubType=0x06
+[end]
+[_point]
+Type=0x2f0b
+[end]
+[_line]
+Type=0x01
+[end]
+[_polygon]
+Type=0x3c
+[end]
+[_polygon]
+Type=0x10e00
+[end]
+`), "check.typ")
+	if err != nil {
+		t.Fatalf("Failed to parse: %v", err)
+	}
+	refs, err := Load(writeStyle(t))
+	if err != nil {
+		t.Fatalf("Failed to load: %v", err)
+	}
+
+	r := Check(typFile, refs)
+	summary := func(issues []Issue) string {
+		var s []string
+		for _, i := range issues {
+			s = append(s, i.Kind+" "+i.Code+" "+i.Defined)
+		}
+		return strings.Join(s, ", ")
+	}
+	if got := summary(r.Missing); got != "point 0x2e0a , point 0x2a , polygon 0x50 " {
+		t.Errorf("Unexpected missing types: %s", got)
+	}
+	if got := summary(r.WrongKind); got != "line 0x3c polygon" || r.WrongKind[0].Index != 0 {
+		t.Errorf("Unexpected types of the wrong kind: %s %+v", got, r.WrongKind)
+	}
+	if got := summary(r.Unused); got != "point 0x2f0b point, polygon 0x10e00 polygon" || r.Unused[1].Index != 1 {
+		t.Errorf("Unexpected unused types: %s", got)
+	}
+	if r.Missing[0].Name != "Specialty retail" || r.Count() != 6 {
+		t.Errorf("Unexpected report: %+v", r)
+	}
+}
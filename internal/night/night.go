@@ -0,0 +1,180 @@
+// Package night derives night colours from day colours. Types with a day
+// icon or day colours but no night version are drawn with their day
+// colours in night mode, which glares on a device; a Generator fills in
+// the night icon, night colours and night label colour from the day ones
+// with one of several strategies.
+package night
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/dyuri/typtui/internal/colorspace"
+	"github.com/dyuri/typtui/internal/parser"
+)
+
+// Strategy is how day colours become night colours
+type Strategy string
+
+const (
+	// DarkenLab lowers the L* lightness of CIE L*a*b*, darkening all hues
+	// evenly and keeping them apart
+	DarkenLab Strategy = "lab"
+	// DarkenHSL lowers the HSL lightness
+	DarkenHSL Strategy = "hsl"
+	// Invert flips the L* lightness: light areas become dark and dark
+	// lines light, hues are kept
+	Invert Strategy = "invert"
+	// Palette darkens in L*a*b* and takes the nearest colour of a dark
+	// base palette, so the night theme uses a few coordinated colours
+	Palette Strategy = "palette"
+)
+
+// Strategies lists the strategies, the default first
+var Strategies = []Strategy{DarkenLab, DarkenHSL, Invert, Palette}
+
+// ParseStrategy returns the strategy of a name
+func ParseStrategy(name string) (Strategy, error) {
+	for _, s := range Strategies {
+		if strings.EqualFold(name, string(s)) {
+			return s, nil
+		}
+	}
+	return "", fmt.Errorf("unknown strategy %q, use %s", name, strategyNames())
+}
+
+// strategyNames lists the strategies for messages
+func strategyNames() string {
+	names := make([]string, len(Strategies))
+	for i, s := range Strategies {
+		names[i] = string(s)
+	}
+	return strings.Join(names[:len(names)-1], ", ") + " or " + names[len(names)-1]
+}
+
+// DefaultPalette is the dark base palette of the Palette strategy: greys,
+// night blues for water, greens for vegetation, browns, reds and ambers
+var DefaultPalette = []string{
+	"#000000", "#1C1C1C", "#333333", "#4D4D4D", "#808080", "#B3B3B3",
+	"#0A1A2F", "#123A5A", "#0F2A14", "#29471F", "#2E2414", "#4F3A1E",
+	"#3D1414", "#7A2A1A", "#5A4A10", "#8A6D1A", "#2A1A3D",
+}
+
+// Options choose how night colours are made
+type Options struct {
+	Strategy Strategy
+	Amount   float64  // how much darker, from 0 to 1; Invert does not use it
+	Palette  []string // "#RRGGBB" colours of the Palette strategy, DefaultPalette if empty
+	Replace  bool     // also regenerate night versions a type already has
+}
+
+// DefaultOptions darkens colours by 60% in L*a*b*
+func DefaultOptions() Options {
+	return Options{Strategy: DarkenLab, Amount: 0.6}
+}
+
+// Generator makes night colours with a set of options
+type Generator struct {
+	opts    Options
+	palette []colorspace.Lab
+}
+
+// New checks the options and returns a generator using them
+func New(opts Options) (*Generator, error) {
+	if opts.Strategy == "" {
+		opts.Strategy = DarkenLab
+	}
+	if _, err := ParseStrategy(string(opts.Strategy)); err != nil {
+		return nil, err
+	}
+	if opts.Amount < 0 || opts.Amount > 1 {
+		return nil, fmt.Errorf("amount must be from 0 to 1, got %g", opts.Amount)
+	}
+
+	g := &Generator{opts: opts}
+	if opts.Strategy == Palette {
+		palette := opts.Palette
+		if len(palette) == 0 {
+			palette = DefaultPalette
+		}
+		for _, hex := range palette {
+			c, ok := colorspace.ParseHex(hex)
+			if !ok {
+				return nil, fmt.Errorf("invalid palette colour %q, use #RRGGBB", hex)
+			}
+			g.palette = append(g.palette, c.Lab())
+		}
+	}
+	return g, nil
+}
+
+// Color returns the night version of a day colour. none and colours that
+// are not "#RRGGBB" are returned as they are.
+func (g *Generator) Color(c parser.Color) parser.Color {
+	rgb, ok := colorspace.ParseHex(c.Hex)
+	if !ok {
+		return c
+	}
+
+	var night colorspace.RGB
+	switch g.opts.Strategy {
+	case DarkenHSL:
+		hsl := rgb.HSL()
+		hsl.L *= 1 - g.opts.Amount
+		night = hsl.RGB()
+	case Invert:
+		lab := rgb.Lab()
+		lab.L = 100 - lab.L
+		night = lab.RGB()
+	case Palette:
+		lab := rgb.Lab()
+		lab.L *= 1 - g.opts.Amount
+		night = g.nearest(lab).RGB()
+	default:
+		lab := rgb.Lab()
+		lab.L *= 1 - g.opts.Amount
+		night = lab.RGB()
+	}
+	return parser.Color{Hex: night.Hex()}
+}
+
+// nearest returns the colour of the base palette that looks closest
+func (g *Generator) nearest(lab colorspace.Lab) colorspace.Lab {
+	best := g.palette[0]
+	for _, c := range g.palette[1:] {
+		if colorspace.DeltaE(lab, c) < colorspace.DeltaE(lab, best) {
+			best = c
+		}
+	}
+	return best
+}
+
+// Label returns the night version of a label colour. Labels are drawn on
+// the dark night map, so whatever the strategy, dark label colours get
+// the lightness of their inverse and light ones are kept.
+func (g *Generator) Label(c parser.Color) parser.Color {
+	rgb, ok := colorspace.ParseHex(c.Hex)
+	if !ok {
+		return c
+	}
+	lab := rgb.Lab()
+	if lab.L >= 50 {
+		return parser.Color{Hex: rgb.Hex()}
+	}
+	lab.L = 100 - lab.L
+	return parser.Color{Hex: lab.RGB().Hex()}
+}
+
+// Icon returns a night version of a day icon: the same pixels with the
+// palette colours made night colours and none kept transparent
+func (g *Generator) Icon(day *parser.XPMIcon) *parser.XPMIcon {
+	if day == nil {
+		return nil
+	}
+	night := day.Clone()
+	for i, entry := range night.Palette {
+		// The grey and mono visuals describe the day colour
+		night.Palette[i] = parser.PaletteEntry{Key: entry.Key, Color: g.Color(entry.Color), Symbolic: entry.Symbolic}
+	}
+	return night
+}
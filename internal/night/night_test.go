@@ -0,0 +1,122 @@
+package night
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/dyuri/typtui/internal/colorspace"
+	"github.com/dyuri/typtui/internal/parser"
+)
+
+func lightness(t *testing.T, c parser.Color) float64 {
+	t.Helper()
+	rgb, ok := colorspace.ParseHex(c.Hex)
+	if !ok {
+		t.Fatalf("Invalid colour %q", c.Hex)
+	}
+	return rgb.Lab().L
+}
+
+func TestNew(t *testing.T) {
+	if _, err := New(Options{Strategy: "sepia"}); err == nil {
+		t.Error("Expected an error for an unknown strategy")
+	}
+	if _, err := New(Options{Amount: 1.5}); err == nil {
+		t.Error("Expected an error for an amount over 1")
+	}
+	if _, err := New(Options{Strategy: Palette, Palette: []string{"#123"}}); err == nil {
+		t.Error("Expected an error for a short palette colour")
+	}
+	if s, err := ParseStrategy("HSL"); s != DarkenHSL || err != nil {
+		t.Errorf("ParseStrategy(HSL) = %q, %v", s, err)
+	}
+}
+
+func TestColor(t *testing.T) {
+	white := parser.Color{Hex: "#FFFFFF"}
+	red := parser.Color{Hex: "#FF0000", Name: "red"}
+
+	for _, s := range []Strategy{DarkenLab, DarkenHSL, Invert, Palette} {
+		g, err := New(Options{Strategy: s, Amount: 0.6})
+		if err != nil {
+			t.Fatal(err)
+		}
+		night := g.Color(red)
+		if night.Name != "" || lightness(t, night) >= lightness(t, red) {
+			t.Errorf("%s: red became %+v", s, night)
+		}
+		if l := lightness(t, g.Color(white)); l > 45 {
+			t.Errorf("%s: white became %s with L* %.1f", s, g.Color(white).Hex, l)
+		}
+		if got := g.Color(parser.Color{Hex: "none"}); got.Hex != "none" {
+			t.Errorf("%s: none became %q", s, got.Hex)
+		}
+	}
+
+	g, _ := New(Options{Strategy: Invert})
+	if l := lightness(t, g.Color(parser.Color{Hex: "#000000"})); l < 99 {
+		t.Errorf("Invert: black has L* %.1f", l)
+	}
+
+	g, _ = New(Options{Strategy: Palette, Amount: 0.5, Palette: []string{"#000000", "#123A5A"}})
+	if got := g.Color(parser.Color{Hex: "#4080C0"}); got.Hex != "#123A5A" {
+		t.Errorf("Palette: blue became %s", got.Hex)
+	}
+
+	g, _ = New(DefaultOptions())
+	if l := lightness(t, g.Label(parser.Color{Hex: "#202020"})); l < 50 {
+		t.Errorf("A dark label stays dark at night: L* %.1f", l)
+	}
+	if got := g.Label(white); got.Hex != "#FFFFFF" {
+		t.Errorf("A white label became %s", got.Hex)
+	}
+}
+
+func TestFile(t *testing.T) {
+	typFile, err := parser.ParseFile("../../testdata/night/map.typ")
+	if err != nil {
+		t.Fatalf("Failed to parse map.typ: %v", err)
+	}
+	g, _ := New(DefaultOptions())
+
+	changes := g.File(typFile)
+	if len(changes) != 2 {
+		t.Fatalf("Expected the bank and the building to change, got %+v", changes)
+	}
+	bank := changes[0]
+	if bank.Kind != "point" || bank.Type != "0x2f06" || bank.Index != 0 ||
+		strings.Join(bank.Fields, ",") != "NightXpm,NightFontColor" || len(bank.Colors) != 2 {
+		t.Errorf("Unexpected change: %+v", bank)
+	}
+
+	night := typFile.Points[0].NightXpm
+	if night == nil || night.Palette[1].Color.Hex != "none" || strings.Join(night.Data, "|") != "ab|ba" {
+		t.Fatalf("Unexpected night icon: %+v", night)
+	}
+	if typFile.Points[0].DayXpm.Palette[0].Color.Hex != "#FF0000" {
+		t.Error("The day icon changed")
+	}
+	if typFile.Points[1].NightXpm.Palette[0].Color.Hex != "#000000" {
+		t.Error("An existing night icon was replaced")
+	}
+	building := changes[1]
+	if building.Kind != "polygon" || building.Index != 0 || typFile.Polygons[0].NightXpm == nil {
+		t.Errorf("Unexpected change: %+v", building)
+	}
+
+	// Replace regenerates the night icon the car dealer has
+	g, _ = New(Options{Strategy: Invert, Replace: true})
+	changes = g.File(typFile)
+	if len(changes) != 3 || typFile.Points[1].NightXpm.Palette[0].Color.Hex != "#000000" {
+		t.Errorf("Expected all types to change, got %+v", changes)
+	}
+
+	// The file still writes and reads back
+	var text strings.Builder
+	if err := parser.Write(&text, typFile); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := parser.ParseReader(strings.NewReader(text.String()), "night.typ"); err != nil {
+		t.Errorf("Failed to read the night file back: %v\n%s", err, text.String())
+	}
+}
@@ -0,0 +1,124 @@
+package night
+
+import (
+	"github.com/dyuri/typtui/internal/parser"
+)
+
+// Change lists the night versions generated for a type
+type Change struct {
+	Kind   string    `json:"kind"` // "point", "line" or "polygon"
+	Index  int       `json:"index"`
+	Type   string    `json:"type"`   // the type code in canonical form
+	Fields []string  `json:"fields"` // the night fields set, as named in TYP text
+	Colors []Mapping `json:"colors"` // the colours changed, in order of first use
+}
+
+// Mapping is a day colour and the night colour it became
+type Mapping struct {
+	Day   string `json:"day"`
+	Night string `json:"night"`
+}
+
+// Empty reports whether the type needed no night versions
+func (c Change) Empty() bool {
+	return len(c.Fields) == 0
+}
+
+// scheme holds the day and night fields a type has
+type scheme struct {
+	dayXpm      *parser.XPMIcon
+	nightXpm    **parser.XPMIcon
+	dayColors   []parser.Color
+	nightColors *[]parser.Color
+	dayFont     parser.Color
+	nightFont   *parser.Color
+}
+
+// generate fills in the night fields of a scheme that are missing, or all
+// of them with Replace
+func (g *Generator) generate(s scheme, change *Change) {
+	seen := make(map[string]bool)
+	record := func(day, night parser.Color) parser.Color {
+		if day.Hex != night.Hex && !seen[day.Hex] {
+			seen[day.Hex] = true
+			change.Colors = append(change.Colors, Mapping{Day: day.Hex, Night: night.Hex})
+		}
+		return night
+	}
+
+	if s.dayXpm != nil && (*s.nightXpm == nil || g.opts.Replace) {
+		night := g.Icon(s.dayXpm)
+		for i, entry := range s.dayXpm.Palette {
+			record(entry.Color, night.Palette[i].Color)
+		}
+		*s.nightXpm = night
+		change.Fields = append(change.Fields, "NightXpm")
+	}
+
+	if len(s.dayColors) > 0 && (len(*s.nightColors) == 0 || g.opts.Replace) {
+		colors := make([]parser.Color, len(s.dayColors))
+		for i, c := range s.dayColors {
+			colors[i] = record(c, g.Color(c))
+		}
+		*s.nightColors = colors
+		change.Fields = append(change.Fields, "NightCustomColor")
+	}
+
+	if s.dayFont.Hex != "" && (s.nightFont.Hex == "" || g.opts.Replace) {
+		*s.nightFont = record(s.dayFont, g.Label(s.dayFont))
+		change.Fields = append(change.Fields, "NightFontColor")
+	}
+}
+
+// typeCode returns the canonical code of a type, or the code as written
+// if it cannot be read
+func typeCode(typ, subType string) string {
+	if code, err := parser.ParseTypeCode(typ, subType); err == nil {
+		return code.String()
+	}
+	return typ
+}
+
+// Point generates the night versions of a point type
+func (g *Generator) Point(p *parser.PointType) Change {
+	change := Change{Kind: "point", Type: typeCode(p.Type, p.SubType)}
+	g.generate(scheme{p.DayXpm, &p.NightXpm, p.DayColors, &p.NightColors, p.DayFontColor, &p.NightFontColor}, &change)
+	return change
+}
+
+// Line generates the night versions of a line type. The night pattern has
+// the pixels of the day pattern, as compiled files require.
+func (g *Generator) Line(l *parser.LineType) Change {
+	change := Change{Kind: "line", Type: typeCode(l.Type, "")}
+	g.generate(scheme{l.DayXpm, &l.NightXpm, l.DayColors, &l.NightColors, l.DayFontColor, &l.NightFontColor}, &change)
+	return change
+}
+
+// Polygon generates the night versions of a polygon type
+func (g *Generator) Polygon(p *parser.PolygonType) Change {
+	change := Change{Kind: "polygon", Type: typeCode(p.Type, "")}
+	g.generate(scheme{p.DayXpm, &p.NightXpm, p.DayColors, &p.NightColors, p.DayFontColor, &p.NightFontColor}, &change)
+	return change
+}
+
+// File generates the night versions of every type of a file and returns
+// the types that changed
+func (g *Generator) File(t *parser.TYPFile) []Change {
+	var changes []Change
+	add := func(change Change, index int) {
+		if !change.Empty() {
+			change.Index = index
+			changes = append(changes, change)
+		}
+	}
+	for i := range t.Points {
+		add(g.Point(&t.Points[i]), i)
+	}
+	for i := range t.Lines {
+		add(g.Line(&t.Lines[i]), i)
+	}
+	for i := range t.Polygons {
+		add(g.Polygon(&t.Polygons[i]), i)
+	}
+	return changes
+}
@@ -0,0 +1,191 @@
+package parser
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Compiled (binary) TYP layout
+//
+// All integers are little endian. The file starts with a fixed header:
+//
+//	0x00 u16        header length (0x5b)
+//	0x02 [10]byte   "GARMIN TYP"
+//	0x0c u8         format version (1)
+//	0x0d u8         lock flag
+//	0x0e [7]byte    compile date: year-1900 (u16), month, day, hour, min, sec
+//	0x15 u16        code page
+//	0x17 u32 u32    point data offset, length
+//	0x1f u32 u32    line data offset, length
+//	0x27 u32 u32    polygon data offset, length
+//	0x2f u16        family ID
+//	0x31 u16        product code
+//	0x33 u32 u16 u32  point index offset, record size, length
+//	0x3d u32 u16 u32  line index offset, record size, length
+//	0x47 u32 u16 u32  polygon index offset, record size, length
+//	0x51 u32 u16 u32  draw order offset, record size, length
+//
+// Index records are a u16 type key followed by the offset of the type's
+// record within the matching data section. The key holds the subtype in
+// bits 0-4, the type in bits 5-12 and the extended flag in bit 13. Index
+// records are sorted by key.
+//
+// Draw order records are a u8 polygon type followed by a u32 bit mask of
+// extended subtypes. A record with type zero terminates a level.
+const (
+	binaryHeaderLength  = 0x5b
+	binaryDrawOrderSize = 5
+	binaryExtendedFlag  = 0x2000
+	binaryPatternWidth  = 32
+	binaryPatternHeight = 32
+)
+
+var binarySignature = []byte("GARMIN TYP")
+
+// Point record flags
+const (
+	pointHasBitmap = 0x01
+	pointHasNight  = 0x02
+	pointHasLabels = 0x04
+	pointHasFont   = 0x08
+)
+
+// Bitmap colour modes
+const (
+	colorModeOpaque      = 0x00
+	colorModeTransparent = 0x10
+)
+
+// Line record flags (second flag byte)
+const (
+	lineHasLabels      = 0x01
+	lineUseOrientation = 0x02
+	lineHasFont        = 0x04
+)
+
+// Colour scheme bits of line records (bits 0-2 of the first flag byte) and
+// polygon records (low nibble of the flag byte). Without schemeNight the
+// night colours are the day colours and both transparency bits are equal.
+//
+//	line     0: day line and border      polygon 0x06: day colour
+//	         1: + night line and border          0x07: + night colour
+//	         3: day line, night line and border  0x08: day pattern, two colours
+//	         5: day line and border, night line  0x09: + night pattern, two colours
+//	         6: day line                         0x0b: day one colour, night two
+//	         7: day line, night line             0x0d: day two colours, night one
+//	                                             0x0e: day pattern, one colour
+//	                                             0x0f: + night pattern, one colour
+const (
+	schemeNight            = 0x01 // night colours follow the day colours
+	schemeDayTransparent   = 0x02 // one day colour, no border or background
+	schemeNightTransparent = 0x04 // one night colour, no border or background
+	schemePattern          = 0x08 // polygons only, a 32x32 pattern follows
+)
+
+// Polygon record flags
+const (
+	polygonHasLabels = 0x10
+	polygonHasFont   = 0x20
+)
+
+// Font info flags, the low three bits hold the font style
+const (
+	fontHasDayColor   = 0x08
+	fontHasNightColor = 0x10
+)
+
+// fontStyles maps binary font style numbers to their text names
+var fontStyles = []string{"", "NoLabel", "SmallFont", "NormalFont", "LargeFont"}
+
+// isBinaryTYP reports whether data starts with a compiled TYP header
+func isBinaryTYP(data []byte) bool {
+	return len(data) >= 2+len(binarySignature) &&
+		bytes.Equal(data[2:2+len(binarySignature)], binarySignature)
+}
+
+// bitsPerPixel returns the bitmap depth used for the given number of palette
+// indexes, counting the transparent index. Garmin devices derive the depth
+// from the palette size this way, so three indexes use two bits but four
+// already use four.
+func bitsPerPixel(indexes int) int {
+	switch {
+	case indexes == 0:
+		return 0
+	case indexes < 2:
+		return 1
+	case indexes < 4:
+		return 2
+	case indexes < 16:
+		return 4
+	default:
+		return 8
+	}
+}
+
+// schemeColors returns the number of day and night colours stored for a line
+// or polygon colour scheme
+func schemeColors(scheme int) (day, night int) {
+	day = 2
+	if scheme&schemeDayTransparent != 0 {
+		day = 1
+	}
+	if scheme&schemeNight != 0 {
+		night = 2
+		if scheme&schemeNightTransparent != 0 {
+			night = 1
+		}
+	}
+	return day, night
+}
+
+// validScheme reports whether a colour scheme is well formed: without night
+// colours both transparency bits must agree
+func validScheme(scheme int) bool {
+	if scheme&schemeNight != 0 {
+		return true
+	}
+	return (scheme&schemeDayTransparent != 0) == (scheme&schemeNightTransparent != 0)
+}
+
+// colorScheme returns the colour scheme for the given numbers of day and
+// night colours, night being zero when the day colours apply at night too
+func colorScheme(day, night int) int {
+	scheme := 0
+	if day == 1 {
+		scheme |= schemeDayTransparent
+	}
+	switch {
+	case night == 0 && day == 1:
+		scheme |= schemeNightTransparent
+	case night == 1:
+		scheme |= schemeNight | schemeNightTransparent
+	case night == 2:
+		scheme |= schemeNight
+	}
+	return scheme
+}
+
+// isTransparent reports whether a palette colour means no colour
+func isTransparent(c Color) bool {
+	return c.Hex == "none" || c.Hex == "transparent"
+}
+
+// parseHexColor parses a #RRGGBB colour
+func parseHexColor(hexColor string) (r, g, b uint8, err error) {
+	hex := strings.TrimPrefix(hexColor, "#")
+	if len(hex) != 6 {
+		return 0, 0, 0, fmt.Errorf("invalid color %q", hexColor)
+	}
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("invalid color %q", hexColor)
+	}
+	return uint8(v >> 16), uint8(v >> 8), uint8(v), nil
+}
+
+// formatHexColor formats an RGB triple as #RRGGBB
+func formatHexColor(r, g, b uint8) string {
+	return fmt.Sprintf("#%02X%02X%02X", r, g, b)
+}
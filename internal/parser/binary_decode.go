@@ -0,0 +1,557 @@
+package parser
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+)
+
+// xpmChars are the pixel characters assigned to decoded palette entries.
+// Space is reserved for transparent pixels.
+const xpmChars = "!$%&'()*+,-./0123456789:<=>?@ABCDEFGHIJKLMNOPQRSTUVWXYZ[]^_`abcdefghijklmnopqrstuvwxyz{|}~"
+
+// binaryReader reads little endian values from a compiled TYP file. The first
+// out-of-range read is recorded in err and makes every later read a no-op.
+type binaryReader struct {
+	data     []byte
+	pos      int
+	err      error
+	filePath string
+	codePage int // code page of the labels
+}
+
+// fail records a ParseError at the given offset unless one is already set
+func (r *binaryReader) fail(offset int, format string, args ...interface{}) {
+	if r.err == nil {
+		r.err = &ParseError{
+			Offset:  int64(offset),
+			Message: fmt.Sprintf(format, args...),
+			File:    r.filePath,
+		}
+	}
+}
+
+// bytes returns the next n bytes
+func (r *binaryReader) bytes(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || r.pos+n > len(r.data) {
+		r.fail(r.pos, "unexpected end of data reading %d bytes", n)
+		return nil
+	}
+	b := r.data[r.pos : r.pos+n]
+	r.pos += n
+	return b
+}
+
+func (r *binaryReader) u8() int {
+	b := r.bytes(1)
+	if b == nil {
+		return 0
+	}
+	return int(b[0])
+}
+
+func (r *binaryReader) u16() int {
+	b := r.bytes(2)
+	if b == nil {
+		return 0
+	}
+	return int(binary.LittleEndian.Uint16(b))
+}
+
+func (r *binaryReader) u32() int {
+	b := r.bytes(4)
+	if b == nil {
+		return 0
+	}
+	return int(binary.LittleEndian.Uint32(b))
+}
+
+// uint reads an unsigned integer of 1 to 4 bytes
+func (r *binaryReader) uint(size int) int {
+	b := r.bytes(size)
+	v := 0
+	for i := len(b) - 1; i >= 0; i-- {
+		v = v<<8 | int(b[i])
+	}
+	return v
+}
+
+// color reads a BGR colour triple
+func (r *binaryReader) color() Color {
+	b := r.bytes(3)
+	if b == nil {
+		return Color{}
+	}
+	return Color{Hex: formatHexColor(b[2], b[1], b[0])}
+}
+
+// section describes a data or index section in the header
+type section struct {
+	offset int
+	length int
+	size   int // record size, index sections only
+}
+
+// ParseBinary decodes a compiled TYP file
+func ParseBinary(data []byte, filePath string) (*TYPFile, error) {
+	r := &binaryReader{data: data, filePath: filePath}
+
+	headerLength := r.u16()
+	if !isBinaryTYP(data) {
+		return nil, &ParseError{Offset: 2, Message: "missing GARMIN TYP signature", File: filePath}
+	}
+	if r.err == nil && headerLength < binaryHeaderLength {
+		return nil, &ParseError{Offset: 0, Message: fmt.Sprintf("header length 0x%x too short", headerLength), File: filePath}
+	}
+
+	typFile := &TYPFile{
+		FilePath: filePath,
+		Binary:   true,
+	}
+
+	r.pos = 0x15
+	typFile.Header.CodePage = r.u16()
+	r.codePage = typFile.Header.CodePage
+	pointData := section{offset: r.u32(), length: r.u32()}
+	lineData := section{offset: r.u32(), length: r.u32()}
+	polygonData := section{offset: r.u32(), length: r.u32()}
+	typFile.Header.FID = r.u16()
+	typFile.Header.ProductCode = r.u16()
+	pointIndex := section{offset: r.u32(), size: r.u16(), length: r.u32()}
+	lineIndex := section{offset: r.u32(), size: r.u16(), length: r.u32()}
+	polygonIndex := section{offset: r.u32(), size: r.u16(), length: r.u32()}
+	drawOrder := section{offset: r.u32(), size: r.u16(), length: r.u32()}
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	for _, s := range []section{pointData, lineData, polygonData, pointIndex, lineIndex, polygonIndex, drawOrder} {
+		if s.offset+s.length > len(data) {
+			return nil, &ParseError{
+				Offset:  int64(s.offset),
+				Message: fmt.Sprintf("section of %d bytes extends past end of file", s.length),
+				File:    filePath,
+			}
+		}
+	}
+
+	err := r.readIndex(pointIndex, pointData, func(code TypeCode) {
+		typFile.Points = append(typFile.Points, r.readPoint(code))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = r.readIndex(lineIndex, lineData, func(code TypeCode) {
+		typFile.Lines = append(typFile.Lines, r.readLine(code))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = r.readIndex(polygonIndex, polygonData, func(code TypeCode) {
+		typFile.Polygons = append(typFile.Polygons, r.readPolygon(code))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.readDrawOrder(drawOrder, &typFile.DrawOrder); err != nil {
+		return nil, err
+	}
+
+	return typFile, nil
+}
+
+// ParseBinaryFile reads and decodes a compiled TYP file
+func ParseBinaryFile(filePath string) (*TYPFile, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	return ParseBinary(data, filePath)
+}
+
+// readIndex walks an index section and calls read with the reader positioned
+// at each type record
+func (r *binaryReader) readIndex(index, data section, read func(TypeCode)) error {
+	if index.length == 0 {
+		return nil
+	}
+	if index.size < 3 || index.size > 6 || index.length%index.size != 0 {
+		return &ParseError{
+			Offset:  int64(index.offset),
+			Message: fmt.Sprintf("invalid index record size %d", index.size),
+			File:    r.filePath,
+		}
+	}
+
+	for off := index.offset; off < index.offset+index.length; off += index.size {
+		r.pos = off
+		key := r.u16()
+		recordOffset := r.uint(index.size - 2)
+		if r.err != nil {
+			return r.err
+		}
+		if recordOffset >= data.length {
+			return &ParseError{
+				Offset:  int64(off),
+				Message: fmt.Sprintf("type record offset 0x%x outside data section", recordOffset),
+				File:    r.filePath,
+			}
+		}
+
+		code := TypeCode{
+			Type:     (key >> 5) & 0xff,
+			SubType:  key & 0x1f,
+			Extended: key&binaryExtendedFlag != 0,
+		}
+
+		r.pos = data.offset + recordOffset
+		read(code)
+		if r.err != nil {
+			return r.err
+		}
+	}
+	return nil
+}
+
+// readPoint reads a point record
+func (r *binaryReader) readPoint(code TypeCode) PointType {
+	point := PointType{
+		Type:   code.String(),
+		Labels: make(map[string]string),
+	}
+
+	flags := r.u8()
+	width := r.u8()
+	height := r.u8()
+
+	point.DayXpm = r.readBitmapIcon(width, height)
+	if flags&pointHasNight != 0 {
+		point.NightXpm = r.readBitmapIcon(width, height)
+	}
+	if flags&pointHasLabels != 0 {
+		point.Labels = r.readLabels()
+	}
+	if flags&pointHasFont != 0 {
+		point.FontStyle, point.DayFontColor, point.NightFontColor = r.readFont()
+	}
+
+	return point
+}
+
+// readBitmapIcon reads a palette and bitmap of the given size. It returns nil
+// for an empty icon.
+func (r *binaryReader) readBitmapIcon(width, height int) *XPMIcon {
+	numColors := r.u8()
+	mode := r.u8()
+	if width == 0 || height == 0 {
+		if numColors != 0 {
+			r.fail(r.pos-2, "palette of %d colors for empty bitmap", numColors)
+		}
+		return nil
+	}
+
+	colors := make([]Color, numColors)
+	for i := range colors {
+		colors[i] = r.color()
+	}
+
+	transparent := false
+	switch mode {
+	case colorModeOpaque:
+		if numColors == 0 {
+			r.fail(r.pos-2, "opaque %dx%d bitmap without colors", width, height)
+			return nil
+		}
+	case colorModeTransparent:
+		transparent = true
+	default:
+		r.fail(r.pos-3*numColors-1, "unknown color mode 0x%02x", mode)
+		return nil
+	}
+
+	indexes := numColors
+	if transparent {
+		indexes++
+	}
+	pixels := r.readBitmap(width, height, bitsPerPixel(indexes))
+	for _, p := range pixels {
+		if p >= indexes {
+			r.fail(r.pos, "pixel color index %d outside palette of %d", p, indexes)
+			return nil
+		}
+	}
+
+	return NewIndexedXPM(width, height, colors, transparent, pixels)
+}
+
+// readBitmap reads a packed bitmap. Pixels are stored least significant bit
+// first and every row is padded to a whole byte.
+func (r *binaryReader) readBitmap(width, height, bpp int) []int {
+	rowBytes := (width*bpp + 7) / 8
+	pixels := make([]int, 0, width*height)
+	mask := 1<<bpp - 1
+
+	for y := 0; y < height; y++ {
+		row := r.bytes(rowBytes)
+		if row == nil {
+			return pixels
+		}
+		for x := 0; x < width; x++ {
+			bit := x * bpp
+			pixels = append(pixels, int(row[bit/8]>>(bit%8))&mask)
+		}
+	}
+	return pixels
+}
+
+// newSolidXPM builds a "0 0 n 0" XPM holding just a list of colours
+func newSolidXPM(colors ...Color) *XPMIcon {
+	xpm := &XPMIcon{
+		Colors: len(colors),
+	}
+	for i, c := range colors {
+		xpm.Palette = append(xpm.Palette, PaletteEntry{Key: pixelKey(i, 1), Color: c})
+	}
+	return xpm
+}
+
+// pixelKey returns the palette key for the i-th decoded colour
+func pixelKey(i, cpp int) string {
+	if cpp == 1 {
+		return string(xpmChars[i])
+	}
+	return string(xpmChars[i/len(xpmChars)]) + string(xpmChars[i%len(xpmChars)])
+}
+
+// readLabels reads a label block
+func (r *binaryReader) readLabels() map[string]string {
+	labels := make(map[string]string)
+
+	start := r.pos
+	length := r.u8()
+	if length&1 != 0 {
+		length >>= 1
+	} else {
+		r.pos = start
+		length = r.u16() >> 2
+	}
+
+	end := r.pos + length
+	for r.err == nil && r.pos < end {
+		lang := r.u8()
+		var label []byte
+		for r.err == nil {
+			c := r.u8()
+			if c == 0 {
+				break
+			}
+			label = append(label, byte(c))
+		}
+		labels[fmt.Sprintf("0x%02x", lang)] = r.decodeLabel(label)
+	}
+
+	if r.err == nil && r.pos != end {
+		r.fail(start, "label block length %d does not match its contents", length)
+	}
+	return labels
+}
+
+// decodeLabel converts a label to UTF-8. Labels in a code page that cannot
+// be decoded are kept as they are.
+func (r *binaryReader) decodeLabel(label []byte) string {
+	text, err := DecodeText(string(label), r.codePage)
+	if err != nil {
+		return string(label)
+	}
+	return text
+}
+
+// readFont reads a font style byte and the optional font colours
+func (r *binaryReader) readFont() (style string, day, night Color) {
+	flags := r.u8()
+	if s := flags & 0x07; s < len(fontStyles) {
+		style = fontStyles[s]
+	} else {
+		r.fail(r.pos-1, "unknown font style %d", s)
+	}
+	if flags&fontHasDayColor != 0 {
+		day = r.color()
+		day.Day = true
+	}
+	if flags&fontHasNightColor != 0 {
+		night = r.color()
+	}
+	return style, day, night
+}
+
+// readLine reads a line record
+func (r *binaryReader) readLine(code TypeCode) LineType {
+	line := LineType{
+		Type:   code.String(),
+		Labels: make(map[string]string),
+	}
+
+	flags := r.u8()
+	flags2 := r.u8()
+	scheme := flags & 0x07
+	rows := flags >> 3
+
+	if !validScheme(scheme) {
+		r.fail(r.pos-2, "unknown line color scheme %d", scheme)
+		return line
+	}
+	dayColors, nightColors := r.readSchemeColors(scheme)
+
+	if rows == 0 {
+		// Bordered lines store the line width and the total width
+		line.LineWidth = r.u8()
+		if len(dayColors) == 2 || len(nightColors) == 2 {
+			if total := r.u8(); total > line.LineWidth {
+				line.BorderWidth = (total - line.LineWidth) / 2
+			}
+		}
+		line.DayXpm = newSolidXPM(dayColors...)
+		if nightColors != nil {
+			line.NightXpm = newSolidXPM(nightColors...)
+		}
+	} else {
+		// Bitmap lines draw set bits in the first colour and clear bits in
+		// the second one, or leave them transparent with a single colour
+		pixels := r.readBitmap(binaryPatternWidth, rows, 1)
+		line.DayXpm = newPatternXPM(binaryPatternWidth, rows, dayColors, pixels)
+		if nightColors != nil {
+			line.NightXpm = newPatternXPM(binaryPatternWidth, rows, nightColors, pixels)
+		}
+	}
+
+	line.UseOrientation = flags2&lineUseOrientation != 0
+	if flags2&lineHasLabels != 0 {
+		line.Labels = r.readLabels()
+	}
+	if flags2&lineHasFont != 0 {
+		line.FontStyle, line.DayFontColor, line.NightFontColor = r.readFont()
+	}
+
+	return line
+}
+
+// newPatternXPM builds a two colour XPM from a 1 bit pattern where set bits
+// use the foreground colour and clear bits the background colour or no
+// colour at all
+func newPatternXPM(width, height int, colors []Color, bits []int) *XPMIcon {
+	pixels := make([]int, len(bits))
+	for i, bit := range bits {
+		pixels[i] = 1 - bit
+	}
+	if len(colors) == 1 {
+		return NewIndexedXPM(width, height, colors, true, pixels)
+	}
+	return NewIndexedXPM(width, height, colors, false, pixels)
+}
+
+// readSchemeColors reads the day and night colours of a colour scheme. The
+// night colours are nil when the day colours apply at night too.
+func (r *binaryReader) readSchemeColors(scheme int) (day, night []Color) {
+	numDay, numNight := schemeColors(scheme)
+	for i := 0; i < numDay; i++ {
+		day = append(day, r.color())
+	}
+	for i := 0; i < numNight; i++ {
+		night = append(night, r.color())
+	}
+	return day, night
+}
+
+// readPolygon reads a polygon record
+func (r *binaryReader) readPolygon(code TypeCode) PolygonType {
+	polygon := PolygonType{
+		Type:   code.String(),
+		Labels: make(map[string]string),
+	}
+
+	flags := r.u8()
+	scheme := flags & 0x0f
+
+	pattern := scheme&schemePattern != 0
+	if !validScheme(scheme) || (!pattern && scheme&schemeDayTransparent == 0) {
+		r.fail(r.pos-1, "unknown polygon color scheme 0x%x", scheme)
+		return polygon
+	}
+
+	var dayColors, nightColors []Color
+	if pattern {
+		dayColors, nightColors = r.readSchemeColors(scheme)
+	} else {
+		// Solid fills have a single colour for day and night
+		dayColors = []Color{r.color()}
+		if scheme&schemeNight != 0 {
+			nightColors = []Color{r.color()}
+		}
+	}
+
+	if pattern {
+		bits := r.readBitmap(binaryPatternWidth, binaryPatternHeight, 1)
+		polygon.DayXpm = newPatternXPM(binaryPatternWidth, binaryPatternHeight, dayColors, bits)
+		if nightColors != nil {
+			polygon.NightXpm = newPatternXPM(binaryPatternWidth, binaryPatternHeight, nightColors, bits)
+		}
+	} else {
+		polygon.DayXpm = newSolidXPM(dayColors...)
+		if nightColors != nil {
+			polygon.NightXpm = newSolidXPM(nightColors...)
+		}
+	}
+
+	if flags&polygonHasLabels != 0 {
+		polygon.Labels = r.readLabels()
+	}
+	if flags&polygonHasFont != 0 {
+		polygon.FontStyle, polygon.DayFontColor, polygon.NightFontColor = r.readFont()
+	}
+
+	return polygon
+}
+
+// readDrawOrder reads the polygon draw order section
+func (r *binaryReader) readDrawOrder(s section, drawOrder *DrawOrder) error {
+	if s.length == 0 {
+		return nil
+	}
+	if s.size != binaryDrawOrderSize || s.length%s.size != 0 {
+		return &ParseError{
+			Offset:  int64(s.offset),
+			Message: fmt.Sprintf("invalid draw order record size %d", s.size),
+			File:    r.filePath,
+		}
+	}
+
+	r.pos = s.offset
+	level := 1
+	for r.pos < s.offset+s.length {
+		typ := r.u8()
+		subTypes := r.u32()
+		if typ == 0 {
+			// Level terminator
+			level++
+			continue
+		}
+		if subTypes == 0 {
+			code := TypeCode{Type: typ}
+			drawOrder.Polygons = append(drawOrder.Polygons, DrawOrderEntry{Type: code.String(), Level: level})
+			continue
+		}
+		for sub := 0; sub < 32; sub++ {
+			if subTypes&(1<<sub) != 0 {
+				code := TypeCode{Type: typ, SubType: sub, Extended: true}
+				drawOrder.Polygons = append(drawOrder.Polygons, DrawOrderEntry{Type: code.String(), Level: level})
+			}
+		}
+	}
+	return r.err
+}
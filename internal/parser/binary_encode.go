@@ -0,0 +1,740 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/dyuri/typtui/internal/atomicfile"
+)
+
+// binaryRecord is an encoded type record together with its index key
+type binaryRecord struct {
+	key  int
+	name string
+	data []byte
+}
+
+// EncodeBinary compiles a TYPFile into the binary TYP layout described in
+// binary.go. The compile date is left zero so the output only depends on the
+// input. Types are written in index (type code) order.
+func EncodeBinary(typFile *TYPFile) ([]byte, error) {
+	var points, lines, polygons []binaryRecord
+
+	for _, point := range typFile.Points {
+		rec, err := encodePoint(point, typFile.Header.CodePage)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode point type %s: %w", point.Type, err)
+		}
+		points = append(points, rec)
+	}
+
+	for _, line := range typFile.Lines {
+		rec, err := encodeLine(line, typFile.Header.CodePage)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode line type %s: %w", line.Type, err)
+		}
+		lines = append(lines, rec)
+	}
+
+	for _, polygon := range typFile.Polygons {
+		rec, err := encodePolygon(polygon, typFile.Header.CodePage)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode polygon type %s: %w", polygon.Type, err)
+		}
+		polygons = append(polygons, rec)
+	}
+
+	drawOrder, err := encodeDrawOrder(typFile.DrawOrder)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode draw order: %w", err)
+	}
+
+	pointData, pointIndex, pointSize, err := encodeSection(points)
+	if err != nil {
+		return nil, fmt.Errorf("point types: %w", err)
+	}
+	lineData, lineIndex, lineSize, err := encodeSection(lines)
+	if err != nil {
+		return nil, fmt.Errorf("line types: %w", err)
+	}
+	polygonData, polygonIndex, polygonSize, err := encodeSection(polygons)
+	if err != nil {
+		return nil, fmt.Errorf("polygon types: %w", err)
+	}
+
+	header := typFile.Header
+	if header.CodePage > 0xffff || header.FID > 0xffff || header.ProductCode > 0xffff {
+		return nil, fmt.Errorf("header values must fit in 16 bits")
+	}
+
+	var b bytes.Buffer
+	w := func(v interface{}) {
+		binary.Write(&b, binary.LittleEndian, v)
+	}
+
+	offset := binaryHeaderLength
+	place := func(data []byte) uint32 {
+		off := offset
+		offset += len(data)
+		return uint32(off)
+	}
+
+	w(uint16(binaryHeaderLength))
+	b.Write(binarySignature)
+	w(uint8(1))              // format version
+	w(uint8(0))              // not locked
+	b.Write(make([]byte, 7)) // compile date
+	w(uint16(header.CodePage))
+	w(place(pointData))
+	w(uint32(len(pointData)))
+	w(place(lineData))
+	w(uint32(len(lineData)))
+	w(place(polygonData))
+	w(uint32(len(polygonData)))
+	w(uint16(header.FID))
+	w(uint16(header.ProductCode))
+	for _, index := range []struct {
+		data []byte
+		size int
+	}{
+		{pointIndex, pointSize},
+		{lineIndex, lineSize},
+		{polygonIndex, polygonSize},
+		{drawOrder, binaryDrawOrderSize},
+	} {
+		w(place(index.data))
+		w(uint16(index.size))
+		w(uint32(len(index.data)))
+	}
+
+	for _, data := range [][]byte{pointData, lineData, polygonData, pointIndex, lineIndex, polygonIndex, drawOrder} {
+		b.Write(data)
+	}
+
+	return b.Bytes(), nil
+}
+
+// WriteBinaryFile compiles a TYPFile and writes it to disk. The file is
+// replaced atomically, as with WriteFile.
+func WriteBinaryFile(typFile *TYPFile, filePath string) error {
+	data, err := EncodeBinary(typFile)
+	if err != nil {
+		return err
+	}
+	if err := atomicfile.WriteFile(filePath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	return nil
+}
+
+// encodeSection sorts records by key and returns the data section, the index
+// section and the index record size
+func encodeSection(records []binaryRecord) (data, index []byte, size int, err error) {
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].key < records[j].key
+	})
+
+	offsets := make([]int, len(records))
+	for i, rec := range records {
+		if i > 0 && rec.key == records[i-1].key {
+			return nil, nil, 0, fmt.Errorf("duplicate type %s", rec.name)
+		}
+		offsets[i] = len(data)
+		data = append(data, rec.data...)
+	}
+
+	offsetSize := 1
+	for len(data) > 1<<(8*offsetSize) && offsetSize < 4 {
+		offsetSize++
+	}
+	size = 2 + offsetSize
+
+	for i, rec := range records {
+		index = append(index, byte(rec.key), byte(rec.key>>8))
+		for n := 0; n < offsetSize; n++ {
+			index = append(index, byte(offsets[i]>>(8*n)))
+		}
+	}
+
+	return data, index, size, nil
+}
+
+// indexKey returns the index key for a type and subtype
+func indexKey(typ, subType string) (int, error) {
+	code, err := ParseTypeCode(typ, subType)
+	if err != nil {
+		return 0, err
+	}
+	if code.Type > 0xff {
+		return 0, fmt.Errorf("type 0x%x out of range", code.Type)
+	}
+	if code.SubType > 0x1f {
+		return 0, fmt.Errorf("subtype 0x%x out of range", code.SubType)
+	}
+	key := code.Type<<5 | code.SubType
+	if code.Extended {
+		key |= binaryExtendedFlag
+	}
+	return key, nil
+}
+
+// encodePoint encodes a point record
+func encodePoint(point PointType, codePage int) (binaryRecord, error) {
+	key, err := indexKey(point.Type, point.SubType)
+	if err != nil {
+		return binaryRecord{}, err
+	}
+
+	var b bytes.Buffer
+	flags := 0
+	if point.DayXpm != nil && point.DayXpm.Width > 0 && point.DayXpm.Height > 0 {
+		flags |= pointHasBitmap
+	}
+	if point.NightXpm != nil {
+		flags |= pointHasNight
+	}
+	labels, err := encodeLabels(point.Labels, codePage)
+	if err != nil {
+		return binaryRecord{}, err
+	}
+	if labels != nil {
+		flags |= pointHasLabels
+	}
+	font, err := encodeFont(point.FontStyle,
+		fontColor(point.DayFontColor, point.DayColors), fontColor(point.NightFontColor, point.NightColors))
+	if err != nil {
+		return binaryRecord{}, err
+	}
+	if font != nil {
+		flags |= pointHasFont
+	}
+
+	width, height := 0, 0
+	if point.DayXpm != nil {
+		width, height = point.DayXpm.Width, point.DayXpm.Height
+	} else if point.NightXpm != nil {
+		return binaryRecord{}, fmt.Errorf("night icon without day icon")
+	}
+	if width > 0xff || height > 0xff {
+		return binaryRecord{}, fmt.Errorf("icon size %dx%d too large", width, height)
+	}
+	if point.NightXpm != nil && (point.NightXpm.Width != width || point.NightXpm.Height != height) {
+		return binaryRecord{}, fmt.Errorf("night icon size differs from day icon")
+	}
+
+	b.WriteByte(byte(flags))
+	b.WriteByte(byte(width))
+	b.WriteByte(byte(height))
+
+	if point.DayXpm == nil {
+		b.Write([]byte{0, colorModeOpaque})
+	} else if err := encodeBitmapIcon(&b, point.DayXpm); err != nil {
+		return binaryRecord{}, err
+	}
+	if point.NightXpm != nil {
+		if err := encodeBitmapIcon(&b, point.NightXpm); err != nil {
+			return binaryRecord{}, err
+		}
+	}
+
+	b.Write(labels)
+	b.Write(font)
+
+	return binaryRecord{key: key, name: point.Type, data: b.Bytes()}, nil
+}
+
+// encodeBitmapIcon writes the palette and bitmap of an icon
+func encodeBitmapIcon(b *bytes.Buffer, xpm *XPMIcon) error {
+	colors, transparent, pixels, err := xpmIndexes(xpm)
+	if err != nil {
+		return err
+	}
+	if xpm.Width == 0 || xpm.Height == 0 {
+		b.Write([]byte{0, colorModeOpaque})
+		return nil
+	}
+	if len(colors) > 0xff {
+		return fmt.Errorf("too many colors: %d", len(colors))
+	}
+
+	mode := colorModeOpaque
+	indexes := len(colors)
+	if transparent {
+		mode = colorModeTransparent
+		indexes++
+	}
+
+	b.WriteByte(byte(len(colors)))
+	b.WriteByte(byte(mode))
+	for _, c := range colors {
+		if err := writeColor(b, c); err != nil {
+			return err
+		}
+	}
+	writeBitmap(b, xpm.Width, xpm.Height, bitsPerPixel(indexes), pixels)
+	return nil
+}
+
+// xpmIndexes resolves XPM pixels into palette indexes. Opaque colours are
+// numbered in palette order, the transparent colour (if any) comes last.
+func xpmIndexes(xpm *XPMIcon) (colors []Color, transparent bool, pixels []int, err error) {
+	index := make(map[string]int)
+	var transparentKeys []string
+	for _, entry := range xpm.Palette {
+		if isTransparent(entry.Color) {
+			transparentKeys = append(transparentKeys, entry.Key)
+			continue
+		}
+		index[entry.Key] = len(colors)
+		colors = append(colors, entry.Color)
+	}
+	for _, key := range transparentKeys {
+		index[key] = len(colors)
+		transparent = true
+	}
+
+	if xpm.Width == 0 || xpm.Height == 0 {
+		return colors, transparent, nil, nil
+	}
+
+	cpp := xpm.CharsPerPixel
+	if cpp < 1 {
+		return nil, false, nil, fmt.Errorf("invalid chars per pixel %d", cpp)
+	}
+	if len(xpm.Data) != xpm.Height {
+		return nil, false, nil, fmt.Errorf("expected %d pixel rows, got %d", xpm.Height, len(xpm.Data))
+	}
+
+	for y, row := range xpm.Data {
+		if len(row) != xpm.Width*cpp {
+			return nil, false, nil, fmt.Errorf("row %d: expected %d characters, got %d", y+1, xpm.Width*cpp, len(row))
+		}
+		for x := 0; x < len(row); x += cpp {
+			i, ok := index[row[x:x+cpp]]
+			if !ok {
+				return nil, false, nil, fmt.Errorf("row %d: pixel %q not in palette", y+1, row[x:x+cpp])
+			}
+			pixels = append(pixels, i)
+		}
+	}
+
+	return colors, transparent, pixels, nil
+}
+
+// writeBitmap packs pixels least significant bit first, padding every row
+// to a whole byte
+func writeBitmap(b *bytes.Buffer, width, height, bpp int, pixels []int) {
+	rowBytes := (width*bpp + 7) / 8
+	for y := 0; y < height; y++ {
+		row := make([]byte, rowBytes)
+		for x := 0; x < width; x++ {
+			bit := x * bpp
+			row[bit/8] |= byte(pixels[y*width+x] << (bit % 8))
+		}
+		b.Write(row)
+	}
+}
+
+// writeColor writes a colour as a BGR triple
+func writeColor(b *bytes.Buffer, c Color) error {
+	r, g, bl, err := parseHexColor(c.Hex)
+	if err != nil {
+		return err
+	}
+	b.Write([]byte{bl, g, r})
+	return nil
+}
+
+// encodeLabels encodes a label block in a code page, or returns nil when
+// there are no labels
+func encodeLabels(labels map[string]string, codePage int) ([]byte, error) {
+	type entry struct {
+		lang  int
+		label string
+	}
+	var entries []entry
+	for code, label := range labels {
+		if label == "" {
+			continue
+		}
+		lang, err := parseNumber(code)
+		if err != nil || lang > 0xff {
+			return nil, fmt.Errorf("invalid language code %q", code)
+		}
+		if strings.IndexByte(label, 0) >= 0 {
+			return nil, fmt.Errorf("label %q contains a NUL byte", label)
+		}
+		if SupportedCodePage(codePage) {
+			if label, err = EncodeText(label, codePage); err != nil {
+				return nil, fmt.Errorf("label %s: %w", code, err)
+			}
+		}
+		entries = append(entries, entry{lang, label})
+	}
+	if len(entries) == 0 {
+		return nil, nil
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].lang < entries[j].lang
+	})
+
+	var body []byte
+	for _, e := range entries {
+		body = append(body, byte(e.lang))
+		body = append(body, e.label...)
+		body = append(body, 0)
+	}
+
+	// The length is stored as 2n+1 in one byte, or as 4n+2 in two bytes
+	switch n := len(body); {
+	case n < 0x40:
+		return append([]byte{byte(n<<1 | 1)}, body...), nil
+	case n < 0x4000:
+		v := n<<2 | 2
+		return append([]byte{byte(v), byte(v >> 8)}, body...), nil
+	default:
+		return nil, fmt.Errorf("labels too long: %d bytes", n)
+	}
+}
+
+// encodeFont encodes the font style and colours, or returns nil when the
+// defaults apply
+func encodeFont(style string, day, night Color) ([]byte, error) {
+	if style == "" && day.Hex == "" && night.Hex == "" {
+		return nil, nil
+	}
+
+	flags := -1
+	for i, name := range fontStyles {
+		if strings.EqualFold(style, name) {
+			flags = i
+		}
+	}
+	if strings.EqualFold(style, "Default") {
+		flags = 0
+	}
+	if flags < 0 {
+		return nil, fmt.Errorf("unknown font style %q", style)
+	}
+
+	var b bytes.Buffer
+	if day.Hex != "" {
+		flags |= fontHasDayColor
+	}
+	if night.Hex != "" {
+		flags |= fontHasNightColor
+	}
+	b.WriteByte(byte(flags))
+	if day.Hex != "" {
+		if err := writeColor(&b, day); err != nil {
+			return nil, err
+		}
+	}
+	if night.Hex != "" {
+		if err := writeColor(&b, night); err != nil {
+			return nil, err
+		}
+	}
+	return b.Bytes(), nil
+}
+
+// fontColor picks the label colour of a type: the font colour when it is
+// set, else the first custom colour
+func fontColor(font Color, custom []Color) Color {
+	if font.Hex == "" && len(custom) > 0 {
+		return custom[0]
+	}
+	return font
+}
+
+// solidColors returns the colours of a "0 0 n 0" XPM
+func solidColors(xpm *XPMIcon) ([]Color, error) {
+	colors, transparent, _, err := xpmIndexes(xpm)
+	if err != nil {
+		return nil, err
+	}
+	if transparent {
+		return nil, fmt.Errorf("solid colors cannot be transparent")
+	}
+	return colors, nil
+}
+
+// patternBits converts a two colour pattern into bits, set for the
+// foreground colour. A single colour pattern has a transparent background.
+func patternBits(xpm *XPMIcon, width, height int) (colors []Color, bits []int, err error) {
+	if xpm.Width != width || xpm.Height != height {
+		return nil, nil, fmt.Errorf("pattern must be %dx%d, got %dx%d", width, height, xpm.Width, xpm.Height)
+	}
+	colors, transparent, pixels, err := xpmIndexes(xpm)
+	if err != nil {
+		return nil, nil, err
+	}
+	switch {
+	case len(colors) == 2 && !transparent:
+	case len(colors) == 1 && transparent:
+	default:
+		return nil, nil, fmt.Errorf("pattern needs two colors or one color and none")
+	}
+
+	bits = make([]int, len(pixels))
+	for i, p := range pixels {
+		if p == 0 {
+			bits[i] = 1
+		}
+	}
+	return colors, bits, nil
+}
+
+// nightPatternColors returns the colours of a night pattern, which must set
+// the same bits as the day pattern. Pixels are matched by colour, so the
+// night palette may list its two colours in either order.
+func nightPatternColors(xpm *XPMIcon, width, height int, dayBits []int) ([]Color, error) {
+	colors, bits, err := patternBits(xpm, width, height)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case equalBits(bits, dayBits):
+		return colors, nil
+	case len(colors) == 2 && invertedBits(bits, dayBits):
+		return []Color{colors[1], colors[0]}, nil
+	default:
+		return nil, fmt.Errorf("night pattern differs from day pattern")
+	}
+}
+
+// equalBits reports whether two patterns are identical
+func equalBits(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// invertedBits reports whether one pattern is the inverse of the other
+func invertedBits(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] == b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// encodeLine encodes a line record
+func encodeLine(line LineType, codePage int) (binaryRecord, error) {
+	key, err := indexKey(line.Type, "")
+	if err != nil {
+		return binaryRecord{}, err
+	}
+	if line.DayXpm == nil {
+		return binaryRecord{}, fmt.Errorf("line has no colors")
+	}
+
+	var day, night []Color
+	var bits []int
+	rows := line.DayXpm.Height
+	if line.DayXpm.Width == 0 {
+		rows = 0
+		if day, err = solidColors(line.DayXpm); err != nil {
+			return binaryRecord{}, err
+		}
+		if len(day) < 1 || len(day) > 2 {
+			return binaryRecord{}, fmt.Errorf("solid line needs one or two colors")
+		}
+		if line.NightXpm != nil {
+			if night, err = solidColors(line.NightXpm); err != nil {
+				return binaryRecord{}, err
+			}
+			if len(night) < 1 || len(night) > 2 {
+				return binaryRecord{}, fmt.Errorf("solid line needs one or two night colors")
+			}
+		}
+	} else {
+		if rows < 1 || rows > 31 {
+			return binaryRecord{}, fmt.Errorf("line pattern height %d out of range", rows)
+		}
+		if day, bits, err = patternBits(line.DayXpm, binaryPatternWidth, rows); err != nil {
+			return binaryRecord{}, err
+		}
+		if line.NightXpm != nil {
+			if night, err = nightPatternColors(line.NightXpm, binaryPatternWidth, rows, bits); err != nil {
+				return binaryRecord{}, err
+			}
+		}
+	}
+
+	scheme := colorScheme(len(day), len(night))
+
+	flags2 := 0
+	if line.UseOrientation {
+		flags2 |= lineUseOrientation
+	}
+	labels, err := encodeLabels(line.Labels, codePage)
+	if err != nil {
+		return binaryRecord{}, err
+	}
+	if labels != nil {
+		flags2 |= lineHasLabels
+	}
+	font, err := encodeFont(line.FontStyle,
+		fontColor(line.DayFontColor, line.DayColors), fontColor(line.NightFontColor, line.NightColors))
+	if err != nil {
+		return binaryRecord{}, err
+	}
+	if font != nil {
+		flags2 |= lineHasFont
+	}
+
+	var b bytes.Buffer
+	b.WriteByte(byte(rows<<3 | scheme))
+	b.WriteByte(byte(flags2))
+	for _, c := range append(day, night...) {
+		if err := writeColor(&b, c); err != nil {
+			return binaryRecord{}, err
+		}
+	}
+	if rows == 0 {
+		// Bordered lines store the line width and the total width. The
+		// format has no night widths, NightLineWidth is text-only.
+		total := line.LineWidth + 2*line.BorderWidth
+		if line.LineWidth > 0xff || total > 0xff {
+			return binaryRecord{}, fmt.Errorf("line width out of range")
+		}
+		b.WriteByte(byte(line.LineWidth))
+		if len(day) == 2 || len(night) == 2 {
+			b.WriteByte(byte(total))
+		}
+	} else {
+		writeBitmap(&b, binaryPatternWidth, rows, 1, bits)
+	}
+	b.Write(labels)
+	b.Write(font)
+
+	return binaryRecord{key: key, name: line.Type, data: b.Bytes()}, nil
+}
+
+// encodePolygon encodes a polygon record
+func encodePolygon(polygon PolygonType, codePage int) (binaryRecord, error) {
+	key, err := indexKey(polygon.Type, "")
+	if err != nil {
+		return binaryRecord{}, err
+	}
+	if polygon.DayXpm == nil {
+		return binaryRecord{}, fmt.Errorf("polygon has no colors")
+	}
+
+	var day, night []Color
+	var bits []int
+	var scheme int
+	if polygon.DayXpm.Width == 0 {
+		if day, err = solidColors(polygon.DayXpm); err != nil {
+			return binaryRecord{}, err
+		}
+		if len(day) != 1 {
+			return binaryRecord{}, fmt.Errorf("solid polygon needs exactly one color")
+		}
+		scheme = schemeDayTransparent | schemeNightTransparent
+		if polygon.NightXpm != nil {
+			if night, err = solidColors(polygon.NightXpm); err != nil {
+				return binaryRecord{}, err
+			}
+			if len(night) != 1 {
+				return binaryRecord{}, fmt.Errorf("solid polygon needs exactly one night color")
+			}
+			scheme |= schemeNight
+		}
+	} else {
+		if day, bits, err = patternBits(polygon.DayXpm, binaryPatternWidth, binaryPatternHeight); err != nil {
+			return binaryRecord{}, err
+		}
+		if polygon.NightXpm != nil {
+			if night, err = nightPatternColors(polygon.NightXpm, binaryPatternWidth, binaryPatternHeight, bits); err != nil {
+				return binaryRecord{}, err
+			}
+		}
+		scheme = schemePattern | colorScheme(len(day), len(night))
+	}
+
+	flags := scheme
+	labels, err := encodeLabels(polygon.Labels, codePage)
+	if err != nil {
+		return binaryRecord{}, err
+	}
+	if labels != nil {
+		flags |= polygonHasLabels
+	}
+	font, err := encodeFont(polygon.FontStyle,
+		fontColor(polygon.DayFontColor, polygon.DayColors), fontColor(polygon.NightFontColor, polygon.NightColors))
+	if err != nil {
+		return binaryRecord{}, err
+	}
+	if font != nil {
+		flags |= polygonHasFont
+	}
+
+	var b bytes.Buffer
+	b.WriteByte(byte(flags))
+	for _, c := range append(day, night...) {
+		if err := writeColor(&b, c); err != nil {
+			return binaryRecord{}, err
+		}
+	}
+	if bits != nil {
+		writeBitmap(&b, binaryPatternWidth, binaryPatternHeight, 1, bits)
+	}
+	b.Write(labels)
+	b.Write(font)
+
+	return binaryRecord{key: key, name: polygon.Type, data: b.Bytes()}, nil
+}
+
+// encodeDrawOrder encodes the polygon draw order. Each level lists its
+// types, with the subtypes of an extended type combined into one mask, and
+// ends with a zero record. Empty levels keep their terminator so the level
+// numbers survive.
+func encodeDrawOrder(drawOrder DrawOrder) ([]byte, error) {
+	var b []byte
+	for _, entries := range drawOrder.Levels() {
+		type drawType struct {
+			typ      int
+			extended bool
+		}
+		var types []drawType
+		masks := make(map[drawType]uint32)
+		for _, entry := range entries {
+			code, err := ParseTypeCode(entry.Type, "")
+			if err != nil {
+				return nil, err
+			}
+			if code.Type > 0xff || code.SubType > 0x1f {
+				return nil, fmt.Errorf("type %s out of range", entry.Type)
+			}
+			t := drawType{code.Type, code.Extended}
+			if _, seen := masks[t]; !seen {
+				types = append(types, t)
+				masks[t] = 0
+			}
+			if code.Extended {
+				masks[t] |= 1 << code.SubType
+			}
+		}
+		for _, t := range types {
+			b = append(b, byte(t.typ))
+			b = binary.LittleEndian.AppendUint32(b, masks[t])
+		}
+		b = append(b, make([]byte, binaryDrawOrderSize)...)
+	}
+	return b, nil
+}
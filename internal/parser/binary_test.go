@@ -0,0 +1,626 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite golden files in testdata")
+
+// xpmPixels resolves an icon into colour values, one row per pixel row. Solid
+// color XPMs are returned as a single row of palette colours.
+func xpmPixels(xpm *XPMIcon) [][]string {
+	if xpm == nil {
+		return nil
+	}
+	color := func(key string) string {
+		c, ok := xpm.Palette.Lookup(key)
+		if !ok {
+			return "?" + key
+		}
+		if isTransparent(c) {
+			return "none"
+		}
+		return strings.ToUpper(c.Hex)
+	}
+
+	if xpm.Width == 0 {
+		var row []string
+		for _, key := range xpm.Palette.Keys() {
+			row = append(row, color(key))
+		}
+		return [][]string{row}
+	}
+
+	var rows [][]string
+	for _, data := range xpm.Data {
+		var row []string
+		for x := 0; x+xpm.CharsPerPixel <= len(data); x += xpm.CharsPerPixel {
+			row = append(row, color(data[x:x+xpm.CharsPerPixel]))
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+func equalPixels(a, b *XPMIcon) bool {
+	pa, pb := xpmPixels(a), xpmPixels(b)
+	if len(pa) != len(pb) {
+		return false
+	}
+	for y := range pa {
+		if strings.Join(pa[y], ",") != strings.Join(pb[y], ",") {
+			return false
+		}
+	}
+	return true
+}
+
+func equalLabels(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for code, label := range a {
+		if b[code] != label {
+			return false
+		}
+	}
+	return true
+}
+
+// readHexFixture reads an annotated hex dump: hex byte pairs with ';'
+// comments
+func readHexFixture(t *testing.T, path string) []byte {
+	t.Helper()
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read %s: %v", path, err)
+	}
+	var data []byte
+	for i, line := range strings.Split(string(content), "\n") {
+		if idx := strings.Index(line, ";"); idx >= 0 {
+			line = line[:idx]
+		}
+		b, err := hex.DecodeString(strings.Join(strings.Fields(line), ""))
+		if err != nil {
+			t.Fatalf("%s:%d: %v", path, i+1, err)
+		}
+		data = append(data, b...)
+	}
+	return data
+}
+
+// compareDecoded checks a decoded binary TYP file against its text source
+func compareDecoded(t *testing.T, compiled, text *TYPFile) {
+	t.Helper()
+
+	if compiled.Header.CodePage != text.Header.CodePage ||
+		compiled.Header.FID != text.Header.FID ||
+		compiled.Header.ProductCode != text.Header.ProductCode {
+		t.Errorf("Header mismatch: got %+v, want %+v", compiled.Header, text.Header)
+	}
+
+	if len(compiled.Points) != len(text.Points) {
+		t.Fatalf("Expected %d points, got %d", len(text.Points), len(compiled.Points))
+	}
+	for i, want := range text.Points {
+		got := compiled.Points[i]
+		if got.Type != want.Type {
+			t.Errorf("Point %d: expected type %s, got %s", i, want.Type, got.Type)
+		}
+		if !equalLabels(got.Labels, want.Labels) {
+			t.Errorf("Point %s: expected labels %v, got %v", want.Type, want.Labels, got.Labels)
+		}
+		if got.FontStyle != want.FontStyle {
+			t.Errorf("Point %s: expected font style %q, got %q", want.Type, want.FontStyle, got.FontStyle)
+		}
+		if !equalFontColors(got.DayFontColor, got.NightFontColor, want.DayFontColor, want.NightFontColor) {
+			t.Errorf("Point %s: expected font colours %q/%q, got %q/%q", want.Type,
+				want.DayFontColor.Hex, want.NightFontColor.Hex, got.DayFontColor.Hex, got.NightFontColor.Hex)
+		}
+		if !equalPixels(got.DayXpm, want.DayXpm) {
+			t.Errorf("Point %s: day icon mismatch:\n%v\n%v", want.Type, xpmPixels(got.DayXpm), xpmPixels(want.DayXpm))
+		}
+		if !equalPixels(got.NightXpm, want.NightXpm) {
+			t.Errorf("Point %s: night icon mismatch:\n%v\n%v", want.Type, xpmPixels(got.NightXpm), xpmPixels(want.NightXpm))
+		}
+	}
+
+	if len(compiled.Lines) != len(text.Lines) {
+		t.Fatalf("Expected %d lines, got %d", len(text.Lines), len(compiled.Lines))
+	}
+	for i, want := range text.Lines {
+		got := compiled.Lines[i]
+		if got.Type != want.Type {
+			t.Errorf("Line %d: expected type %s, got %s", i, want.Type, got.Type)
+		}
+		if !equalLabels(got.Labels, want.Labels) {
+			t.Errorf("Line %s: expected labels %v, got %v", want.Type, want.Labels, got.Labels)
+		}
+		if got.LineWidth != want.LineWidth || got.BorderWidth != want.BorderWidth {
+			t.Errorf("Line %s: expected widths %d/%d, got %d/%d", want.Type,
+				want.LineWidth, want.BorderWidth, got.LineWidth, got.BorderWidth)
+		}
+		if got.UseOrientation != want.UseOrientation {
+			t.Errorf("Line %s: expected UseOrientation %v", want.Type, want.UseOrientation)
+		}
+		if got.FontStyle != want.FontStyle {
+			t.Errorf("Line %s: expected font style %q, got %q", want.Type, want.FontStyle, got.FontStyle)
+		}
+		if !equalFontColors(got.DayFontColor, got.NightFontColor, want.DayFontColor, want.NightFontColor) {
+			t.Errorf("Line %s: expected font colours %q/%q, got %q/%q", want.Type,
+				want.DayFontColor.Hex, want.NightFontColor.Hex, got.DayFontColor.Hex, got.NightFontColor.Hex)
+		}
+		if !equalPixels(got.DayXpm, want.DayXpm) {
+			t.Errorf("Line %s: pattern mismatch:\n%v\n%v", want.Type, xpmPixels(got.DayXpm), xpmPixels(want.DayXpm))
+		}
+	}
+
+	if len(compiled.Polygons) != len(text.Polygons) {
+		t.Fatalf("Expected %d polygons, got %d", len(text.Polygons), len(compiled.Polygons))
+	}
+	for i, want := range text.Polygons {
+		got := compiled.Polygons[i]
+		if got.Type != want.Type {
+			t.Errorf("Polygon %d: expected type %s, got %s", i, want.Type, got.Type)
+		}
+		if !equalLabels(got.Labels, want.Labels) {
+			t.Errorf("Polygon %s: expected labels %v, got %v", want.Type, want.Labels, got.Labels)
+		}
+		if got.FontStyle != want.FontStyle {
+			t.Errorf("Polygon %s: expected font style %q, got %q", want.Type, want.FontStyle, got.FontStyle)
+		}
+		if !equalFontColors(got.DayFontColor, got.NightFontColor, want.DayFontColor, want.NightFontColor) {
+			t.Errorf("Polygon %s: expected font colours %q/%q, got %q/%q", want.Type,
+				want.DayFontColor.Hex, want.NightFontColor.Hex, got.DayFontColor.Hex, got.NightFontColor.Hex)
+		}
+		if !equalPixels(got.DayXpm, want.DayXpm) {
+			t.Errorf("Polygon %s: pattern mismatch", want.Type)
+		}
+	}
+
+	if !reflect.DeepEqual(compiled.DrawOrder.Polygons, text.DrawOrder.Polygons) {
+		t.Errorf("Expected draw order %v, got %v", text.DrawOrder.Polygons, compiled.DrawOrder.Polygons)
+	}
+}
+
+// equalFontColors compares day and night font colours, ignoring hex case
+func equalFontColors(gotDay, gotNight, wantDay, wantNight Color) bool {
+	return strings.EqualFold(gotDay.Hex, wantDay.Hex) && strings.EqualFold(gotNight.Hex, wantNight.Hex)
+}
+
+func TestParseBinaryMatchesText(t *testing.T) {
+	text, err := ParseFile("../../testdata/binary/styles.txt")
+	if err != nil {
+		t.Fatalf("Failed to parse styles.txt: %v", err)
+	}
+	compiled, err := ParseFile("../../testdata/binary/styles.typ")
+	if err != nil {
+		t.Fatalf("Failed to parse styles.typ: %v", err)
+	}
+
+	if !compiled.Binary {
+		t.Error("Expected compiled file to be detected as binary")
+	}
+	if text.Binary {
+		t.Error("Expected text file not to be detected as binary")
+	}
+
+	compareDecoded(t, compiled, text)
+}
+
+func TestParseBinaryReference(t *testing.T) {
+	text, err := ParseFile("../../testdata/binary/reference.txt")
+	if err != nil {
+		t.Fatalf("Failed to parse reference.txt: %v", err)
+	}
+	compiled, err := ParseBinary(readHexFixture(t, "../../testdata/binary/reference.hex"), "reference.typ")
+	if err != nil {
+		t.Fatalf("Failed to decode reference.hex: %v", err)
+	}
+
+	compareDecoded(t, compiled, text)
+}
+
+func TestParseBinaryLineSchemes(t *testing.T) {
+	red, black, blue := "00 00 ff", "00 00 00", "ff 00 00"
+
+	tests := []struct {
+		name          string
+		record        string
+		day, night    []string
+		width, border int
+	}{
+		{"line and border", "00 00" + red + black + "04 08", []string{"#FF0000", "#000000"}, nil, 4, 2},
+		{"with night", "01 00" + red + black + blue + black + "04 06",
+			[]string{"#FF0000", "#000000"}, []string{"#0000FF", "#000000"}, 4, 1},
+		{"night border only", "03 00" + red + blue + black + "02 04",
+			[]string{"#FF0000"}, []string{"#0000FF", "#000000"}, 2, 1},
+		{"day border only", "05 00" + red + black + blue + "03 05",
+			[]string{"#FF0000", "#000000"}, []string{"#0000FF"}, 3, 1},
+		{"single", "06 00" + red + "05", []string{"#FF0000"}, nil, 5, 0},
+		{"single with night", "07 00" + red + blue + "05", []string{"#FF0000"}, []string{"#0000FF"}, 5, 0},
+	}
+
+	colors := func(xpm *XPMIcon) []string {
+		if xpm == nil {
+			return nil
+		}
+		return xpmPixels(xpm)[0]
+	}
+
+	for _, tt := range tests {
+		data, err := hex.DecodeString(strings.ReplaceAll(tt.record, " ", ""))
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		r := &binaryReader{data: data}
+		line := r.readLine(TypeCode{Type: 0x01})
+		if r.err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.name, r.err)
+			continue
+		}
+		if r.pos != len(data) {
+			t.Errorf("%s: read %d of %d bytes", tt.name, r.pos, len(data))
+		}
+		if strings.Join(colors(line.DayXpm), ",") != strings.Join(tt.day, ",") {
+			t.Errorf("%s: expected day colors %v, got %v", tt.name, tt.day, colors(line.DayXpm))
+		}
+		if strings.Join(colors(line.NightXpm), ",") != strings.Join(tt.night, ",") {
+			t.Errorf("%s: expected night colors %v, got %v", tt.name, tt.night, colors(line.NightXpm))
+		}
+		if line.LineWidth != tt.width || line.BorderWidth != tt.border {
+			t.Errorf("%s: expected widths %d/%d, got %d/%d", tt.name, tt.width, tt.border, line.LineWidth, line.BorderWidth)
+		}
+	}
+}
+
+func TestParseBinaryErrors(t *testing.T) {
+	data, err := os.ReadFile("../../testdata/binary/styles.typ")
+	if err != nil {
+		t.Fatalf("Failed to read styles.typ: %v", err)
+	}
+
+	// Corrupt the colour scheme of the first line record
+	lineData := int(binary.LittleEndian.Uint32(data[0x1f:]))
+	badScheme := append([]byte(nil), data...)
+	badScheme[lineData] = 0x02
+
+	tests := []struct {
+		name       string
+		data       []byte
+		wantOffset int64
+	}{
+		{"bad signature", append([]byte{0x5b, 0x00}, []byte("GARMIN XYZ")...), 2},
+		{"truncated header", data[:0x30], 0x2f},
+		{"bad line scheme", badScheme, int64(lineData)},
+	}
+
+	for _, tt := range tests {
+		_, err := ParseBinary(tt.data, "test.typ")
+		var parseErr *ParseError
+		if !errors.As(err, &parseErr) {
+			t.Errorf("%s: expected ParseError, got %v", tt.name, err)
+			continue
+		}
+		if parseErr.Offset != tt.wantOffset {
+			t.Errorf("%s: expected offset 0x%x, got 0x%x (%v)", tt.name, tt.wantOffset, parseErr.Offset, err)
+		}
+		if !strings.Contains(err.Error(), "test.typ: offset 0x") {
+			t.Errorf("%s: expected error to name file and offset, got %q", tt.name, err.Error())
+		}
+	}
+}
+
+func TestParseBinaryCorrupt(t *testing.T) {
+	data, err := os.ReadFile("../../testdata/binary/styles.typ")
+	if err != nil {
+		t.Fatalf("Failed to read styles.typ: %v", err)
+	}
+
+	parse := func(name string, data []byte) {
+		defer func() {
+			if r := recover(); r != nil {
+				t.Errorf("%s: ParseBinary panicked: %v", name, r)
+			}
+		}()
+		ParseBinary(data, "test.typ")
+	}
+
+	for n := range len(data) {
+		parse(fmt.Sprintf("truncated to %d bytes", n), data[:n])
+	}
+	for i := range data {
+		for _, b := range []byte{0x00, 0x01, 0x7f, 0xff, data[i] ^ 0x80} {
+			mutated := append([]byte(nil), data...)
+			mutated[i] = b
+			parse(fmt.Sprintf("byte 0x%x set to 0x%02x", i, b), mutated)
+		}
+	}
+}
+
+func TestParseBinaryOpaqueBitmapWithoutColors(t *testing.T) {
+	// A 2x2 opaque bitmap with an empty palette has no bits per pixel
+	r := &binaryReader{data: []byte{0x00, colorModeOpaque, 0x00, 0x00}}
+	if xpm := r.readBitmapIcon(2, 2); xpm != nil {
+		t.Errorf("Expected no icon, got %v", xpmPixels(xpm))
+	}
+	var parseErr *ParseError
+	if !errors.As(r.err, &parseErr) || parseErr.Offset != 0 {
+		t.Errorf("Expected ParseError at offset 0, got %v", r.err)
+	}
+}
+
+func TestParseTypeCode(t *testing.T) {
+	tests := []struct {
+		typ, subType string
+		want         TypeCode
+		wantString   string
+	}{
+		{"0x2f06", "", TypeCode{Type: 0x2f, SubType: 0x06}, "0x2f06"},
+		{"0x2f", "0x06", TypeCode{Type: 0x2f, SubType: 0x06}, "0x2f06"},
+		{"0x13", "", TypeCode{Type: 0x13}, "0x13"},
+		{"0x10f04", "", TypeCode{Type: 0x0f, SubType: 0x04, Extended: true}, "0x10f04"},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseTypeCode(tt.typ, tt.subType)
+		if err != nil {
+			t.Errorf("ParseTypeCode(%q, %q) error: %v", tt.typ, tt.subType, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseTypeCode(%q, %q) = %+v, want %+v", tt.typ, tt.subType, got, tt.want)
+		}
+		if got.String() != tt.wantString {
+			t.Errorf("String() = %q, want %q", got.String(), tt.wantString)
+		}
+	}
+
+	if _, err := ParseTypeCode("bank", ""); err == nil {
+		t.Error("Expected error for invalid type code")
+	}
+}
+
+func TestEncodeBinaryGolden(t *testing.T) {
+	for _, name := range []string{"minimal", "styles"} {
+		source := filepath.Join("../../testdata/binary", name+".txt")
+		golden := filepath.Join("../../testdata/binary", name+".typ")
+
+		typFile, err := ParseFile(source)
+		if err != nil {
+			t.Fatalf("Failed to parse %s: %v", source, err)
+		}
+		got, err := EncodeBinary(typFile)
+		if err != nil {
+			t.Fatalf("Failed to encode %s: %v", source, err)
+		}
+
+		if *update {
+			if err := os.WriteFile(golden, got, 0644); err != nil {
+				t.Fatalf("Failed to update %s: %v", golden, err)
+			}
+		}
+
+		want, err := os.ReadFile(golden)
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", golden, err)
+		}
+		compareEncoded(t, name, got, want)
+	}
+}
+
+func TestEncodeBinaryReference(t *testing.T) {
+	typFile, err := ParseFile("../../testdata/binary/reference.txt")
+	if err != nil {
+		t.Fatalf("Failed to parse reference.txt: %v", err)
+	}
+	got, err := EncodeBinary(typFile)
+	if err != nil {
+		t.Fatalf("Failed to encode reference.txt: %v", err)
+	}
+	want := readHexFixture(t, "../../testdata/binary/reference.hex")
+
+	// The encoder leaves the compile date zero
+	if len(want) > 0x15 {
+		copy(want[0x0e:0x15], make([]byte, 7))
+	}
+
+	compareEncoded(t, "reference", got, want)
+}
+
+// TestMkgmapFixtures checks the decoder and the encoder against files
+// compiled by mkgmap: each testdata/binary/mkgmap/NAME.typ is decoded and
+// compared with NAME.txt, and NAME.txt must encode to the same bytes.
+func TestMkgmapFixtures(t *testing.T) {
+	compiled, err := filepath.Glob("../../testdata/binary/mkgmap/*.typ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(compiled) == 0 {
+		t.Skip("no files compiled by mkgmap in testdata/binary/mkgmap")
+	}
+
+	for _, path := range compiled {
+		name := strings.TrimSuffix(filepath.Base(path), ".typ")
+		text, err := ParseFile(strings.TrimSuffix(path, ".typ") + ".txt")
+		if err != nil {
+			t.Fatalf("Failed to parse the source of %s: %v", path, err)
+		}
+		decoded, err := ParseFile(path)
+		if err != nil {
+			t.Fatalf("Failed to decode %s: %v", path, err)
+		}
+		compareDecoded(t, decoded, text)
+
+		got, err := EncodeBinary(text)
+		if err != nil {
+			t.Fatalf("Failed to encode the source of %s: %v", path, err)
+		}
+		want, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", path, err)
+		}
+		// The encoder leaves the compile date zero
+		if len(want) > 0x15 {
+			copy(want[0x0e:0x15], make([]byte, 7))
+		}
+		compareEncoded(t, name, got, want)
+	}
+}
+
+// compareEncoded reports the first byte where an encoded file differs from
+// the expected one
+func compareEncoded(t *testing.T, name string, got, want []byte) {
+	t.Helper()
+
+	if bytes.Equal(got, want) {
+		return
+	}
+	for i := 0; i < len(got) && i < len(want); i++ {
+		if got[i] != want[i] {
+			t.Errorf("%s: first difference at offset 0x%x: got 0x%02x, want 0x%02x", name, i, got[i], want[i])
+			break
+		}
+	}
+	t.Errorf("%s: encoded %d bytes, expected %d", name, len(got), len(want))
+}
+
+func TestEncodeBinaryNightPatternOrder(t *testing.T) {
+	pattern := func(fg, bg string, palette Palette) *XPMIcon {
+		row := strings.Repeat(fg+bg, binaryPatternWidth/2)
+		data := make([]string, binaryPatternHeight)
+		for i := range data {
+			data[i] = row
+		}
+		return &XPMIcon{
+			Width:         binaryPatternWidth,
+			Height:        binaryPatternHeight,
+			Colors:        2,
+			CharsPerPixel: 1,
+			Data:          data,
+			Palette:       palette,
+		}
+	}
+
+	// The night palette lists its colours the other way round than the day one
+	typFile := &TYPFile{Polygons: []PolygonType{{
+		Type:     "0x13",
+		DayXpm:   pattern("a", "b", Palette{{Key: "a", Color: Color{Hex: "#FF0000"}}, {Key: "b", Color: Color{Hex: "#00FF00"}}}),
+		NightXpm: pattern("y", "x", Palette{{Key: "x", Color: Color{Hex: "#008000"}}, {Key: "y", Color: Color{Hex: "#800000"}}}),
+	}}}
+
+	data, err := EncodeBinary(typFile)
+	if err != nil {
+		t.Fatalf("Failed to encode night pattern: %v", err)
+	}
+	decoded, err := ParseBinary(data, "night.typ")
+	if err != nil {
+		t.Fatalf("Failed to decode night pattern: %v", err)
+	}
+	polygon := decoded.Polygons[0]
+	if !equalPixels(polygon.DayXpm, typFile.Polygons[0].DayXpm) {
+		t.Errorf("Day pattern changed:\n%v", xpmPixels(polygon.DayXpm))
+	}
+	if !equalPixels(polygon.NightXpm, typFile.Polygons[0].NightXpm) {
+		t.Errorf("Night pattern changed:\n%v", xpmPixels(polygon.NightXpm))
+	}
+}
+
+func TestEncodeBinaryRoundTrip(t *testing.T) {
+	want, err := os.ReadFile("../../testdata/binary/styles.typ")
+	if err != nil {
+		t.Fatalf("Failed to read styles.typ: %v", err)
+	}
+
+	typFile, err := ParseBinary(want, "styles.typ")
+	if err != nil {
+		t.Fatalf("Failed to decode styles.typ: %v", err)
+	}
+	got, err := EncodeBinary(typFile)
+	if err != nil {
+		t.Fatalf("Failed to encode decoded file: %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("Re-encoding changed the file: %d bytes, want %d", len(got), len(want))
+	}
+
+	tempFile := filepath.Join(t.TempDir(), "styles.typ")
+	if err := WriteBinaryFile(typFile, tempFile); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	reloaded, err := ParseFile(tempFile)
+	if err != nil {
+		t.Fatalf("Failed to reload file: %v", err)
+	}
+	if !reloaded.Binary || len(reloaded.Polygons) != len(typFile.Polygons) {
+		t.Errorf("Reloaded file does not match: binary=%v, %d polygons", reloaded.Binary, len(reloaded.Polygons))
+	}
+}
+
+func TestEncodeBinaryErrors(t *testing.T) {
+	icon := func(rows ...string) *XPMIcon {
+		return &XPMIcon{
+			Width:         2,
+			Height:        len(rows),
+			Colors:        1,
+			CharsPerPixel: 1,
+			Data:          rows,
+			Palette:       Palette{{Key: "a", Color: Color{Hex: "#FF0000"}}},
+		}
+	}
+
+	tests := []struct {
+		name    string
+		typFile TYPFile
+		wantErr string
+	}{
+		{
+			"unknown pixel",
+			TYPFile{Points: []PointType{{Type: "0x2f06", DayXpm: icon("ab")}}},
+			"not in palette",
+		},
+		{
+			"short row",
+			TYPFile{Points: []PointType{{Type: "0x2f06", DayXpm: icon("a")}}},
+			"expected 2 characters",
+		},
+		{
+			"duplicate type",
+			TYPFile{Points: []PointType{{Type: "0x2f06"}, {Type: "0x2f", SubType: "0x06"}}},
+			"duplicate type",
+		},
+		{
+			"subtype out of range",
+			TYPFile{Points: []PointType{{Type: "0x2f", SubType: "0x20"}}},
+			"out of range",
+		},
+		{
+			"polygon without colors",
+			TYPFile{Polygons: []PolygonType{{Type: "0x13"}}},
+			"no colors",
+		},
+		{
+			"unknown font style",
+			TYPFile{Points: []PointType{{Type: "0x2f06", FontStyle: "Huge"}}},
+			"unknown font style",
+		},
+	}
+
+	for _, tt := range tests {
+		_, err := EncodeBinary(&tt.typFile)
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("%s: expected error containing %q, got %v", tt.name, tt.wantErr, err)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package parser
+
+// Clone returns a deep copy of the file, so edits to the copy do not show
+// in the original. The source layout used for lossless saving is shared, as
+// writing does not change it.
+func (t *TYPFile) Clone() *TYPFile {
+	c := *t
+
+	c.Points = make([]PointType, len(t.Points))
+	for i, point := range t.Points {
+		point.Labels = cloneLabels(point.Labels)
+		point.DayXpm, point.NightXpm = point.DayXpm.Clone(), point.NightXpm.Clone()
+		point.DayColors, point.NightColors = cloneColorList(point.DayColors), cloneColorList(point.NightColors)
+		c.Points[i] = point
+	}
+
+	c.Lines = make([]LineType, len(t.Lines))
+	for i, line := range t.Lines {
+		line.Labels = cloneLabels(line.Labels)
+		line.DayXpm, line.NightXpm = line.DayXpm.Clone(), line.NightXpm.Clone()
+		line.DayColors, line.NightColors = cloneColorList(line.DayColors), cloneColorList(line.NightColors)
+		c.Lines[i] = line
+	}
+
+	c.Polygons = make([]PolygonType, len(t.Polygons))
+	for i, polygon := range t.Polygons {
+		polygon.Labels = cloneLabels(polygon.Labels)
+		polygon.DayXpm, polygon.NightXpm = polygon.DayXpm.Clone(), polygon.NightXpm.Clone()
+		polygon.DayColors, polygon.NightColors = cloneColorList(polygon.DayColors), cloneColorList(polygon.NightColors)
+		c.Polygons[i] = polygon
+	}
+
+	c.DrawOrder.Polygons = append([]DrawOrderEntry(nil), t.DrawOrder.Polygons...)
+	return &c
+}
+
+// Clone returns a copy of the icon with its own palette and pixel rows. A
+// nil icon gives nil.
+func (x *XPMIcon) Clone() *XPMIcon {
+	if x == nil {
+		return nil
+	}
+	c := *x
+	c.Palette = append(Palette(nil), x.Palette...)
+	c.Data = append([]string(nil), x.Data...)
+	return &c
+}
+
+// cloneLabels copies a label map
+func cloneLabels(labels map[string]string) map[string]string {
+	if labels == nil {
+		return nil
+	}
+	c := make(map[string]string, len(labels))
+	for code, label := range labels {
+		c[code] = label
+	}
+	return c
+}
+
+// cloneColorList copies a colour list
+func cloneColorList(colors []Color) []Color {
+	if colors == nil {
+		return nil
+	}
+	return append([]Color(nil), colors...)
+}
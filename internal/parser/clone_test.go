@@ -0,0 +1,33 @@
+package parser
+
+import "testing"
+
+func TestClone(t *testing.T) {
+	typFile, err := ParseFile("../../testdata/sample/basic.typ")
+	if err != nil {
+		t.Fatalf("Failed to parse file: %v", err)
+	}
+	if len(typFile.Points) == 0 || typFile.Points[0].DayXpm == nil {
+		t.Fatal("Expected a point with an icon in the sample file")
+	}
+
+	label := typFile.Points[0].Labels["0x04"]
+	row := typFile.Points[0].DayXpm.Data[0]
+	order := len(typFile.DrawOrder.Polygons)
+
+	c := typFile.Clone()
+	c.Points[0].Labels["0x04"] = "Changed"
+	c.Points[0].DayXpm.Data[0] = "changed"
+	c.Points = append(c.Points, PointType{Type: "0xff"})
+	c.DrawOrder.Polygons = append(c.DrawOrder.Polygons, DrawOrderEntry{Level: 1, Type: "0x01"})
+
+	if got := typFile.Points[0].Labels["0x04"]; got != label {
+		t.Errorf("Editing the clone changed the original label to %q", got)
+	}
+	if got := typFile.Points[0].DayXpm.Data[0]; got != row {
+		t.Errorf("Editing the clone changed the original icon row to %q", got)
+	}
+	if len(typFile.DrawOrder.Polygons) != order {
+		t.Errorf("Editing the clone changed the original draw order")
+	}
+}
@@ -0,0 +1,234 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// CodePageUTF8 is the code page number of UTF-8 labels
+const CodePageUTF8 = 65001
+
+// defaultCodePage is used for files that do not declare a code page
+const defaultCodePage = 1252
+
+// CodePageError reports a character that a code page cannot represent
+type CodePageError struct {
+	CodePage int
+	Rune     rune
+	Offset   int // byte offset of the character in the text
+}
+
+func (e *CodePageError) Error() string {
+	return fmt.Sprintf("%q cannot be written in code page %d", e.Rune, e.CodePage)
+}
+
+// SupportedCodePage reports whether text can be converted to and from a
+// code page. 0 stands for the default code page, 1252.
+func SupportedCodePage(codePage int) bool {
+	codePage = effectiveCodePage(codePage)
+	return codePage == CodePageUTF8 || codePageTables[codePage] != nil
+}
+
+// effectiveCodePage resolves 0 to the default code page
+func effectiveCodePage(codePage int) int {
+	if codePage == 0 {
+		return defaultCodePage
+	}
+	return codePage
+}
+
+// DecodeText converts text in a code page to UTF-8. Bytes the code page
+// leaves undefined become U+FFFD.
+func DecodeText(s string, codePage int) (string, error) {
+	codePage = effectiveCodePage(codePage)
+	if codePage == CodePageUTF8 {
+		return strings.ToValidUTF8(s, "�"), nil
+	}
+	table := codePageTables[codePage]
+	if table == nil {
+		return "", fmt.Errorf("unsupported code page %d", codePage)
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; c < 0x80 {
+			b.WriteByte(c)
+		} else {
+			b.WriteRune(table[c-0x80])
+		}
+	}
+	return b.String(), nil
+}
+
+// EncodeText converts UTF-8 text to a code page. A character the code page
+// cannot represent is reported as a *CodePageError.
+func EncodeText(s string, codePage int) (string, error) {
+	codePage = effectiveCodePage(codePage)
+	if codePage == CodePageUTF8 {
+		return s, nil
+	}
+	reverse := reverseCodePage(codePage)
+	if reverse == nil {
+		return "", fmt.Errorf("unsupported code page %d", codePage)
+	}
+
+	var b strings.Builder
+	for i, r := range s {
+		if r < 0x80 {
+			b.WriteByte(byte(r))
+			continue
+		}
+		c, ok := reverse[r]
+		if !ok {
+			return "", &CodePageError{CodePage: codePage, Rune: r, Offset: i}
+		}
+		b.WriteByte(c)
+	}
+	return b.String(), nil
+}
+
+// CheckText reports the first character of s that a code page cannot
+// represent. Unsupported code pages are not checked.
+func CheckText(s string, codePage int) error {
+	if !SupportedCodePage(codePage) {
+		return nil
+	}
+	_, err := EncodeText(s, codePage)
+	return err
+}
+
+// encodeOutput converts generated text to the code page a file is written
+// in. Labels are checked before writing; any other character the code page
+// lacks, such as bytes that were never decoded, is written unchanged.
+func encodeOutput(s string, codePage int) string {
+	reverse := reverseCodePage(codePage)
+	if reverse == nil || isASCII(s) {
+		return s
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if c, ok := reverse[r]; ok {
+			b.WriteByte(c)
+		} else {
+			b.WriteString(s[i : i+size])
+		}
+		i += size
+	}
+	return b.String()
+}
+
+// isASCII reports whether s only has 7-bit characters
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			return false
+		}
+	}
+	return true
+}
+
+// outputCodePage returns the encoding text output is written in: that of
+// the source text, or the declared code page for new and ASCII-only files
+func (t *TYPFile) outputCodePage() int {
+	if t.syntax != nil && t.syntax.textCodePage != 0 {
+		return t.syntax.textCodePage
+	}
+	return effectiveCodePage(t.Header.CodePage)
+}
+
+var (
+	reverseTablesOnce sync.Once
+	reverseTables     map[int]map[rune]byte
+)
+
+// reverseCodePage returns the rune to byte map of a single-byte code page
+func reverseCodePage(codePage int) map[rune]byte {
+	reverseTablesOnce.Do(func() {
+		reverseTables = make(map[int]map[rune]byte, len(codePageTables))
+		for cp, table := range codePageTables {
+			reverse := make(map[rune]byte, len(table))
+			for i, r := range table {
+				if r != utf8.RuneError {
+					reverse[r] = byte(0x80 + i)
+				}
+			}
+			reverseTables[cp] = reverse
+		}
+	})
+	return reverseTables[codePage]
+}
+
+// ConvertCodePage switches a file to another code page. Every label must be
+// representable in the new code page; otherwise nothing is changed and the
+// problems are returned. Text files written in a single-byte code page are
+// transcoded line by line, comments included.
+func ConvertCodePage(typFile *TYPFile, codePage int) error {
+	if !SupportedCodePage(codePage) {
+		return fmt.Errorf("unsupported code page %d", codePage)
+	}
+
+	if problems := CheckLabels(typFile, codePage); len(problems) > 0 {
+		return fmt.Errorf("%d labels cannot be converted, first: %w", len(problems), problems[0])
+	}
+
+	if doc := typFile.syntax; doc != nil && doc.textCodePage != 0 && doc.textCodePage != CodePageUTF8 {
+		from, to := doc.textCodePage, effectiveCodePage(codePage)
+		if err := doc.mapLines(func(line string) (string, error) {
+			text, err := DecodeText(line, from)
+			if err != nil {
+				return "", err
+			}
+			return EncodeText(text, to)
+		}); err != nil {
+			return fmt.Errorf("cannot convert the file text: %w", err)
+		}
+		doc.textCodePage = to
+	}
+
+	typFile.Header.CodePage = codePage
+	typFile.Modified = true
+	return nil
+}
+
+// LabelError is a label that cannot be written in the code page of its file
+type LabelError struct {
+	Kind     string // "point", "line" or "polygon"
+	Type     string
+	LangCode string
+	Err      *CodePageError
+}
+
+func (e *LabelError) Error() string {
+	return fmt.Sprintf("%s %s, label %s: %v", e.Kind, e.Type, e.LangCode, e.Err)
+}
+
+func (e *LabelError) Unwrap() error {
+	return e.Err
+}
+
+// CheckLabels returns the labels that a code page cannot represent, in file
+// order
+func CheckLabels(typFile *TYPFile, codePage int) []*LabelError {
+	var problems []*LabelError
+	check := func(kind, typ string, labels map[string]string) {
+		for _, code := range sortedLangCodes(labels) {
+			if err := CheckText(labels[code], codePage); err != nil {
+				problems = append(problems, &LabelError{Kind: kind, Type: typ, LangCode: code, Err: err.(*CodePageError)})
+			}
+		}
+	}
+	for _, point := range typFile.Points {
+		check("point", point.Type, point.Labels)
+	}
+	for _, line := range typFile.Lines {
+		check("line", line.Type, line.Labels)
+	}
+	for _, polygon := range typFile.Polygons {
+		check("polygon", polygon.Type, polygon.Labels)
+	}
+	return problems
+}
@@ -0,0 +1,148 @@
+package parser
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestDecodeEncodeText(t *testing.T) {
+	tests := []struct {
+		codePage int
+		text     string
+		encoded  string
+	}{
+		{1250, "Kő", "K\xf5"},
+		{1251, "Банк", "\xc1\xe0\xed\xea"},
+		{1252, "Café", "Caf\xe9"},
+		{0, "Café", "Caf\xe9"},
+		{CodePageUTF8, "Kő", "Kő"},
+	}
+
+	for _, tt := range tests {
+		encoded, err := EncodeText(tt.text, tt.codePage)
+		if err != nil || encoded != tt.encoded {
+			t.Errorf("EncodeText(%q, %d) = %q, %v; want %q", tt.text, tt.codePage, encoded, err, tt.encoded)
+		}
+		decoded, err := DecodeText(tt.encoded, tt.codePage)
+		if err != nil || decoded != tt.text {
+			t.Errorf("DecodeText(%q, %d) = %q, %v; want %q", tt.encoded, tt.codePage, decoded, err, tt.text)
+		}
+	}
+
+	_, err := EncodeText("Aő", 1252)
+	var cpErr *CodePageError
+	if !errors.As(err, &cpErr) || cpErr.Rune != 'ő' || cpErr.Offset != 1 {
+		t.Errorf("Expected a CodePageError for 'ő' at 1, got %v", err)
+	}
+	if _, err := DecodeText("x", 932); err == nil {
+		t.Error("Expected an error for an unsupported code page")
+	}
+}
+
+func TestParseLegacyLabels(t *testing.T) {
+	source := "[_id]\nCodePage=1250\n[end]\n; K\xf5\n[_point]\nType=0x2f06\nString=0x04,K\xf5\n[end]\n"
+
+	typFile, err := ParseReader(strings.NewReader(source), "legacy.typ")
+	if err != nil {
+		t.Fatalf("Failed to parse: %v", err)
+	}
+	if got := typFile.Points[0].Labels["0x04"]; got != "Kő" {
+		t.Errorf("Expected label Kő, got %q", got)
+	}
+
+	// Unchanged files are written back byte for byte
+	var buf bytes.Buffer
+	if err := Write(&buf, typFile); err != nil {
+		t.Fatalf("Failed to write: %v", err)
+	}
+	if buf.String() != source {
+		t.Errorf("Round trip changed the file:\n%q", buf.String())
+	}
+
+	// Edited labels are written in the code page of the file
+	typFile.Points[0].Labels["0x04"] = "Kőzet"
+	buf.Reset()
+	if err := Write(&buf, typFile); err != nil {
+		t.Fatalf("Failed to write: %v", err)
+	}
+	if want := strings.Replace(source, "K\xf5\n[end]", "K\xf5zet\n[end]", 1); buf.String() != want {
+		t.Errorf("Unexpected output:\n%q\nwant:\n%q", buf.String(), want)
+	}
+}
+
+func TestParseUnrepresentableLabel(t *testing.T) {
+	source := "[_id]\nCodePage=1252\n[end]\n[_point]\nType=0x2f06\nString=0x04, Kő\n[end]\n"
+
+	_, diagnostics, err := ParseReaderTolerant(strings.NewReader(source), "utf8.typ")
+	if err != nil {
+		t.Fatalf("Tolerant parse failed: %v", err)
+	}
+	if len(diagnostics) != 1 || diagnostics[0].Severity != SeverityWarning ||
+		diagnostics[0].Line != 6 || diagnostics[0].Column != 15 {
+		t.Errorf("Expected one warning at 6:15, got %v", diagnostics)
+	}
+}
+
+func TestWriteUnrepresentableLabel(t *testing.T) {
+	typFile := &TYPFile{
+		Header: Header{CodePage: 1252},
+		Points: []PointType{{Type: "0x2f06", Labels: map[string]string{"0x04": "Kő"}}},
+	}
+
+	var buf bytes.Buffer
+	err := Write(&buf, typFile)
+	var labelErr *LabelError
+	if !errors.As(err, &labelErr) || labelErr.Type != "0x2f06" || labelErr.LangCode != "0x04" {
+		t.Fatalf("Expected a LabelError, got %v", err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("Expected nothing written, got %q", buf.String())
+	}
+	if _, err := EncodeBinary(typFile); err == nil {
+		t.Error("Expected the compiler to reject the label")
+	}
+}
+
+func TestConvertCodePage(t *testing.T) {
+	source := "[_id]\nCodePage=1250\n[end]\n; K\xf5\n[_point]\nType=0x2f06\nString=0x04,K\xf5\nString=0x02,M\xe9sz\n[end]\n"
+
+	typFile, err := ParseReader(strings.NewReader(source), "legacy.typ")
+	if err != nil {
+		t.Fatalf("Failed to parse: %v", err)
+	}
+
+	// ő is not in Western European
+	if err := ConvertCodePage(typFile, 1252); err == nil {
+		t.Fatal("Expected converting to 1252 to fail")
+	}
+	if typFile.Header.CodePage != 1250 || typFile.Modified {
+		t.Error("A failed conversion changed the file")
+	}
+
+	if err := ConvertCodePage(typFile, CodePageUTF8); err != nil {
+		t.Fatalf("Failed to convert: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := Write(&buf, typFile); err != nil {
+		t.Fatalf("Failed to write: %v", err)
+	}
+	want := "[_id]\nCodePage=65001\n[end]\n; Kő\n[_point]\nType=0x2f06\nString=0x04,Kő\nString=0x02,Mész\n[end]\n"
+	if buf.String() != want {
+		t.Errorf("Unexpected output:\n%q\nwant:\n%q", buf.String(), want)
+	}
+
+	// Binary files store labels in the header code page
+	data, err := EncodeBinary(typFile)
+	if err != nil {
+		t.Fatalf("Failed to encode: %v", err)
+	}
+	decoded, err := ParseBinary(data, "legacy.typ")
+	if err != nil {
+		t.Fatalf("Failed to decode: %v", err)
+	}
+	if got := decoded.Points[0].Labels["0x04"]; got != "Kő" {
+		t.Errorf("Expected label Kő after compiling, got %q", got)
+	}
+}
@@ -0,0 +1,53 @@
+package parser
+
+import "fmt"
+
+// Severity tells how serious a Diagnostic is
+type Severity int
+
+const (
+	// SeverityError marks input that could not be read; the property or
+	// section it belongs to is incomplete
+	SeverityError Severity = iota
+	// SeverityWarning marks input that was ignored
+	SeverityWarning
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityError:
+		return "error"
+	case SeverityWarning:
+		return "warning"
+	}
+	return fmt.Sprintf("Severity(%d)", int(s))
+}
+
+// Diagnostic is a problem found while parsing a TYP text file
+type Diagnostic struct {
+	Severity Severity
+	Line     int
+	Column   int    // 1-based, 0 when the whole line is meant
+	Section  string // Section name without brackets, e.g. "_point"
+	Index    int    // Index of the type in its TYPFile slice, -1 if none
+	Message  string
+	Hint     string // Suggested fix, may be empty
+}
+
+func (d Diagnostic) String() string {
+	location := fmt.Sprintf("%d", d.Line)
+	if d.Column > 0 {
+		location += fmt.Sprintf(":%d", d.Column)
+	}
+	return location + ": " + d.Severity.String() + ": " + d.Message
+}
+
+// HasErrors reports whether any of the diagnostics is an error
+func HasErrors(diagnostics []Diagnostic) bool {
+	for _, d := range diagnostics {
+		if d.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,76 @@
+package parser
+
+import "sort"
+
+// Level returns the draw order level of a polygon type, or 0 if it has none
+func (d DrawOrder) Level(typ string) int {
+	for _, entry := range d.Polygons {
+		if sameType(entry.Type, typ) {
+			return entry.Level
+		}
+	}
+	return 0
+}
+
+// SetLevel moves a polygon type to a draw order level. Level 0 removes the
+// type from the draw order.
+func (d *DrawOrder) SetLevel(typ string, level int) {
+	for i, entry := range d.Polygons {
+		if !sameType(entry.Type, typ) {
+			continue
+		}
+		if level <= 0 {
+			d.Polygons = append(d.Polygons[:i], d.Polygons[i+1:]...)
+		} else {
+			d.Polygons[i].Level = level
+		}
+		return
+	}
+	if level > 0 {
+		d.Polygons = append(d.Polygons, DrawOrderEntry{Type: typ, Level: level})
+	}
+}
+
+// MaxLevel returns the highest level in use
+func (d DrawOrder) MaxLevel() int {
+	max := 0
+	for _, entry := range d.Polygons {
+		if entry.Level > max {
+			max = entry.Level
+		}
+	}
+	return max
+}
+
+// Levels returns the entries grouped by level. Index 0 holds level 1; levels
+// without types are empty.
+func (d DrawOrder) Levels() [][]DrawOrderEntry {
+	levels := make([][]DrawOrderEntry, d.MaxLevel())
+	for _, entry := range d.Polygons {
+		if entry.Level > 0 {
+			levels[entry.Level-1] = append(levels[entry.Level-1], entry)
+		}
+	}
+	return levels
+}
+
+// sorted returns the entries ordered by level, keeping the order within a
+// level
+func (d DrawOrder) sorted() []DrawOrderEntry {
+	entries := append([]DrawOrderEntry(nil), d.Polygons...)
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Level < entries[j].Level
+	})
+	return entries
+}
+
+// sameType reports whether two type codes name the same type, so "0x3" and
+// "0x03" match
+func sameType(a, b string) bool {
+	if a == b {
+		return true
+	}
+	codeA, errA := ParseTypeCode(a, "")
+	codeB, errB := ParseTypeCode(b, "")
+	return errA == nil && errB == nil && codeA == codeB
+}
@@ -0,0 +1,27 @@
+package parser
+
+import "testing"
+
+func TestDrawOrderLevels(t *testing.T) {
+	var d DrawOrder
+	d.SetLevel("0x03", 2)
+	d.SetLevel("0x10f04", 4)
+	d.SetLevel("0x3", 1) // same type as 0x03
+
+	if got := d.Level("0x03"); got != 1 {
+		t.Errorf("Expected 0x03 on level 1, got %d", got)
+	}
+	if got := d.MaxLevel(); got != 4 {
+		t.Errorf("Expected max level 4, got %d", got)
+	}
+
+	levels := d.Levels()
+	if len(levels) != 4 || len(levels[0]) != 1 || len(levels[1]) != 0 || len(levels[3]) != 1 {
+		t.Errorf("Unexpected levels: %v", levels)
+	}
+
+	d.SetLevel("0x10f04", 0)
+	if d.Level("0x10f04") != 0 || len(d.Polygons) != 1 {
+		t.Errorf("Expected 0x10f04 to be removed, got %v", d.Polygons)
+	}
+}
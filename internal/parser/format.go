@@ -0,0 +1,327 @@
+package parser
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+)
+
+// FormatOptions controls the canonical form written by Format
+type FormatOptions struct {
+	// SortTypes orders points, lines and polygons by type code instead of
+	// keeping them in file order
+	SortTypes bool
+}
+
+// Format writes a TYPFile in canonical form, so the same content always
+// gives the same bytes:
+//
+//   - sections in a fixed order: header, draw order, points, lines,
+//     polygons, then sections the parser does not know
+//   - one blank line between sections and none inside them
+//   - properties in a fixed order with their canonical key spelling
+//   - labels sorted by language code, palettes in file order
+//   - upper case colour hex digits and lower case type code hex digits
+//
+// Comments are kept with the property or section that follows them, and
+// trailing comments stay on their line. Formatting a formatted file does not
+// change it.
+func Format(w io.Writer, typFile *TYPFile, opts FormatOptions) error {
+	codePage := typFile.outputCodePage()
+	if problems := CheckLabels(typFile, codePage); len(problems) > 0 {
+		return fmt.Errorf("failed to format file: %d labels cannot be written in code page %d, first: %w",
+			len(problems), codePage, problems[0])
+	}
+
+	doc := typFile.syntax
+	if doc == nil {
+		doc = &document{newline: "\n"}
+	}
+	canon := canonicalFile(typFile, opts)
+
+	b := bufio.NewWriter(w)
+	f := &formatter{
+		o:    &outputBuilder{b: b, newline: doc.newline, codePage: codePage},
+		used: make(map[*syntaxSection]bool),
+	}
+
+	sectionsNamed := func(name string) []*syntaxSection {
+		var secs []*syntaxSection
+		for _, sec := range doc.sections {
+			if sec.name == name {
+				secs = append(secs, sec)
+			}
+		}
+		return secs
+	}
+
+	header := headerEntries(canon.Header)
+	if secs := sectionsNamed("_id"); len(secs) > 0 || len(header) > 0 {
+		f.section("_id", secs, header)
+	}
+	if secs := sectionsNamed("_drawOrder"); len(secs) > 0 || len(canon.DrawOrder.Polygons) > 0 {
+		f.section("_drawOrder", secs, drawOrderEntries(DrawOrder{Polygons: canon.DrawOrder.sorted()}))
+	}
+	for _, point := range canon.Points {
+		f.section("_point", f.claim(point.syntax), pointEntries(point))
+	}
+	for _, line := range canon.Lines {
+		f.section("_line", f.claim(line.syntax), lineEntries(line))
+	}
+	for _, polygon := range canon.Polygons {
+		f.section("_polygon", f.claim(polygon.syntax), polygonEntries(polygon))
+	}
+	for _, sec := range doc.sections {
+		switch sec.name {
+		case "_id", "_drawOrder", "_point", "_line", "_polygon":
+		default:
+			f.unknown(sec)
+		}
+	}
+	if trailer := commentLines(doc.trailer); len(trailer) > 0 {
+		f.begin(trailer)
+	}
+
+	if err := b.Flush(); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	return nil
+}
+
+// formatter writes the sections of a canonical file
+type formatter struct {
+	o        *outputBuilder
+	used     map[*syntaxSection]bool // source sections whose comments are written
+	sections int
+}
+
+// claim returns the source section of a type, unless another type already
+// took its comments
+func (f *formatter) claim(sec *syntaxSection) []*syntaxSection {
+	if sec == nil || f.used[sec] {
+		return nil
+	}
+	f.used[sec] = true
+	return []*syntaxSection{sec}
+}
+
+// keep writes source lines, already trimmed and in the output encoding
+func (f *formatter) keep(lines ...string) {
+	for _, line := range lines {
+		f.o.raw(line + f.o.newline)
+	}
+}
+
+// begin starts a section or trailing comment block, separated from the one
+// before by a blank line
+func (f *formatter) begin(comments []string) {
+	if f.sections > 0 {
+		f.o.line("")
+	}
+	f.sections++
+	f.keep(comments...)
+}
+
+// section writes a known section with its properties in canonical form.
+// Comments and properties the parser does not know are placed before the
+// property that followed them in the source.
+func (f *formatter) section(name string, secs []*syntaxSection, entries []syntaxEntry) {
+	var leading []string
+	for _, sec := range secs {
+		leading = append(leading, commentLines(sec.leading)...)
+	}
+	f.begin(leading)
+	f.o.line("[" + name + "]")
+
+	queue := make(map[string][]int)
+	for i, entry := range entries {
+		queue[entry.id] = append(queue[entry.id], i)
+	}
+	before := make([][]string, len(entries))
+	inline := make([]string, len(entries)) // trailing comments
+	var pending []string
+	for _, sec := range secs {
+		for _, entry := range groupEntries(name, sec.body) {
+			if entry.id == "" {
+				pending = append(pending, commentLines(entry.lines)...)
+				continue
+			}
+			if next := queue[entry.id]; len(next) > 0 {
+				before[next[0]] = append(before[next[0]], pending...)
+				before[next[0]] = append(before[next[0]], commentLines(entry.lines[1:])...)
+				if l := lexLine(trimNewline(entry.lines[0])); l.comment >= 0 {
+					inline[next[0]] = l.source[l.comment:]
+				}
+				queue[entry.id] = next[1:]
+				pending = nil
+				continue
+			}
+			if _, parsed := sec.snapshot[entry.id]; !parsed {
+				// Not understood by the parser, keep it as it is
+				pending = append(pending, trimmedLines(entry.lines)...)
+			}
+		}
+	}
+
+	for i, entry := range entries {
+		f.keep(before[i]...)
+		for j, line := range entry.lines {
+			if j == 0 && inline[i] != "" {
+				f.keep(encodeOutput(line, f.o.codePage) + " " + inline[i])
+			} else {
+				f.o.line(line)
+			}
+		}
+	}
+	f.keep(pending...)
+	f.o.line("[end]")
+}
+
+// unknown writes a section the parser does not know, without blank lines
+func (f *formatter) unknown(sec *syntaxSection) {
+	f.begin(commentLines(sec.leading))
+	f.keep(trimmedLines([]string{sec.header})...)
+	f.keep(trimmedLines(sec.body)...)
+	f.o.line("[end]")
+}
+
+// commentLines returns the comment lines of raw source lines, trimmed
+func commentLines(raw []string) []string {
+	var comments []string
+	for _, line := range raw {
+		if l := lexLine(trimNewline(line)); l.kind == lineComment {
+			comments = append(comments, strings.TrimSpace(l.source))
+		}
+	}
+	return comments
+}
+
+// trimmedLines returns raw source lines without surrounding white space,
+// dropping blank ones
+func trimmedLines(raw []string) []string {
+	var lines []string
+	for _, line := range raw {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// canonicalFile returns a copy of typFile with normalised spellings, and
+// with types sorted if asked. Source sections are shared with typFile.
+func canonicalFile(typFile *TYPFile, opts FormatOptions) *TYPFile {
+	c := *typFile
+
+	c.Points = make([]PointType, len(typFile.Points))
+	for i, point := range typFile.Points {
+		point.Type, point.SubType = canonicalHex(point.Type), canonicalHex(point.SubType)
+		point.DayXpm, point.NightXpm = canonicalXPM(point.DayXpm), canonicalXPM(point.NightXpm)
+		point.DayColors, point.NightColors = canonicalColors(point.DayColors), canonicalColors(point.NightColors)
+		point.DayFontColor, point.NightFontColor = canonicalColor(point.DayFontColor), canonicalColor(point.NightFontColor)
+		point.CustomColor = canonicalColor(point.CustomColor)
+		c.Points[i] = point
+	}
+
+	c.Lines = make([]LineType, len(typFile.Lines))
+	for i, line := range typFile.Lines {
+		line.Type = canonicalHex(line.Type)
+		line.DayXpm, line.NightXpm = canonicalXPM(line.DayXpm), canonicalXPM(line.NightXpm)
+		line.DayColors, line.NightColors = canonicalColors(line.DayColors), canonicalColors(line.NightColors)
+		line.DayFontColor, line.NightFontColor = canonicalColor(line.DayFontColor), canonicalColor(line.NightFontColor)
+		line.CustomColor = canonicalColor(line.CustomColor)
+		c.Lines[i] = line
+	}
+
+	c.Polygons = make([]PolygonType, len(typFile.Polygons))
+	for i, polygon := range typFile.Polygons {
+		polygon.Type = canonicalHex(polygon.Type)
+		polygon.DayXpm, polygon.NightXpm = canonicalXPM(polygon.DayXpm), canonicalXPM(polygon.NightXpm)
+		polygon.DayColors, polygon.NightColors = canonicalColors(polygon.DayColors), canonicalColors(polygon.NightColors)
+		polygon.DayFontColor, polygon.NightFontColor = canonicalColor(polygon.DayFontColor), canonicalColor(polygon.NightFontColor)
+		polygon.ContourColor = canonicalColor(polygon.ContourColor)
+		polygon.CustomColor = canonicalColor(polygon.CustomColor)
+		c.Polygons[i] = polygon
+	}
+
+	c.DrawOrder.Polygons = make([]DrawOrderEntry, len(typFile.DrawOrder.Polygons))
+	for i, entry := range typFile.DrawOrder.Polygons {
+		entry.Type = canonicalHex(entry.Type)
+		c.DrawOrder.Polygons[i] = entry
+	}
+
+	if opts.SortTypes {
+		sort.SliceStable(c.Points, func(i, j int) bool {
+			return typeOrder(c.Points[i].Type, c.Points[i].SubType) < typeOrder(c.Points[j].Type, c.Points[j].SubType)
+		})
+		sort.SliceStable(c.Lines, func(i, j int) bool {
+			return typeOrder(c.Lines[i].Type, "") < typeOrder(c.Lines[j].Type, "")
+		})
+		sort.SliceStable(c.Polygons, func(i, j int) bool {
+			return typeOrder(c.Polygons[i].Type, "") < typeOrder(c.Polygons[j].Type, "")
+		})
+	}
+
+	return &c
+}
+
+// typeOrder returns the sort key of a type code. Codes that cannot be read
+// sort last.
+func typeOrder(typ, subType string) int {
+	code, err := ParseTypeCode(typ, subType)
+	if err != nil {
+		return math.MaxInt
+	}
+	key := code.Type<<8 | code.SubType
+	if code.Extended {
+		key |= 0x10000
+	}
+	return key
+}
+
+// canonicalHex spells the digits of a 0x number in lower case
+func canonicalHex(value string) string {
+	if len(value) > 2 && (value[:2] == "0x" || value[:2] == "0X") {
+		return "0x" + strings.ToLower(value[2:])
+	}
+	return value
+}
+
+// canonicalColor spells a colour with upper case hex digits
+func canonicalColor(c Color) Color {
+	if isTransparent(c) {
+		c.Hex = "none"
+	} else {
+		c.Hex = strings.ToUpper(c.Hex)
+	}
+	return c
+}
+
+// canonicalColors applies canonicalColor to a copy of colors
+func canonicalColors(colors []Color) []Color {
+	if colors == nil {
+		return nil
+	}
+	out := make([]Color, len(colors))
+	for i, c := range colors {
+		out[i] = canonicalColor(c)
+	}
+	return out
+}
+
+// canonicalXPM returns a copy of an icon with canonical palette colours
+func canonicalXPM(xpm *XPMIcon) *XPMIcon {
+	if xpm == nil {
+		return nil
+	}
+	c := *xpm
+	c.Palette = make(Palette, len(xpm.Palette))
+	for i, entry := range xpm.Palette {
+		entry.Color = canonicalColor(entry.Color)
+		c.Palette[i] = entry
+	}
+	return &c
+}
@@ -0,0 +1,142 @@
+package parser
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// formatString parses TYP text and returns it formatted
+func formatString(t *testing.T, source string, opts FormatOptions) string {
+	t.Helper()
+	typFile, err := ParseReader(strings.NewReader(source), "format.typ")
+	if err != nil {
+		t.Fatalf("Failed to parse: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := Format(&buf, typFile, opts); err != nil {
+		t.Fatalf("Failed to format: %v", err)
+	}
+	return buf.String()
+}
+
+func TestFormat(t *testing.T) {
+	source := `; header comment
+[_id]
+CodePage=1252   ; western europe
+
+
+FID=1234
+[end]
+[_polygon]
+Type=0x1A
+String2=0x02,Park
+String1=0x04,Park
+ContourColor=#aabbcc
+[end]
+
+
+; the bank
+[_point]
+Type=0x2F06
+Author=someone
+String=0x04,Bank
+DayXpm="2 1 2 1"
+"a c #ff0000"
+; inside the icon
+"b c none"
+"ab"
+[end]
+[_drawOrder]
+Type=0x1A,1
+[end]
+[_notes]
+  free text
+[end]
+; the end
+`
+	want := `; header comment
+[_id]
+CodePage=1252 ; western europe
+FID=1234
+[end]
+
+[_drawOrder]
+Type=0x1a,1
+[end]
+
+; the bank
+[_point]
+Type=0x2f06
+Author=someone
+String=0x04,Bank
+; inside the icon
+DayXpm="2 1 2 1"
+"a c #FF0000"
+"b c none"
+"ab"
+[end]
+
+[_polygon]
+Type=0x1a
+String=0x02,Park
+String=0x04,Park
+ContourColor=#AABBCC
+[end]
+
+[_notes]
+free text
+[end]
+
+; the end
+`
+
+	got := formatString(t, source, FormatOptions{})
+	if got != want {
+		t.Errorf("Unexpected output:\n%s\nwant:\n%s", got, want)
+	}
+
+	// Formatting is idempotent
+	if again := formatString(t, got, FormatOptions{}); again != got {
+		t.Errorf("Formatting twice changed the output:\n%s", again)
+	}
+}
+
+func TestFormatSortTypes(t *testing.T) {
+	source := "[_point]\nType=0x2f\nSubType=0x06\n[end]\n[_point]\nType=0x10f04\n[end]\n[_point]\nType=0x2f01\n[end]\n" +
+		"[_line]\nType=0x10\n[end]\n[_line]\nType=0x02\n[end]\n"
+	want := "[_point]\nType=0x2f01\n[end]\n\n[_point]\nType=0x2f\nSubType=0x06\n[end]\n\n[_point]\nType=0x10f04\n[end]\n\n" +
+		"[_line]\nType=0x02\n[end]\n\n[_line]\nType=0x10\n[end]\n"
+
+	if got := formatString(t, source, FormatOptions{SortTypes: true}); got != want {
+		t.Errorf("Unexpected output:\n%s\nwant:\n%s", got, want)
+	}
+	if got := formatString(t, source, FormatOptions{}); !strings.HasPrefix(got, "[_point]\nType=0x2f\n") {
+		t.Errorf("Types were sorted without SortTypes:\n%s", got)
+	}
+}
+
+func TestWriteLabelOrder(t *testing.T) {
+	typFile := &TYPFile{
+		Points: []PointType{{Type: "0x2f06", Labels: map[string]string{
+			"0x04": "Bank", "0x01": "Banque", "0x02": "Bank", "0x0a": "Banca", "0x03": "Bank",
+		}}},
+	}
+
+	var first bytes.Buffer
+	if err := Write(&first, typFile); err != nil {
+		t.Fatalf("Failed to write: %v", err)
+	}
+	if !strings.Contains(first.String(), "String=0x01,Banque\nString=0x02,Bank\nString=0x03,Bank\nString=0x04,Bank\nString=0x0a,Banca\n") {
+		t.Errorf("Labels not in language code order:\n%s", first.String())
+	}
+	for i := 0; i < 10; i++ {
+		var again bytes.Buffer
+		if err := Write(&again, typFile); err != nil {
+			t.Fatalf("Failed to write: %v", err)
+		}
+		if again.String() != first.String() {
+			t.Fatalf("Output changed between writes:\n%s\n%s", first.String(), again.String())
+		}
+	}
+}
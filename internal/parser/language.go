@@ -0,0 +1,51 @@
+package parser
+
+// languageNames are the languages of label codes in TYP files
+var languageNames = map[string]string{
+	"0x01": "French",
+	"0x02": "German",
+	"0x03": "Dutch",
+	"0x04": "English",
+	"0x05": "Italian",
+	"0x06": "Finnish",
+	"0x07": "Swedish",
+	"0x08": "Spanish",
+	"0x09": "Basque",
+	"0x0a": "Catalan",
+	"0x0b": "Galician",
+	"0x0c": "Welsh",
+	"0x0d": "Gaelic",
+	"0x0e": "Danish",
+	"0x0f": "Norwegian",
+	"0x10": "Portuguese",
+	"0x11": "Slovak",
+	"0x12": "Czech",
+	"0x13": "Croatian",
+	"0x14": "Hungarian",
+	"0x15": "Polish",
+	"0x16": "Turkish",
+	"0x17": "Greek",
+	"0x18": "Slovenian",
+	"0x19": "Russian",
+	"0x1a": "Estonian",
+	"0x1b": "Latvian",
+	"0x1c": "Romanian",
+	"0x1d": "Albanian",
+	"0x1e": "Bosnian",
+	"0x1f": "Lithuanian",
+	"0x20": "Serbian",
+	"0x21": "Macedonian",
+	"0x22": "Bulgarian",
+}
+
+// LanguageName returns the language of a label code such as "0x04"
+func LanguageName(code string) (string, bool) {
+	name, ok := languageNames[code]
+	return name, ok
+}
+
+// LabelCodes returns the language codes of labels in the order they are
+// written, by number
+func LabelCodes(labels map[string]string) []string {
+	return sortedLangCodes(labels)
+}
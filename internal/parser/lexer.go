@@ -0,0 +1,207 @@
+package parser
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// lineKind classifies a line of TYP text
+type lineKind int
+
+const (
+	lineBlank    lineKind = iota // empty or whitespace only
+	lineComment                  // nothing but a comment
+	lineSection                  // [name]
+	lineProperty                 // Key=Value
+	lineQuoted                   // "..." line of XPM data
+	lineText                     // anything else
+)
+
+// lexedLine is a line of TYP text split into its parts. Offsets are byte
+// offsets into the source line; use column to turn them into positions.
+type lexedLine struct {
+	kind   lineKind
+	source string // the line without its line ending
+	text   string // the line without comment and surrounding whitespace
+	textAt int
+
+	name string // section name, for lineSection
+
+	key     string // for lineProperty
+	keyAt   int
+	value   string // property value, or the contents of a quoted line
+	valueAt int
+
+	comment int // offset of an inline or whole-line comment, -1 if none
+
+	problem *lexProblem
+}
+
+// lexProblem is something wrong with the shape of a line. The line is still
+// classified as well as possible.
+type lexProblem struct {
+	at      int
+	message string
+	hint    string
+}
+
+// column returns the 1-based rune column of a byte offset in the line
+func (l lexedLine) column(at int) int {
+	if at > len(l.source) {
+		at = len(l.source)
+	}
+	return utf8.RuneCountInString(l.source[:at]) + 1
+}
+
+// isEnd reports whether the line is an [end] marker
+func (l lexedLine) isEnd() bool {
+	return l.kind == lineSection && l.name == "end"
+}
+
+// lexLine splits a line of TYP text.
+//
+// A ; or # as the first character of a line starts a comment. Later in the
+// line, ; starts a comment when it follows whitespace, and # only when it
+// also stands on its own, so values such as DayFontColor=#FF0000,
+// String=0x04,Bar; Pub and String=0x04,Hut #3 are kept whole. Comment
+// characters inside double quotes are never comments.
+func lexLine(source string) lexedLine {
+	l := lexedLine{source: source, comment: -1}
+
+	inQuote := false
+	quoteAt := 0
+	leading := true
+	prev := rune(0)
+	for i, r := range source {
+		switch {
+		case r == '"':
+			inQuote = !inQuote
+			quoteAt = i
+		case inQuote:
+		case r == ';' || r == '#':
+			next, _ := utf8.DecodeRuneInString(source[i+1:])
+			afterSpace := prev == ' ' || prev == '\t'
+			if leading ||
+				(r == ';' && afterSpace) ||
+				(r == '#' && afterSpace && (i+1 == len(source) || next == ' ' || next == '\t')) {
+				l.comment = i
+			}
+		}
+		if l.comment >= 0 {
+			break
+		}
+		if r != ' ' && r != '\t' {
+			leading = false
+		}
+		prev = r
+	}
+	content := source
+	if l.comment >= 0 {
+		content = source[:l.comment]
+	}
+	l.text = strings.TrimSpace(content)
+	l.textAt = len(content) - len(strings.TrimLeftFunc(content, unicode.IsSpace))
+	end := l.textAt + len(l.text)
+
+	switch {
+	case l.text == "":
+		if l.comment >= 0 {
+			l.kind = lineComment
+		} else {
+			l.kind = lineBlank
+		}
+
+	case l.text[0] == '[':
+		l.kind = lineSection
+		close := strings.IndexByte(l.text, ']')
+		if close < 0 {
+			l.name = strings.TrimSpace(l.text[1:])
+			if l.problem == nil {
+				l.problem = &lexProblem{
+					at:      end,
+					message: "missing ']' in section header",
+					hint:    "Write the header as [" + l.name + "]",
+				}
+			}
+			break
+		}
+		l.name = strings.TrimSpace(l.text[1:close])
+		if rest := strings.TrimSpace(l.text[close+1:]); rest != "" && l.problem == nil {
+			l.problem = &lexProblem{
+				at:      l.textAt + close + 1 + strings.Index(l.text[close+1:], rest),
+				message: "unexpected text after section header: " + rest,
+				hint:    "Put it on a line of its own, or make it a comment with ;",
+			}
+		}
+
+	case l.text[0] == '"':
+		l.kind = lineQuoted
+		l.valueAt = l.textAt + 1
+		value := l.text[1:]
+		if close := strings.IndexByte(value, '"'); close >= 0 {
+			value = value[:close]
+		}
+		l.value = value
+
+	default:
+		eq := strings.IndexByte(l.text, '=')
+		if eq < 0 {
+			l.kind = lineText
+			break
+		}
+		l.kind = lineProperty
+		key := l.text[:eq]
+		l.key = strings.TrimSpace(key)
+		l.keyAt = l.textAt
+		value := l.text[eq+1:]
+		l.value = strings.TrimSpace(value)
+		l.valueAt = l.textAt + eq + 1 + len(value) - len(strings.TrimLeftFunc(value, unicode.IsSpace))
+		if l.key == "" && l.problem == nil {
+			l.problem = &lexProblem{
+				at:      l.textAt,
+				message: "missing key before '='",
+				hint:    "Properties are written as Key=Value",
+			}
+		}
+	}
+
+	// A stray quote inside a label is fine; only quoted values must close
+	quoted := l.kind == lineQuoted || (l.kind == lineProperty && strings.HasPrefix(l.value, "\""))
+	if inQuote && quoted && l.problem == nil {
+		l.problem = &lexProblem{
+			at:      quoteAt,
+			message: "unterminated quoted string",
+			hint:    "Close the string with \"",
+		}
+	}
+
+	return l
+}
+
+// token is a piece of a line and its byte offset in the line
+type token struct {
+	text string
+	at   int
+}
+
+// splitFields splits s around runs of whitespace like strings.Fields,
+// keeping the offset of each field. at is the offset of s in its line.
+func splitFields(s string, at int) []token {
+	var fields []token
+	start := -1
+	for i, r := range s {
+		space := r == ' ' || r == '\t'
+		switch {
+		case !space && start < 0:
+			start = i
+		case space && start >= 0:
+			fields = append(fields, token{text: s[start:i], at: at + start})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		fields = append(fields, token{text: s[start:], at: at + start})
+	}
+	return fields
+}
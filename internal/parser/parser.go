@@ -2,89 +2,170 @@ package parser
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"strconv"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 )
 
+// maxLineLength is the longest line the parser accepts. bufio.Scanner's
+// 64 KiB default is too small for very wide XPM rows.
+const maxLineLength = 16 << 20
+
 // Parser handles TYP file parsing
 type Parser struct {
+	// Tolerant makes Parse keep going after errors. Problems are collected
+	// as diagnostics and the parts that could be read are returned.
+	Tolerant bool
+
 	scanner  *bufio.Scanner
+	closer   io.Closer // closed when Parse returns, if the parser opened it
 	lineNum  int
 	filePath string
+	replay   bool      // the next scan returns the current line again
+	line     lexedLine // the current line
+
+	// Diagnostics and the section they are attributed to
+	diagnostics []Diagnostic
+	section     string
+	index       int
+
+	// Concrete syntax of the file, see syntax.go
+	doc *document
+	raw []string // raw lines not yet assigned to a section
+
+	// Label encoding: the declared code page, and whether labels were read
+	// as legacy code page bytes or as non-ASCII UTF-8
+	codePage   int
+	legacyText bool
+	utf8Text   bool
 }
 
-// NewParser creates a new parser for the given file
+// NewParser creates a new parser for the given file. The file is closed
+// when Parse returns.
 func NewParser(filePath string) (*Parser, error) {
 	file, err := os.Open(filePath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open file: %w", err)
 	}
 
-	scanner := bufio.NewScanner(file)
+	p := NewReaderParser(file, filePath)
+	p.closer = file
+	return p, nil
+}
+
+// NewReaderParser creates a new parser reading TYP text from r. The name is
+// used as FilePath and in error messages.
+func NewReaderParser(r io.Reader, name string) *Parser {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineLength)
+	scanner.Split(scanRawLines)
 	return &Parser{
 		scanner:  scanner,
 		lineNum:  0,
-		filePath: filePath,
-	}, nil
+		filePath: name,
+		index:    -1,
+	}
+}
+
+// Diagnostics returns the problems found by the last Parse, in file order.
+// Warnings are reported in strict mode too.
+func (p *Parser) Diagnostics() []Diagnostic {
+	return p.diagnostics
 }
 
 // Parse parses the TYP file and returns a TYPFile structure
 func (p *Parser) Parse() (*TYPFile, error) {
+	if p.closer != nil {
+		defer p.closer.Close()
+	}
+
 	typFile := &TYPFile{
 		FilePath: p.filePath,
 		Modified: false,
 	}
+	p.doc = &document{newline: "\n"}
 
-	for p.scanner.Scan() {
-		p.lineNum++
-		line := p.scanner.Text()
-		line = p.cleanLine(line)
+	for p.scan() {
+		line := p.line
 
-		if line == "" {
+		if line.kind == lineBlank || line.kind == lineComment {
 			continue
 		}
 
+		p.section, p.index = "", -1
+
 		// Check for section markers
-		if strings.HasPrefix(line, "[") {
-			section := strings.TrimSpace(strings.Trim(line, "[]"))
+		if line.kind != lineSection {
+			p.warn(Diagnostic{
+				Column:  line.column(line.textAt),
+				Message: "ignored line outside of a section",
+				Hint:    "Move it into a section or comment it out",
+			})
+			continue
+		}
 
-			switch section {
-			case "_id":
-				if err := p.parseHeader(&typFile.Header); err != nil {
-					return nil, err
-				}
-			case "_point":
-				point, err := p.parsePoint()
-				if err != nil {
-					return nil, err
-				}
-				typFile.Points = append(typFile.Points, *point)
-			case "_line":
-				line, err := p.parseLine()
-				if err != nil {
-					return nil, err
-				}
-				typFile.Lines = append(typFile.Lines, *line)
-			case "_polygon":
-				polygon, err := p.parsePolygon()
-				if err != nil {
-					return nil, err
-				}
-				typFile.Polygons = append(typFile.Polygons, *polygon)
-			case "_drawOrder":
-				if err := p.parseDrawOrder(&typFile.DrawOrder); err != nil {
-					return nil, err
-				}
-			case "end":
-				// Section end marker - ignore
-			default:
-				// Unknown section - skip it
-				if err := p.skipToEnd(); err != nil {
-					return nil, err
-				}
+		section := line.name
+		if section == "end" {
+			p.warn(Diagnostic{
+				Message: "[end] without a section",
+				Hint:    "Remove it, or add the section header it belongs to",
+			})
+			continue
+		}
+		sec := p.beginSection(section)
+		p.section = section
+
+		switch section {
+		case "_id":
+			if err := p.parseHeader(&typFile.Header); err != nil {
+				return nil, err
+			}
+			p.endSection(sec, headerEntries(typFile.Header))
+		case "_point":
+			p.index = len(typFile.Points)
+			point, err := p.parsePoint()
+			if err != nil {
+				return nil, err
+			}
+			p.endSection(sec, pointEntries(*point))
+			point.syntax = sec
+			typFile.Points = append(typFile.Points, *point)
+		case "_line":
+			p.index = len(typFile.Lines)
+			line, err := p.parseLine()
+			if err != nil {
+				return nil, err
 			}
+			p.endSection(sec, lineEntries(*line))
+			line.syntax = sec
+			typFile.Lines = append(typFile.Lines, *line)
+		case "_polygon":
+			p.index = len(typFile.Polygons)
+			polygon, err := p.parsePolygon()
+			if err != nil {
+				return nil, err
+			}
+			p.endSection(sec, polygonEntries(*polygon))
+			polygon.syntax = sec
+			typFile.Polygons = append(typFile.Polygons, *polygon)
+		case "_drawOrder":
+			var drawOrder DrawOrder
+			if err := p.parseDrawOrder(&drawOrder); err != nil {
+				return nil, err
+			}
+			p.endSection(sec, drawOrderEntries(drawOrder))
+			typFile.DrawOrder.Polygons = append(typFile.DrawOrder.Polygons, drawOrder.Polygons...)
+		default:
+			// Unknown section - skip it
+			if err := p.skipToEnd(); err != nil {
+				return nil, err
+			}
+			p.endSection(sec, nil)
 		}
 	}
 
@@ -92,47 +173,203 @@ func (p *Parser) Parse() (*TYPFile, error) {
 		return nil, fmt.Errorf("error reading file: %w", err)
 	}
 
+	p.doc.trailer = p.raw
+	switch {
+	case p.legacyText:
+		p.doc.textCodePage = effectiveCodePage(typFile.Header.CodePage)
+	case p.utf8Text:
+		p.doc.textCodePage = CodePageUTF8
+	}
+	typFile.syntax = p.doc
+
 	return typFile, nil
 }
 
-// cleanLine removes comments and trims whitespace
-func (p *Parser) cleanLine(line string) string {
-	// Remove comments (lines starting with ; or #)
-	if idx := strings.Index(line, ";"); idx >= 0 {
-		line = line[:idx]
+// scan advances to the next line, keeping the raw line for the syntax tree.
+// Each line is lexed once; problems with its shape are reported as warnings.
+func (p *Parser) scan() bool {
+	replay := p.replay
+	if p.replay {
+		p.replay = false
+	} else if !p.scanner.Scan() {
+		return false
+	}
+	p.lineNum++
+	raw := p.scanner.Text()
+	if p.lineNum == 1 && strings.HasSuffix(raw, "\r\n") && p.doc != nil {
+		p.doc.newline = "\r\n"
+	}
+	p.raw = append(p.raw, raw)
+
+	if !replay {
+		p.line = lexLine(trimNewline(raw))
+		if problem := p.line.problem; problem != nil {
+			p.warn(Diagnostic{
+				Column:  p.line.column(problem.at),
+				Message: problem.message,
+				Hint:    problem.hint,
+			})
+		}
+	}
+	return true
+}
+
+// unscan pushes the current line back so the next scan returns it again
+func (p *Parser) unscan() {
+	p.replay = true
+	p.lineNum--
+	p.raw = p.raw[:len(p.raw)-1]
+}
+
+// text returns the current line without its line ending
+func (p *Parser) text() string {
+	return trimNewline(p.scanner.Text())
+}
+
+// column returns the 1-based column of a byte offset in the current line
+func (p *Parser) column(at int) int {
+	return p.line.column(at)
+}
+
+// fail reports an error at the current line. In strict mode the returned
+// ParseError aborts the parse; tolerant parsers record it and get nil.
+func (p *Parser) fail(d Diagnostic) error {
+	d.Severity = SeverityError
+	d = p.locate(d)
+	p.diagnostics = append(p.diagnostics, d)
+	if p.Tolerant {
+		return nil
 	}
-	if idx := strings.Index(line, "#"); idx >= 0 {
-		line = line[:idx]
+	return &ParseError{
+		Line:    d.Line,
+		Column:  d.Column,
+		Message: d.Message,
+		File:    p.filePath,
 	}
-	return strings.TrimSpace(line)
 }
 
-// parseHeader parses the [_id] section
-func (p *Parser) parseHeader(header *Header) error {
-	for p.scanner.Scan() {
-		p.lineNum++
-		line := p.cleanLine(p.scanner.Text())
+// warn records a warning at the current line
+func (p *Parser) warn(d Diagnostic) {
+	d.Severity = SeverityWarning
+	p.diagnostics = append(p.diagnostics, p.locate(d))
+}
 
-		if line == "" {
+// locate fills in the position of a diagnostic
+func (p *Parser) locate(d Diagnostic) Diagnostic {
+	if d.Line == 0 {
+		d.Line = p.lineNum
+	}
+	d.Section = p.section
+	d.Index = p.index
+	return d
+}
+
+// beginSection starts a syntax section at the header line just scanned
+func (p *Parser) beginSection(name string) *syntaxSection {
+	n := len(p.raw)
+	sec := &syntaxSection{
+		name:    name,
+		leading: p.raw[:n-1],
+		header:  p.raw[n-1],
+	}
+	p.raw = nil
+	return sec
+}
+
+// endSection completes a syntax section after its [end] line was scanned
+// and records the properties as parsed
+func (p *Parser) endSection(sec *syntaxSection, entries []syntaxEntry) {
+	sec.body = p.raw
+	if n := len(p.raw); n > 0 && lexLine(trimNewline(p.raw[n-1])).isEnd() {
+		sec.body = p.raw[:n-1]
+		sec.end = p.raw[n-1]
+	}
+	sec.snapshot = entryMap(entries)
+	p.doc.sections = append(p.doc.sections, sec)
+	p.raw = nil
+}
+
+// parseSection reads the lines of the current section up to its [end] and
+// passes each property to fn. A section header before [end] is left for
+// the caller to read, so a missing [end] only loses that one marker.
+func (p *Parser) parseSection(kind string, fn func(prop lexedLine) error) error {
+	for p.scan() {
+		line := p.line
+
+		switch line.kind {
+		case lineBlank, lineComment:
+			continue
+		case lineSection:
+			if line.isEnd() {
+				return nil
+			}
+			err := p.fail(Diagnostic{
+				Column:  p.column(line.textAt),
+				Message: fmt.Sprintf("missing [end] before %s", line.text),
+				Hint:    "Add [end] at the end of the " + kind + " section",
+			})
+			p.unscan()
+			return err
+		case lineText, lineQuoted:
+			p.warn(Diagnostic{
+				Column:  p.column(line.textAt),
+				Message: fmt.Sprintf("ignored line without '=': %s", line.text),
+				Hint:    "Properties are written as Key=Value",
+			})
 			continue
 		}
 
-		if line == "[end]" {
-			return nil
+		if err := fn(line); err != nil {
+			return err
 		}
+	}
 
-		parts := strings.SplitN(line, "=", 2)
-		if len(parts) != 2 {
-			continue
-		}
+	return p.fail(Diagnostic{
+		Message: fmt.Sprintf("unexpected end of file in %s section", kind),
+		Hint:    "Add [end] at the end of the " + kind + " section",
+	})
+}
+
+// invalidValue reports a property value of the current line that could not
+// be parsed
+func (p *Parser) invalidValue(key, value, hint string) error {
+	return p.fail(Diagnostic{
+		Column:  p.column(p.line.valueAt),
+		Message: fmt.Sprintf("invalid value for %s: %s", key, value),
+		Hint:    hint,
+	})
+}
+
+// parseColor parses a #RRGGBB colour value
+func (p *Parser) parseColor(key, value string) (Color, error) {
+	if _, _, _, err := parseHexColor(value); err != nil {
+		return Color{}, p.invalidValue(key, value, "Use a colour in #RRGGBB form")
+	}
+	return Color{Hex: value}, nil
+}
 
-		key := strings.TrimSpace(parts[0])
-		value := strings.TrimSpace(parts[1])
+// parseFlag parses a Y/N property
+func parseFlag(value string) bool {
+	return strings.ToUpper(value) == "Y" || value == "1"
+}
+
+// parseHeader parses the [_id] section
+func (p *Parser) parseHeader(header *Header) error {
+	return p.parseSection("header", func(prop lexedLine) error {
+		key, value := prop.key, prop.value
 
 		var err error
 		switch key {
 		case "CodePage":
 			header.CodePage, err = strconv.Atoi(value)
+			if err == nil && !SupportedCodePage(header.CodePage) {
+				p.warn(Diagnostic{
+					Column:  p.column(prop.valueAt),
+					Message: fmt.Sprintf("code page %d is not supported, labels are not converted", header.CodePage),
+					Hint:    "Use one of the Windows code pages 1250 to 1258, or 65001 for UTF-8",
+				})
+			}
+			p.codePage = header.CodePage
 		case "FID":
 			header.FID, err = strconv.Atoi(value)
 		case "ProductCode":
@@ -142,19 +379,10 @@ func (p *Parser) parseHeader(header *Header) error {
 		}
 
 		if err != nil {
-			return &ParseError{
-				Line:    p.lineNum,
-				Message: fmt.Sprintf("invalid value for %s: %s", key, value),
-				File:    p.filePath,
-			}
+			return p.invalidValue(key, value, "Use a decimal number")
 		}
-	}
-
-	return &ParseError{
-		Line:    p.lineNum,
-		Message: "unexpected end of file in header section",
-		File:    p.filePath,
-	}
+		return nil
+	})
 }
 
 // parsePoint parses a [_point] section
@@ -163,39 +391,18 @@ func (p *Parser) parsePoint() (*PointType, error) {
 		Labels: make(map[string]string),
 	}
 
-	for p.scanner.Scan() {
-		p.lineNum++
-		line := p.cleanLine(p.scanner.Text())
-
-		if line == "" {
-			continue
-		}
-
-		if line == "[end]" {
-			return point, nil
-		}
-
-		if err := p.parsePointProperty(point, line); err != nil {
-			return nil, err
-		}
-	}
-
-	return nil, &ParseError{
-		Line:    p.lineNum,
-		Message: "unexpected end of file in point section",
-		File:    p.filePath,
+	err := p.parseSection("point", func(prop lexedLine) error {
+		return p.parsePointProperty(point, prop)
+	})
+	if err != nil {
+		return nil, err
 	}
+	return point, nil
 }
 
 // parsePointProperty parses a single property line in a point definition
-func (p *Parser) parsePointProperty(point *PointType, line string) error {
-	parts := strings.SplitN(line, "=", 2)
-	if len(parts) != 2 {
-		return nil // Skip malformed lines
-	}
-
-	key := strings.TrimSpace(parts[0])
-	value := strings.TrimSpace(parts[1])
+func (p *Parser) parsePointProperty(point *PointType, prop lexedLine) error {
+	key, value := prop.key, prop.value
 
 	switch key {
 	case "Type":
@@ -208,19 +415,40 @@ func (p *Parser) parsePointProperty(point *PointType, line string) error {
 			point.Labels[langCode] = label
 		}
 	case "DayXpm":
-		xpm, err := p.parseXPM(value)
+		xpm, err := p.parseXPM(prop)
 		if err != nil {
 			return err
 		}
 		point.DayXpm = xpm
 	case "NightXpm":
-		xpm, err := p.parseXPM(value)
+		xpm, err := p.parseXPM(prop)
 		if err != nil {
 			return err
 		}
 		point.NightXpm = xpm
 	case "FontStyle":
 		point.FontStyle = value
+	case "ExtendedLabels":
+		point.ExtendedLabels = parseFlag(value)
+	case "DayCustomColor", "NightCustomColor", "DayFontColor", "NightFontColor", "CustomColor":
+		color, err := p.parseColor(key, value)
+		if err != nil || color.Hex == "" {
+			return err
+		}
+		switch key {
+		case "DayCustomColor":
+			color.Day = true
+			point.DayColors = append(point.DayColors, color)
+		case "NightCustomColor":
+			point.NightColors = append(point.NightColors, color)
+		case "DayFontColor":
+			color.Day = true
+			point.DayFontColor = color
+		case "NightFontColor":
+			point.NightFontColor = color
+		case "CustomColor":
+			point.CustomColor = color
+		}
 	}
 
 	return nil
@@ -232,39 +460,18 @@ func (p *Parser) parseLine() (*LineType, error) {
 		Labels: make(map[string]string),
 	}
 
-	for p.scanner.Scan() {
-		p.lineNum++
-		textLine := p.cleanLine(p.scanner.Text())
-
-		if textLine == "" {
-			continue
-		}
-
-		if textLine == "[end]" {
-			return line, nil
-		}
-
-		if err := p.parseLineProperty(line, textLine); err != nil {
-			return nil, err
-		}
-	}
-
-	return nil, &ParseError{
-		Line:    p.lineNum,
-		Message: "unexpected end of file in line section",
-		File:    p.filePath,
+	err := p.parseSection("line", func(prop lexedLine) error {
+		return p.parseLineProperty(line, prop)
+	})
+	if err != nil {
+		return nil, err
 	}
+	return line, nil
 }
 
 // parseLineProperty parses a single property line in a line definition
-func (p *Parser) parseLineProperty(line *LineType, textLine string) error {
-	parts := strings.SplitN(textLine, "=", 2)
-	if len(parts) != 2 {
-		return nil
-	}
-
-	key := strings.TrimSpace(parts[0])
-	value := strings.TrimSpace(parts[1])
+func (p *Parser) parseLineProperty(line *LineType, prop lexedLine) error {
+	key, value := prop.key, prop.value
 
 	var err error
 	switch key {
@@ -279,20 +486,51 @@ func (p *Parser) parseLineProperty(line *LineType, textLine string) error {
 		line.LineWidth, err = strconv.Atoi(value)
 	case "BorderWidth":
 		line.BorderWidth, err = strconv.Atoi(value)
+	case "NightLineWidth":
+		line.NightLineWidth, err = strconv.Atoi(value)
+	case "NightBorderWidth":
+		line.NightBorderWidth, err = strconv.Atoi(value)
 	case "LineStyle":
 		line.LineStyle = value
-	case "Xpm":
-		line.DayXpm, err = p.parseXPM(value)
+	case "Xpm", "NightXpm":
+		xpm, err := p.parseXPM(prop)
+		if err != nil {
+			return err
+		}
+		if key == "Xpm" {
+			line.DayXpm = xpm
+		} else {
+			line.NightXpm = xpm
+		}
 	case "UseOrientation":
-		line.UseOrientation = strings.ToUpper(value) == "Y" || value == "1"
+		line.UseOrientation = parseFlag(value)
+	case "ExtendedLabels":
+		line.ExtendedLabels = parseFlag(value)
+	case "FontStyle":
+		line.FontStyle = value
+	case "DayCustomColor", "NightCustomColor", "DayFontColor", "NightFontColor", "CustomColor":
+		color, err := p.parseColor(key, value)
+		if err != nil || color.Hex == "" {
+			return err
+		}
+		switch key {
+		case "DayCustomColor":
+			color.Day = true
+			line.DayColors = append(line.DayColors, color)
+		case "NightCustomColor":
+			line.NightColors = append(line.NightColors, color)
+		case "DayFontColor":
+			color.Day = true
+			line.DayFontColor = color
+		case "NightFontColor":
+			line.NightFontColor = color
+		case "CustomColor":
+			line.CustomColor = color
+		}
 	}
 
 	if err != nil {
-		return &ParseError{
-			Line:    p.lineNum,
-			Message: fmt.Sprintf("invalid value for %s: %s", key, value),
-			File:    p.filePath,
-		}
+		return p.invalidValue(key, value, "Use a width in pixels, e.g. 3")
 	}
 
 	return nil
@@ -304,39 +542,18 @@ func (p *Parser) parsePolygon() (*PolygonType, error) {
 		Labels: make(map[string]string),
 	}
 
-	for p.scanner.Scan() {
-		p.lineNum++
-		line := p.cleanLine(p.scanner.Text())
-
-		if line == "" {
-			continue
-		}
-
-		if line == "[end]" {
-			return polygon, nil
-		}
-
-		if err := p.parsePolygonProperty(polygon, line); err != nil {
-			return nil, err
-		}
-	}
-
-	return nil, &ParseError{
-		Line:    p.lineNum,
-		Message: "unexpected end of file in polygon section",
-		File:    p.filePath,
+	err := p.parseSection("polygon", func(prop lexedLine) error {
+		return p.parsePolygonProperty(polygon, prop)
+	})
+	if err != nil {
+		return nil, err
 	}
+	return polygon, nil
 }
 
 // parsePolygonProperty parses a single property line in a polygon definition
-func (p *Parser) parsePolygonProperty(polygon *PolygonType, line string) error {
-	parts := strings.SplitN(line, "=", 2)
-	if len(parts) != 2 {
-		return nil
-	}
-
-	key := strings.TrimSpace(parts[0])
-	value := strings.TrimSpace(parts[1])
+func (p *Parser) parsePolygonProperty(polygon *PolygonType, prop lexedLine) error {
+	key, value := prop.key, prop.value
 
 	switch key {
 	case "Type":
@@ -346,100 +563,173 @@ func (p *Parser) parsePolygonProperty(polygon *PolygonType, line string) error {
 		if langCode != "" {
 			polygon.Labels[langCode] = label
 		}
-	case "Xpm":
-		xpm, err := p.parseXPM(value)
+	case "Xpm", "NightXpm":
+		xpm, err := p.parseXPM(prop)
 		if err != nil {
 			return err
 		}
-		polygon.DayXpm = xpm
+		if key == "Xpm" {
+			polygon.DayXpm = xpm
+		} else {
+			polygon.NightXpm = xpm
+		}
 	case "ExtendedLabels":
-		polygon.ExtendedLabels = strings.ToUpper(value) == "Y" || value == "1"
+		polygon.ExtendedLabels = parseFlag(value)
 	case "FontStyle":
 		polygon.FontStyle = value
+	case "DayCustomColor", "NightCustomColor", "DayFontColor", "NightFontColor", "ContourColor", "CustomColor":
+		color, err := p.parseColor(key, value)
+		if err != nil || color.Hex == "" {
+			return err
+		}
+		switch key {
+		case "DayCustomColor":
+			color.Day = true
+			polygon.DayColors = append(polygon.DayColors, color)
+		case "NightCustomColor":
+			polygon.NightColors = append(polygon.NightColors, color)
+		case "DayFontColor":
+			color.Day = true
+			polygon.DayFontColor = color
+		case "NightFontColor":
+			polygon.NightFontColor = color
+		case "ContourColor":
+			polygon.ContourColor = color
+		case "CustomColor":
+			polygon.CustomColor = color
+		}
 	}
 
 	return nil
 }
 
-// parseDrawOrder parses the [_drawOrder] section
+// parseDrawOrder parses the [_drawOrder] section. Each line puts a polygon
+// type on a level: Type=0x03,1
 func (p *Parser) parseDrawOrder(drawOrder *DrawOrder) error {
-	for p.scanner.Scan() {
-		p.lineNum++
-		line := p.cleanLine(p.scanner.Text())
-
-		if line == "" {
-			continue
-		}
-
-		if line == "[end]" {
+	return p.parseSection("draw order", func(prop lexedLine) error {
+		key, value := prop.key, prop.value
+		if key != "Type" {
 			return nil
 		}
 
-		parts := strings.SplitN(line, "=", 2)
-		if len(parts) != 2 || strings.TrimSpace(parts[0]) != "Type" {
-			continue
+		typeCode, levelValue, ok := strings.Cut(value, ",")
+		levelAt := prop.valueAt + len(typeCode) + 1 +
+			len(levelValue) - len(strings.TrimLeftFunc(levelValue, unicode.IsSpace))
+		typeCode = strings.TrimSpace(typeCode)
+		levelValue = strings.TrimSpace(levelValue)
+		if _, err := ParseTypeCode(typeCode, ""); err != nil {
+			return p.fail(Diagnostic{
+				Column:  p.column(prop.valueAt),
+				Message: fmt.Sprintf("invalid draw order type: %s", typeCode),
+				Hint:    "Use a polygon type such as 0x03, or 0x10f04 for an extended type",
+			})
+		}
+		if !ok {
+			return p.fail(Diagnostic{
+				Column:  p.column(prop.valueAt + len(value)),
+				Message: fmt.Sprintf("missing draw order level for %s", typeCode),
+				Hint:    "Write the level after the type, e.g. Type=" + typeCode + ",1",
+			})
+		}
+		level, err := strconv.Atoi(levelValue)
+		if err != nil || level < 1 {
+			return p.fail(Diagnostic{
+				Column:  p.column(levelAt),
+				Message: fmt.Sprintf("invalid draw order level for %s: %s", typeCode, levelValue),
+				Hint:    "The level is a number from 1 up, e.g. Type=" + typeCode + ",1",
+			})
 		}
 
-		// Draw order types are listed as Type=0x01,1
-		// We'll just store the type code for now
-		typeCode := strings.Split(strings.TrimSpace(parts[1]), ",")[0]
-		// For now, just add to points (we'd need more logic to determine category)
-		drawOrder.Points = append(drawOrder.Points, typeCode)
-	}
-
-	return nil
+		drawOrder.Polygons = append(drawOrder.Polygons, DrawOrderEntry{Type: typeCode, Level: level})
+		return nil
+	})
 }
 
-// parseString parses a string definition like "0x04,Bank"
+// parseString parses a string definition like "0x04,Bank" of the current
+// line and converts the label to UTF-8
 func (p *Parser) parseString(value string) (langCode, label string) {
-	parts := strings.SplitN(value, ",", 2)
-	if len(parts) != 2 {
+	langCode, label, at := splitLabel(value)
+	if langCode == "" {
 		return "", ""
 	}
-
-	langCode = strings.TrimSpace(parts[0])
-	label = strings.TrimSpace(parts[1])
-	return
+	return langCode, p.decodeLabel(label, p.line.valueAt+at)
 }
 
-// parseXPM parses an XPM definition (simplified for now)
-func (p *Parser) parseXPM(value string) (*XPMIcon, error) {
-	// XPM format: "width height colors chars_per_pixel"
-	value = strings.Trim(value, "\"")
-	parts := strings.Fields(value)
+// splitLabel splits a String= value into its language code and label. at is
+// the offset of the label in value.
+func splitLabel(value string) (langCode, label string, at int) {
+	comma := strings.IndexByte(value, ',')
+	if comma < 0 {
+		return "", "", 0
+	}
+	rest := value[comma+1:]
+	at = comma + 1 + len(rest) - len(strings.TrimLeftFunc(rest, unicode.IsSpace))
+	return strings.TrimSpace(value[:comma]), strings.TrimSpace(rest), at
+}
 
-	if len(parts) < 4 {
-		return nil, &ParseError{
-			Line:    p.lineNum,
-			Message: "invalid XPM format",
-			File:    p.filePath,
+// decodeLabel converts a label starting at byte offset at of the current
+// line to UTF-8. A label that is valid UTF-8 is taken as it is and checked
+// against the declared code page; anything else is read in that code page.
+func (p *Parser) decodeLabel(label string, at int) string {
+	if !utf8.ValidString(label) {
+		p.legacyText = true
+		text, err := DecodeText(label, p.codePage)
+		if err != nil {
+			return label
 		}
+		return text
 	}
 
-	xpm := &XPMIcon{
-		Palette: make(map[string]Color),
-		Data:    make([]string, 0),
+	if !isASCII(label) {
+		p.utf8Text = true
 	}
-
-	var err error
-	xpm.Width, err = strconv.Atoi(parts[0])
-	if err != nil {
-		return nil, err
+	if err := CheckText(label, p.codePage); err != nil {
+		var cpErr *CodePageError
+		if errors.As(err, &cpErr) {
+			p.warn(Diagnostic{
+				Column:  p.column(at + cpErr.Offset),
+				Message: cpErr.Error(),
+				Hint:    "Change the label, or switch the file to another code page with typtui convert",
+			})
+		}
 	}
+	return label
+}
 
-	xpm.Height, err = strconv.Atoi(parts[1])
-	if err != nil {
-		return nil, err
-	}
+// parseXPM parses an XPM definition (simplified for now)
+func (p *Parser) parseXPM(prop lexedLine) (*XPMIcon, error) {
+	// XPM format: "width height colors chars_per_pixel". Blanking the
+	// quotes keeps the offsets of the fields.
+	header := strings.ReplaceAll(prop.value, "\"", " ")
+	parts := splitFields(header, prop.valueAt)
 
-	xpm.Colors, err = strconv.Atoi(parts[2])
-	if err != nil {
+	if len(parts) < 4 {
+		err := p.fail(Diagnostic{
+			Column:  p.column(prop.valueAt),
+			Message: "invalid XPM format",
+			Hint:    "The XPM header is \"width height colors chars_per_pixel\"",
+		})
+		p.skipXPMData()
 		return nil, err
 	}
 
-	xpm.CharsPerPixel, err = strconv.Atoi(parts[3])
-	if err != nil {
-		return nil, err
+	xpm := &XPMIcon{
+		Data: make([]string, 0),
+	}
+
+	fields := []*int{&xpm.Width, &xpm.Height, &xpm.Colors, &xpm.CharsPerPixel}
+	for i, field := range fields {
+		n, err := strconv.Atoi(parts[i].text)
+		if err != nil || n < 0 {
+			err := p.fail(Diagnostic{
+				Column:  p.column(parts[i].at),
+				Message: fmt.Sprintf("invalid XPM header value: %s", parts[i].text),
+				Hint:    "The XPM header is \"width height colors chars_per_pixel\"",
+			})
+			p.skipXPMData()
+			return nil, err
+		}
+		*field = n
 	}
 
 	// Parse color palette and data lines
@@ -447,33 +737,41 @@ func (p *Parser) parseXPM(value string) (*XPMIcon, error) {
 	totalLinesToRead := xpm.Colors + xpm.Height
 	linesRead := 0
 
-	for linesRead < totalLinesToRead && p.scanner.Scan() {
-		p.lineNum++
-		line := p.scanner.Text()
-
+	for linesRead < totalLinesToRead && p.scan() {
 		// Skip empty lines and comments
-		if strings.TrimSpace(line) == "" || strings.HasPrefix(strings.TrimSpace(line), ";") {
+		if p.line.kind == lineBlank || p.line.kind == lineComment {
 			continue
 		}
 
 		// Check if this is a quoted line (XPM data)
-		if !strings.HasPrefix(strings.TrimSpace(line), "\"") {
-			// Not an XPM line, we're done
+		if p.line.kind != lineQuoted {
+			// Not an XPM line, leave it for the section
+			p.unscan()
 			break
 		}
 
-		line = strings.Trim(strings.TrimSpace(line), "\"")
+		line := p.line.value
 
 		if linesRead < xpm.Colors {
 			// This is a color definition line
-			// Format: "! c #778899" or "  c none"
-			if strings.Contains(line, " c ") {
-				parts := strings.SplitN(line, " c ", 2)
-				if len(parts) == 2 {
-					char := parts[0]
-					colorValue := strings.TrimSpace(parts[1])
-					xpm.Palette[char] = Color{Hex: colorValue}
+			// Format: "! c #778899", "  c none" or "ab c red s border"
+			entry, at, err := parsePaletteEntry(line, xpm.CharsPerPixel)
+			if err != nil {
+				if err := p.fail(Diagnostic{
+					Column:  p.column(p.line.valueAt + at),
+					Message: "invalid XPM colour: " + err.Error(),
+					Hint:    "Write colours as \"<chars> c #RRGGBB\", a colour name or none",
+				}); err != nil {
+					return nil, err
 				}
+			} else if _, dup := xpm.Palette.Lookup(entry.Key); dup {
+				p.warn(Diagnostic{
+					Column:  p.column(p.line.valueAt),
+					Message: fmt.Sprintf("duplicate XPM colour %q", entry.Key),
+					Hint:    "Each pixel key can only have one colour; the first one is used",
+				})
+			} else {
+				xpm.Palette = append(xpm.Palette, entry)
 			}
 		} else {
 			// This is pixel data
@@ -483,30 +781,99 @@ func (p *Parser) parseXPM(value string) (*XPMIcon, error) {
 		linesRead++
 	}
 
+	if linesRead < totalLinesToRead {
+		p.warn(Diagnostic{
+			Message: fmt.Sprintf("XPM has %d of %d palette and pixel lines", linesRead, totalLinesToRead),
+			Hint:    "Check the colors and height in the XPM header",
+		})
+	}
+
 	return xpm, nil
 }
 
+// skipXPMData skips the quoted lines of an XPM whose header was unusable
+func (p *Parser) skipXPMData() {
+	for p.scan() {
+		switch p.line.kind {
+		case lineBlank, lineComment, lineQuoted:
+		default:
+			p.unscan()
+			return
+		}
+	}
+}
+
 // skipToEnd skips lines until [end] is found
 func (p *Parser) skipToEnd() error {
-	for p.scanner.Scan() {
-		p.lineNum++
-		line := p.cleanLine(p.scanner.Text())
-		if line == "[end]" {
+	for p.scan() {
+		if p.line.isEnd() {
 			return nil
 		}
 	}
-	return &ParseError{
-		Line:    p.lineNum,
+	return p.fail(Diagnostic{
 		Message: "unexpected end of file while skipping section",
-		File:    p.filePath,
-	}
+		Hint:    "Add [end] at the end of the section",
+	})
 }
 
-// ParseFile is a convenience function to parse a TYP file
+// ParseFile is a convenience function to parse a TYP file. Compiled binary
+// files are detected from their header and decoded with ParseBinary.
 func ParseFile(filePath string) (*TYPFile, error) {
-	parser, err := NewParser(filePath)
+	file, err := os.Open(filePath)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	return ParseReader(file, filePath)
+}
+
+// ParseReader parses a TYP file from r, such as stdin, an embedded asset or
+// an HTTP body. Text and compiled binary input are told apart by the header.
+// The name is used as FilePath and in error messages.
+func ParseReader(r io.Reader, name string) (*TYPFile, error) {
+	typFile, _, err := parseReader(r, name, false)
+	return typFile, err
+}
+
+// ParseFileTolerant parses a TYP file like ParseFile, but keeps going after
+// errors and returns every problem it found. The error is only set when the
+// file cannot be read or a compiled file cannot be decoded.
+func ParseFileTolerant(filePath string) (*TYPFile, []Diagnostic, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	return ParseReaderTolerant(file, filePath)
+}
+
+// ParseReaderTolerant is the tolerant form of ParseReader, see
+// ParseFileTolerant
+func ParseReaderTolerant(r io.Reader, name string) (*TYPFile, []Diagnostic, error) {
+	return parseReader(r, name, true)
+}
+
+// parseReader detects the format of r and parses it
+func parseReader(r io.Reader, name string, tolerant bool) (*TYPFile, []Diagnostic, error) {
+	br := bufio.NewReader(r)
+
+	head, err := br.Peek(2 + len(binarySignature))
+	if err != nil && err != io.EOF {
+		return nil, nil, fmt.Errorf("error reading file: %w", err)
 	}
-	return parser.Parse()
+	if isBinaryTYP(head) {
+		data, err := io.ReadAll(br)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error reading file: %w", err)
+		}
+		typFile, err := ParseBinary(data, name)
+		return typFile, nil, err
+	}
+
+	p := NewReaderParser(br, name)
+	p.Tolerant = tolerant
+	typFile, err := p.Parse()
+	return typFile, p.Diagnostics(), err
 }
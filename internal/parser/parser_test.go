@@ -1,6 +1,11 @@
 package parser
 
 import (
+	"bytes"
+	"errors"
+	"os"
+	"strconv"
+	"strings"
 	"testing"
 )
 
@@ -140,24 +145,411 @@ func TestParseString(t *testing.T) {
 	}
 }
 
-func TestCleanLine(t *testing.T) {
-	p := &Parser{}
-
+func TestLexLine(t *testing.T) {
 	tests := []struct {
 		input string
-		want  string
+		kind  lineKind
+		text  string
+		value string
+	}{
+		{"; This is a comment", lineComment, "", ""},
+		{"Type=0x2f06 ; with comment", lineProperty, "Type=0x2f06", "0x2f06"},
+		{"  Type=0x2f06  ", lineProperty, "Type=0x2f06", "0x2f06"},
+		{"# Another comment", lineComment, "", ""},
+		{"Type=0x2f06", lineProperty, "Type=0x2f06", "0x2f06"},
+		{"DayFontColor=#FF0000 # red", lineProperty, "DayFontColor=#FF0000", "#FF0000"},
+		{"String=0x04,Bar; Pub", lineProperty, "String=0x04,Bar; Pub", "0x04,Bar; Pub"},
+		{"String=0x04,Hut #3", lineProperty, "String=0x04,Hut #3", "0x04,Hut #3"},
+		{"String=0x04,Rock 'n' Roll ;comment", lineProperty, "String=0x04,Rock 'n' Roll", "0x04,Rock 'n' Roll"},
+		{`"a c #FF0000" ; red`, lineQuoted, `"a c #FF0000"`, "a c #FF0000"},
+		{`"; # ;"`, lineQuoted, `"; # ;"`, "; # ;"},
+		{`DayXpm="8 8 2 1" ; icon`, lineProperty, `DayXpm="8 8 2 1"`, `"8 8 2 1"`},
+		{"[_point] ; a POI", lineSection, "[_point]", ""},
+		{"", lineBlank, "", ""},
+		{"stray", lineText, "stray", ""},
+	}
+
+	for _, tt := range tests {
+		got := lexLine(tt.input)
+		if got.kind != tt.kind || got.text != tt.text || got.value != tt.value {
+			t.Errorf("lexLine(%q) = kind %d, text %q, value %q; want kind %d, text %q, value %q",
+				tt.input, got.kind, got.text, got.value, tt.kind, tt.text, tt.value)
+		}
+	}
+}
+
+func TestLexLineProblems(t *testing.T) {
+	tests := []struct {
+		input  string
+		column int
+	}{
+		{`  "abc`, 3},
+		{`DayXpm="8 8 2 1`, 8},
+		{"[_point", 8},
+		{"[_point] x", 10},
+		{" =1", 2},
+	}
+
+	for _, tt := range tests {
+		l := lexLine(tt.input)
+		if l.problem == nil {
+			t.Errorf("lexLine(%q): expected a problem", tt.input)
+			continue
+		}
+		if col := l.column(l.problem.at); col != tt.column {
+			t.Errorf("lexLine(%q): problem %q at column %d, want %d", tt.input, l.problem.message, col, tt.column)
+		}
+	}
+
+	if l := lexLine(`String=0x04,12" Pizza`); l.problem != nil {
+		t.Errorf("A stray quote in a label must not be a problem: %s", l.problem.message)
+	}
+}
+
+func TestParseCommentCharsInValues(t *testing.T) {
+	source := "[_point]\nType=0x2f06\nString1=0x04,Bar; Pub\nString2=0x02,Hut #3 ; comment\n" +
+		"DayFontColor=#FF0000\nDayXpm=\"1 1 1 1\" ; icon\n\"# c #00FF00\"\n\"#\"\n[end]\n"
+
+	typFile, err := ParseReader(strings.NewReader(source), "comments.typ")
+	if err != nil {
+		t.Fatalf("Failed to parse: %v", err)
+	}
+
+	point := typFile.Points[0]
+	if point.Labels["0x04"] != "Bar; Pub" || point.Labels["0x02"] != "Hut #3" {
+		t.Errorf("Labels cut off: %v", point.Labels)
+	}
+	if point.DayFontColor.Hex != "#FF0000" {
+		t.Errorf("DayFontColor = %q", point.DayFontColor.Hex)
+	}
+	if point.DayXpm == nil || !hasColor(point.DayXpm, "#", "#00FF00") || len(point.DayXpm.Data) != 1 {
+		t.Errorf("Unexpected icon %+v", point.DayXpm)
+	}
+}
+
+func TestParseErrorColumns(t *testing.T) {
+	tests := []struct {
+		source string
+		column int
 	}{
-		{"; This is a comment", ""},
-		{"Type=0x2f06 ; with comment", "Type=0x2f06"},
-		{"  Type=0x2f06  ", "Type=0x2f06"},
-		{"# Another comment", ""},
-		{"Type=0x2f06", "Type=0x2f06"},
+		{"[_line]\nType=0x01\nLineWidth =  wide\n[end]\n", 14},
+		{"[_point]\nType=0x2f06\nDayXpm=\"8 8 x 1\"\n[end]\n", 13},
+		{"[_point]\nType=0x2f06\nDayXpm=\"8 8 2 1\"\n[end]\n[_point]\nType=0x2f07\nDayXpm=\"8 8 2 x\"\n[end]\n", 15},
+		{"[_drawOrder]\nType=0x13, zz\n[end]\n", 12},
+		{"[_id]\nCodePage=12x\n[end]\n", 10},
 	}
 
 	for _, tt := range tests {
-		got := p.cleanLine(tt.input)
-		if got != tt.want {
-			t.Errorf("cleanLine(%q) = %q, want %q", tt.input, got, tt.want)
+		_, err := ParseReader(strings.NewReader(tt.source), "columns.typ")
+		var parseErr *ParseError
+		if !errors.As(err, &parseErr) {
+			t.Errorf("Expected a ParseError for %q, got %v", tt.source, err)
+			continue
+		}
+		if parseErr.Column != tt.column {
+			t.Errorf("%q: error %v at column %d, want %d", tt.source, parseErr, parseErr.Column, tt.column)
 		}
 	}
 }
+
+func TestParseReader(t *testing.T) {
+	data, err := os.ReadFile("../../testdata/sample/minimal.typ")
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+
+	typFile, err := ParseReader(bytes.NewReader(data), "stdin")
+	if err != nil {
+		t.Fatalf("Failed to parse reader: %v", err)
+	}
+	if typFile.FilePath != "stdin" {
+		t.Errorf("Expected FilePath 'stdin', got %q", typFile.FilePath)
+	}
+	if len(typFile.Points) != 1 || typFile.Points[0].Labels["0x04"] != "Bank" {
+		t.Errorf("Unexpected points: %+v", typFile.Points)
+	}
+
+	var out bytes.Buffer
+	if err := Write(&out, typFile); err != nil {
+		t.Fatalf("Failed to write: %v", err)
+	}
+	if out.String() != string(data) {
+		t.Errorf("Write changed an unmodified file:\n%s", out.String())
+	}
+}
+
+func TestParseReaderBinary(t *testing.T) {
+	data, err := os.ReadFile("../../testdata/binary/minimal.typ")
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+
+	typFile, err := ParseReader(bytes.NewReader(data), "minimal.typ")
+	if err != nil {
+		t.Fatalf("Failed to parse reader: %v", err)
+	}
+	if !typFile.Binary {
+		t.Error("Expected compiled input to be decoded as binary")
+	}
+}
+
+func TestParseLongLine(t *testing.T) {
+	// A single XPM row well past bufio.Scanner's 64 KiB default
+	width := 100000
+	source := "[_polygon]\nType=0x01\n" +
+		"Xpm=\"" + strconv.Itoa(width) + " 1 1 1\"\n" +
+		"\"a c #FF0000\"\n" +
+		"\"" + strings.Repeat("a", width) + "\"\n" +
+		"[end]\n"
+
+	typFile, err := ParseReader(strings.NewReader(source), "wide.typ")
+	if err != nil {
+		t.Fatalf("Failed to parse long line: %v", err)
+	}
+	if len(typFile.Polygons) != 1 || typFile.Polygons[0].DayXpm == nil {
+		t.Fatalf("Expected one polygon with an XPM, got %+v", typFile.Polygons)
+	}
+	if got := len(typFile.Polygons[0].DayXpm.Data[0]); got != width {
+		t.Errorf("Expected row of %d pixels, got %d", width, got)
+	}
+}
+
+func TestNewParserClosesFile(t *testing.T) {
+	p, err := NewParser("../../testdata/sample/minimal.typ")
+	if err != nil {
+		t.Fatalf("Failed to create parser: %v", err)
+	}
+	if _, err := p.Parse(); err != nil {
+		t.Fatalf("Failed to parse: %v", err)
+	}
+
+	file := p.closer.(*os.File)
+	if err := file.Close(); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("Expected file to be closed after Parse, got %v", err)
+	}
+}
+
+func TestParseErrorMessage(t *testing.T) {
+	tests := []struct {
+		err  ParseError
+		want string
+	}{
+		{ParseError{Line: 12, Column: 5, Message: "bad", File: "a.typ"}, "a.typ:12:5: bad"},
+		{ParseError{Line: 65, Message: "bad", File: "a.typ"}, "a.typ:65: bad"},
+		{ParseError{Line: 7, Column: 3, Message: "bad"}, "line 7, col 3: bad"},
+		{ParseError{Offset: 0x20, Message: "bad"}, "offset 0x20: bad"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.err.Error(); got != tt.want {
+			t.Errorf("Error() = %q, want %q", got, tt.want)
+		}
+	}
+}
+
+const brokenTYP = `[_id]
+CodePage=abc
+[end]
+
+[_point]
+Type=0x2f06
+String=0x04,Bank
+
+[_line]
+Type=0x01
+LineWidth=wide
+Xpm="0 0 x 0"
+"1 c #FF0000"
+[end]
+
+[_polygon]
+Type=0x03
+[end]
+`
+
+func TestParseStrictStopsAtFirstError(t *testing.T) {
+	_, err := ParseReader(strings.NewReader(brokenTYP), "broken.typ")
+
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) {
+		t.Fatalf("Expected ParseError, got %v", err)
+	}
+	if parseErr.Line != 2 || parseErr.Column != 10 {
+		t.Errorf("Expected error at 2:10, got %d:%d", parseErr.Line, parseErr.Column)
+	}
+}
+
+func TestParseTolerant(t *testing.T) {
+	typFile, diagnostics, err := ParseReaderTolerant(strings.NewReader(brokenTYP), "broken.typ")
+	if err != nil {
+		t.Fatalf("Tolerant parse failed: %v", err)
+	}
+
+	want := []Diagnostic{
+		{Severity: SeverityError, Line: 2, Column: 10, Section: "_id", Index: -1},
+		{Severity: SeverityError, Line: 9, Column: 1, Section: "_point", Index: 0},
+		{Severity: SeverityError, Line: 11, Column: 11, Section: "_line", Index: 0},
+		{Severity: SeverityError, Line: 12, Column: 10, Section: "_line", Index: 0},
+	}
+	if len(diagnostics) != len(want) {
+		t.Fatalf("Expected %d diagnostics, got %d: %v", len(want), len(diagnostics), diagnostics)
+	}
+	for i, d := range diagnostics {
+		w := want[i]
+		if d.Severity != w.Severity || d.Line != w.Line || d.Column != w.Column ||
+			d.Section != w.Section || d.Index != w.Index {
+			t.Errorf("Diagnostic %d = %+v, want %+v", i, d, w)
+		}
+		if d.Hint == "" {
+			t.Errorf("Diagnostic %d has no hint: %v", i, d)
+		}
+	}
+	if !HasErrors(diagnostics) {
+		t.Error("Expected HasErrors to be true")
+	}
+
+	// Everything around the errors is still read
+	if len(typFile.Points) != 1 || typFile.Points[0].Labels["0x04"] != "Bank" {
+		t.Errorf("Expected the unterminated point to be kept, got %+v", typFile.Points)
+	}
+	if len(typFile.Lines) != 1 || typFile.Lines[0].Type != "0x01" || typFile.Lines[0].DayXpm != nil {
+		t.Errorf("Unexpected lines: %+v", typFile.Lines)
+	}
+	if len(typFile.Polygons) != 1 || typFile.Polygons[0].Type != "0x03" {
+		t.Errorf("Expected the polygon after the errors, got %+v", typFile.Polygons)
+	}
+
+	// Lines the parser could not read are kept on save
+	var out bytes.Buffer
+	if err := Write(&out, typFile); err != nil {
+		t.Fatalf("Failed to write: %v", err)
+	}
+	if out.String() != brokenTYP {
+		t.Errorf("Write changed an unmodified file:\n%s", out.String())
+	}
+}
+
+func TestParseWarnings(t *testing.T) {
+	source := "stray\n[end]\n[_point]\nType=0x2f06\nnonsense\n[end]\n"
+
+	p := NewReaderParser(strings.NewReader(source), "warn.typ")
+	if _, err := p.Parse(); err != nil {
+		t.Fatalf("Warnings must not fail a strict parse: %v", err)
+	}
+
+	diagnostics := p.Diagnostics()
+	if len(diagnostics) != 3 {
+		t.Fatalf("Expected 3 warnings, got %v", diagnostics)
+	}
+	for i, line := range []int{1, 2, 5} {
+		if diagnostics[i].Severity != SeverityWarning || diagnostics[i].Line != line {
+			t.Errorf("Diagnostic %d = %v, want a warning on line %d", i, diagnostics[i], line)
+		}
+	}
+	if HasErrors(diagnostics) {
+		t.Error("Expected HasErrors to be false")
+	}
+}
+
+func TestParseDrawOrderErrors(t *testing.T) {
+	source := "[_drawOrder]\nType=0x03\nType=0x13,0\nType=zz,1\nType=0x10f04,4\n[end]\n"
+
+	typFile, diagnostics, err := ParseReaderTolerant(strings.NewReader(source), "draworder.typ")
+	if err != nil {
+		t.Fatalf("Tolerant parse failed: %v", err)
+	}
+
+	if len(diagnostics) != 3 {
+		t.Fatalf("Expected 3 diagnostics, got %v", diagnostics)
+	}
+	for i, line := range []int{2, 3, 4} {
+		if diagnostics[i].Line != line || diagnostics[i].Section != "_drawOrder" {
+			t.Errorf("Diagnostic %d = %+v, want line %d in _drawOrder", i, diagnostics[i], line)
+		}
+	}
+	if typFile.DrawOrder.Level("0x10f04") != 4 || len(typFile.DrawOrder.Polygons) != 1 {
+		t.Errorf("Expected only the valid entry, got %v", typFile.DrawOrder.Polygons)
+	}
+}
+
+const propertiesTYP = `[_point]
+Type=0x2f06
+ExtendedLabels=Y
+DayFontColor=#FF0000
+NightFontColor=#00FF00
+[end]
+
+[_line]
+Type=0x01
+LineWidth=4
+BorderWidth=1
+NightLineWidth=3
+NightBorderWidth=0
+ExtendedLabels=Y
+FontStyle=SmallFont
+NightXpm="32 1 1 1"
+"a c #000000"
+"aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+DayFontColor=#112233
+[end]
+
+[_polygon]
+Type=0x03
+NightXpm="0 0 1 0"
+"a c #101010"
+ContourColor=#445566
+NightFontColor=#FFFFFF
+[end]
+`
+
+func TestParseProperties(t *testing.T) {
+	typFile, err := ParseReader(strings.NewReader(propertiesTYP), "properties.typ")
+	if err != nil {
+		t.Fatalf("Failed to parse: %v", err)
+	}
+
+	point := typFile.Points[0]
+	if !point.ExtendedLabels {
+		t.Error("Expected point ExtendedLabels")
+	}
+	if point.DayFontColor.Hex != "#FF0000" || point.NightFontColor.Hex != "#00FF00" {
+		t.Errorf("Point font colours = %q/%q", point.DayFontColor.Hex, point.NightFontColor.Hex)
+	}
+
+	line := typFile.Lines[0]
+	if line.NightLineWidth != 3 || line.NightBorderWidth != 0 {
+		t.Errorf("Night widths = %d/%d, want 3/0", line.NightLineWidth, line.NightBorderWidth)
+	}
+	if !line.ExtendedLabels || line.FontStyle != "SmallFont" {
+		t.Errorf("ExtendedLabels = %v, FontStyle = %q", line.ExtendedLabels, line.FontStyle)
+	}
+	if line.NightXpm == nil || line.NightXpm.Width != 32 {
+		t.Errorf("Expected a 32 pixel night pattern, got %+v", line.NightXpm)
+	}
+	if line.DayFontColor.Hex != "#112233" {
+		t.Errorf("Line day font colour = %q", line.DayFontColor.Hex)
+	}
+
+	polygon := typFile.Polygons[0]
+	if polygon.NightXpm == nil || !hasColor(polygon.NightXpm, "a", "#101010") {
+		t.Errorf("Expected a night colour, got %+v", polygon.NightXpm)
+	}
+	if polygon.ContourColor.Hex != "#445566" || polygon.NightFontColor.Hex != "#FFFFFF" {
+		t.Errorf("Contour/night font colour = %q/%q", polygon.ContourColor.Hex, polygon.NightFontColor.Hex)
+	}
+}
+
+func TestParseInvalidColor(t *testing.T) {
+	source := "[_point]\nType=0x2f06\nDayFontColor=#12345\n[end]\n"
+
+	_, diagnostics, err := ParseReaderTolerant(strings.NewReader(source), "color.typ")
+	if err != nil {
+		t.Fatalf("Tolerant parse failed: %v", err)
+	}
+	if len(diagnostics) != 1 || diagnostics[0].Line != 3 {
+		t.Fatalf("Expected one diagnostic on line 3, got %v", diagnostics)
+	}
+	if diagnostics[0].Hint != "Use a colour in #RRGGBB form" {
+		t.Errorf("Unexpected hint %q", diagnostics[0].Hint)
+	}
+}
@@ -0,0 +1,473 @@
+package parser
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+)
+
+// document is the concrete syntax of a parsed TYP text file. It keeps every
+// source line, including comments, blank lines and sections the parser does
+// not understand, so an unmodified file is written back byte for byte.
+type document struct {
+	sections []*syntaxSection
+	trailer  []string // raw lines after the last section
+	newline  string   // line ending for rewritten lines
+
+	// textCodePage is the encoding of the source text: a single-byte code
+	// page, CodePageUTF8, or 0 for plain ASCII, which is written in the
+	// code page of the header
+	textCodePage int
+}
+
+// mapLines replaces every raw line of the document with fn(line). Line
+// endings are passed through fn too.
+func (d *document) mapLines(fn func(string) (string, error)) error {
+	mapAll := func(lines []string) error {
+		for i, line := range lines {
+			mapped, err := fn(line)
+			if err != nil {
+				return err
+			}
+			lines[i] = mapped
+		}
+		return nil
+	}
+	for _, sec := range d.sections {
+		single := []string{sec.header, sec.end}
+		for _, lines := range [][]string{sec.leading, sec.body, single} {
+			if err := mapAll(lines); err != nil {
+				return err
+			}
+		}
+		sec.header, sec.end = single[0], single[1]
+	}
+	return mapAll(d.trailer)
+}
+
+// syntaxSection is a [name] ... [end] block of the source. Raw lines keep
+// their line endings.
+type syntaxSection struct {
+	name    string
+	leading []string // comment and blank lines before the header
+	header  string
+	body    []string
+	end     string // empty when the section was not closed
+
+	// snapshot holds the canonical rendering of each property as parsed,
+	// keyed by entry id. It tells edited properties from unchanged ones.
+	snapshot map[string]string
+}
+
+// syntaxEntry is one property of a section: a key line and, for XPM data,
+// the quoted lines that follow it. Comment and blank lines are entries with
+// an empty id.
+type syntaxEntry struct {
+	id    string
+	lines []string
+}
+
+// key returns the form used to compare entries
+func (e syntaxEntry) key() string {
+	return strings.Join(e.lines, "\n")
+}
+
+// labelID returns the entry id of a String= property
+func labelID(langCode string) string {
+	return "String:" + langCode
+}
+
+// entryMap indexes rendered entries by id
+func entryMap(entries []syntaxEntry) map[string]string {
+	m := make(map[string]string, len(entries))
+	for _, e := range entries {
+		m[e.id] = e.key()
+	}
+	return m
+}
+
+// equalEntryMaps reports whether two entry maps hold the same properties
+func equalEntryMaps(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for id, v := range a {
+		if w, ok := b[id]; !ok || v != w {
+			return false
+		}
+	}
+	return true
+}
+
+// scanRawLines is a bufio.SplitFunc that returns lines together with their
+// line endings
+func scanRawLines(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	if atEOF && len(data) == 0 {
+		return 0, nil, nil
+	}
+	if i := bytes.IndexByte(data, '\n'); i >= 0 {
+		return i + 1, data[:i+1], nil
+	}
+	if atEOF {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
+
+// trimNewline strips the line ending from a raw line
+func trimNewline(raw string) string {
+	return strings.TrimRight(raw, "\r\n")
+}
+
+// groupEntries splits section body lines into entries. Quoted lines belong
+// to the XPM property above them, along with any comments between them.
+func groupEntries(section string, body []string) []syntaxEntry {
+	var entries []syntaxEntry
+	var pending []string // trivia seen inside an XPM block
+	inXPM := false
+	drawOrderSeen := make(map[string]int)
+
+	flushPending := func() {
+		for _, raw := range pending {
+			entries = append(entries, syntaxEntry{lines: []string{raw}})
+		}
+		pending = nil
+	}
+
+	for _, raw := range body {
+		line := lexLine(trimNewline(raw))
+
+		switch {
+		case inXPM && line.kind == lineQuoted:
+			last := &entries[len(entries)-1]
+			last.lines = append(last.lines, pending...)
+			last.lines = append(last.lines, raw)
+			pending = nil
+			continue
+		case line.kind == lineBlank || line.kind == lineComment:
+			if inXPM {
+				pending = append(pending, raw)
+			} else {
+				entries = append(entries, syntaxEntry{lines: []string{raw}})
+			}
+			continue
+		}
+
+		flushPending()
+		inXPM = false
+
+		// Lines without a key are identified by their text, so the writer
+		// keeps them as they are
+		key := line.text
+		if line.kind == lineProperty {
+			key = line.key
+		}
+		id := key
+		switch {
+		case section == "_drawOrder" && key == "Type" && line.kind == lineProperty:
+			typeCode, _, _ := strings.Cut(line.value, ",")
+			typ := drawOrderType(strings.TrimSpace(typeCode))
+			drawOrderSeen[typ]++
+			id = drawOrderID(typ, drawOrderSeen[typ])
+		case key == "String", key == "String1", key == "String2", key == "String3", key == "String4":
+			if line.kind == lineProperty {
+				langCode, _, _ := splitLabel(line.value)
+				id = labelID(langCode)
+			}
+		}
+		if line.kind == lineProperty && strings.HasSuffix(key, "Xpm") {
+			inXPM = true
+		}
+		entries = append(entries, syntaxEntry{id: id, lines: []string{raw}})
+	}
+	flushPending()
+
+	return entries
+}
+
+// outputBuilder writes a document, tracking whether the output currently ends
+// in the middle of a line
+type outputBuilder struct {
+	b        *bufio.Writer
+	newline  string
+	codePage int // encoding of rewritten lines
+	openLine bool
+}
+
+// raw writes source lines unchanged
+func (o *outputBuilder) raw(lines ...string) {
+	for _, line := range lines {
+		if line == "" {
+			continue
+		}
+		o.b.WriteString(line)
+		o.openLine = !strings.HasSuffix(line, "\n")
+	}
+}
+
+// line writes a new line, terminating the previous one first if needed
+func (o *outputBuilder) line(text string) {
+	if o.openLine {
+		o.b.WriteString(o.newline)
+	}
+	o.b.WriteString(encodeOutput(text, o.codePage))
+	o.b.WriteString(o.newline)
+	o.openLine = false
+}
+
+// writeDocument writes a TYPFile through its concrete syntax tree. Sections
+// whose content is unchanged are copied verbatim; edited sections keep their
+// comments, unknown keys and property order with only the changed
+// properties rewritten. Types are written in the order of their slices: the
+// source sections of a kind are filled in turn, each type taking its leading
+// comments along, and a new type follows the type before it in the slice.
+// Deleted types are dropped. Rewritten lines are encoded in codePage; source
+// lines are copied as they are.
+func writeDocument(b *bufio.Writer, typFile *TYPFile, codePage int) error {
+	doc := typFile.syntax
+	o := &outputBuilder{b: b, newline: doc.newline, codePage: codePage}
+
+	inDoc := make(map[*syntaxSection]bool)
+	lastOfKind := make(map[string]*syntaxSection)
+	for _, sec := range doc.sections {
+		inDoc[sec] = true
+		lastOfKind[sec.name] = sec
+	}
+
+	// Draw order lines stay in the section they came from, each section
+	// taking as many lines of a type as it had; new ones go to the last
+	// draw order section
+	drawOrder := drawOrderEntries(typFile.DrawOrder)
+	var drawOrderSections []*syntaxSection
+	parsedTypes := make(map[*syntaxSection]map[string]int)
+	for _, sec := range doc.sections {
+		if sec.name != "_drawOrder" {
+			continue
+		}
+		drawOrderSections = append(drawOrderSections, sec)
+		parsedTypes[sec] = make(map[string]int)
+		for id := range sec.snapshot {
+			typ, _, _ := strings.Cut(strings.TrimPrefix(id, "Type:"), "#")
+			parsedTypes[sec][typ]++
+		}
+	}
+	drawOrderAssigned := make(map[*syntaxSection][]DrawOrderEntry)
+	for _, entry := range typFile.DrawOrder.Polygons {
+		typ := drawOrderType(entry.Type)
+		owner := lastOfKind["_drawOrder"]
+		for _, sec := range drawOrderSections {
+			if parsedTypes[sec][typ] > 0 {
+				parsedTypes[sec][typ]--
+				owner = sec
+				break
+			}
+		}
+		drawOrderAssigned[owner] = append(drawOrderAssigned[owner], entry)
+	}
+	drawOrderOwner := make(map[*syntaxSection][]syntaxEntry)
+	for _, sec := range drawOrderSections {
+		drawOrderOwner[sec] = drawOrderEntries(DrawOrder{Polygons: drawOrderAssigned[sec]})
+	}
+	writeNewDrawOrder := func(blankBefore bool) {
+		if lastOfKind["_drawOrder"] != nil || len(drawOrder) == 0 {
+			return
+		}
+		if blankBefore {
+			o.line("")
+		}
+		o.line("[_drawOrder]")
+		for _, entry := range drawOrderEntries(DrawOrder{Polygons: typFile.DrawOrder.sorted()}) {
+			o.line(entry.lines[0])
+		}
+		o.line("[end]")
+		if !blankBefore {
+			o.line("")
+		}
+	}
+
+	// Match types to their source sections in slice order. New types are
+	// kept with the type before them, or first if there is none.
+	owner := make(map[*syntaxSection][]syntaxEntry)
+	placed := make(map[string][]placedType)
+	firstNew := make(map[string][][]syntaxEntry)
+	place := func(kind string, sec *syntaxSection, entries []syntaxEntry) {
+		_, taken := owner[sec]
+		if sec != nil && inDoc[sec] && !taken {
+			owner[sec] = entries
+			placed[kind] = append(placed[kind], placedType{sec: sec, entries: entries})
+			return
+		}
+		if n := len(placed[kind]); n > 0 {
+			placed[kind][n-1].following = append(placed[kind][n-1].following, entries)
+			return
+		}
+		firstNew[kind] = append(firstNew[kind], entries)
+	}
+	for _, point := range typFile.Points {
+		place("_point", point.syntax, pointEntries(point))
+	}
+	for _, line := range typFile.Lines {
+		place("_line", line.syntax, lineEntries(line))
+	}
+	for _, polygon := range typFile.Polygons {
+		place("_polygon", polygon.syntax, polygonEntries(polygon))
+	}
+	slot := make(map[string]int)
+
+	writeNew := func(name string, types [][]syntaxEntry) {
+		for _, entries := range types {
+			o.line("")
+			o.line("[" + name + "]")
+			for _, entry := range entries {
+				for _, line := range entry.lines {
+					o.line(line)
+				}
+			}
+			o.line("[end]")
+		}
+	}
+
+	headerWritten := false
+	if lastOfKind["_id"] == nil {
+		headerEntries := headerEntries(typFile.Header)
+		if len(headerEntries) > 0 {
+			o.line("[_id]")
+			for _, entry := range headerEntries {
+				o.line(entry.lines[0])
+			}
+			o.line("[end]")
+			o.line("")
+		}
+		headerWritten = true
+		writeNewDrawOrder(false)
+	}
+
+	for _, sec := range doc.sections {
+		if _, ok := owner[sec]; !ok {
+			o.raw(sec.leading...)
+		}
+
+		switch sec.name {
+		case "_id":
+			if headerWritten {
+				o.raw(sec.header)
+				o.raw(sec.body...)
+				o.raw(sec.end)
+			} else {
+				writeSection(o, sec, headerEntries(typFile.Header))
+				headerWritten = true
+				writeNewDrawOrder(true)
+			}
+		case "_drawOrder":
+			writeSection(o, sec, drawOrderOwner[sec])
+		case "_point", "_line", "_polygon":
+			if _, ok := owner[sec]; ok {
+				// The source sections of a kind take its types in slice order
+				if slot[sec.name] == 0 {
+					writeNew(sec.name, firstNew[sec.name])
+					firstNew[sec.name] = nil
+				}
+				typ := placed[sec.name][slot[sec.name]]
+				slot[sec.name]++
+				o.raw(typ.sec.leading...)
+				writeSection(o, typ.sec, typ.entries)
+				writeNew(sec.name, typ.following)
+			}
+		default:
+			o.raw(sec.header)
+			o.raw(sec.body...)
+			o.raw(sec.end)
+		}
+
+		// New types of a kind whose source sections were all deleted
+		if lastOfKind[sec.name] == sec {
+			writeNew(sec.name, firstNew[sec.name])
+			firstNew[sec.name] = nil
+		}
+	}
+
+	o.raw(doc.trailer...)
+	writeNew("_point", firstNew["_point"])
+	writeNew("_line", firstNew["_line"])
+	writeNew("_polygon", firstNew["_polygon"])
+
+	return nil
+}
+
+// placedType is an existing type with the new types that follow it
+type placedType struct {
+	sec       *syntaxSection
+	entries   []syntaxEntry
+	following [][]syntaxEntry
+}
+
+// writeSection writes a section with the given current properties
+func writeSection(o *outputBuilder, sec *syntaxSection, entries []syntaxEntry) {
+	fresh := make(map[string]syntaxEntry, len(entries))
+	for _, e := range entries {
+		fresh[e.id] = e
+	}
+
+	o.raw(sec.header)
+
+	if equalEntryMaps(sec.snapshot, entryMap(entries)) {
+		o.raw(sec.body...)
+		o.raw(sec.end)
+		return
+	}
+
+	used := make(map[string]bool)
+	for _, entry := range groupEntries(sec.name, sec.body) {
+		if entry.id == "" {
+			o.raw(entry.lines...)
+			continue
+		}
+
+		current, known := fresh[entry.id]
+		_, parsed := sec.snapshot[entry.id]
+		switch {
+		case known && !used[entry.id]:
+			used[entry.id] = true
+			if sec.snapshot[entry.id] == current.key() {
+				o.raw(entry.lines...)
+			} else {
+				for _, line := range withSourceKey(current.lines, entry.lines[0]) {
+					o.line(line)
+				}
+			}
+		case known || parsed:
+			// Removed, or a duplicate of a property already written
+		default:
+			// Not understood by the parser, keep it as it is
+			o.raw(entry.lines...)
+		}
+	}
+
+	for _, entry := range entries {
+		if !used[entry.id] {
+			for _, line := range entry.lines {
+				o.line(line)
+			}
+		}
+	}
+
+	if sec.end != "" {
+		o.raw(sec.end)
+	} else {
+		o.line("[end]")
+	}
+}
+
+// withSourceKey gives a rewritten property the key it had in the source, so
+// an edited String2= line is not renamed to String=
+func withSourceKey(lines []string, source string) []string {
+	sourceKey, _, ok := strings.Cut(trimNewline(source), "=")
+	if !ok {
+		return lines
+	}
+	key, value, ok := strings.Cut(lines[0], "=")
+	if !ok || strings.TrimSpace(sourceKey) == key {
+		return lines
+	}
+	return append([]string{strings.TrimSpace(sourceKey) + "=" + value}, lines[1:]...)
+}
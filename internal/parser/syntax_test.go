@@ -0,0 +1,313 @@
+package parser
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// writeToString writes a TYPFile to a temporary file and returns its content
+func writeToString(t *testing.T, typFile *TYPFile) string {
+	t.Helper()
+
+	tempFile := filepath.Join(t.TempDir(), "out.typ")
+	if err := WriteFile(typFile, tempFile); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	content, err := os.ReadFile(tempFile)
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	return string(content)
+}
+
+func TestRoundTripUnmodified(t *testing.T) {
+	files := []string{
+		"../../testdata/sample/basic.typ",
+		"../../testdata/sample/minimal.typ",
+		"../../testdata/sample/commented.typ",
+		"../../testdata/binary/styles.txt",
+	}
+
+	for _, file := range files {
+		original, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", file, err)
+		}
+
+		typFile, err := ParseFile(file)
+		if err != nil {
+			t.Fatalf("Failed to parse %s: %v", file, err)
+		}
+
+		if got := writeToString(t, typFile); got != string(original) {
+			t.Errorf("%s: unmodified round trip changed the file:\n%s", file, got)
+		}
+	}
+}
+
+func TestRoundTripCRLF(t *testing.T) {
+	original, err := os.ReadFile("../../testdata/sample/commented.typ")
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	crlf := strings.ReplaceAll(string(original), "\n", "\r\n")
+	source := filepath.Join(t.TempDir(), "crlf.typ")
+	if err := os.WriteFile(source, []byte(crlf), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	typFile, err := ParseFile(source)
+	if err != nil {
+		t.Fatalf("Failed to parse file: %v", err)
+	}
+	if got := writeToString(t, typFile); got != crlf {
+		t.Errorf("CRLF round trip changed the file:\n%q", got)
+	}
+
+	typFile.Polygons[0].Labels["0x04"] = "Garden"
+	want := strings.Replace(crlf, "String=0x04,Park\r\n", "String=0x04,Garden\r\n", 1)
+	if got := writeToString(t, typFile); got != want {
+		t.Errorf("CRLF edit did not keep line endings:\n%q", got)
+	}
+}
+
+func TestRoundTripMinimalDiff(t *testing.T) {
+	file := "../../testdata/sample/commented.typ"
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	original := string(data)
+
+	tests := []struct {
+		name string
+		edit func(*TYPFile)
+		want string
+	}{
+		{
+			"change label",
+			func(f *TYPFile) { f.Points[0].Labels["0x01"] = "Banque Populaire" },
+			strings.Replace(original, "String2=0x01,Banque\n", "String2=0x01,Banque Populaire\n", 1),
+		},
+		{
+			"change width",
+			func(f *TYPFile) { f.Lines[0].LineWidth = 6 },
+			strings.Replace(original, "LineWidth=4\n", "LineWidth=6\n", 1),
+		},
+		{
+			"remove property",
+			func(f *TYPFile) { f.Points[0].FontStyle = "" },
+			strings.Replace(original, "FontStyle=SmallFont\n", "", 1),
+		},
+		{
+			"add property",
+			func(f *TYPFile) { f.Lines[0].UseOrientation = true },
+			strings.Replace(original, "\"2 c #000000\"\n[end]", "\"2 c #000000\"\nUseOrientation=Y\n[end]", 1),
+		},
+		{
+			"change header",
+			func(f *TYPFile) { f.Header.FID = 4321 },
+			strings.Replace(original, "FID=1234\n", "FID=4321\n", 1),
+		},
+		{
+			"delete type",
+			func(f *TYPFile) { f.Lines = nil },
+			strings.Replace(original, "[_line]\nType=0x01\nString=0x04,Highway\n\nLineWidth=4\nBorderWidth=1\nXpm=\"0 0 2 0\"\n\"1 c #FF0000\"\n\"2 c #000000\"\n[end]\n", "", 1),
+		},
+		{
+			"add type",
+			func(f *TYPFile) {
+				f.Polygons = append(f.Polygons, PolygonType{Type: "0x14", Labels: map[string]string{"0x04": "Forest"}})
+			},
+			strings.Replace(original, "\"a c #90EE90\"\n[end]\n", "\"a c #90EE90\"\n[end]\n\n[_polygon]\nType=0x14\nString=0x04,Forest\n[end]\n", 1),
+		},
+	}
+
+	for _, tt := range tests {
+		typFile, err := ParseFile(file)
+		if err != nil {
+			t.Fatalf("Failed to parse file: %v", err)
+		}
+		tt.edit(typFile)
+
+		if got := writeToString(t, typFile); got != tt.want {
+			t.Errorf("%s: unexpected output:\n%s", tt.name, got)
+		}
+	}
+}
+
+func TestRoundTripEditedXPMKeepsComments(t *testing.T) {
+	typFile, err := ParseFile("../../testdata/sample/commented.typ")
+	if err != nil {
+		t.Fatalf("Failed to parse file: %v", err)
+	}
+
+	typFile.Points[0].DayXpm.Palette.Set("!", Color{Hex: "#000000"})
+	got := writeToString(t, typFile)
+
+	for _, want := range []string{"; the bank icon\n", "Author=someone\n", "\"! c #000000\"\n", "[_comments]\nFree form notes"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Expected output to contain %q:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "#778899") {
+		t.Errorf("Expected old color to be replaced:\n%s", got)
+	}
+
+	reloaded, err := ParseFile(writeTemp(t, got))
+	if err != nil {
+		t.Fatalf("Failed to reload file: %v", err)
+	}
+	if c, _ := reloaded.Points[0].DayXpm.Palette.Lookup("!"); c.Hex != "#000000" {
+		t.Errorf("Expected edited color to persist, got %q", c.Hex)
+	}
+}
+
+// writeTemp writes content to a temporary file and returns its path
+func writeTemp(t *testing.T, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "in.typ")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	return path
+}
+
+func TestRoundTripDrawOrder(t *testing.T) {
+	original := "[_id]\nFID=1\n[end]\n\n[_drawOrder]\n; water under land\nType=0x3c,1\nType=0x03,2\nType=0x10f04,2\n[end]\n"
+
+	tests := []struct {
+		name string
+		edit func(*TYPFile)
+		want string
+	}{
+		{
+			"unchanged",
+			func(f *TYPFile) {},
+			original,
+		},
+		{
+			"move type",
+			func(f *TYPFile) { f.DrawOrder.SetLevel("0x03", 3) },
+			strings.Replace(original, "Type=0x03,2\n", "Type=0x03,3\n", 1),
+		},
+		{
+			"add type",
+			func(f *TYPFile) { f.DrawOrder.SetLevel("0x13", 1) },
+			strings.Replace(original, "Type=0x10f04,2\n", "Type=0x10f04,2\nType=0x13,1\n", 1),
+		},
+		{
+			"remove type",
+			func(f *TYPFile) { f.DrawOrder.SetLevel("0x10f04", 0) },
+			strings.Replace(original, "Type=0x10f04,2\n", "", 1),
+		},
+	}
+
+	for _, tt := range tests {
+		typFile, err := ParseFile(writeTemp(t, original))
+		if err != nil {
+			t.Fatalf("Failed to parse file: %v", err)
+		}
+		tt.edit(typFile)
+
+		if got := writeToString(t, typFile); got != tt.want {
+			t.Errorf("%s: unexpected output:\n%s", tt.name, got)
+		}
+	}
+}
+
+func TestRoundTripReorder(t *testing.T) {
+	bank := "\n; bank\n[_point]\nType=0x2f06\n[end]\n"
+	fuel := "\n; fuel\n[_point]\nType=0x2f01\n[end]\n"
+	header, road := "[_id]\nFID=1\n[end]\n", "\n[_line]\nType=0x01\n[end]\n"
+	original := header + bank + fuel + road
+
+	tests := []struct {
+		name string
+		edit func(*TYPFile)
+		want string
+	}{
+		{
+			"swap",
+			func(f *TYPFile) { f.Points[0], f.Points[1] = f.Points[1], f.Points[0] },
+			header + fuel + bank + road,
+		},
+		{
+			"insert between",
+			func(f *TYPFile) { f.Points = slices.Insert(f.Points, 1, PointType{Type: "0x2f0b"}) },
+			header + bank + "\n[_point]\nType=0x2f0b\n[end]\n" + fuel + road,
+		},
+		{
+			"insert first",
+			func(f *TYPFile) { f.Points = slices.Insert(f.Points, 0, PointType{Type: "0x2f0b"}) },
+			header + "\n[_point]\nType=0x2f0b\n[end]\n" + bank + fuel + road,
+		},
+		{
+			"move and delete",
+			func(f *TYPFile) { f.Points = []PointType{f.Points[1]} },
+			header + "\n; bank\n" + fuel + road,
+		},
+	}
+
+	for _, tt := range tests {
+		typFile, err := ParseFile(writeTemp(t, original))
+		if err != nil {
+			t.Fatalf("Failed to parse file: %v", err)
+		}
+		tt.edit(typFile)
+
+		if got := writeToString(t, typFile); got != tt.want {
+			t.Errorf("%s: unexpected output:\n%s", tt.name, got)
+		}
+	}
+}
+
+func TestRoundTripDuplicateDrawOrder(t *testing.T) {
+	for _, original := range []string{
+		"[_drawOrder]\nType=0x03,1\nType=0x3,2\n[end]\n",
+		"[_drawOrder]\nType=0x03,1\nType=0x03,1\n[end]\n",
+		"[_drawOrder]\nType=0x03,1\n[end]\n[_drawOrder]\nType=0x03,2\n[end]\n",
+	} {
+		typFile, err := ParseFile(writeTemp(t, original))
+		if err != nil {
+			t.Fatalf("Failed to parse file: %v", err)
+		}
+		if got := writeToString(t, typFile); got != original {
+			t.Errorf("Unmodified round trip changed the file:\n%s", got)
+		}
+
+		// Adding a type rewrites the section, which keeps both lines
+		typFile.DrawOrder.SetLevel("0x13", 1)
+		want := strings.TrimSuffix(original, "[end]\n") + "Type=0x13,1\n[end]\n"
+		if got := writeToString(t, typFile); got != want {
+			t.Errorf("Unexpected output after adding a type:\n%s", got)
+		}
+	}
+}
+
+func TestWriteNewDrawOrder(t *testing.T) {
+	typFile, err := ParseFile("../../testdata/sample/minimal.typ")
+	if err != nil {
+		t.Fatalf("Failed to parse file: %v", err)
+	}
+	typFile.DrawOrder.SetLevel("0x13", 2)
+	typFile.DrawOrder.SetLevel("0x03", 1)
+
+	got := writeToString(t, typFile)
+	want := "[end]\n\n[_drawOrder]\nType=0x03,1\nType=0x13,2\n[end]\n\n[_point]"
+	if !strings.Contains(got, want) {
+		t.Errorf("Expected a draw order section after the header:\n%s", got)
+	}
+
+	reloaded, err := ParseFile(writeTemp(t, got))
+	if err != nil {
+		t.Fatalf("Failed to reload: %v", err)
+	}
+	if reloaded.DrawOrder.Level("0x13") != 2 || reloaded.DrawOrder.Level("0x03") != 1 {
+		t.Errorf("Unexpected draw order after reload: %v", reloaded.DrawOrder)
+	}
+}
@@ -0,0 +1,81 @@
+package parser
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// TypeCode is a decoded Garmin type code
+//
+// Standard codes carry the type in the high byte and an optional subtype in
+// the low byte (0x2f06). Extended codes use the 0x1xxyy form, where xx is the
+// type and yy the subtype.
+type TypeCode struct {
+	Type     int
+	SubType  int
+	Extended bool
+}
+
+// ParseTypeCode parses a Type value and an optional SubType value
+func ParseTypeCode(typ, subType string) (TypeCode, error) {
+	var code TypeCode
+
+	value, err := parseNumber(typ)
+	if err != nil {
+		return code, fmt.Errorf("invalid type code %q", typ)
+	}
+
+	switch {
+	case value >= 0x10000:
+		if value > 0x1ffff {
+			return code, fmt.Errorf("type code %q out of range", typ)
+		}
+		code.Extended = true
+		code.Type = (value >> 8) & 0xff
+		code.SubType = value & 0xff
+	case value > 0xff:
+		code.Type = value >> 8
+		code.SubType = value & 0xff
+	default:
+		code.Type = value
+	}
+
+	if subType != "" {
+		sub, err := parseNumber(subType)
+		if err != nil || sub > 0xff {
+			return code, fmt.Errorf("invalid subtype %q", subType)
+		}
+		code.SubType = sub
+	}
+
+	return code, nil
+}
+
+// String formats the type code the way mkgmap writes it
+func (c TypeCode) String() string {
+	switch {
+	case c.Extended:
+		return fmt.Sprintf("0x1%02x%02x", c.Type, c.SubType)
+	case c.SubType != 0:
+		return fmt.Sprintf("0x%02x%02x", c.Type, c.SubType)
+	default:
+		return fmt.Sprintf("0x%02x", c.Type)
+	}
+}
+
+// parseNumber parses a decimal or 0x-prefixed hexadecimal number
+func parseNumber(value string) (int, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, fmt.Errorf("empty value")
+	}
+	n, err := strconv.ParseInt(value, 0, 32)
+	if err != nil {
+		return 0, err
+	}
+	if n < 0 {
+		return 0, fmt.Errorf("negative value")
+	}
+	return int(n), nil
+}
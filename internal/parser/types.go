@@ -1,5 +1,10 @@
 package parser
 
+import (
+	"fmt"
+	"strconv"
+)
+
 // TYPFile represents the entire TYP file structure
 type TYPFile struct {
 	Header   Header
@@ -9,6 +14,9 @@ type TYPFile struct {
 	DrawOrder DrawOrder
 	FilePath string
 	Modified bool
+	Binary   bool // Loaded from a compiled binary TYP
+
+	syntax *document // Source layout of text files, see syntax.go
 }
 
 // Header contains TYP file metadata
@@ -21,26 +29,43 @@ type Header struct {
 
 // PointType represents a POI definition
 type PointType struct {
-	Type        string            // e.g., "0x2f06"
-	SubType     string            // Optional subtype
-	Labels      map[string]string // Language code -> label
-	DayXpm      *XPMIcon
-	NightXpm    *XPMIcon
-	DayColors   []Color
-	NightColors []Color
-	FontStyle   string
+	Type           string            // e.g., "0x2f06"
+	SubType        string            // Optional subtype
+	Labels         map[string]string // Language code -> label
+	ExtendedLabels bool
+	DayXpm         *XPMIcon
+	NightXpm       *XPMIcon
+	DayColors      []Color // DayCustomColor
+	NightColors    []Color // NightCustomColor
+	FontStyle      string
+	DayFontColor   Color
+	NightFontColor Color
+	CustomColor    Color
+
+	syntax *syntaxSection
 }
 
 // LineType represents a line definition (roads, trails, etc.)
 type LineType struct {
-	Type              string
-	Labels            map[string]string
-	LineWidth         int
-	BorderWidth       int
-	DayXpm            *XPMIcon
-	NightXpm          *XPMIcon
-	UseOrientation    bool
-	LineStyle         string // "solid", "dashed", etc.
+	Type             string
+	Labels           map[string]string
+	ExtendedLabels   bool
+	LineWidth        int
+	BorderWidth      int
+	NightLineWidth   int // Widths of the night scheme, when they differ
+	NightBorderWidth int
+	DayXpm           *XPMIcon
+	NightXpm         *XPMIcon
+	UseOrientation   bool
+	LineStyle        string  // "solid", "dashed", etc.
+	DayColors        []Color // DayCustomColor
+	NightColors      []Color // NightCustomColor
+	FontStyle        string
+	DayFontColor     Color
+	NightFontColor   Color
+	CustomColor      Color
+
+	syntax *syntaxSection
 }
 
 // PolygonType represents an area definition
@@ -51,6 +76,14 @@ type PolygonType struct {
 	NightXpm       *XPMIcon
 	FontStyle      string
 	ExtendedLabels bool
+	DayColors      []Color // DayCustomColor
+	NightColors    []Color // NightCustomColor
+	DayFontColor   Color
+	NightFontColor Color
+	ContourColor   Color
+	CustomColor    Color
+
+	syntax *syntaxSection
 }
 
 // Color represents a color in hex format
@@ -67,27 +100,49 @@ type XPMIcon struct {
 	Colors  int
 	CharsPerPixel int
 	Data    []string
-	Palette map[string]Color
+	Palette Palette
 }
 
-// DrawOrder specifies rendering order
+// DrawOrder is the [_drawOrder] section. Only polygons have a draw order:
+// types on higher levels are drawn over those on lower ones.
 type DrawOrder struct {
-	Points   []string
-	Lines    []string
-	Polygons []string
+	Polygons []DrawOrderEntry
+}
+
+// DrawOrderEntry puts a polygon type on a draw order level
+type DrawOrderEntry struct {
+	Type  string // e.g. "0x32", or "0x10f04" for an extended type and subtype
+	Level int    // 1 is drawn first
 }
 
-// ParseError represents a parsing error with location information
+// ParseError represents a parsing error with location information.
+// Errors in compiled binary files have no line and report a byte Offset.
 type ParseError struct {
 	Line    int
 	Column  int
+	Offset  int64
 	Message string
 	File    string
 }
 
 func (e *ParseError) Error() string {
+	if e.Line == 0 {
+		location := fmt.Sprintf("offset 0x%x", e.Offset)
+		if e.File != "" {
+			return e.File + ": " + location + ": " + e.Message
+		}
+		return location + ": " + e.Message
+	}
 	if e.File != "" {
-		return e.File + ":" + string(rune(e.Line)) + ":" + string(rune(e.Column)) + ": " + e.Message
+		location := e.File + ":" + strconv.Itoa(e.Line)
+		if e.Column > 0 {
+			location += ":" + strconv.Itoa(e.Column)
+		}
+		return location + ": " + e.Message
+	}
+	location := "line " + strconv.Itoa(e.Line)
+	if e.Column > 0 {
+		location += ", col " + strconv.Itoa(e.Column)
 	}
-	return "line " + string(rune(e.Line)) + ", col " + string(rune(e.Column)) + ": " + e.Message
+	return location + ": " + e.Message
 }
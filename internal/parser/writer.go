@@ -1,255 +1,427 @@
 package parser
 
 import (
+	"bufio"
+	"bytes"
 	"fmt"
-	"os"
-	"strings"
+	"io"
+	"sort"
+
+	"github.com/dyuri/typtui/internal/atomicfile"
 )
 
-// WriteFile writes a TYPFile to disk in TYP text format
+// WriteFile writes a TYPFile to disk in TYP text format. Files that were
+// parsed from text keep their comments, unknown sections and property order,
+// and only the parts that changed are rewritten. The file is replaced
+// atomically and keeps its permissions; if writing fails it is unchanged.
 func WriteFile(typFile *TYPFile, filePath string) error {
-	var b strings.Builder
+	var writeErr error
+	err := atomicfile.Write(filePath, 0644, func(w io.Writer) error {
+		writeErr = Write(w, typFile)
+		return writeErr
+	})
+	if writeErr != nil {
+		return writeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	return nil
+}
+
+// Write writes a TYPFile to w in TYP text format, the same way WriteFile
+// does
+func Write(w io.Writer, typFile *TYPFile) error {
+	// Refuse to write labels the file encoding cannot hold before anything
+	// is written
+	codePage := typFile.outputCodePage()
+	if problems := CheckLabels(typFile, codePage); len(problems) > 0 {
+		return fmt.Errorf("failed to write file: %d labels cannot be written in code page %d, first: %w",
+			len(problems), codePage, problems[0])
+	}
+
+	b := bufio.NewWriter(w)
+
+	if typFile.syntax != nil {
+		if err := writeDocument(b, typFile, codePage); err != nil {
+			return err
+		}
+	} else {
+		// The default layout is rendered in UTF-8 and encoded as a whole
+		var text bytes.Buffer
+		tb := bufio.NewWriter(&text)
+		if err := writeTYP(tb, typFile); err != nil {
+			return err
+		}
+		tb.Flush()
+		b.WriteString(encodeOutput(text.String(), codePage))
+	}
 
+	// Write errors are sticky in bufio.Writer and surface here
+	if err := b.Flush(); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+
+	return nil
+}
+
+// writeTYP writes all sections of a TYPFile in the default layout
+func writeTYP(b *bufio.Writer, typFile *TYPFile) error {
 	// Write header section
-	if err := writeHeader(&b, typFile.Header); err != nil {
+	if err := writeHeader(b, typFile.Header); err != nil {
 		return fmt.Errorf("failed to write header: %w", err)
 	}
 
+	// Write draw order
+	if len(typFile.DrawOrder.Polygons) > 0 {
+		writeDrawOrder(b, typFile.DrawOrder)
+	}
+
 	// Write point types
 	for _, point := range typFile.Points {
-		if err := writePointType(&b, point); err != nil {
+		if err := writePointType(b, point); err != nil {
 			return fmt.Errorf("failed to write point type %s: %w", point.Type, err)
 		}
 	}
 
 	// Write line types
 	for _, line := range typFile.Lines {
-		if err := writeLineType(&b, line); err != nil {
+		if err := writeLineType(b, line); err != nil {
 			return fmt.Errorf("failed to write line type %s: %w", line.Type, err)
 		}
 	}
 
 	// Write polygon types
 	for _, polygon := range typFile.Polygons {
-		if err := writePolygonType(&b, polygon); err != nil {
+		if err := writePolygonType(b, polygon); err != nil {
 			return fmt.Errorf("failed to write polygon type %s: %w", polygon.Type, err)
 		}
 	}
 
-	// Write to file
-	content := b.String()
-	if err := os.WriteFile(filePath, []byte(content), 0644); err != nil {
-		return fmt.Errorf("failed to write file: %w", err)
-	}
-
 	return nil
 }
 
 // writeHeader writes the [_id] header section
-func writeHeader(b *strings.Builder, header Header) error {
+func writeHeader(b *bufio.Writer, header Header) error {
 	b.WriteString("[_id]\n")
+	writeEntries(b, headerEntries(header), "\n")
+	b.WriteString("[end]\n\n")
+	return nil
+}
+
+// headerEntries renders the [_id] properties
+func headerEntries(header Header) []syntaxEntry {
+	var e entryList
 
 	if header.CodePage > 0 {
-		b.WriteString(fmt.Sprintf("CodePage=%d\n", header.CodePage))
+		e.add("CodePage", "CodePage=%d", header.CodePage)
 	}
 	if header.FID > 0 {
-		b.WriteString(fmt.Sprintf("FID=%d\n", header.FID))
+		e.add("FID", "FID=%d", header.FID)
 	}
 	if header.ProductCode > 0 {
-		b.WriteString(fmt.Sprintf("ProductCode=%d\n", header.ProductCode))
+		e.add("ProductCode", "ProductCode=%d", header.ProductCode)
 	}
 	if header.MapID > 0 {
-		b.WriteString(fmt.Sprintf("MapID=%d\n", header.MapID))
+		e.add("MapID", "MapID=%d", header.MapID)
 	}
 
+	return e
+}
+
+// writeDrawOrder writes the [_drawOrder] section
+func writeDrawOrder(b *bufio.Writer, drawOrder DrawOrder) {
+	b.WriteString("[_drawOrder]\n")
+	writeEntries(b, drawOrderEntries(DrawOrder{Polygons: drawOrder.sorted()}), "\n")
 	b.WriteString("[end]\n\n")
-	return nil
+}
+
+// drawOrderEntries renders the draw order, one Type=type,level line each.
+// Repeated lines of a type are numbered, so each keeps an entry of its own.
+func drawOrderEntries(drawOrder DrawOrder) []syntaxEntry {
+	var e entryList
+
+	seen := make(map[string]int)
+	for _, entry := range drawOrder.Polygons {
+		typ := drawOrderType(entry.Type)
+		seen[typ]++
+		e.add(drawOrderID(typ, seen[typ]), "Type=%s,%d", entry.Type, entry.Level)
+	}
+
+	return e
+}
+
+// drawOrderType returns the canonical form of a draw order type code, the
+// same for all its spellings
+func drawOrderType(typ string) string {
+	if code, err := ParseTypeCode(typ, ""); err == nil {
+		return code.String()
+	}
+	return typ
+}
+
+// drawOrderID returns the entry id of the nth draw order line of a type in
+// canonical form
+func drawOrderID(typ string, n int) string {
+	if n > 1 {
+		return fmt.Sprintf("Type:%s#%d", typ, n)
+	}
+	return "Type:" + typ
 }
 
 // writePointType writes a [_point] section
-func writePointType(b *strings.Builder, point PointType) error {
+func writePointType(b *bufio.Writer, point PointType) error {
 	b.WriteString("[_point]\n")
+	writeEntries(b, pointEntries(point), "\n")
+	b.WriteString("[end]\n\n")
+	return nil
+}
+
+// pointEntries renders the properties of a point type
+func pointEntries(point PointType) []syntaxEntry {
+	var e entryList
 
 	// Type (required)
-	b.WriteString(fmt.Sprintf("Type=%s\n", point.Type))
+	e.add("Type", "Type=%s", point.Type)
 
 	// SubType (optional)
 	if point.SubType != "" {
-		b.WriteString(fmt.Sprintf("SubType=%s\n", point.SubType))
+		e.add("SubType", "SubType=%s", point.SubType)
 	}
 
 	// Labels
-	if err := writeLabels(b, point.Labels); err != nil {
-		return err
+	e.addLabels(point.Labels)
+
+	// ExtendedLabels
+	if point.ExtendedLabels {
+		e.add("ExtendedLabels", "ExtendedLabels=Y")
 	}
 
 	// DayXpm
 	if point.DayXpm != nil {
-		if err := writeXPM(b, "DayXpm", point.DayXpm); err != nil {
-			return err
-		}
+		e.addXPM("DayXpm", point.DayXpm)
 	}
 
 	// NightXpm
 	if point.NightXpm != nil {
-		if err := writeXPM(b, "NightXpm", point.NightXpm); err != nil {
-			return err
-		}
+		e.addXPM("NightXpm", point.NightXpm)
 	}
 
-	// DayColors
-	for _, color := range point.DayColors {
-		if color.Hex != "" {
-			b.WriteString(fmt.Sprintf("DayCustomColor=%s\n", color.Hex))
-		}
-	}
-
-	// NightColors
-	for _, color := range point.NightColors {
-		if color.Hex != "" {
-			b.WriteString(fmt.Sprintf("NightCustomColor=%s\n", color.Hex))
-		}
-	}
+	// DayColors and NightColors
+	e.addColors("DayCustomColor", point.DayColors)
+	e.addColors("NightCustomColor", point.NightColors)
 
 	// FontStyle
 	if point.FontStyle != "" {
-		b.WriteString(fmt.Sprintf("FontStyle=%s\n", point.FontStyle))
+		e.add("FontStyle", "FontStyle=%s", point.FontStyle)
 	}
 
-	b.WriteString("[end]\n\n")
-	return nil
+	// Font and custom colours
+	e.addColor("DayFontColor", point.DayFontColor)
+	e.addColor("NightFontColor", point.NightFontColor)
+	e.addColor("CustomColor", point.CustomColor)
+
+	return e
 }
 
 // writeLineType writes a [_line] section
-func writeLineType(b *strings.Builder, line LineType) error {
+func writeLineType(b *bufio.Writer, line LineType) error {
 	b.WriteString("[_line]\n")
+	writeEntries(b, lineEntries(line), "\n")
+	b.WriteString("[end]\n\n")
+	return nil
+}
+
+// lineEntries renders the properties of a line type
+func lineEntries(line LineType) []syntaxEntry {
+	var e entryList
 
 	// Type (required)
-	b.WriteString(fmt.Sprintf("Type=%s\n", line.Type))
+	e.add("Type", "Type=%s", line.Type)
 
 	// Labels
-	if err := writeLabels(b, line.Labels); err != nil {
-		return err
+	e.addLabels(line.Labels)
+
+	// ExtendedLabels
+	if line.ExtendedLabels {
+		e.add("ExtendedLabels", "ExtendedLabels=Y")
+	}
+
+	// FontStyle
+	if line.FontStyle != "" {
+		e.add("FontStyle", "FontStyle=%s", line.FontStyle)
 	}
 
 	// LineWidth
 	if line.LineWidth > 0 {
-		b.WriteString(fmt.Sprintf("LineWidth=%d\n", line.LineWidth))
+		e.add("LineWidth", "LineWidth=%d", line.LineWidth)
 	}
 
 	// BorderWidth
 	if line.BorderWidth > 0 {
-		b.WriteString(fmt.Sprintf("BorderWidth=%d\n", line.BorderWidth))
+		e.add("BorderWidth", "BorderWidth=%d", line.BorderWidth)
+	}
+
+	// Night widths
+	if line.NightLineWidth > 0 {
+		e.add("NightLineWidth", "NightLineWidth=%d", line.NightLineWidth)
+	}
+	if line.NightBorderWidth > 0 {
+		e.add("NightBorderWidth", "NightBorderWidth=%d", line.NightBorderWidth)
 	}
 
 	// LineStyle
 	if line.LineStyle != "" {
-		b.WriteString(fmt.Sprintf("LineStyle=%s\n", line.LineStyle))
+		e.add("LineStyle", "LineStyle=%s", line.LineStyle)
 	}
 
 	// UseOrientation
 	if line.UseOrientation {
-		b.WriteString("UseOrientation=Y\n")
+		e.add("UseOrientation", "UseOrientation=Y")
 	}
 
 	// DayXpm
 	if line.DayXpm != nil {
-		if err := writeXPM(b, "Xpm", line.DayXpm); err != nil {
-			return err
-		}
+		e.addXPM("Xpm", line.DayXpm)
 	}
 
 	// NightXpm
 	if line.NightXpm != nil {
-		if err := writeXPM(b, "NightXpm", line.NightXpm); err != nil {
-			return err
-		}
+		e.addXPM("NightXpm", line.NightXpm)
 	}
 
-	b.WriteString("[end]\n\n")
-	return nil
+	// Font and custom colours
+	e.addColors("DayCustomColor", line.DayColors)
+	e.addColors("NightCustomColor", line.NightColors)
+	e.addColor("DayFontColor", line.DayFontColor)
+	e.addColor("NightFontColor", line.NightFontColor)
+	e.addColor("CustomColor", line.CustomColor)
+
+	return e
 }
 
 // writePolygonType writes a [_polygon] section
-func writePolygonType(b *strings.Builder, polygon PolygonType) error {
+func writePolygonType(b *bufio.Writer, polygon PolygonType) error {
 	b.WriteString("[_polygon]\n")
+	writeEntries(b, polygonEntries(polygon), "\n")
+	b.WriteString("[end]\n\n")
+	return nil
+}
+
+// polygonEntries renders the properties of a polygon type
+func polygonEntries(polygon PolygonType) []syntaxEntry {
+	var e entryList
 
 	// Type (required)
-	b.WriteString(fmt.Sprintf("Type=%s\n", polygon.Type))
+	e.add("Type", "Type=%s", polygon.Type)
 
 	// Labels
-	if err := writeLabels(b, polygon.Labels); err != nil {
-		return err
-	}
+	e.addLabels(polygon.Labels)
 
 	// ExtendedLabels
 	if polygon.ExtendedLabels {
-		b.WriteString("ExtendedLabels=Y\n")
+		e.add("ExtendedLabels", "ExtendedLabels=Y")
 	}
 
 	// FontStyle
 	if polygon.FontStyle != "" {
-		b.WriteString(fmt.Sprintf("FontStyle=%s\n", polygon.FontStyle))
+		e.add("FontStyle", "FontStyle=%s", polygon.FontStyle)
 	}
 
 	// DayXpm
 	if polygon.DayXpm != nil {
-		if err := writeXPM(b, "Xpm", polygon.DayXpm); err != nil {
-			return err
-		}
+		e.addXPM("Xpm", polygon.DayXpm)
 	}
 
 	// NightXpm
 	if polygon.NightXpm != nil {
-		if err := writeXPM(b, "NightXpm", polygon.NightXpm); err != nil {
-			return err
-		}
+		e.addXPM("NightXpm", polygon.NightXpm)
 	}
 
-	b.WriteString("[end]\n\n")
-	return nil
+	// Font, contour and custom colours
+	e.addColors("DayCustomColor", polygon.DayColors)
+	e.addColors("NightCustomColor", polygon.NightColors)
+	e.addColor("DayFontColor", polygon.DayFontColor)
+	e.addColor("NightFontColor", polygon.NightFontColor)
+	e.addColor("ContourColor", polygon.ContourColor)
+	e.addColor("CustomColor", polygon.CustomColor)
+
+	return e
+}
+
+// entryList collects rendered properties
+type entryList []syntaxEntry
+
+// add appends a single line property
+func (e *entryList) add(id, format string, args ...interface{}) {
+	*e = append(*e, syntaxEntry{id: id, lines: []string{fmt.Sprintf(format, args...)}})
 }
 
-// writeLabels writes String= lines for all labels
-func writeLabels(b *strings.Builder, labels map[string]string) error {
-	if len(labels) == 0 {
-		return nil
+// addColor appends a colour property if the colour is set
+func (e *entryList) addColor(key string, color Color) {
+	if color.Hex != "" {
+		e.add(key, "%s=%s", key, color.Hex)
 	}
+}
 
-	// Write labels in a consistent order (English first, then sorted)
-	if label, ok := labels["0x04"]; ok {
-		b.WriteString(fmt.Sprintf("String=0x04,%s\n", label))
+// addColors appends one colour property per colour
+func (e *entryList) addColors(key string, colors []Color) {
+	for _, color := range colors {
+		e.addColor(key, color)
 	}
+}
 
-	// Write other labels
-	for code, label := range labels {
-		if code != "0x04" {
-			b.WriteString(fmt.Sprintf("String=%s,%s\n", code, label))
-		}
+// addLabels appends String= lines for all labels, sorted by language code
+// so the output does not depend on map order
+func (e *entryList) addLabels(labels map[string]string) {
+	for _, code := range sortedLangCodes(labels) {
+		e.add(labelID(code), "String=%s,%s", code, labels[code])
 	}
+}
 
-	return nil
+// sortedLangCodes returns the language codes of labels in numeric order
+func sortedLangCodes(labels map[string]string) []string {
+	codes := make([]string, 0, len(labels))
+	for code := range labels {
+		codes = append(codes, code)
+	}
+	sort.Slice(codes, func(i, j int) bool {
+		a, errA := parseNumber(codes[i])
+		b, errB := parseNumber(codes[j])
+		if errA != nil || errB != nil || a == b {
+			return codes[i] < codes[j]
+		}
+		return a < b
+	})
+	return codes
 }
 
-// writeXPM writes an XPM icon/pattern
-func writeXPM(b *strings.Builder, fieldName string, xpm *XPMIcon) error {
-	// Write XPM header: "width height numColors charsPerPixel"
-	b.WriteString(fmt.Sprintf("%s=\"%d %d %d %d\"\n",
-		fieldName, xpm.Width, xpm.Height, xpm.Colors, xpm.CharsPerPixel))
+// addXPM appends an XPM icon/pattern
+func (e *entryList) addXPM(fieldName string, xpm *XPMIcon) {
+	// Write XPM header: "width height numColors charsPerPixel". The colour
+	// count follows the palette, so dropped or added entries stay readable.
+	lines := []string{fmt.Sprintf("%s=\"%d %d %d %d\"",
+		fieldName, xpm.Width, xpm.Height, len(xpm.Palette), xpm.CharsPerPixel)}
 
-	// Write color palette
-	for char, color := range xpm.Palette {
-		if color.Hex == "none" || color.Hex == "transparent" {
-			b.WriteString(fmt.Sprintf("\"%s c none\"\n", char))
-		} else {
-			b.WriteString(fmt.Sprintf("\"%s c %s\"\n", char, color.Hex))
-		}
+	// Write color palette, in palette order
+	for _, entry := range xpm.Palette {
+		lines = append(lines, fmt.Sprintf("\"%s\"", formatPaletteEntry(entry)))
 	}
 
 	// Write pixel data
 	for _, line := range xpm.Data {
-		b.WriteString(fmt.Sprintf("\"%s\"\n", line))
+		lines = append(lines, fmt.Sprintf("\"%s\"", line))
 	}
 
-	return nil
+	*e = append(*e, syntaxEntry{id: fieldName, lines: lines})
+}
+
+// writeEntries writes rendered properties, one line each
+func writeEntries(b *bufio.Writer, entries []syntaxEntry, newline string) {
+	for _, entry := range entries {
+		for _, line := range entry.lines {
+			b.WriteString(line)
+			b.WriteString(newline)
+		}
+	}
 }
@@ -1,8 +1,10 @@
 package parser
 
 import (
+	"bytes"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -242,3 +244,76 @@ func TestWritePolygonExtendedLabels(t *testing.T) {
 		t.Errorf("Expected FontStyle 'SmallFont', got '%s'", polygon.FontStyle)
 	}
 }
+
+func TestWriteDrawOrder(t *testing.T) {
+	typFile := &TYPFile{
+		DrawOrder: DrawOrder{Polygons: []DrawOrderEntry{
+			{Type: "0x13", Level: 2},
+			{Type: "0x10f04", Level: 3},
+			{Type: "0x03", Level: 1},
+		}},
+	}
+
+	tempFile := filepath.Join(t.TempDir(), "draworder_test.typ")
+	if err := WriteFile(typFile, tempFile); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	reloaded, err := ParseFile(tempFile)
+	if err != nil {
+		t.Fatalf("Failed to reload file: %v", err)
+	}
+
+	// Entries come back sorted by level
+	want := []DrawOrderEntry{
+		{Type: "0x03", Level: 1},
+		{Type: "0x13", Level: 2},
+		{Type: "0x10f04", Level: 3},
+	}
+	if len(reloaded.DrawOrder.Polygons) != len(want) {
+		t.Fatalf("Expected %v, got %v", want, reloaded.DrawOrder.Polygons)
+	}
+	for i, entry := range reloaded.DrawOrder.Polygons {
+		if entry != want[i] {
+			t.Errorf("Entry %d: expected %v, got %v", i, want[i], entry)
+		}
+	}
+}
+
+func TestWriteProperties(t *testing.T) {
+	typFile, err := ParseReader(strings.NewReader(propertiesTYP), "properties.typ")
+	if err != nil {
+		t.Fatalf("Failed to parse: %v", err)
+	}
+
+	typFile.Points[0].NightFontColor = Color{Hex: "#0000FF"}
+	typFile.Lines[0].NightLineWidth = 5
+	typFile.Polygons[0].ContourColor = Color{}
+	typFile.Polygons[0].DayFontColor = Color{Hex: "#ABCDEF"}
+
+	var buf bytes.Buffer
+	if err := Write(&buf, typFile); err != nil {
+		t.Fatalf("Failed to write: %v", err)
+	}
+	reloaded, err := ParseReader(&buf, "properties.typ")
+	if err != nil {
+		t.Fatalf("Failed to reload: %v\n%s", err, buf.String())
+	}
+
+	if got := reloaded.Points[0].NightFontColor.Hex; got != "#0000FF" {
+		t.Errorf("Point night font colour = %q", got)
+	}
+	if !reloaded.Points[0].ExtendedLabels {
+		t.Error("Point ExtendedLabels lost")
+	}
+	if got := reloaded.Lines[0].NightLineWidth; got != 5 {
+		t.Errorf("NightLineWidth = %d, want 5", got)
+	}
+	if reloaded.Lines[0].NightXpm == nil {
+		t.Error("Line night pattern lost")
+	}
+	polygon := reloaded.Polygons[0]
+	if polygon.ContourColor.Hex != "" || polygon.DayFontColor.Hex != "#ABCDEF" {
+		t.Errorf("Contour/day font colour = %q/%q", polygon.ContourColor.Hex, polygon.DayFontColor.Hex)
+	}
+}
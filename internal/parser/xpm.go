@@ -0,0 +1,254 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Palette is the colour table of an XPM icon in file order. The order is
+// kept on save and decides the colour indexes of compiled files.
+type Palette []PaletteEntry
+
+// PaletteEntry is one colour of an XPM palette. Only the "c" colour is used
+// for drawing; the other keys of the colour spec are kept for writing back.
+type PaletteEntry struct {
+	Key      string // the pixel characters, CharsPerPixel long
+	Color    Color  // Hex is "#RRGGBB" or "none"; Name keeps a named colour
+	Symbolic string // "s" key
+	Mono     string // "m" key
+	Gray4    string // "g4" key
+	Gray     string // "g" key
+}
+
+// Lookup returns the colour of a pixel key
+func (p Palette) Lookup(key string) (Color, bool) {
+	for _, entry := range p {
+		if entry.Key == key {
+			return entry.Color, true
+		}
+	}
+	return Color{}, false
+}
+
+// Set changes the colour of a pixel key, adding the key at the end if it
+// is new
+func (p *Palette) Set(key string, c Color) {
+	for i := range *p {
+		if (*p)[i].Key == key {
+			(*p)[i].Color = c
+			return
+		}
+	}
+	*p = append(*p, PaletteEntry{Key: key, Color: c})
+}
+
+// Keys returns the pixel keys in palette order
+func (p Palette) Keys() []string {
+	keys := make([]string, len(p))
+	for i, entry := range p {
+		keys[i] = entry.Key
+	}
+	return keys
+}
+
+// Pixels splits a row of pixel data into pixel keys of CharsPerPixel
+// characters. A trailing partial key is returned as it is.
+func (x *XPMIcon) Pixels(row string) []string {
+	cpp := x.CharsPerPixel
+	if cpp < 1 {
+		cpp = 1
+	}
+	pixels := make([]string, 0, len(row)/cpp)
+	for i := 0; i < len(row); i += cpp {
+		end := i + cpp
+		if end > len(row) {
+			end = len(row)
+		}
+		pixels = append(pixels, row[i:end])
+	}
+	return pixels
+}
+
+// xpmColorKeys are the context keys of an XPM colour spec
+var xpmColorKeys = map[string]bool{"c": true, "m": true, "g4": true, "g": true, "s": true}
+
+// parsePaletteEntry parses the contents of an XPM colour line such as
+// "a c #FF0000" or "ab c red s border". cpp is the number of pixel
+// characters; with 0, as in "0 0 n 0" colour lists, the key runs up to the
+// first space. The returned offset points at the part that could not be
+// read.
+func parsePaletteEntry(line string, cpp int) (entry PaletteEntry, at int, err error) {
+	if cpp < 1 {
+		cpp = strings.IndexAny(line, " \t")
+		if cpp < 0 {
+			cpp = len(line)
+		}
+	}
+	if len(line) < cpp {
+		return entry, 0, fmt.Errorf("palette line shorter than %d pixel characters", cpp)
+	}
+	entry.Key = line[:cpp]
+
+	// Group the rest into key/value pairs. Values may have spaces, such as
+	// "dark slate gray".
+	specs := make(map[string]string)
+	specAt := make(map[string]int)
+	var current string
+	for _, field := range splitFields(line[cpp:], cpp) {
+		if xpmColorKeys[field.text] {
+			current = field.text
+			specs[current] = ""
+			specAt[current] = field.at
+			continue
+		}
+		if current == "" {
+			return entry, field.at, fmt.Errorf("expected a colour key (c, m, g4, g or s), got %q", field.text)
+		}
+		if specs[current] == "" {
+			specAt[current] = field.at
+			specs[current] = field.text
+		} else {
+			specs[current] += " " + field.text
+		}
+	}
+	for key, value := range specs {
+		if value == "" {
+			return entry, specAt[key], fmt.Errorf("missing value for colour key %s", key)
+		}
+	}
+
+	entry.Symbolic = specs["s"]
+	entry.Mono = specs["m"]
+	entry.Gray4 = specs["g4"]
+	entry.Gray = specs["g"]
+
+	// Colour displays use "c"; fall back to the grey and mono visuals
+	for _, key := range []string{"c", "g", "g4", "m"} {
+		spec, ok := specs[key]
+		if !ok {
+			continue
+		}
+		c, ok := ParseColorSpec(spec)
+		if !ok {
+			return entry, specAt[key], fmt.Errorf("unknown colour %q", spec)
+		}
+		entry.Color = c
+		return entry, 0, nil
+	}
+	return entry, len(line), fmt.Errorf("no colour for pixel %q", entry.Key)
+}
+
+// ParseColorSpec parses an XPM colour: "none", #RGB, #RRGGBB, #RRRGGGBBB,
+// #RRRRGGGGBBBB or an X11 colour name. Hex is returned as #RRGGBB, keeping
+// the case of six-digit values; named colours keep their name in Name.
+func ParseColorSpec(spec string) (Color, bool) {
+	if strings.EqualFold(spec, "none") || strings.EqualFold(spec, "transparent") {
+		return Color{Hex: "none"}, true
+	}
+
+	if strings.HasPrefix(spec, "#") {
+		digits := spec[1:]
+		if len(digits) == 0 || len(digits)%3 != 0 || len(digits) > 12 {
+			return Color{}, false
+		}
+		for _, r := range digits {
+			if !strings.ContainsRune("0123456789abcdefABCDEF", r) {
+				return Color{}, false
+			}
+		}
+		n := len(digits) / 3
+		if n == 2 {
+			return Color{Hex: spec}, true
+		}
+		hex := "#"
+		for i := 0; i < 3; i++ {
+			component := digits[i*n : (i+1)*n]
+			if n == 1 {
+				component += component
+			}
+			hex += component[:2]
+		}
+		return Color{Hex: strings.ToUpper(hex)}, true
+	}
+
+	hex, ok := namedColors[colorNameKey(spec)]
+	if !ok {
+		return Color{}, false
+	}
+	return Color{Hex: hex, Name: spec}, true
+}
+
+// colorNameKey folds a colour name for lookup, so "Dark Slate Gray" and
+// "darkslategray" match
+func colorNameKey(name string) string {
+	return strings.ToLower(strings.ReplaceAll(name, " ", ""))
+}
+
+// colorSpec returns the spelling of a colour in an XPM palette line. A
+// named colour is written by name while it still matches the hex value.
+func colorSpec(c Color) string {
+	if isTransparent(c) {
+		return "none"
+	}
+	if c.Name != "" && strings.EqualFold(namedColors[colorNameKey(c.Name)], c.Hex) {
+		return c.Name
+	}
+	return c.Hex
+}
+
+// formatPaletteEntry returns the contents of an XPM colour line
+func formatPaletteEntry(entry PaletteEntry) string {
+	line := entry.Key + " c " + colorSpec(entry.Color)
+	for _, spec := range []struct{ key, value string }{
+		{"s", entry.Symbolic},
+		{"m", entry.Mono},
+		{"g4", entry.Gray4},
+		{"g", entry.Gray},
+	} {
+		if spec.value != "" {
+			line += " " + spec.key + " " + spec.value
+		}
+	}
+	return line
+}
+
+// NewIndexedXPM builds an XPM icon from palette indexes given row by row.
+// Colours are keyed in palette order; the transparent colour, if any, uses
+// the index after the last colour and is keyed with spaces.
+func NewIndexedXPM(width, height int, colors []Color, transparent bool, pixels []int) *XPMIcon {
+	entries := len(colors)
+	if transparent {
+		entries++
+	}
+
+	cpp := 1
+	if len(colors) > len(xpmChars) {
+		cpp = 2
+	}
+
+	keys := make([]string, entries)
+	xpm := &XPMIcon{
+		Width:         width,
+		Height:        height,
+		Colors:        entries,
+		CharsPerPixel: cpp,
+	}
+	for i, c := range colors {
+		keys[i] = pixelKey(i, cpp)
+		xpm.Palette = append(xpm.Palette, PaletteEntry{Key: keys[i], Color: c})
+	}
+	if transparent {
+		keys[len(colors)] = strings.Repeat(" ", cpp)
+		xpm.Palette = append(xpm.Palette, PaletteEntry{Key: keys[len(colors)], Color: Color{Hex: "none"}})
+	}
+
+	for y := 0; y < height && (y+1)*width <= len(pixels); y++ {
+		var row strings.Builder
+		for _, p := range pixels[y*width : (y+1)*width] {
+			row.WriteString(keys[p])
+		}
+		xpm.Data = append(xpm.Data, row.String())
+	}
+
+	return xpm
+}
@@ -0,0 +1,153 @@
+package parser
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// hasColor reports whether an icon maps a pixel key to a colour
+func hasColor(xpm *XPMIcon, key, hex string) bool {
+	if xpm == nil {
+		return false
+	}
+	c, ok := xpm.Palette.Lookup(key)
+	return ok && c.Hex == hex
+}
+
+func TestParseColorSpec(t *testing.T) {
+	tests := []struct {
+		spec string
+		want Color
+		ok   bool
+	}{
+		{"#FF0000", Color{Hex: "#FF0000"}, true},
+		{"#ff8000", Color{Hex: "#ff8000"}, true},
+		{"#f80", Color{Hex: "#FF8800"}, true},
+		{"#fff000000", Color{Hex: "#FF0000"}, true},
+		{"#FFFF80800000", Color{Hex: "#FF8000"}, true},
+		{"none", Color{Hex: "none"}, true},
+		{"None", Color{Hex: "none"}, true},
+		{"red", Color{Hex: "#FF0000", Name: "red"}, true},
+		{"Dark Slate Gray", Color{Hex: "#2F4F4F", Name: "Dark Slate Gray"}, true},
+		{"gray", Color{Hex: "#BEBEBE", Name: "gray"}, true},
+		{"#12345", Color{}, false},
+		{"#GG0000", Color{}, false},
+		{"nocolour", Color{}, false},
+	}
+
+	for _, tt := range tests {
+		got, ok := ParseColorSpec(tt.spec)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("ParseColorSpec(%q) = %+v, %v; want %+v, %v", tt.spec, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestParsePaletteEntry(t *testing.T) {
+	tests := []struct {
+		line string
+		cpp  int
+		want PaletteEntry
+	}{
+		{"a c #FF0000", 1, PaletteEntry{Key: "a", Color: Color{Hex: "#FF0000"}}},
+		{"  c none", 1, PaletteEntry{Key: " ", Color: Color{Hex: "none"}}},
+		{"ab c light blue s water", 2, PaletteEntry{Key: "ab", Color: Color{Hex: "#ADD8E6", Name: "light blue"}, Symbolic: "water"}},
+		{"x s border m black g4 #888 g gray", 1, PaletteEntry{Key: "x", Color: Color{Hex: "#BEBEBE", Name: "gray"},
+			Symbolic: "border", Mono: "black", Gray4: "#888", Gray: "gray"}},
+		{"1 c #101010", 0, PaletteEntry{Key: "1", Color: Color{Hex: "#101010"}}},
+	}
+
+	for _, tt := range tests {
+		got, _, err := parsePaletteEntry(tt.line, tt.cpp)
+		if err != nil {
+			t.Errorf("parsePaletteEntry(%q): %v", tt.line, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parsePaletteEntry(%q) = %+v, want %+v", tt.line, got, tt.want)
+		}
+	}
+
+	for _, line := range []string{"a #FF0000", "a c", "a c mauvish", "a s border"} {
+		if _, _, err := parsePaletteEntry(line, 1); err == nil {
+			t.Errorf("parsePaletteEntry(%q): expected an error", line)
+		}
+	}
+}
+
+const multiCharTYP = `[_point]
+Type=0x2f06
+DayXpm="3 2 4 2"
+"zz c #FF0000"
+"   c none"
+"aa c blue s water"
+"a. c #0F0"
+"zzaaa."
+"  zz  "
+[end]
+`
+
+func TestParseMultiCharPixels(t *testing.T) {
+	typFile, err := ParseReader(strings.NewReader(multiCharTYP), "multi.typ")
+	if err != nil {
+		t.Fatalf("Failed to parse: %v", err)
+	}
+
+	xpm := typFile.Points[0].DayXpm
+	if got := xpm.Palette.Keys(); !reflect.DeepEqual(got, []string{"zz", "  ", "aa", "a."}) {
+		t.Errorf("Palette order = %q", got)
+	}
+	if !hasColor(xpm, "aa", "#0000FF") || !hasColor(xpm, "a.", "#00FF00") {
+		t.Errorf("Unexpected palette %+v", xpm.Palette)
+	}
+	if got := xpm.Pixels(xpm.Data[0]); !reflect.DeepEqual(got, []string{"zz", "aa", "a."}) {
+		t.Errorf("Pixels = %q", got)
+	}
+
+	// Multi-character icons compile
+	data, err := EncodeBinary(typFile)
+	if err != nil {
+		t.Fatalf("Failed to encode: %v", err)
+	}
+	decoded, err := ParseBinary(data, "multi.typ")
+	if err != nil {
+		t.Fatalf("Failed to decode: %v", err)
+	}
+	if !equalPixels(decoded.Points[0].DayXpm, xpm) {
+		t.Errorf("Icon changed:\n%v\n%v", xpmPixels(decoded.Points[0].DayXpm), xpmPixels(xpm))
+	}
+}
+
+func TestWritePaletteOrder(t *testing.T) {
+	typFile, err := ParseReader(strings.NewReader(multiCharTYP), "multi.typ")
+	if err != nil {
+		t.Fatalf("Failed to parse: %v", err)
+	}
+
+	// Editing one colour rewrites the icon with the palette in file order
+	typFile.Points[0].DayXpm.Palette.Set("zz", Color{Hex: "#800000"})
+
+	var buf bytes.Buffer
+	if err := Write(&buf, typFile); err != nil {
+		t.Fatalf("Failed to write: %v", err)
+	}
+	want := strings.Replace(multiCharTYP, `"zz c #FF0000"`, `"zz c #800000"`, 1)
+	want = strings.Replace(want, `"a. c #0F0"`, `"a. c #00FF00"`, 1)
+	if buf.String() != want {
+		t.Errorf("Unexpected output:\n%s\nwant:\n%s", buf.String(), want)
+	}
+}
+
+func TestParseInvalidPaletteColumn(t *testing.T) {
+	source := "[_point]\nType=0x2f06\nDayXpm=\"1 1 1 1\"\n  \"a c mauvish\"\n\"a\"\n[end]\n"
+
+	_, diagnostics, err := ParseReaderTolerant(strings.NewReader(source), "palette.typ")
+	if err != nil {
+		t.Fatalf("Tolerant parse failed: %v", err)
+	}
+	if len(diagnostics) != 1 || diagnostics[0].Line != 4 || diagnostics[0].Column != 8 {
+		t.Errorf("Expected one diagnostic at 4:8, got %v", diagnostics)
+	}
+}
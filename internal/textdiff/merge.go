@@ -0,0 +1,182 @@
+package textdiff
+
+import (
+	"slices"
+	"strings"
+)
+
+// Chunk is a part of a three-way merge. A clean chunk has its merged lines
+// in Lines; a conflict has both changed versions and the base they changed.
+type Chunk struct {
+	Lines    []string
+	Conflict bool
+	Base     []string
+	Ours     []string
+	Theirs   []string
+}
+
+// change replaces base lines [start, end) with lines. A pure insertion has
+// start == end.
+type change struct {
+	start, end int
+	lines      []string
+}
+
+// changes collects the edits turning base into another text as changes
+func changes(edits []Edit) []change {
+	var result []change
+	pos := 0
+	for i := 0; i < len(edits); {
+		if edits[i].Op == Equal {
+			pos++
+			i++
+			continue
+		}
+		c := change{start: pos, end: pos}
+		for ; i < len(edits) && edits[i].Op != Equal; i++ {
+			if edits[i].Op == Delete {
+				c.end++
+			} else {
+				c.lines = append(c.lines, edits[i].Line)
+			}
+		}
+		pos = c.end
+		result = append(result, c)
+	}
+	return result
+}
+
+// Merge combines the changes ours and theirs made to base. Changes to
+// different lines are both kept; changes to the same lines conflict unless
+// they are identical.
+func Merge(base, ours, theirs []string) []Chunk {
+	a := changes(Lines(base, ours))
+	b := changes(Lines(base, theirs))
+
+	var chunks []Chunk
+	clean := func(lines []string) {
+		if len(lines) == 0 {
+			return
+		}
+		if n := len(chunks); n > 0 && !chunks[n-1].Conflict {
+			chunks[n-1].Lines = append(chunks[n-1].Lines, lines...)
+			return
+		}
+		chunks = append(chunks, Chunk{Lines: append([]string(nil), lines...)})
+	}
+
+	pos := 0
+	for len(a) > 0 || len(b) > 0 {
+		// The region starts at the earliest change and grows while changes
+		// of either side overlap it
+		start := -1
+		if len(a) > 0 {
+			start = a[0].start
+		}
+		if len(b) > 0 && (start < 0 || b[0].start < start) {
+			start = b[0].start
+		}
+		end := start
+		var ac, bc []change
+		for grown := true; grown; {
+			grown = false
+			for len(a) > 0 && overlaps(a[0], start, end) {
+				end = max(end, a[0].end)
+				ac, a = append(ac, a[0]), a[1:]
+				grown = true
+			}
+			for len(b) > 0 && overlaps(b[0], start, end) {
+				end = max(end, b[0].end)
+				bc, b = append(bc, b[0]), b[1:]
+				grown = true
+			}
+		}
+
+		clean(base[pos:start])
+		oursLines := applyChanges(base, start, end, ac)
+		theirsLines := applyChanges(base, start, end, bc)
+		switch {
+		case len(bc) == 0:
+			clean(oursLines)
+		case len(ac) == 0:
+			clean(theirsLines)
+		case slices.Equal(oursLines, theirsLines):
+			clean(oursLines)
+		default:
+			chunks = append(chunks, Chunk{
+				Conflict: true,
+				Base:     append([]string(nil), base[start:end]...),
+				Ours:     oursLines,
+				Theirs:   theirsLines,
+			})
+		}
+		pos = end
+	}
+	clean(base[pos:])
+	return chunks
+}
+
+// overlaps reports whether a change touches the region [start, end). Changes
+// at the start of the region always do, so that two insertions at the same
+// place conflict.
+func overlaps(c change, start, end int) bool {
+	return c.start < end || c.start == start
+}
+
+// applyChanges returns base lines [start, end) with the changes of one side
+func applyChanges(base []string, start, end int, cs []change) []string {
+	var lines []string
+	pos := start
+	for _, c := range cs {
+		lines = append(lines, base[pos:c.start]...)
+		lines = append(lines, c.lines...)
+		pos = c.end
+	}
+	return append(lines, base[pos:end]...)
+}
+
+// Conflicts counts the conflicts of a merge
+func Conflicts(chunks []Chunk) int {
+	n := 0
+	for _, chunk := range chunks {
+		if chunk.Conflict {
+			n++
+		}
+	}
+	return n
+}
+
+// MergeText merges three texts and returns the result with conflicts
+// marked the way git marks them, and the number of conflicts. Lines end
+// the way they do in ours.
+func MergeText(base, ours, theirs, oursName, theirsName string) (string, int) {
+	chunks := Merge(SplitLines(base), SplitLines(ours), SplitLines(theirs))
+	newline := "\n"
+	if strings.Contains(ours, "\r\n") {
+		newline = "\r\n"
+	}
+
+	var sb strings.Builder
+	line := func(s string) {
+		sb.WriteString(s)
+		sb.WriteString(newline)
+	}
+	for _, chunk := range chunks {
+		if !chunk.Conflict {
+			for _, l := range chunk.Lines {
+				line(l)
+			}
+			continue
+		}
+		line("<<<<<<< " + oursName)
+		for _, l := range chunk.Ours {
+			line(l)
+		}
+		line("=======")
+		for _, l := range chunk.Theirs {
+			line(l)
+		}
+		line(">>>>>>> " + theirsName)
+	}
+	return sb.String(), Conflicts(chunks)
+}
@@ -0,0 +1,54 @@
+package textdiff
+
+import "testing"
+
+func TestMergeText(t *testing.T) {
+	base := "[_point]\nType=0x01\nString=0x04,Bank\n[end]\n[_point]\nType=0x02\nString=0x04,Cafe\n[end]\n"
+
+	tests := []struct {
+		name         string
+		ours, theirs string
+		want         string
+		conflicts    int
+	}{
+		{
+			name:   "only ours",
+			ours:   "[_point]\nType=0x01\nString=0x04,ATM\n[end]\n[_point]\nType=0x02\nString=0x04,Cafe\n[end]\n",
+			theirs: base,
+			want:   "[_point]\nType=0x01\nString=0x04,ATM\n[end]\n[_point]\nType=0x02\nString=0x04,Cafe\n[end]\n",
+		},
+		{
+			name:   "different types",
+			ours:   "[_point]\nType=0x01\nString=0x04,ATM\n[end]\n[_point]\nType=0x02\nString=0x04,Cafe\n[end]\n",
+			theirs: "[_point]\nType=0x01\nString=0x04,Bank\n[end]\n[_point]\nType=0x02\nString=0x04,Coffee\n[end]\n",
+			want:   "[_point]\nType=0x01\nString=0x04,ATM\n[end]\n[_point]\nType=0x02\nString=0x04,Coffee\n[end]\n",
+		},
+		{
+			name:   "same change",
+			ours:   "[_point]\nType=0x01\nString=0x04,ATM\n[end]\n[_point]\nType=0x02\nString=0x04,Cafe\n[end]\n",
+			theirs: "[_point]\nType=0x01\nString=0x04,ATM\n[end]\n[_point]\nType=0x02\nString=0x04,Cafe\n[end]\n",
+			want:   "[_point]\nType=0x01\nString=0x04,ATM\n[end]\n[_point]\nType=0x02\nString=0x04,Cafe\n[end]\n",
+		},
+		{
+			name:      "same line",
+			ours:      "[_point]\nType=0x01\nString=0x04,ATM\n[end]\n[_point]\nType=0x02\nString=0x04,Cafe\n[end]\n",
+			theirs:    "[_point]\nType=0x01\nString=0x04,Money\n[end]\n[_point]\nType=0x02\nString=0x04,Cafe\n[end]\n",
+			want:      "[_point]\nType=0x01\n<<<<<<< ours\nString=0x04,ATM\n=======\nString=0x04,Money\n>>>>>>> theirs\n[end]\n[_point]\nType=0x02\nString=0x04,Cafe\n[end]\n",
+			conflicts: 1,
+		},
+		{
+			name:      "insertions at the same place",
+			ours:      base + "; ours\n",
+			theirs:    base + "; theirs\n",
+			want:      base + "<<<<<<< ours\n; ours\n=======\n; theirs\n>>>>>>> theirs\n",
+			conflicts: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		got, conflicts := MergeText(base, tt.ours, tt.theirs, "ours", "theirs")
+		if got != tt.want || conflicts != tt.conflicts {
+			t.Errorf("%s: got %d conflicts:\n%s\nwant %d:\n%s", tt.name, conflicts, got, tt.conflicts, tt.want)
+		}
+	}
+}
@@ -0,0 +1,252 @@
+// Package textdiff computes line diffs, formats them as unified diffs and
+// merges the changes two texts made to a common base. It is used for
+// previews, such as what restoring a backup would change, and for combining
+// edits with changes made to a file on disk.
+package textdiff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Op is the kind of an edit
+type Op int
+
+const (
+	Equal  Op = iota // the line is in both texts
+	Delete           // the line is only in the old text
+	Insert           // the line is only in the new text
+)
+
+// Edit is one line of a diff
+type Edit struct {
+	Op   Op
+	Line string
+}
+
+// maxEditDistance bounds the work spent on very different texts. Beyond it
+// the differing middle part is reported as replaced as a whole.
+const maxEditDistance = 4096
+
+// SplitLines splits text into lines without their line endings. A final
+// line ending does not start another line.
+func SplitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	lines := strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSuffix(line, "\r")
+	}
+	return lines
+}
+
+// Lines returns the edits that turn a into b, using Myers' algorithm
+func Lines(a, b []string) []Edit {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	edits := make([]Edit, 0, len(a)+len(b)-prefix-suffix)
+	edits = appendLines(edits, Equal, a[:prefix])
+	edits = append(edits, myers(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+	edits = appendLines(edits, Equal, a[len(a)-suffix:])
+	return edits
+}
+
+// appendLines appends one edit per line
+func appendLines(edits []Edit, op Op, lines []string) []Edit {
+	for _, line := range lines {
+		edits = append(edits, Edit{Op: op, Line: line})
+	}
+	return edits
+}
+
+// myers finds a shortest edit script. trace keeps the furthest reaching x
+// of every diagonal k after each step d, for walking the path back.
+func myers(a, b []string) []Edit {
+	n, m := len(a), len(b)
+	if n == 0 || m == 0 {
+		return appendLines(appendLines(nil, Delete, a), Insert, b)
+	}
+
+	offset := n + m
+	v := make([]int, 2*offset+2)
+	var trace [][]int
+	for d := 0; d <= n+m; d++ {
+		if d > maxEditDistance {
+			return appendLines(appendLines(nil, Delete, a), Insert, b)
+		}
+		done := false
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				done = true
+				break
+			}
+		}
+		trace = append(trace, append([]int(nil), v[offset-d:offset+d+1]...))
+		if done {
+			break
+		}
+	}
+
+	// Walk back from the end, collecting edits in reverse
+	var reversed []Edit
+	x, y := n, m
+	for d := len(trace) - 1; d > 0; d-- {
+		prev := trace[d-1] // diagonal k is at prev[k+d-1]
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && prev[k-1+d-1] < prev[k+1+d-1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := prev[prevK+d-1]
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			reversed = append(reversed, Edit{Op: Equal, Line: a[x-1]})
+			x--
+			y--
+		}
+		if x == prevX {
+			reversed = append(reversed, Edit{Op: Insert, Line: b[y-1]})
+			y--
+		} else {
+			reversed = append(reversed, Edit{Op: Delete, Line: a[x-1]})
+			x--
+		}
+	}
+	for x > 0 && y > 0 {
+		reversed = append(reversed, Edit{Op: Equal, Line: a[x-1]})
+		x--
+		y--
+	}
+
+	edits := make([]Edit, len(reversed))
+	for i, edit := range reversed {
+		edits[len(reversed)-1-i] = edit
+	}
+	return edits
+}
+
+// Hunk is a group of changes with the lines around them. Starts are 1-based
+// line numbers.
+type Hunk struct {
+	AStart, ALen int
+	BStart, BLen int
+	Edits        []Edit
+}
+
+// Hunks groups edits into hunks with up to context unchanged lines around
+// each change. Changes closer than twice the context share a hunk.
+func Hunks(edits []Edit, context int) []Hunk {
+	var hunks []Hunk
+	aLine, bLine := make([]int, len(edits)+1), make([]int, len(edits)+1)
+	for i, edit := range edits {
+		aLine[i+1], bLine[i+1] = aLine[i], bLine[i]
+		if edit.Op != Insert {
+			aLine[i+1]++
+		}
+		if edit.Op != Delete {
+			bLine[i+1]++
+		}
+	}
+
+	for i := 0; i < len(edits); {
+		if edits[i].Op == Equal {
+			i++
+			continue
+		}
+
+		start := i - context
+		if start < 0 {
+			start = 0
+		}
+		last := i // just after the last change of the hunk
+		for j := i; j < len(edits); j++ {
+			if edits[j].Op != Equal {
+				last = j + 1
+			} else if j-last >= 2*context {
+				break
+			}
+		}
+		end := last + context
+		if end > len(edits) {
+			end = len(edits)
+		}
+
+		hunks = append(hunks, Hunk{
+			AStart: aLine[start] + 1,
+			ALen:   aLine[end] - aLine[start],
+			BStart: bLine[start] + 1,
+			BLen:   bLine[end] - bLine[start],
+			Edits:  edits[start:end],
+		})
+		i = end
+	}
+	return hunks
+}
+
+// Unified formats the difference between two texts as a unified diff with
+// three lines of context. It returns "" when the texts are equal.
+func Unified(aName, bName, a, b string) string {
+	hunks := Hunks(Lines(SplitLines(a), SplitLines(b)), 3)
+	if len(hunks) == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %s\n+++ %s\n", aName, bName)
+	for _, hunk := range hunks {
+		sb.WriteString(hunk.Header())
+		sb.WriteString("\n")
+		for _, edit := range hunk.Edits {
+			sb.WriteString(edit.Prefix())
+			sb.WriteString(edit.Line)
+			sb.WriteString("\n")
+		}
+	}
+	return sb.String()
+}
+
+// Header returns the "@@ -a,n +b,m @@" line of a hunk. An empty side is
+// numbered after the line it follows, as diff(1) does.
+func (h Hunk) Header() string {
+	aStart, bStart := h.AStart, h.BStart
+	if h.ALen == 0 {
+		aStart--
+	}
+	if h.BLen == 0 {
+		bStart--
+	}
+	return fmt.Sprintf("@@ -%d,%d +%d,%d @@", aStart, h.ALen, bStart, h.BLen)
+}
+
+// Prefix returns the unified diff marker of an edit
+func (e Edit) Prefix() string {
+	switch e.Op {
+	case Delete:
+		return "-"
+	case Insert:
+		return "+"
+	}
+	return " "
+}
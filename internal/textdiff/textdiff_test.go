@@ -0,0 +1,99 @@
+package textdiff
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+// apply rebuilds both sides of a diff
+func apply(edits []Edit) (a, b []string) {
+	for _, edit := range edits {
+		if edit.Op != Insert {
+			a = append(a, edit.Line)
+		}
+		if edit.Op != Delete {
+			b = append(b, edit.Line)
+		}
+	}
+	return a, b
+}
+
+func TestLines(t *testing.T) {
+	tests := []struct {
+		a, b    string
+		changes int
+	}{
+		{"", "", 0},
+		{"a\nb\nc", "a\nb\nc", 0},
+		{"a\nb\nc", "a\nx\nc", 2},
+		{"", "a\nb", 2},
+		{"a\nb", "", 2},
+		{"a\nb\nc\nd", "b\nc\nd\ne", 2},
+		{"[_point]\nType=0x01\n[end]", "[_point]\nType=0x02\nString=0x04,Bank\n[end]", 3},
+	}
+
+	for _, tt := range tests {
+		a, b := SplitLines(tt.a), SplitLines(tt.b)
+		edits := Lines(a, b)
+		gotA, gotB := apply(edits)
+		if strings.Join(gotA, "\n") != tt.a || strings.Join(gotB, "\n") != tt.b {
+			t.Errorf("Lines(%q, %q) does not rebuild the inputs: %v", tt.a, tt.b, edits)
+		}
+		changes := 0
+		for _, edit := range edits {
+			if edit.Op != Equal {
+				changes++
+			}
+		}
+		if changes != tt.changes {
+			t.Errorf("Lines(%q, %q) has %d changes, want %d", tt.a, tt.b, changes, tt.changes)
+		}
+	}
+}
+
+func TestLinesRandom(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	words := []string{"a", "b", "c", "d"}
+	for i := 0; i < 200; i++ {
+		var a, b []string
+		for j := r.Intn(20); j > 0; j-- {
+			a = append(a, words[r.Intn(len(words))])
+		}
+		for j := r.Intn(20); j > 0; j-- {
+			b = append(b, words[r.Intn(len(words))])
+		}
+		gotA, gotB := apply(Lines(a, b))
+		if strings.Join(gotA, ",") != strings.Join(a, ",") || strings.Join(gotB, ",") != strings.Join(b, ",") {
+			t.Fatalf("Lines(%q, %q) does not rebuild the inputs", a, b)
+		}
+	}
+}
+
+func TestUnified(t *testing.T) {
+	a := "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n"
+	b := "1\n2\nthree\n4\n5\n6\n7\n8\n9\n10\n11\n12\n13\n"
+
+	want := `--- old
++++ new
+@@ -1,6 +1,6 @@
+ 1
+ 2
+-3
++three
+ 4
+ 5
+ 6
+@@ -10,3 +10,4 @@
+ 10
+ 11
+ 12
++13
+`
+	if got := Unified("old", "new", a, b); got != want {
+		t.Errorf("Unexpected diff:\n%s\nwant:\n%s", got, want)
+	}
+	if got := Unified("old", "new", a, a); got != "" {
+		t.Errorf("Expected no diff for equal texts, got:\n%s", got)
+	}
+}
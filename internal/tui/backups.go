@@ -0,0 +1,175 @@
+package tui
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/dyuri/typtui/internal/backup"
+	"github.com/dyuri/typtui/internal/textdiff"
+)
+
+// openBackups lists the backups of the open file in ModeRestoreBackup
+func (m *Model) openBackups() {
+	backups, err := backup.List(m.filePath)
+	if err != nil {
+		m.status = fmt.Sprintf("Cannot list backups: %v", err)
+		return
+	}
+	if len(backups) == 0 {
+		m.status = "No backups of " + m.filePath
+		return
+	}
+
+	m.backups = backups
+	m.backupIdx = 0
+	m.restoreConfirm = false
+	m.mode = ModeRestoreBackup
+	m.loadBackupPreview()
+}
+
+// loadBackupPreview diffs the selected backup against the file on disk:
+// the changes restoring it would make
+func (m *Model) loadBackupPreview() {
+	m.backupPreview = nil
+	m.backupErr = nil
+
+	current, err := os.ReadFile(m.filePath)
+	if err != nil {
+		m.backupErr = err
+		return
+	}
+	saved, err := os.ReadFile(m.backups[m.backupIdx].Path)
+	if err != nil {
+		m.backupErr = err
+		return
+	}
+	edits := textdiff.Lines(textdiff.SplitLines(string(current)), textdiff.SplitLines(string(saved)))
+	m.backupPreview = textdiff.Hunks(edits, 3)
+}
+
+// handleBackupsKeyPress handles keyboard input on the restore backup screen
+func (m Model) handleBackupsKeyPress(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if m.restoreConfirm {
+		switch msg.String() {
+		case "y", "Y":
+			return m.restoreBackup()
+		default:
+			m.restoreConfirm = false
+		}
+		return m, nil
+	}
+
+	switch msg.String() {
+	case "q", "ctrl+c":
+		if m.modified {
+			m.mode = ModeConfirmQuit
+			return m, nil
+		}
+		return m, tea.Quit
+
+	case "up", "k":
+		if m.backupIdx > 0 {
+			m.backupIdx--
+			m.loadBackupPreview()
+		}
+
+	case "down", "j":
+		if m.backupIdx < len(m.backups)-1 {
+			m.backupIdx++
+			m.loadBackupPreview()
+		}
+
+	case "enter":
+		if m.modified {
+			// Restoring drops the edits in memory, ask first
+			m.restoreConfirm = true
+			return m, nil
+		}
+		return m.restoreBackup()
+
+	case "esc":
+		m.mode = ModeList
+		m.backups = nil
+		m.backupPreview = nil
+	}
+
+	return m, nil
+}
+
+// restoreBackup replaces the file with the selected backup and reloads it
+func (m Model) restoreBackup() (tea.Model, tea.Cmd) {
+	m.restoreConfirm = false
+	selected := m.backups[m.backupIdx]
+	if err := backup.Restore(m.filePath, selected, m.config.Backups); err != nil {
+		m.status = fmt.Sprintf("Error restoring: %v", err)
+		return m, nil
+	}
+
+	m.mode = ModeList
+	m.modified = false
+	m.discardJournal()
+	m.backups = nil
+	m.backupPreview = nil
+	m.selectedIdx = 0
+	m.status = "Restored the backup from " + selected.Time.Format("2006-01-02 15:04:05")
+	return m, loadFileCmd(m.filePath)
+}
+
+// viewBackups renders the list of backups with a preview of the selected one
+func (m Model) viewBackups() string {
+	var b strings.Builder
+
+	b.WriteString(titleStyle.Render("Backups of " + m.filePath))
+	b.WriteString("\n\n")
+
+	for i, bak := range m.backups {
+		line := fmt.Sprintf("%s  %8s", bak.Time.Format("2006-01-02 15:04:05"), formatSize(bak.Size))
+		if i == m.backupIdx {
+			b.WriteString(selectedStyle.Render("▸ " + line))
+		} else {
+			b.WriteString("  " + line)
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString("\n")
+
+	// The preview gets the rest of the screen
+	room := m.height - len(m.backups) - 9
+	if room < 5 {
+		room = 5
+	}
+	switch {
+	case m.backupErr != nil:
+		b.WriteString(errorStyle.Render(m.backupErr.Error()))
+		b.WriteString("\n")
+	case len(m.backupPreview) == 0:
+		b.WriteString(statusStyle.Render("Same as the file on disk"))
+		b.WriteString("\n")
+	default:
+		b.WriteString(statusStyle.Render("Restoring changes the file on disk like this:"))
+		b.WriteString("\n")
+		b.WriteString(renderHunks(m.backupPreview, room))
+	}
+
+	b.WriteString("\n")
+	if m.restoreConfirm {
+		b.WriteString(errorStyle.Render("Unsaved changes will be lost. Restore anyway? [y/N]"))
+	} else {
+		b.WriteString(helpStyle.Render("[↑/↓] Select backup  [Enter] Restore  [Esc] Back  [q] Quit"))
+	}
+
+	return b.String()
+}
+
+// formatSize formats a file size for humans
+func formatSize(size int64) string {
+	switch {
+	case size >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(size)/(1<<20))
+	case size >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(size)/(1<<10))
+	}
+	return fmt.Sprintf("%d B", size)
+}
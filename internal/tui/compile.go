@@ -0,0 +1,384 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/dyuri/typtui/internal/compiler"
+)
+
+// Fields of the compile form
+const (
+	compileJar = iota
+	compileOutput
+	compileFamily
+	compileProduct
+)
+
+// compileLogMsg is a line of mkgmap output
+type compileLogMsg struct {
+	msg compiler.Message
+}
+
+// compileDoneMsg is sent when mkgmap has finished
+type compileDoneMsg struct {
+	err error
+}
+
+// waitCompileCmd waits for the next line of mkgmap output or its end
+func waitCompileCmd(ch chan tea.Msg) tea.Cmd {
+	return func() tea.Msg {
+		return <-ch
+	}
+}
+
+// openCompile shows the output of the last compilation in ModeCompile, or
+// the form starting one if there was none
+func (m *Model) openCompile() {
+	m.mode = ModeCompile
+	if m.compileRunning || m.compileLog != nil || m.compileErr != nil {
+		m.inputs = nil
+		return
+	}
+	m.openCompileForm()
+}
+
+// openCompileForm shows the form compiling the file with mkgmap. The
+// command line comes from the config file; the IDs default to those of
+// the file.
+func (m *Model) openCompileForm() {
+	cfg := m.config.Mkgmap
+	id := func(n int) string {
+		if n == 0 {
+			return ""
+		}
+		return strconv.Itoa(n)
+	}
+	output := m.compileOutput
+	if output == "" {
+		output = compiler.DefaultOutput(m.filePath)
+	}
+
+	fields := []struct {
+		prompt, placeholder, value string
+		limit                      int
+	}{
+		compileJar:     {"mkgmap.jar: ", "path of mkgmap.jar", cfg.Jar, 256},
+		compileOutput:  {"Output: ", "path of the compiled TYP file", output, 256},
+		compileFamily:  {"Family ID: ", fmt.Sprintf("empty keeps FID=%d", m.typFile.Header.FID), id(cfg.FamilyID), 5},
+		compileProduct: {"Product ID: ", fmt.Sprintf("empty keeps ProductCode=%d", m.typFile.Header.ProductCode), id(cfg.ProductID), 5},
+	}
+
+	inputs := make([]textinput.Model, len(fields))
+	for i, f := range fields {
+		inputs[i] = textinput.New()
+		inputs[i].Prompt = f.prompt
+		inputs[i].Placeholder = f.placeholder
+		inputs[i].CharLimit = f.limit
+		inputs[i].Width = 50
+		inputs[i].SetValue(f.value)
+	}
+	focus := compileJar
+	if cfg.Jar != "" {
+		focus = compileOutput
+	}
+	inputs[focus].Focus()
+
+	m.inputs = inputs
+	m.focusedField = focus
+	m.formErr = ""
+}
+
+// startCompile runs mkgmap on a copy of the file as it is now, with the
+// settings of the form, and streams its output into the log
+func (m *Model) startCompile() tea.Cmd {
+	value := func(i int) string { return strings.TrimSpace(m.inputs[i].Value()) }
+	id := func(i int, name string) (int, bool) {
+		if value(i) == "" {
+			return 0, true
+		}
+		n, err := strconv.Atoi(value(i))
+		if err != nil || n < 1 || n > 0xffff {
+			m.formErr = name + " must be a number from 1 to 65535"
+			return 0, false
+		}
+		return n, true
+	}
+
+	opts := compiler.Options{
+		Java: m.config.Mkgmap.Java,
+		Jar:  expandHome(value(compileJar)),
+		Args: m.config.Mkgmap.Options,
+	}
+	if opts.Jar == "" {
+		m.formErr = "Enter the path of mkgmap.jar"
+		return nil
+	}
+	shown := value(compileOutput)
+	output := expandHome(shown)
+	if output == "" {
+		m.formErr = "Enter the path of the compiled file"
+		return nil
+	}
+	if compiler.SamePath(output, m.filePath) {
+		m.formErr = "The compiled file would overwrite the file being edited"
+		return nil
+	}
+	var ok bool
+	if opts.FamilyID, ok = id(compileFamily, "Family ID"); !ok {
+		return nil
+	}
+	if opts.ProductID, ok = id(compileProduct, "Product ID"); !ok {
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := make(chan tea.Msg, 64)
+	closed, finished := make(chan struct{}), make(chan struct{})
+	typFile := m.typFile.Clone()
+	go func() {
+		defer close(finished)
+		// Once the program has ended nobody reads the output
+		send := func(msg tea.Msg) {
+			select {
+			case ch <- msg:
+			case <-closed:
+			}
+		}
+		_, err := compiler.Run(ctx, typFile, output, opts, func(msg compiler.Message) {
+			send(compileLogMsg{msg})
+		})
+		send(compileDoneMsg{err})
+	}()
+
+	m.inputs = nil
+	m.compileLog = []compiler.Message{}
+	m.compileIdx = 0
+	m.compileCh = ch
+	m.compileCancel = cancel
+	m.compileClosed = closed
+	m.compileFinished = finished
+	m.compileRunning = true
+	m.compileOutput = shown
+	m.compileErr = nil
+	return waitCompileCmd(ch)
+}
+
+// handleCompileLog adds a line of mkgmap output to the log, keeping the
+// selection on the last line if it was there
+func (m Model) handleCompileLog(msg compileLogMsg) (tea.Model, tea.Cmd) {
+	follow := m.compileIdx >= len(m.compileLog)-1
+	m.compileLog = append(m.compileLog, msg.msg)
+	if follow {
+		m.compileIdx = len(m.compileLog) - 1
+	}
+	return m, waitCompileCmd(m.compileCh)
+}
+
+// handleCompileDone records the outcome of a compilation
+func (m Model) handleCompileDone(msg compileDoneMsg) (tea.Model, tea.Cmd) {
+	m.compileRunning = false
+	m.compileCancel()
+	m.compileCancel = nil
+	m.compileCh = nil
+	m.compileErr = msg.err
+	if msg.err != nil {
+		m.status = fmt.Sprintf("mkgmap: %v", msg.err)
+	} else {
+		m.status = "Compiled to " + m.compileOutput + m.compileWarnings()
+	}
+	return m, nil
+}
+
+// compileWarnings counts the problems mkgmap reported, "" if none
+func (m Model) compileWarnings() string {
+	errs, warnings := 0, 0
+	for _, msg := range m.compileLog {
+		switch msg.Severity {
+		case compiler.SeverityError:
+			errs++
+		case compiler.SeverityWarning:
+			warnings++
+		}
+	}
+	if errs == 0 && warnings == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" (%d errors, %d warnings)", errs, warnings)
+}
+
+// stopCompile stops a running mkgmap
+func (m *Model) stopCompile() {
+	if m.compileCancel != nil {
+		m.compileCancel()
+	}
+}
+
+// Close stops a running mkgmap and waits until its temporary files are
+// gone. Call it once the program has ended.
+func (m Model) Close() {
+	if !m.compileRunning {
+		return
+	}
+	m.stopCompile()
+	close(m.compileClosed)
+	<-m.compileFinished
+}
+
+// handleCompileKeyPress handles keyboard input in the compile form and the
+// mkgmap log
+func (m Model) handleCompileKeyPress(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if m.inputs != nil {
+		var cmd tea.Cmd
+		switch msg.String() {
+		case "enter":
+			return m, m.startCompile()
+
+		case "esc":
+			m.inputs = nil
+			if m.compileLog == nil && m.compileErr == nil {
+				m.mode = ModeList
+			}
+			return m, nil
+
+		case "tab", "down":
+			m.moveFocus(1)
+			return m, nil
+
+		case "shift+tab", "up":
+			m.moveFocus(-1)
+			return m, nil
+		}
+		m.inputs[m.focusedField], cmd = m.inputs[m.focusedField].Update(msg)
+		return m, cmd
+	}
+
+	switch msg.String() {
+	case "q", "ctrl+c":
+		if m.modified {
+			m.mode = ModeConfirmQuit
+			return m, nil
+		}
+		m.stopCompile()
+		return m, tea.Quit
+
+	case "up", "k":
+		if m.compileIdx > 0 {
+			m.compileIdx--
+		}
+
+	case "down", "j":
+		if m.compileIdx < len(m.compileLog)-1 {
+			m.compileIdx++
+		}
+
+	case "enter":
+		// Jump to the type the selected line is about
+		if m.compileIdx >= len(m.compileLog) {
+			return m, nil
+		}
+		line := m.compileLog[m.compileIdx]
+		if line.Index < 0 {
+			return m, nil
+		}
+		for tab, kind := range tabKinds {
+			if kind == line.Kind {
+				m.activeTab = tab
+				m.selectedIdx = line.Index
+				if m.selectedIdx < m.getMaxIndex() {
+					m.mode = ModeDetail
+				}
+			}
+		}
+
+	case "c":
+		if !m.compileRunning {
+			m.openCompileForm()
+		}
+
+	case "x":
+		m.stopCompile()
+
+	case "esc":
+		m.mode = ModeList
+	}
+
+	return m, nil
+}
+
+// viewCompile renders the compile form or the mkgmap log
+func (m Model) viewCompile() string {
+	var b strings.Builder
+
+	b.WriteString(m.renderHeader())
+	b.WriteString("\n\n")
+
+	if m.inputs != nil {
+		b.WriteString(titleStyle.Render("Compile with mkgmap"))
+		b.WriteString("\n\n")
+		for _, input := range m.inputs {
+			b.WriteString(input.View())
+			b.WriteString("\n\n")
+		}
+		if m.formErr != "" {
+			b.WriteString(errorStyle.Render(m.formErr))
+			b.WriteString("\n\n")
+		}
+		b.WriteString(helpStyle.Render("[Enter] Compile  [Esc] Cancel  [Tab/↑/↓] Navigate fields"))
+		return b.String()
+	}
+
+	b.WriteString(titleStyle.Render("mkgmap → " + m.compileOutput))
+	b.WriteString("\n")
+	switch {
+	case m.compileRunning:
+		b.WriteString(statusStyle.Render("Compiling..."))
+	case m.compileErr != nil:
+		b.WriteString(errorStyle.Render(m.compileErr.Error()))
+	default:
+		b.WriteString(statusStyle.Render("Compiled" + m.compileWarnings()))
+	}
+	b.WriteString("\n\n")
+
+	// Keep the selection visible when the log is taller than the screen
+	visible := max(m.height-9, 5)
+	start := max(m.compileIdx-visible+1, 0)
+	end := min(start+visible, len(m.compileLog))
+	for i := start; i < end; i++ {
+		b.WriteString(renderCompileLine(m.compileLog[i], i == m.compileIdx))
+		b.WriteString("\n")
+	}
+
+	b.WriteString("\n")
+	help := "[↑/↓] Navigate  [Enter] Go to type  [c] Compile again  [Esc] Back  [q] Quit"
+	if m.compileRunning {
+		help = "[↑/↓] Navigate  [x] Stop mkgmap  [Esc] Back  [q] Quit"
+	}
+	b.WriteString(helpStyle.Render(help))
+
+	return b.String()
+}
+
+// renderCompileLine renders a line of mkgmap output with the type it is
+// about
+func renderCompileLine(msg compiler.Message, selected bool) string {
+	text := msg.Text
+	switch {
+	case selected:
+		text = selectedStyle.Render("▸ " + text)
+	case msg.Severity == compiler.SeverityError:
+		text = "  " + errorStyle.Render(text)
+	case msg.Severity == compiler.SeverityWarning:
+		text = "  " + warningStyle.Render(text)
+	default:
+		text = "  " + text
+	}
+	if msg.Kind != "" {
+		text += helpStyle.Render(fmt.Sprintf("  → %s %s", msg.Kind, msg.Type))
+	}
+	return text
+}
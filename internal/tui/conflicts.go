@@ -0,0 +1,196 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/dyuri/typtui/internal/config"
+	"github.com/dyuri/typtui/internal/typdiff"
+	"github.com/dyuri/typtui/internal/typmerge"
+)
+
+// NewMergeModel creates a model that resolves the conflicts of a merge
+// before the merged file is edited and saved to filePath
+func NewMergeModel(filePath string, cfg config.Config, merge *typmerge.Result) Model {
+	m := NewModel(filePath, cfg)
+	m.merge = merge
+	// A journal of the conflicted file has nothing worth recovering
+	m.recoveryChecked = true
+	return m
+}
+
+// startMerge replaces the loaded file with the merge result and lists the
+// conflicts in ModeConflicts
+func (m *Model) startMerge() {
+	m.modified = true
+	m.conflictIdx = 0
+	m.diagnostics = nil
+	m.applyMerge()
+	m.mode = ModeConflicts
+}
+
+// applyMerge takes the merged file with the conflicts resolved so far
+func (m *Model) applyMerge() {
+	m.typFile = m.merge.File()
+	m.typFile.FilePath = m.filePath
+	m.revalidate()
+}
+
+// homeMode is the mode to go back to from screens opened over the list:
+// the conflicts while a merge is being resolved
+func (m Model) homeMode() Mode {
+	if m.merge != nil {
+		return ModeConflicts
+	}
+	return ModeList
+}
+
+// resolveConflict resolves the selected conflict to one side and moves on
+// to the next unresolved one
+func (m *Model) resolveConflict(side typmerge.Side) {
+	m.merge.Conflicts[m.conflictIdx].Resolve(side)
+	m.applyMerge()
+
+	for i := 1; i < len(m.merge.Conflicts); i++ {
+		next := (m.conflictIdx + i) % len(m.merge.Conflicts)
+		if !m.merge.Conflicts[next].Resolved {
+			m.conflictIdx = next
+			return
+		}
+	}
+}
+
+// handleConflictsKeyPress handles keyboard input in the conflict list
+func (m Model) handleConflictsKeyPress(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	conflicts := m.merge.Conflicts
+
+	switch msg.String() {
+	case "q", "ctrl+c":
+		m.mode = ModeConfirmQuit
+		return m, nil
+
+	case "up", "k":
+		if m.conflictIdx > 0 {
+			m.conflictIdx--
+		}
+
+	case "down", "j":
+		if m.conflictIdx < len(conflicts)-1 {
+			m.conflictIdx++
+		}
+
+	case "o":
+		if len(conflicts) > 0 {
+			m.resolveConflict(typmerge.Ours)
+		}
+
+	case "t":
+		if len(conflicts) > 0 {
+			m.resolveConflict(typmerge.Theirs)
+		}
+
+	case "O", "T":
+		side := typmerge.Ours
+		if msg.String() == "T" {
+			side = typmerge.Theirs
+		}
+		for _, c := range conflicts {
+			if !c.Resolved {
+				c.Resolve(side)
+			}
+		}
+		m.applyMerge()
+
+	case "enter":
+		// Later edits are made to the merged file, the merge is done
+		unresolved := m.merge.Unresolved()
+		m.merge = nil
+		m.mode = ModeList
+		m.selectedIdx = 0
+		m.status = "Merged, press Ctrl+S to save"
+		if unresolved > 0 {
+			m.status = fmt.Sprintf("Merged keeping our version of %d unresolved conflicts, press Ctrl+S to save", unresolved)
+		}
+	}
+
+	return m, nil
+}
+
+// viewConflicts renders the merge conflicts with the versions of the
+// selected one
+func (m Model) viewConflicts() string {
+	var b strings.Builder
+
+	conflicts := m.merge.Conflicts
+	title := fmt.Sprintf("Merge conflicts in %s (%d of %d resolved)",
+		m.filePath, len(conflicts)-m.merge.Unresolved(), len(conflicts))
+	b.WriteString(titleStyle.Render(title))
+	b.WriteString("\n\n")
+	if len(conflicts) == 0 {
+		b.WriteString(statusStyle.Render("The changes merged without conflicts"))
+		b.WriteString("\n")
+	}
+
+	// Keep the selection visible, leaving room for the versions below
+	visible := m.height/2 - 4
+	if visible < 3 {
+		visible = 3
+	}
+	start := 0
+	if m.conflictIdx >= visible {
+		start = m.conflictIdx - visible + 1
+	}
+	end := min(start+visible, len(conflicts))
+
+	for i := start; i < end; i++ {
+		c := conflicts[i]
+		mark := "[ ]"
+		if c.Resolved {
+			mark = "[" + c.Choice.String()[:1] + "]"
+		}
+		where := c.Kind + " " + c.Type
+		what := c.Property
+		if what == "" {
+			what = fmt.Sprintf("%s by us, %s by them", c.Ours, c.Theirs)
+		}
+
+		if i == m.conflictIdx {
+			b.WriteString(selectedStyle.Render(fmt.Sprintf("▸ %s %-16s %s", mark, where, what)))
+		} else {
+			fmt.Fprintf(&b, "  %s %-16s %s", mark, where, what)
+		}
+		b.WriteString("\n")
+	}
+
+	if m.conflictIdx < len(conflicts) {
+		c := conflicts[m.conflictIdx]
+		b.WriteString("\n")
+		if c.Property != "" {
+			fmt.Fprintf(&b, "  %-7s %s\n", "Base:", conflictValue(c.Base))
+			fmt.Fprintf(&b, "  %-7s %s\n", "Ours:", conflictValue(c.Ours))
+			fmt.Fprintf(&b, "  %-7s %s\n", "Theirs:", conflictValue(c.Theirs))
+		}
+		if c.OursIcon != nil || c.TheirsIcon != nil {
+			b.WriteString("\n")
+			b.WriteString(helpStyle.Render("  ours → theirs"))
+			b.WriteString("\n")
+			for _, line := range typdiff.SideBySide(c.OursIcon, c.TheirsIcon, true) {
+				b.WriteString("  " + line + "\n")
+			}
+		}
+	}
+
+	b.WriteString("\n")
+	b.WriteString(helpStyle.Render("[↑/↓] Navigate  [o/t] Keep ours/theirs  [O/T] All remaining  [Enter] Done  [q] Quit"))
+
+	return b.String()
+}
+
+// conflictValue shows an unset value
+func conflictValue(s string) string {
+	if s == "" {
+		return helpStyle.Render("(unset)")
+	}
+	return s
+}
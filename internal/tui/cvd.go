@@ -0,0 +1,47 @@
+package tui
+
+import (
+	"slices"
+
+	"github.com/dyuri/typtui/internal/cvd"
+	"github.com/dyuri/typtui/internal/parser"
+)
+
+// cycleSimulation switches the icon previews to the next colour vision
+// deficiency, and back to normal vision after the last
+func (m *Model) cycleSimulation() {
+	i := slices.Index(cvd.Deficiencies, m.simulation)
+	if i+1 < len(cvd.Deficiencies) {
+		m.simulation = cvd.Deficiencies[i+1]
+		m.status = "Previews simulate " + string(m.simulation)
+	} else {
+		m.simulation = ""
+		m.status = "Previews show normal colour vision"
+	}
+}
+
+// seenIcon returns an icon as it looks with the simulated deficiency
+func (m Model) seenIcon(xpm *parser.XPMIcon) *parser.XPMIcon {
+	if m.simulation == "" {
+		return xpm
+	}
+	return m.simulation.Icon(xpm)
+}
+
+// seenColor returns a palette colour as it looks with the simulated
+// deficiency
+func (m Model) seenColor(hex string) string {
+	if m.simulation == "" {
+		return hex
+	}
+	return m.simulation.Color(parser.Color{Hex: hex}).Hex
+}
+
+// simulationNote returns " (as seen with <deficiency>)" while a
+// deficiency is simulated
+func (m Model) simulationNote() string {
+	if m.simulation == "" {
+		return ""
+	}
+	return " (as seen with " + string(m.simulation) + ")"
+}
@@ -0,0 +1,191 @@
+package tui
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/dyuri/typtui/internal/parser"
+	"github.com/dyuri/typtui/internal/xpmimage"
+)
+
+// Fields of the export form
+const (
+	exportPath = iota
+	exportTarget
+	exportScale
+	exportSheet
+)
+
+// tabKinds names the kind of type on each tab
+var tabKinds = map[Tab]string{
+	TabPoints:   "point",
+	TabLines:    "line",
+	TabPolygons: "polygon",
+}
+
+// selectedCode returns the type code of the selected type in mkgmap form
+func (m Model) selectedCode() string {
+	var typ, subType string
+	switch m.activeTab {
+	case TabPoints:
+		typ, subType = m.typFile.Points[m.selectedIdx].Type, m.typFile.Points[m.selectedIdx].SubType
+	case TabLines:
+		typ = m.typFile.Lines[m.selectedIdx].Type
+	case TabPolygons:
+		typ = m.typFile.Polygons[m.selectedIdx].Type
+	}
+	if code, err := parser.ParseTypeCode(typ, subType); err == nil {
+		return code.String()
+	}
+	return typ
+}
+
+// exportPaths returns where the icon of the selected type and the sprite
+// sheet of its kind are written by default: next to the TYP file
+func (m Model) exportPaths() (icon, sheet string) {
+	dir := filepath.Dir(m.filePath)
+	kind := tabKinds[m.activeTab]
+	return filepath.Join(dir, kind+"-"+m.selectedCode()+".png"), filepath.Join(dir, kind+"s.png")
+}
+
+// openExport shows the form exporting the icon of the selected type, or
+// the icons of all types of its kind, to PNG
+func (m *Model) openExport() {
+	if m.selectedXPM("") == nil {
+		return
+	}
+	icon, _ := m.exportPaths()
+
+	fields := []struct {
+		prompt, placeholder, value string
+		limit                      int
+	}{
+		exportPath:   {"PNG file: ", "path of the PNG to write", icon, 256},
+		exportTarget: {"Icon: ", strings.Join(m.xpmTargets(), " or "), m.xpmTargets()[0], 10},
+		exportScale:  {"Scale: ", "size of an icon pixel in PNG pixels", "1", 3},
+		exportSheet:  {"Sprite sheet: ", "Y to export every " + tabKinds[m.activeTab] + " with a JSON index", "N", 1},
+	}
+
+	inputs := make([]textinput.Model, len(fields))
+	for i, f := range fields {
+		inputs[i] = textinput.New()
+		inputs[i].Prompt = f.prompt
+		inputs[i].Placeholder = f.placeholder
+		inputs[i].CharLimit = f.limit
+		inputs[i].Width = 50
+		inputs[i].SetValue(f.value)
+	}
+	inputs[exportPath].Focus()
+
+	m.inputs = inputs
+	m.focusedField = exportPath
+	m.formErr = ""
+	m.mode = ModeExportPNG
+}
+
+// handleExportKeyPress handles keyboard input in the export form
+func (m Model) handleExportKeyPress(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	var cmd tea.Cmd
+
+	switch msg.String() {
+	case "enter":
+		m.exportImage()
+		return m, nil
+
+	case "esc":
+		m.mode = ModeDetail
+		m.inputs = nil
+		return m, nil
+
+	case "tab", "down":
+		m.moveFocus(1)
+		return m, nil
+
+	case "shift+tab", "up":
+		m.moveFocus(-1)
+		return m, nil
+	}
+
+	m.inputs[m.focusedField], cmd = m.inputs[m.focusedField].Update(msg)
+	return m, cmd
+}
+
+// exportImage writes the PNG the form asks for and returns to the detail
+// view, or explains what is wrong with the form
+func (m *Model) exportImage() {
+	value := func(i int) string { return strings.TrimSpace(m.inputs[i].Value()) }
+
+	target := ""
+	for _, t := range m.xpmTargets() {
+		if strings.EqualFold(value(exportTarget), t) {
+			target = t
+		}
+	}
+	if target == "" {
+		m.formErr = "Icon must be " + strings.Join(m.xpmTargets(), " or ")
+		return
+	}
+	scale, err := strconv.Atoi(value(exportScale))
+	if err != nil || scale < 1 {
+		m.formErr = "Scale must be a number from 1"
+		return
+	}
+	path := value(exportPath)
+	if path == "" {
+		m.formErr = "Enter the path of the PNG"
+		return
+	}
+
+	if strings.EqualFold(value(exportSheet), "Y") {
+		// The default name of the icon makes no sense for a sheet
+		if icon, sheet := m.exportPaths(); path == icon {
+			path = sheet
+		}
+		entries, err := xpmimage.Entries(m.typFile, tabKinds[m.activeTab], target == "NightXpm")
+		if err != nil {
+			m.formErr = err.Error()
+			return
+		}
+		if _, err := xpmimage.ExportSheet(path, entries, scale); err != nil {
+			m.formErr = err.Error()
+			return
+		}
+		m.status = fmt.Sprintf("Exported the %s sprite sheet to %s and %s", tabKinds[m.activeTab], path, xpmimage.IndexPath(path))
+	} else {
+		if err := xpmimage.ExportFile(path, *m.selectedXPM(target), scale); err != nil {
+			m.formErr = fmt.Sprintf("Cannot export %s: %v", target, err)
+			return
+		}
+		m.status = fmt.Sprintf("Exported %s to %s", target, path)
+	}
+
+	m.inputs = nil
+	m.mode = ModeDetail
+}
+
+// viewExport renders the PNG export form
+func (m Model) viewExport() string {
+	var b strings.Builder
+
+	b.WriteString(m.renderHeader())
+	b.WriteString("\n\n")
+	b.WriteString(titleStyle.Render("Export to PNG"))
+	b.WriteString("\n\n")
+
+	for _, input := range m.inputs {
+		b.WriteString(input.View())
+		b.WriteString("\n\n")
+	}
+
+	if m.formErr != "" {
+		b.WriteString(errorStyle.Render(m.formErr))
+		b.WriteString("\n\n")
+	}
+	b.WriteString(helpStyle.Render("[Enter] Export  [Esc] Cancel  [Tab/↑/↓] Navigate fields"))
+
+	return b.String()
+}
@@ -0,0 +1,223 @@
+package tui
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/dyuri/typtui/internal/filestamp"
+	"github.com/dyuri/typtui/internal/parser"
+	"github.com/dyuri/typtui/internal/textdiff"
+)
+
+// watchInterval is how often the file on disk is checked for changes
+const watchInterval = 2 * time.Second
+
+// errChangedOnDisk refuses a save that would overwrite changes made to the
+// file by someone else
+var errChangedOnDisk = errors.New("the file changed on disk since it was loaded")
+
+// watchTickMsg asks for the next check of the file on disk
+type watchTickMsg struct{}
+
+// diskCheckedMsg reports whether the file still has the stamp it was
+// checked against
+type diskCheckedMsg struct {
+	stamp   filestamp.Stamp
+	changed bool
+	removed bool
+}
+
+// watchTickCmd waits for the next check of the file on disk
+func (m Model) watchTickCmd() tea.Cmd {
+	if m.filePath == "" {
+		return nil
+	}
+	return tea.Tick(watchInterval, func(time.Time) tea.Msg {
+		return watchTickMsg{}
+	})
+}
+
+// checkDiskCmd compares the file on disk with the loaded version in the
+// background
+func (m Model) checkDiskCmd() tea.Cmd {
+	if m.stamp.IsZero() {
+		return m.watchTickCmd()
+	}
+	stamp, path := m.stamp, m.filePath
+	return func() tea.Msg {
+		changed, err := stamp.Changed(path)
+		return diskCheckedMsg{stamp: stamp, changed: changed, removed: errors.Is(err, fs.ErrNotExist)}
+	}
+}
+
+// openFileChanged shows what changed on disk and asks how to go on. quit
+// continues quitting once the file is overwritten.
+func (m *Model) openFileChanged(quit bool) {
+	m.changedHunks = nil
+	m.changedErr = nil
+	m.changedStatus = ""
+	m.quitAfterSave = quit
+	if m.mode != ModeFileChanged {
+		m.changedReturn = m.mode
+		if m.changedReturn == ModeConfirmQuit {
+			m.changedReturn = m.homeMode()
+		}
+	}
+	m.mode = ModeFileChanged
+
+	disk, _, err := filestamp.Read(m.filePath)
+	if err != nil {
+		m.changedErr = err
+		return
+	}
+	edits := textdiff.Lines(textdiff.SplitLines(string(m.loaded)), textdiff.SplitLines(string(disk)))
+	m.changedHunks = textdiff.Hunks(edits, 3)
+}
+
+// handleFileChangedKeyPress handles keyboard input on the file changed
+// screen
+func (m Model) handleFileChangedKeyPress(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "r", "R":
+		// Take the version on disk, the edits are dropped
+		m.modified = false
+		m.discardJournal()
+		m.mode = ModeList
+		m.selectedIdx = 0
+		m.status = "Reloaded " + m.filePath
+		return m, loadFileCmd(m.filePath)
+
+	case "o", "O":
+		if m.typFile.Binary {
+			m.changedStatus = m.filePath + " is a compiled TYP file and is opened read-only"
+			return m, nil
+		}
+		if err := m.checkFindings(); err != nil {
+			m.openValidation(true, m.quitAfterSave)
+			return m, nil
+		}
+		if err := m.writeFile(); err != nil {
+			m.changedStatus = fmt.Sprintf("Error saving: %v", err)
+			return m, nil
+		}
+		if m.quitAfterSave {
+			return m, tea.Quit
+		}
+		m.mode = m.changedReturn
+		return m, nil
+
+	case "m", "M":
+		if m.modified {
+			return m.mergeDisk()
+		}
+
+	case "esc", "c", "C":
+		m.mode = m.changedReturn
+		m.status = "Not saved, the file changed on disk"
+	}
+
+	return m, nil
+}
+
+// mergeDisk combines the edits with the changes made on disk, using the
+// version that was loaded as their common base
+func (m Model) mergeDisk() (tea.Model, tea.Cmd) {
+	disk, stamp, err := filestamp.Read(m.filePath)
+	if err != nil {
+		m.changedStatus = fmt.Sprintf("Cannot read the file: %v", err)
+		return m, nil
+	}
+	var ours bytes.Buffer
+	if err := parser.Write(&ours, m.typFile); err != nil {
+		m.changedStatus = fmt.Sprintf("Cannot merge: %v", err)
+		return m, nil
+	}
+
+	merged, conflicts := textdiff.MergeText(string(m.loaded), ours.String(), string(disk), "typtui", m.filePath)
+	if conflicts > 0 {
+		m.changedStatus = fmt.Sprintf("%d conflicts: your edits and the changes on disk touch the same lines. Reload or overwrite instead.", conflicts)
+		return m, nil
+	}
+	typFile, diagnostics, err := parser.ParseReaderTolerant(strings.NewReader(merged), m.filePath)
+	if err != nil {
+		m.changedStatus = fmt.Sprintf("Cannot merge: %v", err)
+		return m, nil
+	}
+
+	m.typFile = typFile
+	m.diagnostics = diagnostics
+	m.diagIdx = 0
+	m.selectedIdx = 0
+	m.loaded = disk
+	m.stamp = stamp
+	m.diskChanged = false
+	m.modified = true
+	m.revalidate()
+	m.mode = ModeList
+	if parser.HasErrors(diagnostics) {
+		m.mode = ModeError
+	}
+	m.status = "Merged the changes on disk with your edits, press Ctrl+S to save"
+	return m, nil
+}
+
+// renderDiskBanner renders the warning shown while the file on disk differs
+// from the loaded version
+func (m Model) renderDiskBanner() string {
+	if m.diskRemoved {
+		return errorStyle.Render("⚠ " + m.filePath + " was removed from disk, saving creates it again")
+	}
+	return errorStyle.Render("⚠ " + m.filePath + " changed on disk  [r] Reload or merge")
+}
+
+// viewFileChanged renders the choices for a file that changed on disk
+func (m Model) viewFileChanged() string {
+	var b strings.Builder
+
+	b.WriteString(titleStyle.Render("File changed on disk"))
+	b.WriteString("\n\n")
+	fmt.Fprintf(&b, "%s was changed by someone else after it was loaded.\n\n", m.filePath)
+
+	room := m.height - 14
+	if room < 5 {
+		room = 5
+	}
+	switch {
+	case m.changedErr != nil:
+		b.WriteString(errorStyle.Render(m.changedErr.Error()))
+		b.WriteString("\n")
+	case len(m.changedHunks) == 0:
+		b.WriteString(statusStyle.Render("The contents are the same as when loaded"))
+		b.WriteString("\n")
+	default:
+		b.WriteString(statusStyle.Render("Changes made on disk:"))
+		b.WriteString("\n")
+		b.WriteString(renderHunks(m.changedHunks, room))
+	}
+	b.WriteString("\n")
+
+	if m.modified {
+		b.WriteString("  [R] Reload, dropping your edits\n")
+	} else {
+		b.WriteString("  [R] Reload\n")
+	}
+	if !m.typFile.Binary {
+		b.WriteString("  [O] Overwrite with your version\n")
+	}
+	if m.modified {
+		b.WriteString("  [M] Merge your edits into the version on disk\n")
+	}
+	b.WriteString("  [Esc/C] Cancel and return\n")
+
+	if m.changedStatus != "" {
+		b.WriteString("\n")
+		b.WriteString(errorStyle.Render(m.changedStatus))
+	}
+
+	return b.String()
+}
@@ -0,0 +1,246 @@
+package tui
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/dyuri/typtui/internal/parser"
+	"github.com/dyuri/typtui/internal/xpmimage"
+)
+
+// Fields of the import form
+const (
+	importPath = iota
+	importTarget
+	importColors
+	importWidth
+	importHeight
+	importAlpha
+)
+
+// xpmTargets lists the icons of the selected type, day first
+func (m Model) xpmTargets() []string {
+	if m.activeTab == TabPoints {
+		return []string{"DayXpm", "NightXpm"}
+	}
+	return []string{"Xpm", "NightXpm"}
+}
+
+// selectedXPM returns the field of the selected type that holds the day
+// or night icon, or nil if no type is selected
+func (m *Model) selectedXPM(target string) **parser.XPMIcon {
+	night := target == "NightXpm"
+	switch m.activeTab {
+	case TabPoints:
+		if m.selectedIdx < len(m.typFile.Points) {
+			if night {
+				return &m.typFile.Points[m.selectedIdx].NightXpm
+			}
+			return &m.typFile.Points[m.selectedIdx].DayXpm
+		}
+	case TabLines:
+		if m.selectedIdx < len(m.typFile.Lines) {
+			if night {
+				return &m.typFile.Lines[m.selectedIdx].NightXpm
+			}
+			return &m.typFile.Lines[m.selectedIdx].DayXpm
+		}
+	case TabPolygons:
+		if m.selectedIdx < len(m.typFile.Polygons) {
+			if night {
+				return &m.typFile.Polygons[m.selectedIdx].NightXpm
+			}
+			return &m.typFile.Polygons[m.selectedIdx].DayXpm
+		}
+	}
+	return nil
+}
+
+// openImport shows the form importing an image as an icon of the selected
+// type. Lines and polygons default to the 32 pixel wide two colour
+// patterns mkgmap expects.
+func (m *Model) openImport() {
+	if m.typFile.Binary {
+		m.status = m.filePath + " is a compiled TYP file and is opened read-only"
+		return
+	}
+	if m.selectedXPM("") == nil {
+		return
+	}
+
+	colors, width, height := "16", "", ""
+	switch m.activeTab {
+	case TabLines:
+		colors, width = "2", "32"
+	case TabPolygons:
+		colors, width, height = "2", "32", "32"
+	}
+
+	fields := []struct {
+		prompt, placeholder, value string
+		limit                      int
+	}{
+		importPath:   {"Image: ", "path to a PNG, GIF or JPEG file", "", 256},
+		importTarget: {"Icon: ", strings.Join(m.xpmTargets(), " or "), m.xpmTargets()[0], 10},
+		importColors: {"Colours: ", fmt.Sprintf("1 to %d, not counting none", xpmimage.MaxColors), colors, 3},
+		importWidth:  {"Width: ", "empty to keep the image size", width, 4},
+		importHeight: {"Height: ", "empty to keep the aspect ratio", height, 4},
+		importAlpha:  {"Alpha threshold: ", "0 to 255, less opaque pixels become none", "128", 3},
+	}
+
+	inputs := make([]textinput.Model, len(fields))
+	for i, f := range fields {
+		inputs[i] = textinput.New()
+		inputs[i].Prompt = f.prompt
+		inputs[i].Placeholder = f.placeholder
+		inputs[i].CharLimit = f.limit
+		inputs[i].Width = 50
+		inputs[i].SetValue(f.value)
+	}
+	inputs[importPath].Focus()
+
+	m.inputs = inputs
+	m.focusedField = importPath
+	m.formErr = ""
+	m.mode = ModeImportXPM
+}
+
+// handleImportKeyPress handles keyboard input in the import form
+func (m Model) handleImportKeyPress(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	var cmd tea.Cmd
+
+	switch msg.String() {
+	case "enter":
+		m.importImage()
+		return m, nil
+
+	case "esc":
+		m.mode = ModeDetail
+		m.inputs = nil
+		return m, nil
+
+	case "tab", "down":
+		m.moveFocus(1)
+		return m, nil
+
+	case "shift+tab", "up":
+		m.moveFocus(-1)
+		return m, nil
+	}
+
+	m.inputs[m.focusedField], cmd = m.inputs[m.focusedField].Update(msg)
+	return m, cmd
+}
+
+// moveFocus focuses the next or previous field of a form, wrapping around
+func (m *Model) moveFocus(delta int) {
+	m.focusedField = (m.focusedField + delta + len(m.inputs)) % len(m.inputs)
+	for i := range m.inputs {
+		if i == m.focusedField {
+			m.inputs[i].Focus()
+		} else {
+			m.inputs[i].Blur()
+		}
+	}
+}
+
+// expandHome expands a leading ~/ of a path typed in a form to the home
+// directory
+func expandHome(path string) string {
+	if rest, ok := strings.CutPrefix(path, "~/"); ok {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, rest)
+		}
+	}
+	return path
+}
+
+// importImage imports the image from the form into the selected type and
+// returns to the detail view, or explains what is wrong with the form
+func (m *Model) importImage() {
+	value := func(i int) string { return strings.TrimSpace(m.inputs[i].Value()) }
+	number := func(i int, name string) (int, bool) {
+		if value(i) == "" {
+			return 0, true
+		}
+		n, err := strconv.Atoi(value(i))
+		if err != nil || n < 0 {
+			m.formErr = fmt.Sprintf("%s must be a number", name)
+			return 0, false
+		}
+		return n, true
+	}
+
+	path := value(importPath)
+	if path == "" {
+		m.formErr = "Enter the path of the image"
+		return
+	}
+	path = expandHome(path)
+
+	target := ""
+	for _, t := range m.xpmTargets() {
+		if strings.EqualFold(value(importTarget), t) {
+			target = t
+		}
+	}
+	if target == "" {
+		m.formErr = "Icon must be " + strings.Join(m.xpmTargets(), " or ")
+		return
+	}
+
+	var opts xpmimage.Options
+	var ok bool
+	if opts.Colors, ok = number(importColors, "Colours"); !ok {
+		return
+	}
+	if opts.Width, ok = number(importWidth, "Width"); !ok {
+		return
+	}
+	if opts.Height, ok = number(importHeight, "Height"); !ok {
+		return
+	}
+	if opts.Alpha, ok = number(importAlpha, "Alpha threshold"); !ok {
+		return
+	}
+
+	icon, err := xpmimage.ImportFile(path, opts)
+	if err != nil {
+		m.formErr = err.Error()
+		return
+	}
+	*m.selectedXPM(target) = icon
+	m.modified = true
+	m.revalidate()
+	m.inputs = nil
+	m.mode = ModeDetail
+	m.status = fmt.Sprintf("Imported %s as %s, %dx%d with %d colours", filepath.Base(path), target, icon.Width, icon.Height, icon.Colors)
+}
+
+// viewImport renders the image import form
+func (m Model) viewImport() string {
+	var b strings.Builder
+
+	b.WriteString(m.renderHeader())
+	b.WriteString("\n\n")
+	b.WriteString(titleStyle.Render("Import an image as an icon"))
+	b.WriteString("\n\n")
+
+	for _, input := range m.inputs {
+		b.WriteString(input.View())
+		b.WriteString("\n\n")
+	}
+
+	if m.formErr != "" {
+		b.WriteString(errorStyle.Render(m.formErr))
+		b.WriteString("\n\n")
+	}
+	b.WriteString(helpStyle.Render("[Enter] Import  [Esc] Cancel  [Tab/↑/↓] Navigate fields"))
+
+	return b.String()
+}
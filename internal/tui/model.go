@@ -1,8 +1,11 @@
 package tui
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
-	"sort"
+	"io/fs"
 	"strconv"
 	"strings"
 
@@ -10,7 +13,19 @@ import (
 	"github.com/charmbracelet/bubbles/textinput"
 	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/dyuri/typtui/internal/autosave"
+	"github.com/dyuri/typtui/internal/backup"
+	"github.com/dyuri/typtui/internal/compiler"
+	"github.com/dyuri/typtui/internal/config"
+	"github.com/dyuri/typtui/internal/cvd"
+	"github.com/dyuri/typtui/internal/filestamp"
+	"github.com/dyuri/typtui/internal/mkstyle"
+	"github.com/dyuri/typtui/internal/night"
 	"github.com/dyuri/typtui/internal/parser"
+	"github.com/dyuri/typtui/internal/textdiff"
+	"github.com/dyuri/typtui/internal/typedb"
+	"github.com/dyuri/typtui/internal/typmerge"
+	"github.com/dyuri/typtui/internal/validate"
 )
 
 // Mode represents the current UI mode
@@ -24,6 +39,16 @@ const (
 	ModeHelp
 	ModeError
 	ModeConfirmQuit
+	ModeRestoreBackup
+	ModeRecover
+	ModeFileChanged
+	ModeValidate
+	ModeConflicts
+	ModeImportXPM
+	ModeExportPNG
+	ModeStyleCheck
+	ModeCompile
+	ModeNight
 )
 
 // Tab represents the active tab
@@ -33,8 +58,12 @@ const (
 	TabPoints Tab = iota
 	TabLines
 	TabPolygons
+	TabDrawOrder
 )
 
+// tabCount is the number of tabs
+const tabCount = 4
+
 // Model is the main application model
 type Model struct {
 	// Core data
@@ -67,53 +96,203 @@ type Model struct {
 	err    error
 	status string
 
+	// Problems found while loading the file, listed in ModeError
+	diagnostics []parser.Diagnostic
+	diagIdx     int
+
 	// File path (if loaded from command line)
 	filePath string
+
+	// User settings
+	config config.Config
+
+	// Restore backup screen
+	backups        []backup.Backup
+	backupIdx      int
+	backupPreview  []textdiff.Hunk
+	backupErr      error
+	restoreConfirm bool
+
+	// Crash recovery: the autosave journal of the file, what was last
+	// written to it, and a journal found at startup
+	journal         autosave.Journal
+	lastAutosave    []byte
+	recovery        *recovery
+	recoverReturn   Mode // mode to go back to when the journal is discarded
+	recoveryChecked bool
+
+	// The version of the file that was loaded, the base for noticing and
+	// merging changes made on disk, and what the watcher last saw
+	loaded      []byte
+	stamp       filestamp.Stamp
+	diskChanged bool
+	diskRemoved bool
+
+	// File changed on disk screen
+	changedHunks  []textdiff.Hunk
+	changedErr    error
+	changedStatus string
+	changedReturn Mode
+	quitAfterSave bool
+
+	// Validation findings, kept up to date with the edits
+	findings         []validate.Finding
+	findingIdx       int
+	findingsAccepted bool // the user chose to save despite the errors
+	saveBlocked      bool // the list was opened by a refused save
+	validateReturn   Mode
+
+	// Merge whose conflicts are being resolved, nil once done
+	merge       *typmerge.Result
+	conflictIdx int
+
+	// Problem with the image import or export form
+	formErr string
+
+	// mkgmap style the file is cross-checked with, and the last report
+	stylePath   string
+	styleReport *mkstyle.Report
+	styleRules  int
+	styleIdx    int
+
+	// mkgmap compilation, its output as it comes and its outcome
+	compileLog      []compiler.Message
+	compileIdx      int
+	compileCh       chan tea.Msg
+	compileCancel   context.CancelFunc
+	compileClosed   chan struct{} // closed by Close, once nobody reads the output
+	compileFinished chan struct{} // closed once mkgmap and its files are gone
+	compileRunning  bool
+	compileOutput   string
+	compileErr      error
+
+	// Preview of the night generator: what changes in the selected type,
+	// with its day icon and the night icon it gets
+	nightChange night.Change
+	nightDay    *parser.XPMIcon
+	nightIcon   *parser.XPMIcon
+
+	// Colour vision deficiency the icon previews simulate, "" for none
+	simulation cvd.Deficiency
+
+	// Catalogue types matching the Type field of the edit form
+	typeSuggestions []typedb.TypeInfo
+	suggestionIdx   int
 }
 
 // NewModel creates a new TUI model
-func NewModel(filePath string) Model {
-	return Model{
+func NewModel(filePath string, cfg config.Config) Model {
+	m := Model{
 		mode:        ModeList,
 		activeTab:   TabPoints,
 		selectedIdx: 0,
 		filePath:    filePath,
+		config:      cfg,
+		stylePath:   cfg.Style,
 	}
+	if filePath != "" {
+		// Without a state directory the file is edited without autosave
+		m.journal, _ = autosave.For(filePath)
+	}
+	return m
 }
 
 // Init initializes the model
 func (m Model) Init() tea.Cmd {
 	// If a file path was provided, load it
 	if m.filePath != "" {
-		return loadFileCmd(m.filePath)
+		return tea.Batch(loadFileCmd(m.filePath), m.autosaveTickCmd(), m.watchTickCmd())
 	}
 	return nil
 }
 
 // fileLoadedMsg is sent when a file is loaded
 type fileLoadedMsg struct {
-	typFile *parser.TYPFile
-	err     error
+	typFile     *parser.TYPFile
+	diagnostics []parser.Diagnostic
+	data        []byte
+	stamp       filestamp.Stamp
+	err         error
 }
 
-// loadFileCmd loads a TYP file
+// loadFileCmd loads a TYP file. Parsing is tolerant so that every problem
+// in the file can be listed at once. The contents are kept to notice
+// changes made on disk later.
 func loadFileCmd(filePath string) tea.Cmd {
 	return func() tea.Msg {
-		typFile, err := parser.ParseFile(filePath)
-		return fileLoadedMsg{typFile: typFile, err: err}
+		data, stamp, err := filestamp.Read(filePath)
+		if err != nil {
+			return fileLoadedMsg{err: fmt.Errorf("failed to open file: %w", err)}
+		}
+		typFile, diagnostics, err := parser.ParseReaderTolerant(bytes.NewReader(data), filePath)
+		return fileLoadedMsg{typFile: typFile, diagnostics: diagnostics, data: data, stamp: stamp, err: err}
+	}
+}
+
+// drawOrderRow is a polygon type in the draw order tab. Level 0 holds the
+// polygons that are not in the draw order.
+type drawOrderRow struct {
+	typ   string
+	level int
+}
+
+// drawOrderRows lists the draw order by level, followed by the polygon
+// types that have no level yet
+func (m Model) drawOrderRows() []drawOrderRow {
+	var rows []drawOrderRow
+	for level, entries := range m.typFile.DrawOrder.Levels() {
+		for _, entry := range entries {
+			rows = append(rows, drawOrderRow{typ: entry.Type, level: level + 1})
+		}
+	}
+	for _, polygon := range m.typFile.Polygons {
+		if m.typFile.DrawOrder.Level(polygon.Type) == 0 {
+			rows = append(rows, drawOrderRow{typ: polygon.Type})
+		}
+	}
+	return rows
+}
+
+// moveDrawOrder moves the selected polygon type by delta levels and keeps
+// it selected
+func (m *Model) moveDrawOrder(delta int) {
+	rows := m.drawOrderRows()
+	if m.selectedIdx >= len(rows) {
+		return
+	}
+	row := rows[m.selectedIdx]
+	level := row.level + delta
+	if level < 0 {
+		return
+	}
+
+	m.typFile.DrawOrder.SetLevel(row.typ, level)
+	m.modified = true
+	m.revalidate()
+
+	for i, r := range m.drawOrderRows() {
+		if r.typ == row.typ {
+			m.selectedIdx = i
+			break
+		}
+	}
+	if level == 0 {
+		m.status = row.typ + " removed from the draw order"
+	} else {
+		m.status = fmt.Sprintf("%s moved to level %d", row.typ, level)
 	}
 }
 
 // initPointEditInputs initializes text inputs for editing a point type
 func (m *Model) initPointEditInputs(point parser.PointType) {
-	inputs := make([]textinput.Model, 6)
+	inputs := make([]textinput.Model, 10)
 
 	// Type field
 	inputs[0] = textinput.New()
-	inputs[0].Placeholder = "e.g., 0x2f06"
+	inputs[0].Placeholder = "e.g., 0x2f06, a name or an OSM tag"
 	inputs[0].Focus()
-	inputs[0].CharLimit = 10
-	inputs[0].Width = 30
+	inputs[0].CharLimit = 40
+	inputs[0].Width = 40
 	inputs[0].SetValue(point.Type)
 	inputs[0].Prompt = "Type: "
 
@@ -146,7 +325,7 @@ func (m *Model) initPointEditInputs(point parser.PointType) {
 	// Day Color field
 	inputs[4] = textinput.New()
 	inputs[4].Placeholder = "#RRGGBB (e.g., #FF0000)"
-	inputs[4].CharLimit = 7
+	inputs[4].CharLimit = 20
 	inputs[4].Width = 30
 	if len(point.DayColors) > 0 {
 		inputs[4].SetValue(point.DayColors[0].Hex)
@@ -156,27 +335,64 @@ func (m *Model) initPointEditInputs(point parser.PointType) {
 	// Night Color field
 	inputs[5] = textinput.New()
 	inputs[5].Placeholder = "#RRGGBB (e.g., #0000FF)"
-	inputs[5].CharLimit = 7
+	inputs[5].CharLimit = 20
 	inputs[5].Width = 30
 	if len(point.NightColors) > 0 {
 		inputs[5].SetValue(point.NightColors[0].Hex)
 	}
 	inputs[5].Prompt = "Night Color: "
 
+	// ExtendedLabels field
+	inputs[6] = textinput.New()
+	inputs[6].Placeholder = "Y or N"
+	inputs[6].CharLimit = 1
+	inputs[6].Width = 10
+	if point.ExtendedLabels {
+		inputs[6].SetValue("Y")
+	} else {
+		inputs[6].SetValue("N")
+	}
+	inputs[6].Prompt = "ExtendedLabels: "
+
+	// Day font color field
+	inputs[7] = textinput.New()
+	inputs[7].Placeholder = "#RRGGBB (e.g., #000000, empty for none)"
+	inputs[7].CharLimit = 20
+	inputs[7].Width = 30
+	inputs[7].SetValue(point.DayFontColor.Hex)
+	inputs[7].Prompt = "Day Font Color: "
+
+	// Night font color field
+	inputs[8] = textinput.New()
+	inputs[8].Placeholder = "#RRGGBB (e.g., #FFFFFF, empty for none)"
+	inputs[8].CharLimit = 20
+	inputs[8].Width = 30
+	inputs[8].SetValue(point.NightFontColor.Hex)
+	inputs[8].Prompt = "Night Font Color: "
+
+	// Custom color field
+	inputs[9] = textinput.New()
+	inputs[9].Placeholder = "#RRGGBB (empty for none)"
+	inputs[9].CharLimit = 20
+	inputs[9].Width = 30
+	inputs[9].SetValue(point.CustomColor.Hex)
+	inputs[9].Prompt = "Custom Color: "
+
 	m.inputs = inputs
 	m.focusedField = 0
+	m.formErr = ""
 }
 
 // initLineEditInputs initializes text inputs for editing a line type
 func (m *Model) initLineEditInputs(line parser.LineType) {
-	inputs := make([]textinput.Model, 6)
+	inputs := make([]textinput.Model, 15)
 
 	// Type field
 	inputs[0] = textinput.New()
-	inputs[0].Placeholder = "e.g., 0x01"
+	inputs[0].Placeholder = "e.g., 0x01, a name or an OSM tag"
 	inputs[0].Focus()
-	inputs[0].CharLimit = 10
-	inputs[0].Width = 30
+	inputs[0].CharLimit = 40
+	inputs[0].Width = 40
 	inputs[0].SetValue(line.Type)
 	inputs[0].Prompt = "Type: "
 
@@ -230,20 +446,101 @@ func (m *Model) initLineEditInputs(line parser.LineType) {
 	}
 	inputs[5].Prompt = "UseOrientation: "
 
+	// FontStyle field
+	inputs[6] = textinput.New()
+	inputs[6].Placeholder = "NoLabel, SmallFont, NormalFont, LargeFont"
+	inputs[6].CharLimit = 20
+	inputs[6].Width = 40
+	inputs[6].SetValue(line.FontStyle)
+	inputs[6].Prompt = "FontStyle: "
+
+	// ExtendedLabels field
+	inputs[7] = textinput.New()
+	inputs[7].Placeholder = "Y or N"
+	inputs[7].CharLimit = 1
+	inputs[7].Width = 10
+	if line.ExtendedLabels {
+		inputs[7].SetValue("Y")
+	} else {
+		inputs[7].SetValue("N")
+	}
+	inputs[7].Prompt = "ExtendedLabels: "
+
+	// NightLineWidth field
+	inputs[8] = textinput.New()
+	inputs[8].Placeholder = "e.g., 5 (0 for the day width)"
+	inputs[8].CharLimit = 5
+	inputs[8].Width = 20
+	if line.NightLineWidth > 0 {
+		inputs[8].SetValue(fmt.Sprintf("%d", line.NightLineWidth))
+	}
+	inputs[8].Prompt = "NightLineWidth: "
+
+	// NightBorderWidth field
+	inputs[9] = textinput.New()
+	inputs[9].Placeholder = "e.g., 1 (0 for the day width)"
+	inputs[9].CharLimit = 5
+	inputs[9].Width = 20
+	if line.NightBorderWidth > 0 {
+		inputs[9].SetValue(fmt.Sprintf("%d", line.NightBorderWidth))
+	}
+	inputs[9].Prompt = "NightBorderWidth: "
+
+	// Day font color field
+	inputs[10] = textinput.New()
+	inputs[10].Placeholder = "#RRGGBB (e.g., #000000, empty for none)"
+	inputs[10].CharLimit = 20
+	inputs[10].Width = 30
+	inputs[10].SetValue(line.DayFontColor.Hex)
+	inputs[10].Prompt = "Day Font Color: "
+
+	// Night font color field
+	inputs[11] = textinput.New()
+	inputs[11].Placeholder = "#RRGGBB (e.g., #FFFFFF, empty for none)"
+	inputs[11].CharLimit = 20
+	inputs[11].Width = 30
+	inputs[11].SetValue(line.NightFontColor.Hex)
+	inputs[11].Prompt = "Night Font Color: "
+
+	// Day custom colors field
+	inputs[12] = textinput.New()
+	inputs[12].Placeholder = "#RRGGBB, comma separated (e.g., #FF0000, #000000)"
+	inputs[12].CharLimit = 100
+	inputs[12].Width = 50
+	inputs[12].SetValue(colorList(line.DayColors))
+	inputs[12].Prompt = "Day Custom Colors: "
+
+	// Night custom colors field
+	inputs[13] = textinput.New()
+	inputs[13].Placeholder = "#RRGGBB, comma separated (empty for the day colors)"
+	inputs[13].CharLimit = 100
+	inputs[13].Width = 50
+	inputs[13].SetValue(colorList(line.NightColors))
+	inputs[13].Prompt = "Night Custom Colors: "
+
+	// Custom color field
+	inputs[14] = textinput.New()
+	inputs[14].Placeholder = "#RRGGBB (empty for none)"
+	inputs[14].CharLimit = 20
+	inputs[14].Width = 30
+	inputs[14].SetValue(line.CustomColor.Hex)
+	inputs[14].Prompt = "Custom Color: "
+
 	m.inputs = inputs
 	m.focusedField = 0
+	m.formErr = ""
 }
 
 // initPolygonEditInputs initializes text inputs for editing a polygon type
 func (m *Model) initPolygonEditInputs(polygon parser.PolygonType) {
-	inputs := make([]textinput.Model, 4)
+	inputs := make([]textinput.Model, 10)
 
 	// Type field
 	inputs[0] = textinput.New()
-	inputs[0].Placeholder = "e.g., 0x13"
+	inputs[0].Placeholder = "e.g., 0x13, a name or an OSM tag"
 	inputs[0].Focus()
-	inputs[0].CharLimit = 10
-	inputs[0].Width = 30
+	inputs[0].CharLimit = 40
+	inputs[0].Width = 40
 	inputs[0].SetValue(polygon.Type)
 	inputs[0].Prompt = "Type: "
 
@@ -277,8 +574,66 @@ func (m *Model) initPolygonEditInputs(polygon parser.PolygonType) {
 	inputs[3].SetValue(polygon.FontStyle)
 	inputs[3].Prompt = "FontStyle: "
 
+	// Day font color field
+	inputs[4] = textinput.New()
+	inputs[4].Placeholder = "#RRGGBB (e.g., #000000, empty for none)"
+	inputs[4].CharLimit = 20
+	inputs[4].Width = 30
+	inputs[4].SetValue(polygon.DayFontColor.Hex)
+	inputs[4].Prompt = "Day Font Color: "
+
+	// Night font color field
+	inputs[5] = textinput.New()
+	inputs[5].Placeholder = "#RRGGBB (e.g., #FFFFFF, empty for none)"
+	inputs[5].CharLimit = 20
+	inputs[5].Width = 30
+	inputs[5].SetValue(polygon.NightFontColor.Hex)
+	inputs[5].Prompt = "Night Font Color: "
+
+	// Contour color field
+	inputs[6] = textinput.New()
+	inputs[6].Placeholder = "#RRGGBB (e.g., #404040, empty for none)"
+	inputs[6].CharLimit = 20
+	inputs[6].Width = 30
+	inputs[6].SetValue(polygon.ContourColor.Hex)
+	inputs[6].Prompt = "Contour Color: "
+
+	// Day custom colors field
+	inputs[7] = textinput.New()
+	inputs[7].Placeholder = "#RRGGBB, comma separated (e.g., #90EE90)"
+	inputs[7].CharLimit = 100
+	inputs[7].Width = 50
+	inputs[7].SetValue(colorList(polygon.DayColors))
+	inputs[7].Prompt = "Day Custom Colors: "
+
+	// Night custom colors field
+	inputs[8] = textinput.New()
+	inputs[8].Placeholder = "#RRGGBB, comma separated (empty for the day colors)"
+	inputs[8].CharLimit = 100
+	inputs[8].Width = 50
+	inputs[8].SetValue(colorList(polygon.NightColors))
+	inputs[8].Prompt = "Night Custom Colors: "
+
+	// Custom color field
+	inputs[9] = textinput.New()
+	inputs[9].Placeholder = "#RRGGBB (empty for none)"
+	inputs[9].CharLimit = 20
+	inputs[9].Width = 30
+	inputs[9].SetValue(polygon.CustomColor.Hex)
+	inputs[9].Prompt = "Custom Color: "
+
 	m.inputs = inputs
 	m.focusedField = 0
+	m.formErr = ""
+}
+
+// colorList renders colors for a comma separated color field
+func colorList(colors []parser.Color) string {
+	hexes := make([]string, len(colors))
+	for i, c := range colors {
+		hexes[i] = c.Hex
+	}
+	return strings.Join(hexes, ", ")
 }
 
 // initXPMViewport initializes the viewport for XPM editing
@@ -312,52 +667,39 @@ func (m *Model) updateXPMViewportContent() {
 	// Color Palette
 	content.WriteString("Color Palette\n\n")
 
-	// Convert map to sorted slice for consistent ordering
-	type colorEntry struct {
-		char  string
-		color parser.Color
-	}
-	var colors []colorEntry
-	for char, color := range m.editingXPM.Palette {
-		colors = append(colors, colorEntry{char, color})
-	}
-
-	// Sort alphabetically by character
-	sort.Slice(colors, func(i, j int) bool {
-		return colors[i].char < colors[j].char
-	})
-
-	for i, entry := range colors {
+	// Palette entries in file order
+	for i, entry := range m.editingXPM.Palette {
 		prefix := "  "
 		if i == m.xpmColorIdx {
 			prefix = "▸ "
 		}
 
 		// Render color with preview
-		colorDisplay := m.renderColorPreview(entry.color.Hex)
-		content.WriteString(fmt.Sprintf("%s%s → %s\n", prefix, entry.char, colorDisplay))
+		colorDisplay := m.renderColorPreview(entry.Color.Hex)
+		if seen := m.seenColor(entry.Color.Hex); seen != entry.Color.Hex {
+			colorDisplay += " seen as " + m.renderColorPreview(seen)
+		}
+		content.WriteString(fmt.Sprintf("%s%q → %s%s\n", prefix, entry.Key, colorDisplay, colorName(entry.Color)))
 	}
 
 	content.WriteString("\n")
 
 	// Icon Preview
-	content.WriteString("Icon Preview\n")
+	content.WriteString("Icon Preview" + m.simulationNote() + "\n")
 
 	// Render all rows of the XPM with colors
 	for i := 0; i < len(m.editingXPM.Data); i++ {
 		content.WriteString("  ")
 		row := m.editingXPM.Data[i]
 
-		// Process each character/pixel with colors
-		for _, char := range row {
-			charStr := string(char)
-
-			// Look up the color for this character
-			if color, ok := m.editingXPM.Palette[charStr]; ok {
-				content.WriteString(m.renderPixelColored(color.Hex, charStr))
+		// Process each pixel with colors, a pixel is CharsPerPixel characters
+		for _, pixel := range m.editingXPM.Pixels(row) {
+			// Look up the color for this pixel
+			if color, ok := m.editingXPM.Palette.Lookup(pixel); ok {
+				content.WriteString(m.renderPixelColored(m.seenColor(color.Hex), pixel))
 			} else {
-				// Unknown character, show as gray
-				content.WriteString(m.renderPixelColored("#808080", charStr))
+				// Unknown pixel, show as gray
+				content.WriteString(m.renderPixelColored("#808080", pixel))
 			}
 		}
 
@@ -446,11 +788,50 @@ func (m *Model) saveFile() error {
 		return fmt.Errorf("no file path")
 	}
 
+	// The decoder skips the header sections past offset 0x5b that longer
+	// headers carry, so writing a compiled file back could silently lose
+	// data
+	if m.typFile.Binary {
+		return fmt.Errorf("%s is a compiled TYP file and is opened read-only", m.filePath)
+	}
+
+	if err := m.checkFindings(); err != nil {
+		return err
+	}
+
+	// Someone else's changes are not overwritten without asking
+	if !m.stamp.IsZero() {
+		changed, err := m.stamp.Changed(m.filePath)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		if changed {
+			return errChangedOnDisk
+		}
+	}
+
+	return m.writeFile()
+}
+
+// writeFile writes the TYPFile over the file on disk, whatever is there now
+func (m *Model) writeFile() error {
+	// Keep the version on disk before replacing it
+	if _, err := backup.Create(m.filePath, m.config.Backups); err != nil {
+		return err
+	}
 	if err := parser.WriteFile(m.typFile, m.filePath); err != nil {
 		return err
 	}
 
+	// What was written is the new base for noticing changes
+	if data, stamp, err := filestamp.Read(m.filePath); err == nil {
+		m.loaded, m.stamp = data, stamp
+	}
+	m.diskChanged = false
+	m.diskRemoved = false
+
 	m.modified = false
+	m.discardJournal()
 	m.status = "File saved successfully"
 	return nil
 }
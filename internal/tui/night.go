@@ -0,0 +1,223 @@
+package tui
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/dyuri/typtui/internal/night"
+	"github.com/dyuri/typtui/internal/parser"
+)
+
+// Fields of the night generator form
+const (
+	nightStrategy = iota
+	nightAmount
+	nightPalette
+	nightReplace
+)
+
+// openNight shows the form generating the night versions of the selected
+// type, with the settings of the config file
+func (m *Model) openNight() {
+	if m.typFile.Binary {
+		m.status = m.filePath + " is a compiled TYP file and is opened read-only"
+		return
+	}
+	if m.selectedXPM("") == nil {
+		return
+	}
+
+	cfg := m.config.Night
+	fields := []struct {
+		prompt, placeholder, value string
+		limit                      int
+	}{
+		nightStrategy: {"Strategy: ", "lab, hsl, invert or palette", cfg.Strategy, 10},
+		nightAmount:   {"Amount: ", "how much darker, 0 to 1", strconv.FormatFloat(cfg.Amount, 'f', -1, 64), 5},
+		nightPalette:  {"Palette: ", "#RRGGBB colours of the palette strategy, empty for the built-in one", strings.Join(cfg.Palette, ","), 512},
+		nightReplace:  {"Replace: ", "yes to regenerate night versions the type has", "no", 3},
+	}
+
+	inputs := make([]textinput.Model, len(fields))
+	for i, f := range fields {
+		inputs[i] = textinput.New()
+		inputs[i].Prompt = f.prompt
+		inputs[i].Placeholder = f.placeholder
+		inputs[i].CharLimit = f.limit
+		inputs[i].Width = 50
+		inputs[i].SetValue(f.value)
+	}
+	inputs[nightStrategy].Focus()
+
+	m.inputs = inputs
+	m.focusedField = nightStrategy
+	m.formErr = ""
+	m.mode = ModeNight
+	m.updateNightPreview()
+}
+
+// nightGenerator returns a generator with the settings of the form
+func (m Model) nightGenerator() (*night.Generator, error) {
+	value := func(i int) string { return strings.TrimSpace(m.inputs[i].Value()) }
+
+	var opts night.Options
+	var err error
+	if opts.Strategy, err = night.ParseStrategy(value(nightStrategy)); err != nil {
+		return nil, err
+	}
+	if opts.Amount, err = strconv.ParseFloat(value(nightAmount), 64); err != nil {
+		return nil, fmt.Errorf("amount must be a number from 0 to 1")
+	}
+	for _, hex := range strings.Split(value(nightPalette), ",") {
+		if hex = strings.TrimSpace(hex); hex != "" {
+			opts.Palette = append(opts.Palette, hex)
+		}
+	}
+	switch strings.ToLower(value(nightReplace)) {
+	case "y", "yes":
+		opts.Replace = true
+	case "", "n", "no":
+	default:
+		return nil, fmt.Errorf("replace must be yes or no")
+	}
+	return night.New(opts)
+}
+
+// generateNight runs a generator on the selected type, or on a copy of it
+// for a preview, and returns what changed with the day and night icons
+func (m *Model) generateNight(g *night.Generator, apply bool) (change night.Change, day, nightIcon *parser.XPMIcon) {
+	switch m.activeTab {
+	case TabPoints:
+		point := m.typFile.Points[m.selectedIdx]
+		target := &point
+		if apply {
+			target = &m.typFile.Points[m.selectedIdx]
+		}
+		change, day, nightIcon = g.Point(target), target.DayXpm, target.NightXpm
+	case TabLines:
+		line := m.typFile.Lines[m.selectedIdx]
+		target := &line
+		if apply {
+			target = &m.typFile.Lines[m.selectedIdx]
+		}
+		change, day, nightIcon = g.Line(target), target.DayXpm, target.NightXpm
+	case TabPolygons:
+		polygon := m.typFile.Polygons[m.selectedIdx]
+		target := &polygon
+		if apply {
+			target = &m.typFile.Polygons[m.selectedIdx]
+		}
+		change, day, nightIcon = g.Polygon(target), target.DayXpm, target.NightXpm
+	}
+	change.Index = m.selectedIdx
+	return change, day, nightIcon
+}
+
+// updateNightPreview generates the night versions of the selected type
+// with the settings of the form, without changing the type
+func (m *Model) updateNightPreview() {
+	m.nightChange, m.nightDay, m.nightIcon = night.Change{}, nil, nil
+	g, err := m.nightGenerator()
+	if err != nil {
+		m.formErr = err.Error()
+		return
+	}
+	m.formErr = ""
+	m.nightChange, m.nightDay, m.nightIcon = m.generateNight(g, false)
+}
+
+// applyNight stores the night versions in the selected type and returns
+// to the detail view
+func (m *Model) applyNight() {
+	g, err := m.nightGenerator()
+	if err != nil {
+		m.formErr = err.Error()
+		return
+	}
+	change, _, _ := m.generateNight(g, true)
+	m.inputs = nil
+	m.mode = ModeDetail
+	if change.Empty() {
+		m.status = "The type has its night versions, nothing changed"
+		return
+	}
+	m.modified = true
+	m.revalidate()
+	m.status = "Generated " + strings.Join(change.Fields, ", ")
+}
+
+// handleNightKeyPress handles keyboard input in the night generator form,
+// updating the preview as settings change
+func (m Model) handleNightKeyPress(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	var cmd tea.Cmd
+
+	switch msg.String() {
+	case "enter":
+		m.applyNight()
+		return m, nil
+
+	case "esc":
+		m.mode = ModeDetail
+		m.inputs = nil
+		return m, nil
+
+	case "tab", "down":
+		m.moveFocus(1)
+		return m, nil
+
+	case "shift+tab", "up":
+		m.moveFocus(-1)
+		return m, nil
+	}
+
+	m.inputs[m.focusedField], cmd = m.inputs[m.focusedField].Update(msg)
+	m.updateNightPreview()
+	return m, cmd
+}
+
+// viewNight renders the night generator form with the day and night
+// icons side by side and the colours that change
+func (m Model) viewNight() string {
+	var b strings.Builder
+
+	b.WriteString(m.renderHeader())
+	b.WriteString("\n\n")
+	b.WriteString(titleStyle.Render("Generate night colours"))
+	b.WriteString("\n\n")
+
+	for _, input := range m.inputs {
+		b.WriteString(input.View())
+		b.WriteString("\n\n")
+	}
+
+	switch {
+	case m.formErr != "":
+		b.WriteString(errorStyle.Render(m.formErr))
+		b.WriteString("\n\n")
+	case m.nightChange.Empty():
+		b.WriteString(statusStyle.Render("The type has its night versions, set Replace to yes to regenerate them"))
+		b.WriteString("\n\n")
+	default:
+		b.WriteString(selectedStyle.Render("Generates: " + strings.Join(m.nightChange.Fields, ", ")))
+		b.WriteString("\n")
+		for _, c := range m.nightChange.Colors {
+			b.WriteString(fmt.Sprintf("  %s → %s\n", renderColorWithPreview(c.Day), renderColorWithPreview(c.Night)))
+		}
+		b.WriteString("\n")
+	}
+
+	if m.nightDay != nil && len(m.nightDay.Data) > 0 && m.nightIcon != nil {
+		day := "  Day\n" + strings.TrimRight(renderXPMPreview(m.nightDay), "\n")
+		nightIcon := "  Night\n" + strings.TrimRight(renderXPMPreview(m.nightIcon), "\n")
+		b.WriteString(lipgloss.JoinHorizontal(lipgloss.Top, day, "  ", nightIcon))
+		b.WriteString("\n\n")
+	}
+
+	b.WriteString(helpStyle.Render("[Enter] Apply  [Esc] Cancel  [Tab/↑/↓] Navigate fields"))
+
+	return b.String()
+}
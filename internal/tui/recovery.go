@@ -0,0 +1,187 @@
+package tui
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/dyuri/typtui/internal/parser"
+	"github.com/dyuri/typtui/internal/textdiff"
+)
+
+// autosaveTickMsg asks for the next autosave
+type autosaveTickMsg struct{}
+
+// autosavedMsg reports a finished autosave
+type autosavedMsg struct {
+	data []byte
+	err  error
+}
+
+// recovery is an autosave journal found when the file was opened
+type recovery struct {
+	data     []byte
+	saved    time.Time
+	diskTime time.Time
+	hunks    []textdiff.Hunk
+}
+
+// autosaveTickCmd waits for the next autosave, if autosave is on
+func (m Model) autosaveTickCmd() tea.Cmd {
+	if m.journal.Path == "" || m.config.AutosaveSeconds <= 0 {
+		return nil
+	}
+	return tea.Tick(time.Duration(m.config.AutosaveSeconds)*time.Second, func(time.Time) tea.Msg {
+		return autosaveTickMsg{}
+	})
+}
+
+// unsaved reports whether there are edits that are not on disk, including
+// those still in an edit form
+func (m Model) unsaved() bool {
+	if m.typFile == nil || m.typFile.Binary {
+		return false
+	}
+	return m.modified || m.mode == ModeEdit || m.mode == ModeEditXPM
+}
+
+// autosave writes the edits in memory to the journal. The file is rendered
+// here, so the copy is consistent; only the write runs in the background.
+func (m *Model) autosave() tea.Cmd {
+	if !m.unsaved() || m.recovery != nil {
+		return nil
+	}
+
+	// Include the values of an open edit form without applying them
+	snapshot := m.typFile
+	if m.mode == ModeEdit && m.inputs != nil {
+		draft := *m
+		draft.typFile = m.typFile.Clone()
+		// A form with a bad color is left out until it is fixed
+		if draft.saveEdits() == nil {
+			snapshot = draft.typFile
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := parser.Write(&buf, snapshot); err != nil {
+		return func() tea.Msg { return autosavedMsg{err: err} }
+	}
+	data := buf.Bytes()
+	if bytes.Equal(data, m.lastAutosave) {
+		return nil
+	}
+
+	journal := m.journal
+	return func() tea.Msg {
+		return autosavedMsg{data: data, err: journal.Write(data)}
+	}
+}
+
+// discardJournal removes the autosave journal once the edits in it are
+// saved or thrown away
+func (m *Model) discardJournal() {
+	if m.journal.Path != "" {
+		m.journal.Remove()
+	}
+	m.lastAutosave = nil
+}
+
+// checkRecovery looks for a journal left by a session that ended with
+// unsaved edits, and offers it in ModeRecover when it differs from the file
+func (m *Model) checkRecovery() {
+	if m.journal.Path == "" || m.typFile == nil || m.typFile.Binary {
+		return
+	}
+
+	data, saved, err := m.journal.Read()
+	if errors.Is(err, fs.ErrNotExist) {
+		return
+	}
+	if err != nil {
+		m.status = fmt.Sprintf("Cannot read the autosave journal: %v", err)
+		return
+	}
+
+	if bytes.Equal(m.loaded, data) {
+		m.discardJournal()
+		return
+	}
+
+	r := &recovery{data: data, saved: saved, diskTime: m.stamp.ModTime}
+	edits := textdiff.Lines(textdiff.SplitLines(string(m.loaded)), textdiff.SplitLines(string(data)))
+	r.hunks = textdiff.Hunks(edits, 3)
+	m.recovery = r
+	m.recoverReturn = m.mode
+	m.mode = ModeRecover
+}
+
+// handleRecoverKeyPress handles keyboard input on the recovery screen
+func (m Model) handleRecoverKeyPress(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "q", "ctrl+c":
+		// Leave the journal for the next launch
+		return m, tea.Quit
+
+	case "r", "R":
+		typFile, diagnostics, err := parser.ParseReaderTolerant(bytes.NewReader(m.recovery.data), m.filePath)
+		if err != nil {
+			m.status = fmt.Sprintf("Cannot recover: %v", err)
+			return m, nil
+		}
+		m.typFile = typFile
+		m.diagnostics = diagnostics
+		m.diagIdx = 0
+		m.selectedIdx = 0
+		m.modified = true
+		m.lastAutosave = m.recovery.data
+		m.recovery = nil
+		m.revalidate()
+		m.mode = ModeList
+		if parser.HasErrors(diagnostics) {
+			m.mode = ModeError
+		}
+		m.status = "Recovered the autosaved edits, press Ctrl+S to save them"
+
+	case "d", "D":
+		m.discardJournal()
+		m.recovery = nil
+		m.mode = m.recoverReturn
+		m.status = "Discarded the autosaved edits"
+	}
+
+	return m, nil
+}
+
+// viewRecover renders the offer to recover autosaved edits
+func (m Model) viewRecover() string {
+	var b strings.Builder
+
+	b.WriteString(titleStyle.Render("Recover unsaved changes?"))
+	b.WriteString("\n\n")
+	fmt.Fprintf(&b, "%s was closed with unsaved changes, autosaved %s.\n",
+		m.filePath, m.recovery.saved.Format("2006-01-02 15:04:05"))
+	if m.recovery.diskTime.After(m.recovery.saved) {
+		b.WriteString(errorStyle.Render(fmt.Sprintf("The file on disk changed later, at %s.",
+			m.recovery.diskTime.Format("2006-01-02 15:04:05"))))
+		b.WriteString("\n")
+	}
+	b.WriteString("\n")
+
+	room := m.height - 10
+	if room < 5 {
+		room = 5
+	}
+	b.WriteString(statusStyle.Render("Recovering changes the file on disk like this:"))
+	b.WriteString("\n")
+	b.WriteString(renderHunks(m.recovery.hunks, room))
+
+	b.WriteString("\n")
+	b.WriteString(helpStyle.Render("[r] Recover  [d] Discard  [q] Quit and decide later"))
+
+	return b.String()
+}
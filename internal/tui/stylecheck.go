@@ -0,0 +1,255 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/dyuri/typtui/internal/mkstyle"
+)
+
+// styleSection is a part of the cross-check report
+type styleSection struct {
+	title  string
+	issues []mkstyle.Issue
+}
+
+// styleSections returns the parts of the cross-check report in display
+// order
+func (m Model) styleSections() []styleSection {
+	if m.styleReport == nil {
+		return nil
+	}
+	return []styleSection{
+		{"Style types without a TYP definition", m.styleReport.Missing},
+		{"Style types defined as another kind", m.styleReport.WrongKind},
+		{"TYP types the style never produces", m.styleReport.Unused},
+	}
+}
+
+// styleIssue returns the issue at an index of the report across its
+// sections
+func (m Model) styleIssue(index int) (mkstyle.Issue, bool) {
+	for _, s := range m.styleSections() {
+		if index < len(s.issues) {
+			return s.issues[index], true
+		}
+		index -= len(s.issues)
+	}
+	return mkstyle.Issue{}, false
+}
+
+// openStyleCheck cross-checks the file with its mkgmap style in
+// ModeStyleCheck, asking for the style first if none was given
+func (m *Model) openStyleCheck() {
+	m.mode = ModeStyleCheck
+	if m.stylePath == "" {
+		m.openStyleForm()
+		return
+	}
+	if err := m.runStyleCheck(m.stylePath); err != nil {
+		m.openStyleForm()
+		m.formErr = err.Error()
+	}
+}
+
+// openStyleForm asks for the style directory or rule file
+func (m *Model) openStyleForm() {
+	input := textinput.New()
+	input.Prompt = "mkgmap style: "
+	input.Placeholder = "style directory, or its points, lines or polygons file"
+	input.CharLimit = 256
+	input.Width = 60
+	input.SetValue(m.stylePath)
+	input.Focus()
+
+	m.inputs = []textinput.Model{input}
+	m.focusedField = 0
+	m.formErr = ""
+}
+
+// runStyleCheck reads the style rules and cross-checks the file with them
+func (m *Model) runStyleCheck(path string) error {
+	refs, err := mkstyle.Load(expandHome(path))
+	if err != nil {
+		return err
+	}
+	report := mkstyle.Check(m.typFile, refs)
+	m.stylePath = path
+	m.styleReport = &report
+	m.styleRules = len(refs)
+	m.styleIdx = min(m.styleIdx, max(report.Count()-1, 0))
+	m.inputs = nil
+	return nil
+}
+
+// handleStyleCheckKeyPress handles keyboard input in the style form and
+// the cross-check report
+func (m Model) handleStyleCheckKeyPress(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if m.inputs != nil {
+		var cmd tea.Cmd
+		switch msg.String() {
+		case "enter":
+			path := strings.TrimSpace(m.inputs[0].Value())
+			if path == "" {
+				m.formErr = "Enter the path of the style"
+				return m, nil
+			}
+			if err := m.runStyleCheck(path); err != nil {
+				m.formErr = err.Error()
+			}
+			return m, nil
+
+		case "esc":
+			m.inputs = nil
+			if m.styleReport == nil {
+				m.mode = ModeList
+			}
+			return m, nil
+		}
+		m.inputs[0], cmd = m.inputs[0].Update(msg)
+		return m, cmd
+	}
+
+	switch msg.String() {
+	case "q", "ctrl+c":
+		if m.modified {
+			m.mode = ModeConfirmQuit
+			return m, nil
+		}
+		return m, tea.Quit
+
+	case "up", "k":
+		if m.styleIdx > 0 {
+			m.styleIdx--
+		}
+
+	case "down", "j":
+		if m.styleIdx < m.styleReport.Count()-1 {
+			m.styleIdx++
+		}
+
+	case "enter":
+		// Jump to the TYP type the selected issue is about
+		issue, ok := m.styleIssue(m.styleIdx)
+		if !ok || issue.Index < 0 {
+			return m, nil
+		}
+		for tab, kind := range tabKinds {
+			if kind == issue.Defined {
+				m.activeTab = tab
+				m.selectedIdx = issue.Index
+				m.mode = ModeDetail
+			}
+		}
+
+	case "r":
+		if err := m.runStyleCheck(m.stylePath); err != nil {
+			m.openStyleForm()
+			m.formErr = err.Error()
+		}
+
+	case "o":
+		m.openStyleForm()
+
+	case "esc":
+		m.mode = ModeList
+	}
+
+	return m, nil
+}
+
+// viewStyleCheck renders the style form or the cross-check report
+func (m Model) viewStyleCheck() string {
+	var b strings.Builder
+
+	b.WriteString(m.renderHeader())
+	b.WriteString("\n\n")
+
+	if m.inputs != nil {
+		b.WriteString(titleStyle.Render("Cross-check with an mkgmap style"))
+		b.WriteString("\n\n")
+		b.WriteString(m.inputs[0].View())
+		b.WriteString("\n\n")
+		if m.formErr != "" {
+			b.WriteString(errorStyle.Render(m.formErr))
+			b.WriteString("\n\n")
+		}
+		b.WriteString(helpStyle.Render("[Enter] Check  [Esc] Cancel"))
+		return b.String()
+	}
+
+	title := fmt.Sprintf("Cross-check with %s (%d rules, %d issues)", m.stylePath, m.styleRules, m.styleReport.Count())
+	b.WriteString(titleStyle.Render(title))
+	b.WriteString("\n\n")
+	if m.styleReport.Count() == 0 {
+		b.WriteString(statusStyle.Render("The style and the file agree on all types"))
+		b.WriteString("\n")
+	}
+
+	// Keep the selection visible when the report is taller than the screen
+	var lines []string
+	selected := 0
+	index := 0
+	for _, s := range m.styleSections() {
+		if len(s.issues) == 0 {
+			continue
+		}
+		if len(lines) > 0 {
+			lines = append(lines, "")
+		}
+		lines = append(lines, selectedStyle.Render(fmt.Sprintf("%s (%d)", s.title, len(s.issues))))
+		for _, issue := range s.issues {
+			if index == m.styleIdx {
+				selected = len(lines)
+			}
+			lines = append(lines, renderStyleIssue(issue, index == m.styleIdx))
+			index++
+		}
+	}
+	visible := max(m.height-8, 5)
+	start := max(selected-visible+1, 0)
+	for _, line := range lines[start:min(start+visible, len(lines))] {
+		b.WriteString(line)
+		b.WriteString("\n")
+	}
+
+	b.WriteString("\n")
+	b.WriteString(helpStyle.Render("[↑/↓] Navigate  [Enter] Go to type  [r] Check again  [o] Other style  [Esc] Back  [q] Quit"))
+
+	return b.String()
+}
+
+// renderStyleIssue renders an issue of the cross-check report
+func renderStyleIssue(issue mkstyle.Issue, selected bool) string {
+	text := fmt.Sprintf("%-7s %s", issue.Kind, issue.Code)
+	if issue.Name != "" {
+		text += " " + issue.Name
+	}
+
+	var detail []string
+	if len(issue.Refs) > 0 {
+		ref := issue.Refs[0]
+		rule := ref.String()
+		if ref.Rule != "" {
+			rule += " " + ref.Rule
+		}
+		if len(issue.Refs) > 1 {
+			rule += fmt.Sprintf(" (+%d)", len(issue.Refs)-1)
+		}
+		detail = append(detail, rule)
+	}
+	if issue.Defined != "" && issue.Defined != issue.Kind {
+		detail = append(detail, "defined as a "+issue.Defined)
+	}
+	suffix := ""
+	if len(detail) > 0 {
+		suffix = "  " + helpStyle.Render(strings.Join(detail, ", "))
+	}
+
+	if selected {
+		return selectedStyle.Render("▸ "+text) + suffix
+	}
+	return "  " + text + suffix
+}
@@ -0,0 +1,109 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/dyuri/typtui/internal/typedb"
+)
+
+// maxSuggestions is the number of catalogue types offered for the Type field
+const maxSuggestions = 6
+
+// typeName returns the catalogue name of a type, or "" for codes the
+// catalogue does not know
+func typeName(kind, typ, subType string) string {
+	info, ok := typedb.Lookup(kind, typ, subType)
+	if !ok {
+		return ""
+	}
+	return info.Name
+}
+
+// listEntry renders a type in the list: its code, its catalogue name and
+// its label
+func listEntry(code, name, label string, selected bool) string {
+	if selected {
+		text := code
+		if name != "" {
+			text += " " + name
+		}
+		return selectedStyle.Render("▸ " + text + " - " + label)
+	}
+	if name != "" {
+		code += " " + statusStyle.Render(name)
+	}
+	return fmt.Sprintf("  %s - %s", code, label)
+}
+
+// renderTypeInfo renders the Type line of a detail view with what the
+// catalogue knows about the code
+func renderTypeInfo(kind, typ, subType string) string {
+	var b strings.Builder
+
+	b.WriteString(selectedStyle.Render("Type: "))
+	b.WriteString(typ)
+	if subType != "" {
+		b.WriteString(" / " + subType)
+	}
+	if info, ok := typedb.Lookup(kind, typ, subType); ok {
+		b.WriteString(fmt.Sprintf(" - %s (%s)", info.Name, info.Category))
+		if len(info.OSMTags) > 0 {
+			b.WriteString("\n")
+			b.WriteString(statusStyle.Render("OSM: " + info.Tags()))
+		}
+	}
+	b.WriteString("\n\n")
+
+	return b.String()
+}
+
+// updateTypeSuggestions looks up the value of the Type field in the
+// catalogue: by code, name or category, or by OSM tag when it holds a
+// key=value pair
+func (m *Model) updateTypeSuggestions() {
+	m.typeSuggestions = nil
+	m.suggestionIdx = 0
+	if m.focusedField != 0 || len(m.inputs) == 0 {
+		return
+	}
+
+	matches := typedb.Search(tabKinds[m.activeTab], m.inputs[0].Value())
+	if len(matches) == 1 && matches[0].Code == m.inputs[0].Value() {
+		// The code is complete
+		return
+	}
+	m.typeSuggestions = matches[:min(len(matches), maxSuggestions)]
+}
+
+// acceptTypeSuggestion fills the Type field with the chosen suggestion
+func (m *Model) acceptTypeSuggestion() {
+	info := m.typeSuggestions[m.suggestionIdx]
+	m.inputs[0].SetValue(info.Code)
+	m.inputs[0].CursorEnd()
+	if m.activeTab == TabPoints {
+		// The code holds the subtype
+		m.inputs[1].SetValue("")
+	}
+	m.typeSuggestions = nil
+	m.suggestionIdx = 0
+}
+
+// renderTypeSuggestions renders the catalogue types offered for the Type
+// field
+func (m Model) renderTypeSuggestions() string {
+	var b strings.Builder
+	for i, info := range m.typeSuggestions {
+		text := fmt.Sprintf("%-8s %s (%s)", info.Code, info.Name, info.Category)
+		if len(info.OSMTags) > 0 {
+			text += "  " + info.OSMTags[0]
+		}
+		if i == m.suggestionIdx {
+			b.WriteString(selectedStyle.Render("  ▸ " + text))
+		} else {
+			b.WriteString(statusStyle.Render("    " + text))
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
@@ -1,8 +1,8 @@
 package tui
 
 import (
+	"errors"
 	"fmt"
-	"sort"
 	"strconv"
 	"strings"
 
@@ -23,6 +23,40 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		if m.mode == ModeEditXPM {
 			return m.handleXPMEditKeyPress(msg)
 		}
+		// The diagnostics list has its own navigation
+		if m.mode == ModeError && m.err == nil {
+			return m.handleDiagnosticsKeyPress(msg)
+		}
+		if m.mode == ModeRestoreBackup {
+			return m.handleBackupsKeyPress(msg)
+		}
+		if m.mode == ModeRecover {
+			return m.handleRecoverKeyPress(msg)
+		}
+		if m.mode == ModeFileChanged {
+			return m.handleFileChangedKeyPress(msg)
+		}
+		if m.mode == ModeValidate {
+			return m.handleValidateKeyPress(msg)
+		}
+		if m.mode == ModeConflicts {
+			return m.handleConflictsKeyPress(msg)
+		}
+		if m.mode == ModeImportXPM {
+			return m.handleImportKeyPress(msg)
+		}
+		if m.mode == ModeExportPNG {
+			return m.handleExportKeyPress(msg)
+		}
+		if m.mode == ModeStyleCheck {
+			return m.handleStyleCheckKeyPress(msg)
+		}
+		if m.mode == ModeCompile {
+			return m.handleCompileKeyPress(msg)
+		}
+		if m.mode == ModeNight {
+			return m.handleNightKeyPress(msg)
+		}
 		return m.handleKeyPress(msg)
 
 	case tea.WindowSizeMsg:
@@ -49,7 +83,54 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return m, nil
 		}
 		m.typFile = msg.typFile
+		m.diagnostics = msg.diagnostics
+		m.diagIdx = 0
+		m.loaded = msg.data
+		m.stamp = msg.stamp
+		m.diskChanged = false
+		m.diskRemoved = false
+		m.revalidate()
 		m.mode = ModeList
+		if parser.HasErrors(m.diagnostics) {
+			m.mode = ModeError
+		} else if len(m.diagnostics) > 0 {
+			m.status = fmt.Sprintf("Loaded with %d warnings, press ! to review", len(m.diagnostics))
+		}
+		if !m.recoveryChecked {
+			m.recoveryChecked = true
+			m.checkRecovery()
+		}
+		if m.merge != nil {
+			m.startMerge()
+		}
+		return m, nil
+
+	case autosaveTickMsg:
+		return m, tea.Batch(m.autosave(), m.autosaveTickCmd())
+
+	case watchTickMsg:
+		return m, m.checkDiskCmd()
+
+	case diskCheckedMsg:
+		// A save or reload while checking makes the result stale
+		if msg.stamp == m.stamp {
+			m.diskChanged = msg.changed || msg.removed
+			m.diskRemoved = msg.removed
+		}
+		return m, m.watchTickCmd()
+
+	case compileLogMsg:
+		return m.handleCompileLog(msg)
+
+	case compileDoneMsg:
+		return m.handleCompileDone(msg)
+
+	case autosavedMsg:
+		if msg.err != nil {
+			m.status = fmt.Sprintf("Autosave failed: %v", msg.err)
+		} else {
+			m.lastAutosave = msg.data
+		}
 		return m, nil
 	}
 
@@ -73,17 +154,60 @@ func (m Model) handleKeyPress(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			m.mode = ModeConfirmQuit
 			return m, nil
 		}
+		// Nothing to recover, such as a cancelled edit form
+		m.discardJournal()
 		return m, tea.Quit
 
 	case "ctrl+s":
 		// Save file in list or detail mode
 		if (m.mode == ModeList || m.mode == ModeDetail) && m.typFile != nil {
-			if err := m.saveFile(); err != nil {
+			err := m.saveFile()
+			if errors.Is(err, errInvalid) {
+				m.openValidation(true, false)
+			} else if errors.Is(err, errChangedOnDisk) {
+				m.openFileChanged(false)
+			} else if err != nil {
 				m.status = fmt.Sprintf("Error saving: %v", err)
 			}
 		}
 		return m, nil
 
+	case "v":
+		if (m.mode == ModeList || m.mode == ModeDetail) && m.typFile != nil {
+			m.openValidation(false, false)
+		}
+		return m, nil
+
+	case "r":
+		if (m.mode == ModeList || m.mode == ModeDetail) && m.diskChanged && !m.diskRemoved {
+			m.openFileChanged(false)
+		}
+		return m, nil
+
+	case "s":
+		if m.mode == ModeList && m.typFile != nil {
+			m.openStyleCheck()
+		}
+		return m, nil
+
+	case "c":
+		if m.mode == ModeList && m.typFile != nil {
+			m.openCompile()
+		}
+		return m, nil
+
+	case "b":
+		if m.mode == ModeList && m.typFile != nil && m.filePath != "" {
+			m.openBackups()
+		}
+		return m, nil
+
+	case "!":
+		if m.mode == ModeList && len(m.diagnostics) > 0 {
+			m.mode = ModeError
+		}
+		return m, nil
+
 	case "?":
 		if m.mode == ModeHelp {
 			m.mode = ModeList
@@ -94,7 +218,7 @@ func (m Model) handleKeyPress(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 
 	case "tab":
 		if m.mode == ModeList {
-			m.activeTab = (m.activeTab + 1) % 3
+			m.activeTab = (m.activeTab + 1) % tabCount
 			m.selectedIdx = 0
 		}
 		return m, nil
@@ -117,11 +241,23 @@ func (m Model) handleKeyPress(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		return m, nil
 
 	case "enter":
-		if m.mode == ModeList && m.typFile != nil && m.getMaxIndex() > 0 {
+		if m.mode == ModeList && m.typFile != nil && m.getMaxIndex() > 0 && m.activeTab != TabDrawOrder {
 			m.mode = ModeDetail
 		}
 		return m, nil
 
+	case "+", "right", "l":
+		if m.mode == ModeList && m.activeTab == TabDrawOrder {
+			m.moveDrawOrder(1)
+		}
+		return m, nil
+
+	case "-", "left", "h":
+		if m.mode == ModeList && m.activeTab == TabDrawOrder {
+			m.moveDrawOrder(-1)
+		}
+		return m, nil
+
 	case "esc":
 		if m.mode == ModeDetail {
 			m.mode = ModeList
@@ -154,37 +290,46 @@ func (m Model) handleKeyPress(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		}
 		return m, nil
 
-	case "x":
+	case "i":
 		if m.mode == ModeDetail && m.typFile != nil {
-			// Enter XPM edit mode - default to DayXpm
-			switch m.activeTab {
-			case TabPoints:
-				if m.selectedIdx < len(m.typFile.Points) && m.typFile.Points[m.selectedIdx].DayXpm != nil {
-					m.editingXPM = m.typFile.Points[m.selectedIdx].DayXpm
-					m.editingXPMType = "DayXpm"
-					m.xpmColorIdx = 0
-					m.mode = ModeEditXPM
-					// Initialize viewport for XPM editor
-					m.initXPMViewport()
-				}
-			case TabLines:
-				if m.selectedIdx < len(m.typFile.Lines) && m.typFile.Lines[m.selectedIdx].DayXpm != nil {
-					m.editingXPM = m.typFile.Lines[m.selectedIdx].DayXpm
-					m.editingXPMType = "Xpm"
-					m.xpmColorIdx = 0
-					m.mode = ModeEditXPM
-					// Initialize viewport for XPM editor
-					m.initXPMViewport()
-				}
-			case TabPolygons:
-				if m.selectedIdx < len(m.typFile.Polygons) && m.typFile.Polygons[m.selectedIdx].DayXpm != nil {
-					m.editingXPM = m.typFile.Polygons[m.selectedIdx].DayXpm
-					m.editingXPMType = "Xpm"
-					m.xpmColorIdx = 0
-					m.mode = ModeEditXPM
-					// Initialize viewport for XPM editor
-					m.initXPMViewport()
-				}
+			m.openImport()
+		}
+		return m, nil
+
+	case "p":
+		if m.mode == ModeDetail && m.typFile != nil {
+			m.openExport()
+		}
+		return m, nil
+
+	case "C":
+		if m.mode == ModeDetail {
+			m.cycleSimulation()
+		}
+		return m, nil
+
+	case "n":
+		if m.mode == ModeDetail && m.typFile != nil {
+			m.openNight()
+		}
+		return m, nil
+
+	case "x", "X":
+		if m.mode == ModeDetail && m.typFile != nil {
+			// x edits the day icon, X the night icon
+			target := m.xpmTargets()[0]
+			if msg.String() == "X" {
+				target = "NightXpm"
+			}
+			if field := m.selectedXPM(target); field != nil && *field != nil {
+				m.editingXPM = *field
+				m.editingXPMType = target
+				m.xpmColorIdx = 0
+				m.mode = ModeEditXPM
+				// Initialize viewport for XPM editor
+				m.initXPMViewport()
+			} else if field != nil && target == "NightXpm" {
+				m.status = "The type has no night icon, press n to generate one or i to import one"
 			}
 		}
 		return m, nil
@@ -193,6 +338,52 @@ func (m Model) handleKeyPress(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	return m, nil
 }
 
+// handleDiagnosticsKeyPress handles keyboard input in the diagnostics list
+func (m Model) handleDiagnosticsKeyPress(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "q", "ctrl+c":
+		return m, tea.Quit
+
+	case "up", "k":
+		if m.diagIdx > 0 {
+			m.diagIdx--
+		}
+
+	case "down", "j":
+		if m.diagIdx < len(m.diagnostics)-1 {
+			m.diagIdx++
+		}
+
+	case "enter":
+		// Jump to the type the selected problem belongs to
+		if m.diagIdx < len(m.diagnostics) {
+			d := m.diagnostics[m.diagIdx]
+			tab, ok := sectionTabs[d.Section]
+			if ok && d.Index >= 0 {
+				m.activeTab = tab
+				m.selectedIdx = d.Index
+				if m.selectedIdx < m.getMaxIndex() {
+					m.mode = ModeDetail
+				}
+			}
+		}
+
+	case "esc":
+		if m.typFile != nil {
+			m.mode = ModeList
+		}
+	}
+
+	return m, nil
+}
+
+// sectionTabs maps type section names to their tabs
+var sectionTabs = map[string]Tab{
+	"_point":   TabPoints,
+	"_line":    TabLines,
+	"_polygon": TabPolygons,
+}
+
 // getMaxIndex returns the maximum index for the current tab
 func (m Model) getMaxIndex() int {
 	if m.typFile == nil {
@@ -206,6 +397,8 @@ func (m Model) getMaxIndex() int {
 		return len(m.typFile.Lines)
 	case TabPolygons:
 		return len(m.typFile.Polygons)
+	case TabDrawOrder:
+		return len(m.drawOrderRows())
 	}
 	return 0
 }
@@ -217,18 +410,42 @@ func (m Model) handleEditModeKeyPress(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	switch msg.String() {
 	case "ctrl+s":
 		// Save changes
-		m.saveEdits()
+		if err := m.saveEdits(); err != nil {
+			m.formErr = err.Error()
+			return m, nil
+		}
+		m.formErr = ""
 		m.modified = true
+		m.revalidate()
 		m.mode = ModeDetail
 		m.inputs = nil
+		m.typeSuggestions = nil
 		return m, nil
 
 	case "esc":
 		// Cancel editing
 		m.mode = ModeDetail
 		m.inputs = nil
+		m.typeSuggestions = nil
+		return m, nil
+
+	case "ctrl+n", "ctrl+p":
+		// Choose among the catalogue types offered for the Type field
+		if n := len(m.typeSuggestions); n > 0 {
+			if msg.String() == "ctrl+n" {
+				m.suggestionIdx = (m.suggestionIdx + 1) % n
+			} else {
+				m.suggestionIdx = (m.suggestionIdx + n - 1) % n
+			}
+		}
 		return m, nil
 
+	case "enter":
+		if len(m.typeSuggestions) > 0 {
+			m.acceptTypeSuggestion()
+			return m, nil
+		}
+
 	case "tab", "shift+tab", "up", "down":
 		// Navigate between fields
 		if msg.String() == "tab" || msg.String() == "down" {
@@ -251,27 +468,38 @@ func (m Model) handleEditModeKeyPress(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 				m.inputs[i].Blur()
 			}
 		}
+		m.typeSuggestions = nil
 		return m, nil
 	}
 
 	// Forward key to focused input
 	if m.focusedField < len(m.inputs) {
 		m.inputs[m.focusedField], cmd = m.inputs[m.focusedField].Update(msg)
+		m.updateTypeSuggestions()
 	}
 
 	return m, cmd
 }
 
-// saveEdits saves the current edit form values back to the data structure
-func (m *Model) saveEdits() {
+// saveEdits saves the current edit form values back to the data structure.
+// Nothing is changed if a colour field does not hold a colour.
+func (m *Model) saveEdits() error {
 	if len(m.inputs) < 2 {
-		return
+		return nil
 	}
 
 	// Update the appropriate structure
+	var label string
+	f := colorFields{inputs: m.inputs}
 	switch m.activeTab {
 	case TabPoints:
-		if m.selectedIdx < len(m.typFile.Points) && len(m.inputs) >= 6 {
+		if m.selectedIdx < len(m.typFile.Points) && len(m.inputs) >= 10 {
+			dayColor, nightColor := f.color(4), f.color(5)
+			dayFont, nightFont, custom := f.color(7), f.color(8), f.color(9)
+			if f.err != nil {
+				return f.err
+			}
+
 			// Type (index 0)
 			m.typFile.Points[m.selectedIdx].Type = m.inputs[0].Value()
 
@@ -282,18 +510,14 @@ func (m *Model) saveEdits() {
 			if m.typFile.Points[m.selectedIdx].Labels == nil {
 				m.typFile.Points[m.selectedIdx].Labels = make(map[string]string)
 			}
-			m.typFile.Points[m.selectedIdx].Labels["0x04"] = m.inputs[2].Value()
+			label = m.inputs[2].Value()
+			m.typFile.Points[m.selectedIdx].Labels["0x04"] = label
 
 			// FontStyle (index 3)
 			m.typFile.Points[m.selectedIdx].FontStyle = m.inputs[3].Value()
 
 			// Day Color (index 4)
-			dayColorValue := m.inputs[4].Value()
-			if dayColorValue != "" {
-				// Ensure # prefix
-				if !strings.HasPrefix(dayColorValue, "#") {
-					dayColorValue = "#" + dayColorValue
-				}
+			if dayColorValue := dayColor.Hex; dayColorValue != "" {
 				// Update or create day color
 				if len(m.typFile.Points[m.selectedIdx].DayColors) > 0 {
 					m.typFile.Points[m.selectedIdx].DayColors[0].Hex = dayColorValue
@@ -305,12 +529,7 @@ func (m *Model) saveEdits() {
 			}
 
 			// Night Color (index 5)
-			nightColorValue := m.inputs[5].Value()
-			if nightColorValue != "" {
-				// Ensure # prefix
-				if !strings.HasPrefix(nightColorValue, "#") {
-					nightColorValue = "#" + nightColorValue
-				}
+			if nightColorValue := nightColor.Hex; nightColorValue != "" {
 				// Update or create night color
 				if len(m.typFile.Points[m.selectedIdx].NightColors) > 0 {
 					m.typFile.Points[m.selectedIdx].NightColors[0].Hex = nightColorValue
@@ -320,10 +539,25 @@ func (m *Model) saveEdits() {
 					}
 				}
 			}
+
+			// ExtendedLabels (index 6)
+			extLabels := strings.ToUpper(m.inputs[6].Value())
+			m.typFile.Points[m.selectedIdx].ExtendedLabels = (extLabels == "Y" || extLabels == "YES")
+
+			// Font and custom colors (index 7, 8, 9)
+			m.typFile.Points[m.selectedIdx].DayFontColor = dayFont
+			m.typFile.Points[m.selectedIdx].NightFontColor = nightFont
+			m.typFile.Points[m.selectedIdx].CustomColor = custom
 		}
 
 	case TabLines:
-		if m.selectedIdx < len(m.typFile.Lines) && len(m.inputs) >= 6 {
+		if m.selectedIdx < len(m.typFile.Lines) && len(m.inputs) >= 15 {
+			dayFont, nightFont := f.color(10), f.color(11)
+			dayColors, nightColors, custom := f.colors(12, true), f.colors(13, false), f.color(14)
+			if f.err != nil {
+				return f.err
+			}
+
 			// Type (index 0)
 			m.typFile.Lines[m.selectedIdx].Type = m.inputs[0].Value()
 
@@ -331,7 +565,8 @@ func (m *Model) saveEdits() {
 			if m.typFile.Lines[m.selectedIdx].Labels == nil {
 				m.typFile.Lines[m.selectedIdx].Labels = make(map[string]string)
 			}
-			m.typFile.Lines[m.selectedIdx].Labels["0x04"] = m.inputs[1].Value()
+			label = m.inputs[1].Value()
+			m.typFile.Lines[m.selectedIdx].Labels["0x04"] = label
 
 			// LineWidth (index 2)
 			if width, err := strconv.Atoi(m.inputs[2].Value()); err == nil {
@@ -349,10 +584,36 @@ func (m *Model) saveEdits() {
 			// UseOrientation (index 5)
 			useOrient := strings.ToUpper(m.inputs[5].Value())
 			m.typFile.Lines[m.selectedIdx].UseOrientation = (useOrient == "Y" || useOrient == "YES")
+
+			// FontStyle (index 6)
+			m.typFile.Lines[m.selectedIdx].FontStyle = m.inputs[6].Value()
+
+			// ExtendedLabels (index 7)
+			extLabels := strings.ToUpper(m.inputs[7].Value())
+			m.typFile.Lines[m.selectedIdx].ExtendedLabels = (extLabels == "Y" || extLabels == "YES")
+
+			// Night widths (index 8, 9), empty means same as day
+			m.typFile.Lines[m.selectedIdx].NightLineWidth, _ = strconv.Atoi(m.inputs[8].Value())
+			m.typFile.Lines[m.selectedIdx].NightBorderWidth, _ = strconv.Atoi(m.inputs[9].Value())
+
+			// Font colors (index 10, 11)
+			m.typFile.Lines[m.selectedIdx].DayFontColor = dayFont
+			m.typFile.Lines[m.selectedIdx].NightFontColor = nightFont
+
+			// Custom colors (index 12, 13, 14)
+			m.typFile.Lines[m.selectedIdx].DayColors = dayColors
+			m.typFile.Lines[m.selectedIdx].NightColors = nightColors
+			m.typFile.Lines[m.selectedIdx].CustomColor = custom
 		}
 
 	case TabPolygons:
-		if m.selectedIdx < len(m.typFile.Polygons) && len(m.inputs) >= 4 {
+		if m.selectedIdx < len(m.typFile.Polygons) && len(m.inputs) >= 10 {
+			dayFont, nightFont, contour := f.color(4), f.color(5), f.color(6)
+			dayColors, nightColors, custom := f.colors(7, true), f.colors(8, false), f.color(9)
+			if f.err != nil {
+				return f.err
+			}
+
 			// Type (index 0)
 			m.typFile.Polygons[m.selectedIdx].Type = m.inputs[0].Value()
 
@@ -360,7 +621,8 @@ func (m *Model) saveEdits() {
 			if m.typFile.Polygons[m.selectedIdx].Labels == nil {
 				m.typFile.Polygons[m.selectedIdx].Labels = make(map[string]string)
 			}
-			m.typFile.Polygons[m.selectedIdx].Labels["0x04"] = m.inputs[1].Value()
+			label = m.inputs[1].Value()
+			m.typFile.Polygons[m.selectedIdx].Labels["0x04"] = label
 
 			// ExtendedLabels (index 2)
 			extLabels := strings.ToUpper(m.inputs[2].Value())
@@ -368,8 +630,82 @@ func (m *Model) saveEdits() {
 
 			// FontStyle (index 3)
 			m.typFile.Polygons[m.selectedIdx].FontStyle = m.inputs[3].Value()
+
+			// Font and contour colors (index 4, 5, 6)
+			m.typFile.Polygons[m.selectedIdx].DayFontColor = dayFont
+			m.typFile.Polygons[m.selectedIdx].NightFontColor = nightFont
+			m.typFile.Polygons[m.selectedIdx].ContourColor = contour
+
+			// Custom colors (index 7, 8, 9)
+			m.typFile.Polygons[m.selectedIdx].DayColors = dayColors
+			m.typFile.Polygons[m.selectedIdx].NightColors = nightColors
+			m.typFile.Polygons[m.selectedIdx].CustomColor = custom
 		}
 	}
+
+	// Flag a label the code page of the file cannot hold
+	if err := parser.CheckText(label, m.typFile.Header.CodePage); err != nil {
+		m.status = fmt.Sprintf("Label: %v, use typtui convert to change the code page", err)
+	}
+	return nil
+}
+
+// colorFields reads the color fields of an edit form, keeping the first
+// field that does not hold a color
+type colorFields struct {
+	inputs []textinput.Model
+	err    error
+}
+
+// color parses a field holding one color. An empty field clears the color.
+func (f *colorFields) color(i int) parser.Color {
+	value := strings.TrimSpace(f.inputs[i].Value())
+	if value == "" {
+		return parser.Color{}
+	}
+	c, ok := formColor(value)
+	if !ok {
+		f.fail(i, value)
+	}
+	return c
+}
+
+// colors parses a field holding a comma separated list of colors
+func (f *colorFields) colors(i int, day bool) []parser.Color {
+	var colors []parser.Color
+	for _, value := range strings.Split(f.inputs[i].Value(), ",") {
+		if value = strings.TrimSpace(value); value == "" {
+			continue
+		}
+		c, ok := formColor(value)
+		if !ok {
+			f.fail(i, value)
+		}
+		c.Day = day
+		colors = append(colors, c)
+	}
+	return colors
+}
+
+// fail records a value of field i that is not a color
+func (f *colorFields) fail(i int, value string) {
+	if f.err == nil {
+		name := strings.TrimSuffix(f.inputs[i].Prompt, ": ")
+		f.err = fmt.Errorf("%s: %q is not a color, use #RRGGBB or a color name", name, value)
+	}
+}
+
+// formColor parses a color typed in a form: #RRGGBB, RRGGBB, #RGB or an X11
+// color name, returned as #RRGGBB
+func formColor(value string) (parser.Color, bool) {
+	c, ok := parser.ParseColorSpec(value)
+	if !ok && len(value) == 6 {
+		c, ok = parser.ParseColorSpec("#" + value)
+	}
+	if !ok || c.Hex == "none" {
+		return parser.Color{}, false
+	}
+	return parser.Color{Hex: c.Hex}, true
 }
 
 // handleConfirmQuit handles the confirm quit dialog
@@ -377,20 +713,30 @@ func (m Model) handleConfirmQuit(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	switch msg.String() {
 	case "y", "Y":
 		// Save and quit
-		if err := m.saveFile(); err != nil {
+		err := m.saveFile()
+		if errors.Is(err, errInvalid) {
+			m.openValidation(true, true)
+			return m, nil
+		}
+		if errors.Is(err, errChangedOnDisk) {
+			m.openFileChanged(true)
+			return m, nil
+		}
+		if err != nil {
 			m.status = fmt.Sprintf("Error saving: %v", err)
-			m.mode = ModeList
+			m.mode = m.homeMode()
 			return m, nil
 		}
 		return m, tea.Quit
 
 	case "n", "N":
-		// Quit without saving
+		// Quit without saving, the edits are not wanted for recovery
+		m.discardJournal()
 		return m, tea.Quit
 
 	case "esc", "c", "C":
 		// Cancel quit and return to list
-		m.mode = ModeList
+		m.mode = m.homeMode()
 		return m, nil
 	}
 
@@ -409,34 +755,21 @@ func (m Model) handleXPMEditKeyPress(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	if len(m.inputs) > 0 {
 		switch msg.String() {
 		case "enter":
-			// Save the color change
-			newColor := m.inputs[0].Value()
-			if !strings.HasPrefix(newColor, "#") {
-				newColor = "#" + newColor
-			}
-
-			// Find and update the color at xpmColorIdx
-			// Convert to sorted slice for consistent ordering
-			type colorEntry struct {
-				char  string
-				color parser.Color
+			// Save the color change: a hex value, with or without #, a
+			// colour name or none
+			newColor := strings.TrimSpace(m.inputs[0].Value())
+			color, ok := parser.ParseColorSpec(newColor)
+			if !ok {
+				color, ok = parser.ParseColorSpec("#" + newColor)
 			}
-			var colors []colorEntry
-			for char, color := range m.editingXPM.Palette {
-				colors = append(colors, colorEntry{char, color})
+			if !ok {
+				m.status = fmt.Sprintf("Unknown colour %q", newColor)
+				return m, nil
 			}
 
-			// Sort alphabetically by character (same as in view)
-			sort.Slice(colors, func(i, j int) bool {
-				return colors[i].char < colors[j].char
-			})
-
-			// Update the selected color
-			if m.xpmColorIdx < len(colors) {
-				selectedChar := colors[m.xpmColorIdx].char
-				color := m.editingXPM.Palette[selectedChar]
-				color.Hex = newColor
-				m.editingXPM.Palette[selectedChar] = color
+			// Update the selected color, keeping the entry's other keys
+			if m.xpmColorIdx < len(m.editingXPM.Palette) {
+				m.editingXPM.Palette[m.xpmColorIdx].Color = color
 			}
 
 			m.inputs = nil
@@ -462,6 +795,7 @@ func (m Model) handleXPMEditKeyPress(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	case "ctrl+s":
 		// Save changes and return to detail view
 		m.modified = true
+		m.revalidate()
 		m.mode = ModeDetail
 		m.editingXPM = nil
 		m.status = "XPM changes saved"
@@ -499,13 +833,16 @@ func (m Model) handleXPMEditKeyPress(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		// Edit the selected color
 		return m.enterColorEdit()
 
+	case "C":
+		m.cycleSimulation()
+		m.updateXPMViewportContent()
+		return m, nil
+
 	default:
 		// Forward other keys to viewport for scrolling
 		m.xpmViewport, cmd = m.xpmViewport.Update(msg)
 		return m, cmd
 	}
-
-	return m, nil
 }
 
 // enterColorEdit enters color editing mode for the selected palette entry
@@ -514,36 +851,24 @@ func (m Model) enterColorEdit() (tea.Model, tea.Cmd) {
 		return m, nil
 	}
 
-	// Get the color at the current index
-	// Convert to sorted slice for consistent ordering
-	type colorEntry struct {
-		char  string
-		color parser.Color
-	}
-	var colors []colorEntry
-	for char, color := range m.editingXPM.Palette {
-		colors = append(colors, colorEntry{char, color})
-	}
-
-	// Sort alphabetically by character (same as in view)
-	sort.Slice(colors, func(i, j int) bool {
-		return colors[i].char < colors[j].char
-	})
-
-	// Get the selected color
-	if m.xpmColorIdx >= len(colors) {
+	// Get the selected color, in palette order
+	if m.xpmColorIdx >= len(m.editingXPM.Palette) {
 		return m, nil
 	}
-	selectedEntry := colors[m.xpmColorIdx]
+	selectedEntry := m.editingXPM.Palette[m.xpmColorIdx]
 
 	// Create a single text input for the color
+	value := selectedEntry.Color.Hex
+	if selectedEntry.Color.Name != "" {
+		value = selectedEntry.Color.Name
+	}
 	input := textinput.New()
-	input.Placeholder = "#RRGGBB"
-	input.CharLimit = 7
+	input.Placeholder = "#RRGGBB, #RGB, a colour name or none"
+	input.CharLimit = 30
 	input.Width = 30
-	input.SetValue(selectedEntry.color.Hex)
+	input.SetValue(value)
 	input.Focus()
-	input.Prompt = fmt.Sprintf("Color for '%s': ", selectedEntry.char)
+	input.Prompt = fmt.Sprintf("Color for %q: ", selectedEntry.Key)
 
 	m.inputs = []textinput.Model{input}
 	m.focusedField = 0
@@ -0,0 +1,203 @@
+package tui
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/dyuri/typtui/internal/validate"
+)
+
+// errInvalid refuses a save while validation finds errors that were not
+// accepted
+var errInvalid = errors.New("validation found errors")
+
+// revalidate runs the validation rules again after the file changed. A
+// change also takes back an earlier "save anyway".
+func (m *Model) revalidate() {
+	m.findings = nil
+	m.findingsAccepted = false
+	if m.typFile != nil {
+		m.findings = validate.Run(m.typFile, m.config.Rules)
+	}
+	if m.findingIdx >= len(m.findings) {
+		m.findingIdx = 0
+	}
+}
+
+// checkFindings returns errInvalid when there are errors the user did not
+// choose to save anyway
+func (m Model) checkFindings() error {
+	if errs, _ := validate.Count(m.findings); errs > 0 && !m.findingsAccepted {
+		return errInvalid
+	}
+	return nil
+}
+
+// openValidation lists the findings in ModeValidate. With blocked the list
+// explains a refused save and offers to save anyway; quit continues
+// quitting once saved.
+func (m *Model) openValidation(blocked, quit bool) {
+	m.saveBlocked = blocked
+	m.quitAfterSave = quit
+	if m.mode != ModeValidate {
+		m.validateReturn = m.mode
+		if m.validateReturn == ModeConfirmQuit || m.validateReturn == ModeFileChanged {
+			m.validateReturn = m.homeMode()
+		}
+	}
+	m.mode = ModeValidate
+}
+
+// handleValidateKeyPress handles keyboard input in the validation list
+func (m Model) handleValidateKeyPress(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "q", "ctrl+c":
+		if m.modified {
+			m.mode = ModeConfirmQuit
+			return m, nil
+		}
+		return m, tea.Quit
+
+	case "up", "k":
+		if m.findingIdx > 0 {
+			m.findingIdx--
+		}
+
+	case "down", "j":
+		if m.findingIdx < len(m.findings)-1 {
+			m.findingIdx++
+		}
+
+	case "enter":
+		// Jump to the type the selected finding is about
+		if m.findingIdx < len(m.findings) {
+			f := m.findings[m.findingIdx]
+			if tab, ok := sectionTabs[f.Section]; ok {
+				m.activeTab = tab
+				m.selectedIdx = f.Index
+				if m.selectedIdx < m.getMaxIndex() {
+					m.mode = ModeDetail
+				}
+			}
+		}
+
+	case "s", "S":
+		if !m.saveBlocked {
+			return m, nil
+		}
+		m.findingsAccepted = true
+		err := m.saveFile()
+		if errors.Is(err, errChangedOnDisk) {
+			m.openFileChanged(m.quitAfterSave)
+			return m, nil
+		}
+		if err != nil {
+			m.status = fmt.Sprintf("Error saving: %v", err)
+			m.mode = m.validateReturn
+			return m, nil
+		}
+		if m.quitAfterSave {
+			return m, tea.Quit
+		}
+		m.mode = m.validateReturn
+
+	case "esc":
+		m.mode = m.validateReturn
+		if m.saveBlocked {
+			m.status = "Not saved, validation found errors"
+		}
+	}
+
+	return m, nil
+}
+
+// renderFindingsSummary renders the counts of findings for the header, or
+// "" when the file is clean
+func (m Model) renderFindingsSummary() string {
+	errs, warnings := validate.Count(m.findings)
+	switch {
+	case errs > 0:
+		return errorStyle.Render(fmt.Sprintf("✗ %d errors, %d warnings", errs, warnings)) + helpStyle.Render("  [v] Review")
+	case warnings > 0:
+		return statusStyle.Render(fmt.Sprintf("%d warnings", warnings)) + helpStyle.Render("  [v] Review")
+	}
+	return ""
+}
+
+// renderTypeFindings renders the findings about one type for its detail
+// view
+func (m Model) renderTypeFindings(section string, index int) string {
+	var b strings.Builder
+	for _, f := range m.findings {
+		if f.Section != section || f.Index != index {
+			continue
+		}
+		severity := statusStyle.Render(f.Severity.String())
+		if f.Severity == validate.SeverityError {
+			severity = errorStyle.Render(f.Severity.String())
+		}
+		fmt.Fprintf(&b, "  %s %s %s\n", severity, f.Message, helpStyle.Render("("+f.Rule+")"))
+	}
+	if b.Len() == 0 {
+		return ""
+	}
+	return titleStyle.Render("Problems") + "\n" + b.String()
+}
+
+// viewValidate renders the validation findings
+func (m Model) viewValidate() string {
+	var b strings.Builder
+
+	errs, warnings := validate.Count(m.findings)
+	title := fmt.Sprintf("Validation of %s (%d errors, %d warnings)", m.filePath, errs, warnings)
+	b.WriteString(titleStyle.Render(title))
+	b.WriteString("\n\n")
+	if m.saveBlocked {
+		b.WriteString(errorStyle.Render("Saving was stopped: mkgmap or a device may reject these errors."))
+		b.WriteString("\n\n")
+	}
+	if len(m.findings) == 0 {
+		b.WriteString(statusStyle.Render("No problems found"))
+		b.WriteString("\n")
+	}
+
+	// Keep the selection visible when the list is taller than the screen
+	visible := m.height - 10
+	if visible < 5 {
+		visible = 5
+	}
+	start := 0
+	if m.findingIdx >= visible {
+		start = m.findingIdx - visible + 1
+	}
+	end := min(start+visible, len(m.findings))
+
+	for i := start; i < end; i++ {
+		f := m.findings[i]
+		where := strings.TrimPrefix(f.Section, "_") + " " + f.Type
+		severity := statusStyle.Render(f.Severity.String())
+		if f.Severity == validate.SeverityError {
+			severity = errorStyle.Render(f.Severity.String())
+		}
+		rule := helpStyle.Render("(" + f.Rule + ")")
+
+		line := fmt.Sprintf("  %-18s %s %s %s", where, severity, f.Message, rule)
+		if i == m.findingIdx {
+			line = selectedStyle.Render(fmt.Sprintf("▸ %-18s ", where)) + severity + " " +
+				selectedStyle.Render(f.Message) + " " + rule
+		}
+		b.WriteString(line)
+		b.WriteString("\n")
+	}
+
+	b.WriteString("\n")
+	help := "[↑/↓] Navigate  [Enter] Go to type  [Esc] Back  [q] Quit"
+	if m.saveBlocked {
+		help = "[↑/↓] Navigate  [Enter] Go to type  [s] Save anyway  [Esc] Back  [q] Quit"
+	}
+	b.WriteString(helpStyle.Render(help))
+
+	return b.String()
+}
@@ -2,12 +2,12 @@ package tui
 
 import (
 	"fmt"
-	"sort"
 	"strconv"
 	"strings"
 
 	"github.com/charmbracelet/lipgloss"
 	"github.com/dyuri/typtui/internal/parser"
+	"github.com/dyuri/typtui/internal/textdiff"
 )
 
 var (
@@ -32,12 +32,20 @@ var (
 			Foreground(lipgloss.Color("196")).
 			Bold(true)
 
+	warningStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("214"))
+
 	helpStyle = lipgloss.NewStyle().
 			Foreground(lipgloss.Color("241"))
 
 	selectedStyle = lipgloss.NewStyle().
 			Foreground(lipgloss.Color("170")).
 			Bold(true)
+
+	// Diff previews
+	diffDeleteStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("196"))
+	diffInsertStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("42"))
+	diffHunkStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("75"))
 )
 
 // View renders the UI
@@ -59,20 +67,102 @@ func (m Model) View() string {
 		return m.viewEditXPM()
 	case ModeConfirmQuit:
 		return m.viewConfirmQuit()
+	case ModeRestoreBackup:
+		return m.viewBackups()
+	case ModeRecover:
+		return m.viewRecover()
+	case ModeFileChanged:
+		return m.viewFileChanged()
+	case ModeValidate:
+		return m.viewValidate()
+	case ModeConflicts:
+		return m.viewConflicts()
+	case ModeImportXPM:
+		return m.viewImport()
+	case ModeExportPNG:
+		return m.viewExport()
+	case ModeStyleCheck:
+		return m.viewStyleCheck()
+	case ModeCompile:
+		return m.viewCompile()
+	case ModeNight:
+		return m.viewNight()
 	default:
 		return m.viewList()
 	}
 }
 
-// viewError renders the error screen
+// viewError renders the error screen, or the list of problems found while
+// loading the file
 func (m Model) viewError() string {
 	var b strings.Builder
 
-	b.WriteString(titleStyle.Render("Error"))
-	b.WriteString("\n\n")
-	b.WriteString(errorStyle.Render(m.err.Error()))
+	if m.err != nil {
+		b.WriteString(titleStyle.Render("Error"))
+		b.WriteString("\n\n")
+		b.WriteString(errorStyle.Render(m.err.Error()))
+		b.WriteString("\n\n")
+		b.WriteString(helpStyle.Render("Press q to quit"))
+		return b.String()
+	}
+
+	errors := 0
+	for _, d := range m.diagnostics {
+		if d.Severity == parser.SeverityError {
+			errors++
+		}
+	}
+	title := fmt.Sprintf("Problems in %s (%d errors, %d warnings)",
+		m.typFile.FilePath, errors, len(m.diagnostics)-errors)
+	b.WriteString(titleStyle.Render(title))
 	b.WriteString("\n\n")
-	b.WriteString(helpStyle.Render("Press q to quit"))
+
+	// Keep the selection visible when the list is taller than the screen
+	visible := m.height - 8
+	if visible < 5 {
+		visible = 5
+	}
+	start := 0
+	if m.diagIdx >= visible {
+		start = m.diagIdx - visible + 1
+	}
+	end := start + visible
+	if end > len(m.diagnostics) {
+		end = len(m.diagnostics)
+	}
+
+	for i := start; i < end; i++ {
+		d := m.diagnostics[i]
+		location := fmt.Sprintf("%d", d.Line)
+		if d.Column > 0 {
+			location += fmt.Sprintf(":%d", d.Column)
+		}
+		severity := statusStyle.Render(d.Severity.String())
+		if d.Severity == parser.SeverityError {
+			severity = errorStyle.Render(d.Severity.String())
+		}
+		where := ""
+		if d.Section != "" {
+			where = "[" + d.Section + "] "
+		}
+
+		line := fmt.Sprintf("  %-8s %s %s%s", location, severity, where, d.Message)
+		if i == m.diagIdx {
+			line = selectedStyle.Render(fmt.Sprintf("▸ %-8s ", location)) + severity + " " +
+				selectedStyle.Render(where+d.Message)
+		}
+		b.WriteString(line)
+		b.WriteString("\n")
+	}
+
+	if m.diagIdx < len(m.diagnostics) && m.diagnostics[m.diagIdx].Hint != "" {
+		b.WriteString("\n")
+		b.WriteString(statusStyle.Render("Hint: " + m.diagnostics[m.diagIdx].Hint))
+		b.WriteString("\n")
+	}
+
+	b.WriteString("\n")
+	b.WriteString(helpStyle.Render("[↑/↓] Navigate  [Enter] Go to type  [Esc] Back to list  [q] Quit"))
 
 	return b.String()
 }
@@ -88,13 +178,28 @@ func (m Model) viewHelp() string {
 	b.WriteString("  q, Ctrl+C    Quit\n")
 	b.WriteString("  ?            Toggle help\n")
 	b.WriteString("  Ctrl+S       Save file to disk\n")
-	b.WriteString("  Tab          Switch between tabs (Points/Lines/Polygons)\n")
+	b.WriteString("  Tab          Switch between tabs (Points/Lines/Polygons/Draw Order)\n")
 	b.WriteString("  ↑/k          Move up\n")
 	b.WriteString("  ↓/j          Move down\n")
 	b.WriteString("  Enter        View details of selected item\n")
+	b.WriteString("  !            Review problems found while loading\n")
+	b.WriteString("  b            Restore a backup of the file\n")
+	b.WriteString("  r            Reload or merge a file that changed on disk\n")
+	b.WriteString("  v            Review validation problems\n")
+	b.WriteString("  s            Cross-check with an mkgmap style\n")
+	b.WriteString("  c            Compile with mkgmap, or show its output\n")
+	b.WriteString("\n")
+	b.WriteString("Draw Order Tab:\n")
+	b.WriteString("  +/→/l        Move polygon type one level up (drawn later)\n")
+	b.WriteString("  -/←/h        Move polygon type one level down\n")
 	b.WriteString("\n")
 	b.WriteString("Detail View:\n")
 	b.WriteString("  e            Edit selected item\n")
+	b.WriteString("  x/X          Edit the day or night icon\n")
+	b.WriteString("  i            Import a PNG as the day or night icon\n")
+	b.WriteString("  p            Export the icon, or a sprite sheet of all icons, to PNG\n")
+	b.WriteString("  n            Generate night colours from the day colours\n")
+	b.WriteString("  C            Simulate protanopia, deuteranopia, tritanopia, achromatopsia\n")
 	b.WriteString("  Ctrl+S       Save file to disk\n")
 	b.WriteString("  Esc          Return to list view\n")
 	b.WriteString("\n")
@@ -102,6 +207,8 @@ func (m Model) viewHelp() string {
 	b.WriteString("  Ctrl+S       Save changes to item and return to detail\n")
 	b.WriteString("  Esc          Cancel editing\n")
 	b.WriteString("  Tab/↑/↓      Navigate between fields\n")
+	b.WriteString("  Ctrl+N/P     Choose a catalogue type matching the Type field\n")
+	b.WriteString("  Enter        Use the chosen type\n")
 	b.WriteString("\n")
 	b.WriteString(helpStyle.Render("Press ? to return to the main view"))
 
@@ -140,12 +247,19 @@ func (m Model) renderHeader() string {
 	if m.modified {
 		fileName += " [Modified]"
 	}
-	return titleStyle.Render("typtui - " + fileName)
+	header := titleStyle.Render("typtui - " + fileName)
+	if summary := m.renderFindingsSummary(); summary != "" {
+		header += "  " + summary
+	}
+	if m.diskChanged {
+		header += "\n" + m.renderDiskBanner()
+	}
+	return header
 }
 
 // renderTabs renders the tab bar
 func (m Model) renderTabs() string {
-	tabs := []string{"Points", "Lines", "Polygons"}
+	tabs := []string{"Points", "Lines", "Polygons", "Draw Order"}
 	var renderedTabs []string
 
 	for i, tab := range tabs {
@@ -178,11 +292,8 @@ func (m Model) renderContent() string {
 					}
 				}
 
-				line := fmt.Sprintf("  %s - %s", point.Type, label)
-				if i == m.selectedIdx {
-					line = selectedStyle.Render("▸ " + point.Type + " - " + label)
-				}
-				b.WriteString(line)
+				name := typeName("point", point.Type, point.SubType)
+				b.WriteString(listEntry(point.Type, name, label, i == m.selectedIdx))
 				b.WriteString("\n")
 			}
 		}
@@ -200,11 +311,8 @@ func (m Model) renderContent() string {
 					}
 				}
 
-				text := fmt.Sprintf("  %s - %s", line.Type, label)
-				if i == m.selectedIdx {
-					text = selectedStyle.Render("▸ " + line.Type + " - " + label)
-				}
-				b.WriteString(text)
+				name := typeName("line", line.Type, "")
+				b.WriteString(listEntry(line.Type, name, label, i == m.selectedIdx))
 				b.WriteString("\n")
 			}
 		}
@@ -222,14 +330,52 @@ func (m Model) renderContent() string {
 					}
 				}
 
-				line := fmt.Sprintf("  %s - %s", polygon.Type, label)
-				if i == m.selectedIdx {
-					line = selectedStyle.Render("▸ " + polygon.Type + " - " + label)
-				}
-				b.WriteString(line)
+				name := typeName("polygon", polygon.Type, "")
+				b.WriteString(listEntry(polygon.Type, name, label, i == m.selectedIdx))
 				b.WriteString("\n")
 			}
 		}
+
+	case TabDrawOrder:
+		b.WriteString(m.renderDrawOrder())
+	}
+
+	return b.String()
+}
+
+// renderDrawOrder renders the polygon draw order grouped by level, highest
+// level (drawn on top) last
+func (m Model) renderDrawOrder() string {
+	var b strings.Builder
+
+	rows := m.drawOrderRows()
+	if len(rows) == 0 {
+		return statusStyle.Render("No polygons defined")
+	}
+
+	labels := make(map[string]string)
+	for _, polygon := range m.typFile.Polygons {
+		labels[polygon.Type] = polygon.Labels["0x04"]
+	}
+
+	level := -1
+	for i, row := range rows {
+		if row.level != level {
+			level = row.level
+			if i > 0 {
+				b.WriteString("\n")
+			}
+			if level == 0 {
+				b.WriteString(statusStyle.Render("Not in draw order"))
+			} else {
+				b.WriteString(titleStyle.Render(fmt.Sprintf("Level %d", level)))
+			}
+			b.WriteString("\n")
+		}
+
+		name := typeName("polygon", row.typ, "")
+		b.WriteString(listEntry(row.typ, name, labels[row.typ], i == m.selectedIdx))
+		b.WriteString("\n")
 	}
 
 	return b.String()
@@ -238,6 +384,9 @@ func (m Model) renderContent() string {
 // renderFooter renders the footer with help text
 func (m Model) renderFooter() string {
 	footer := "[Tab] Switch  [↑/↓] Navigate  [Enter] Details  [Ctrl+S] Save  [?] Help  [q] Quit"
+	if m.activeTab == TabDrawOrder {
+		footer = "[Tab] Switch  [↑/↓] Navigate  [+/-] Move level  [Ctrl+S] Save  [?] Help  [q] Quit"
+	}
 
 	// Show status message if present
 	if m.status != "" {
@@ -265,23 +414,33 @@ func (m Model) viewDetail() string {
 	b.WriteString("\n\n")
 
 	// Detail content based on active tab
+	var section string
 	switch m.activeTab {
 	case TabPoints:
+		section = "_point"
 		if m.selectedIdx < len(m.typFile.Points) {
 			b.WriteString(m.renderPointDetail(m.typFile.Points[m.selectedIdx]))
 		}
 	case TabLines:
+		section = "_line"
 		if m.selectedIdx < len(m.typFile.Lines) {
 			b.WriteString(m.renderLineDetail(m.typFile.Lines[m.selectedIdx]))
 		}
 	case TabPolygons:
+		section = "_polygon"
 		if m.selectedIdx < len(m.typFile.Polygons) {
 			b.WriteString(m.renderPolygonDetail(m.typFile.Polygons[m.selectedIdx]))
 		}
 	}
 
+	// Validation problems of this type, updated as it is edited
+	if problems := m.renderTypeFindings(section, m.selectedIdx); problems != "" {
+		b.WriteString("\n\n")
+		b.WriteString(problems)
+	}
+
 	b.WriteString("\n\n")
-	b.WriteString(helpStyle.Render("[e] Edit  [x] Edit XPM  [Esc] Back  [?] Help  [q] Quit"))
+	b.WriteString(helpStyle.Render("[e] Edit  [x/X] Edit day/night icon  [i] Import image  [p] Export PNG  [n] Night colours  [C] Colour vision  [Esc] Back  [?] Help  [q] Quit"))
 
 	return b.String()
 }
@@ -319,13 +478,24 @@ func (m Model) viewEdit() string {
 	for i, input := range m.inputs {
 		b.WriteString(input.View())
 		b.WriteString("\n")
+		if i == 0 && len(m.typeSuggestions) > 0 {
+			b.WriteString(m.renderTypeSuggestions())
+		}
 		if i < len(m.inputs)-1 {
 			b.WriteString("\n")
 		}
 	}
 
 	b.WriteString("\n\n")
-	b.WriteString(helpStyle.Render("[Ctrl+S] Save  [Esc] Cancel  [Tab/↑/↓] Navigate fields"))
+	if m.formErr != "" {
+		b.WriteString(errorStyle.Render(m.formErr))
+		b.WriteString("\n\n")
+	}
+	if len(m.typeSuggestions) > 0 {
+		b.WriteString(helpStyle.Render("[Ctrl+N/Ctrl+P] Choose type  [Enter] Use type  [Ctrl+S] Save  [Esc] Cancel  [Tab/↑/↓] Navigate fields"))
+	} else {
+		b.WriteString(helpStyle.Render("[Ctrl+S] Save  [Esc] Cancel  [Tab/↑/↓] Navigate fields"))
+	}
 
 	return b.String()
 }
@@ -353,7 +523,7 @@ func (m Model) viewEditXPM() string {
 	var b strings.Builder
 
 	// Header (fixed, not scrollable)
-	b.WriteString(titleStyle.Render(fmt.Sprintf("Edit %s", m.editingXPMType)))
+	b.WriteString(titleStyle.Render(fmt.Sprintf("Edit %s%s", m.editingXPMType, m.simulationNote())))
 	b.WriteString("\n\n")
 
 	// Scrollable content area (content already set in viewport via updateXPMViewportContent)
@@ -368,7 +538,7 @@ func (m Model) viewEditXPM() string {
 		b.WriteString("\n\n")
 		b.WriteString(helpStyle.Render("[Enter] Save Color  [Esc] Cancel"))
 	} else {
-		b.WriteString(helpStyle.Render("[↑/↓/PgUp/PgDn] Scroll  [Tab] Navigate Colors  [Enter] Edit  [Esc] Back  [Ctrl+S] Save"))
+		b.WriteString(helpStyle.Render("[↑/↓/PgUp/PgDn] Scroll  [Tab] Navigate Colors  [Enter] Edit  [C] Colour vision  [Esc] Back  [Ctrl+S] Save"))
 	}
 
 	return b.String()
@@ -390,9 +560,7 @@ func (m Model) renderPointDetail(point parser.PointType) string {
 	b.WriteString("\n\n")
 
 	// Type
-	b.WriteString(selectedStyle.Render("Type: "))
-	b.WriteString(point.Type)
-	b.WriteString("\n\n")
+	b.WriteString(renderTypeInfo("point", point.Type, point.SubType))
 
 	// Labels
 	if len(point.Labels) > 0 {
@@ -405,6 +573,14 @@ func (m Model) renderPointDetail(point parser.PointType) string {
 		b.WriteString("\n")
 	}
 
+	// Extended labels
+	if point.ExtendedLabels {
+		b.WriteString("Extended Labels: Yes\n\n")
+	}
+
+	// Font
+	b.WriteString(renderFont(point.FontStyle, point.DayFontColor, point.NightFontColor))
+
 	// DayColors
 	if len(point.DayColors) > 0 {
 		b.WriteString(selectedStyle.Render("Day Colors:"))
@@ -462,9 +638,7 @@ func (m Model) renderLineDetail(line parser.LineType) string {
 	b.WriteString("\n\n")
 
 	// Type
-	b.WriteString(selectedStyle.Render("Type: "))
-	b.WriteString(line.Type)
-	b.WriteString("\n\n")
+	b.WriteString(renderTypeInfo("line", line.Type, ""))
 
 	// Labels
 	if len(line.Labels) > 0 {
@@ -484,11 +658,20 @@ func (m Model) renderLineDetail(line parser.LineType) string {
 	if line.BorderWidth > 0 {
 		b.WriteString(fmt.Sprintf("Border Width: %d\n", line.BorderWidth))
 	}
+	if line.NightLineWidth > 0 || line.NightBorderWidth > 0 {
+		b.WriteString(fmt.Sprintf("Night Widths: %d/%d\n", line.NightLineWidth, line.NightBorderWidth))
+	}
 	if line.LineStyle != "" {
 		b.WriteString(fmt.Sprintf("Line Style: %s\n", line.LineStyle))
 	}
+	if line.ExtendedLabels {
+		b.WriteString("Extended Labels: Yes\n")
+	}
 	b.WriteString("\n")
 
+	// Font
+	b.WriteString(renderFont(line.FontStyle, line.DayFontColor, line.NightFontColor))
+
 	// DayXpm
 	if line.DayXpm != nil {
 		b.WriteString(selectedStyle.Render("Day Pattern:"))
@@ -516,9 +699,7 @@ func (m Model) renderPolygonDetail(polygon parser.PolygonType) string {
 	b.WriteString("\n\n")
 
 	// Type
-	b.WriteString(selectedStyle.Render("Type: "))
-	b.WriteString(polygon.Type)
-	b.WriteString("\n\n")
+	b.WriteString(renderTypeInfo("polygon", polygon.Type, ""))
 
 	// Labels
 	if len(polygon.Labels) > 0 {
@@ -536,6 +717,12 @@ func (m Model) renderPolygonDetail(polygon parser.PolygonType) string {
 		b.WriteString("Extended Labels: Yes\n\n")
 	}
 
+	// Font and contour
+	b.WriteString(renderFont(polygon.FontStyle, polygon.DayFontColor, polygon.NightFontColor))
+	if polygon.ContourColor.Hex != "" {
+		b.WriteString(fmt.Sprintf("Contour Color: %s\n\n", renderColorWithPreview(polygon.ContourColor.Hex)))
+	}
+
 	// DayXpm
 	if polygon.DayXpm != nil {
 		b.WriteString(selectedStyle.Render("Day Pattern:"))
@@ -555,6 +742,26 @@ func (m Model) renderPolygonDetail(polygon parser.PolygonType) string {
 	return b.String()
 }
 
+// renderFont renders the font style and label colours, if any are set
+func renderFont(style string, day, night parser.Color) string {
+	if style == "" && day.Hex == "" && night.Hex == "" {
+		return ""
+	}
+
+	var b strings.Builder
+	if style != "" {
+		b.WriteString(fmt.Sprintf("Font Style: %s\n", style))
+	}
+	if day.Hex != "" {
+		b.WriteString(fmt.Sprintf("Day Font Color: %s\n", renderColorWithPreview(day.Hex)))
+	}
+	if night.Hex != "" {
+		b.WriteString(fmt.Sprintf("Night Font Color: %s\n", renderColorWithPreview(night.Hex)))
+	}
+	b.WriteString("\n")
+	return b.String()
+}
+
 // renderXPMInfo renders information about an XPM icon
 func (m Model) renderXPMInfo(xpm *parser.XPMIcon) string {
 	var b strings.Builder
@@ -566,36 +773,33 @@ func (m Model) renderXPMInfo(xpm *parser.XPMIcon) string {
 	if len(xpm.Palette) > 0 {
 		b.WriteString("  Color Palette:\n")
 
-		// Convert to sorted slice for consistent ordering
-		type colorEntry struct {
-			char  string
-			color parser.Color
-		}
-		var colors []colorEntry
-		for char, color := range xpm.Palette {
-			colors = append(colors, colorEntry{char, color})
-		}
-
-		// Sort alphabetically by character
-		sort.Slice(colors, func(i, j int) bool {
-			return colors[i].char < colors[j].char
-		})
-
-		for _, entry := range colors {
-			colorDisplay := renderColorWithPreview(entry.color.Hex)
-			b.WriteString(fmt.Sprintf("    %s → %s\n", entry.char, colorDisplay))
+		// Palette entries in file order
+		for _, entry := range xpm.Palette {
+			colorDisplay := renderColorWithPreview(entry.Color.Hex)
+			if seen := m.seenColor(entry.Color.Hex); seen != entry.Color.Hex {
+				colorDisplay += " seen as " + renderColorWithPreview(seen)
+			}
+			b.WriteString(fmt.Sprintf("    %q → %s%s\n", entry.Key, colorDisplay, colorName(entry.Color)))
 		}
 	}
 
 	// Render the icon preview with colors
 	if len(xpm.Data) > 0 {
-		b.WriteString("\n  Icon Preview:\n")
-		b.WriteString(renderXPMPreview(xpm))
+		b.WriteString("\n  Icon Preview" + m.simulationNote() + ":\n")
+		b.WriteString(renderXPMPreview(m.seenIcon(xpm)))
 	}
 
 	return b.String()
 }
 
+// colorName returns " (name)" for a named palette colour
+func colorName(c parser.Color) string {
+	if c.Name == "" {
+		return ""
+	}
+	return " (" + c.Name + ")"
+}
+
 // renderXPMPreview renders the XPM pixel data with colors applied
 func renderXPMPreview(xpm *parser.XPMIcon) string {
 	var b strings.Builder
@@ -607,16 +811,14 @@ func renderXPMPreview(xpm *parser.XPMIcon) string {
 		b.WriteString("  ")
 		row := xpm.Data[i]
 
-		// Process each character/pixel in the row
-		for _, char := range row {
-			charStr := string(char)
-
-			// Look up the color for this character
-			if color, ok := xpm.Palette[charStr]; ok {
-				b.WriteString(renderPixelWithColor(color.Hex, charStr))
+		// Process each pixel in the row, a pixel is CharsPerPixel characters
+		for _, pixel := range xpm.Pixels(row) {
+			// Look up the color for this pixel
+			if color, ok := xpm.Palette.Lookup(pixel); ok {
+				b.WriteString(renderPixelWithColor(color.Hex, pixel))
 			} else {
-				// Unknown character, show as gray
-				b.WriteString(renderPixelWithColor("#808080", charStr))
+				// Unknown pixel, show as gray
+				b.WriteString(renderPixelWithColor("#808080", pixel))
 			}
 		}
 
@@ -701,46 +903,32 @@ func renderColorWithPreview(hexColor string) string {
 
 // getLanguageName returns a human-readable language name for a language code
 func getLanguageName(code string) string {
-	// Common language codes used in Garmin TYP files
-	languages := map[string]string{
-		"0x01": "French",
-		"0x02": "German",
-		"0x03": "Dutch",
-		"0x04": "English",
-		"0x05": "Italian",
-		"0x06": "Finnish",
-		"0x07": "Swedish",
-		"0x08": "Spanish",
-		"0x09": "Basque",
-		"0x0a": "Catalan",
-		"0x0b": "Galician",
-		"0x0c": "Welsh",
-		"0x0d": "Gaelic",
-		"0x0e": "Danish",
-		"0x0f": "Norwegian",
-		"0x10": "Portuguese",
-		"0x11": "Slovak",
-		"0x12": "Czech",
-		"0x13": "Croatian",
-		"0x14": "Hungarian",
-		"0x15": "Polish",
-		"0x16": "Turkish",
-		"0x17": "Greek",
-		"0x18": "Slovenian",
-		"0x19": "Russian",
-		"0x1a": "Estonian",
-		"0x1b": "Latvian",
-		"0x1c": "Romanian",
-		"0x1d": "Albanian",
-		"0x1e": "Bosnian",
-		"0x1f": "Lithuanian",
-		"0x20": "Serbian",
-		"0x21": "Macedonian",
-		"0x22": "Bulgarian",
-	}
-
-	if name, ok := languages[code]; ok {
+	if name, ok := parser.LanguageName(code); ok {
 		return name
 	}
 	return "Unknown"
 }
+
+// renderHunks renders diff hunks in colour, cut to at most maxLines lines
+func renderHunks(hunks []textdiff.Hunk, maxLines int) string {
+	var lines []string
+	for _, hunk := range hunks {
+		lines = append(lines, diffHunkStyle.Render(hunk.Header()))
+		for _, edit := range hunk.Edits {
+			text := edit.Prefix() + edit.Line
+			switch edit.Op {
+			case textdiff.Delete:
+				text = diffDeleteStyle.Render(text)
+			case textdiff.Insert:
+				text = diffInsertStyle.Render(text)
+			}
+			lines = append(lines, text)
+		}
+	}
+
+	if len(lines) > maxLines {
+		more := len(lines) - maxLines + 1
+		lines = append(lines[:maxLines-1], statusStyle.Render(fmt.Sprintf("… %d more lines", more)))
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
@@ -0,0 +1,170 @@
+package typdiff
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/dyuri/typtui/internal/parser"
+)
+
+// iconGap separates the two icons drawn by SideBySide
+const iconGap = "   "
+
+// SideBySide draws two versions of an icon next to each other and returns
+// the lines. With color pixels are 24-bit ANSI colour blocks; without, the
+// pixel characters are printed, for logs and terminals without colour. A
+// nil icon leaves its side empty. Icons without pixels show their palette.
+func SideBySide(a, b *parser.XPMIcon, color bool) []string {
+	left, right := drawIcon(a, color), drawIcon(b, color)
+	width := iconWidth(a, color)
+
+	lines := make([]string, max(len(left), len(right)))
+	for i := range lines {
+		var l, r string
+		if i < len(left) {
+			l = left[i]
+		}
+		if i < len(right) {
+			r = right[i]
+		}
+		lines[i] = l + strings.Repeat(" ", width-visibleWidth(a, i, color)) + iconGap + r
+	}
+	return lines
+}
+
+// iconDetail describes how an icon changed: its size, the colours of its
+// palette and the number of pixels. It is "" unless both icons are set.
+func iconDetail(a, b *parser.XPMIcon) string {
+	if a == nil || b == nil {
+		return ""
+	}
+	var parts []string
+	if a.Width != b.Width || a.Height != b.Height {
+		parts = append(parts, fmt.Sprintf("size %dx%d → %dx%d", a.Width, a.Height, b.Width, b.Height))
+	}
+	for _, entry := range b.Palette {
+		old, ok := a.Palette.Lookup(entry.Key)
+		switch {
+		case !ok:
+			parts = append(parts, fmt.Sprintf("colour %q added: %s", entry.Key, entry.Color.Hex))
+		case !strings.EqualFold(old.Hex, entry.Color.Hex):
+			parts = append(parts, fmt.Sprintf("colour %q %s → %s", entry.Key, old.Hex, entry.Color.Hex))
+		}
+	}
+	for _, entry := range a.Palette {
+		if _, ok := b.Palette.Lookup(entry.Key); !ok {
+			parts = append(parts, fmt.Sprintf("colour %q removed", entry.Key))
+		}
+	}
+	if a.Width == b.Width && a.Height == b.Height {
+		changed := 0
+		for i := 0; i < len(a.Data) && i < len(b.Data); i++ {
+			pa, pb := a.Pixels(a.Data[i]), b.Pixels(b.Data[i])
+			for j := 0; j < len(pa) || j < len(pb); j++ {
+				if j >= len(pa) || j >= len(pb) || pa[j] != pb[j] {
+					changed++
+				}
+			}
+		}
+		switch {
+		case changed == 1:
+			parts = append(parts, "1 pixel changed")
+		case changed > 1:
+			parts = append(parts, fmt.Sprintf("%d pixels changed", changed))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// drawIcon returns the lines of one icon
+func drawIcon(x *parser.XPMIcon, color bool) []string {
+	if x == nil {
+		return []string{"(none)"}
+	}
+	if x.Width == 0 || x.Height == 0 {
+		var b strings.Builder
+		for _, entry := range x.Palette {
+			b.WriteString(drawPixel(entry.Key, entry.Color.Hex, color))
+		}
+		return []string{b.String()}
+	}
+	lines := make([]string, len(x.Data))
+	for i, row := range x.Data {
+		var b strings.Builder
+		for _, pixel := range x.Pixels(row) {
+			hex := "#808080" // not in the palette
+			if c, ok := x.Palette.Lookup(pixel); ok {
+				hex = c.Hex
+			}
+			b.WriteString(drawPixel(pixel, hex, color))
+		}
+		lines[i] = b.String()
+	}
+	return lines
+}
+
+// drawPixel draws one pixel, two cells wide in colour
+func drawPixel(key, hex string, color bool) string {
+	if !color {
+		return key
+	}
+	r, g, b, ok := parseHex(hex)
+	if !ok {
+		// Transparent, a dim dot keeps the shape readable
+		return "\x1b[2m··\x1b[0m"
+	}
+	return fmt.Sprintf("\x1b[38;2;%d;%d;%dm██\x1b[0m", r, g, b)
+}
+
+// parseHex reads a #RRGGBB colour
+func parseHex(hex string) (r, g, b int, ok bool) {
+	hex = strings.TrimPrefix(hex, "#")
+	if len(hex) != 6 {
+		return 0, 0, 0, false
+	}
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return 0, 0, 0, false
+	}
+	return int(v >> 16), int(v >> 8 & 0xff), int(v & 0xff), true
+}
+
+// iconWidth returns the width in cells of the widest line of an icon
+func iconWidth(x *parser.XPMIcon, color bool) int {
+	width := 0
+	for i := range drawIcon(x, color) {
+		width = max(width, visibleWidth(x, i, color))
+	}
+	return width
+}
+
+// visibleWidth returns the width in cells of line i of an icon
+func visibleWidth(x *parser.XPMIcon, i int, color bool) int {
+	if x == nil {
+		if i == 0 {
+			return len("(none)")
+		}
+		return 0
+	}
+	if x.Width == 0 || x.Height == 0 {
+		if i > 0 {
+			return 0
+		}
+		if color {
+			return 2 * len(x.Palette)
+		}
+		n := 0
+		for _, entry := range x.Palette {
+			n += len(entry.Key)
+		}
+		return n
+	}
+	if i >= len(x.Data) {
+		return 0
+	}
+	if color {
+		return 2 * len(x.Pixels(x.Data[i]))
+	}
+	return len(x.Data[i])
+}
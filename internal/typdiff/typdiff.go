@@ -0,0 +1,377 @@
+// Package typdiff compares two TYP files type by type. Types are matched
+// by kind and type code, so moving a type within the file or spelling its
+// code differently (0x2f06 or Type=0x2f with SubType=0x06) is not a change,
+// and changes are reported per property rather than per line.
+package typdiff
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/dyuri/typtui/internal/parser"
+)
+
+// Change is what happened to a type
+type Change int
+
+const (
+	Added Change = iota
+	Removed
+	Changed
+)
+
+func (c Change) String() string {
+	switch c {
+	case Added:
+		return "added"
+	case Removed:
+		return "removed"
+	case Changed:
+		return "changed"
+	}
+	return fmt.Sprintf("Change(%d)", int(c))
+}
+
+// MarshalText writes the change by name in JSON output
+func (c Change) MarshalText() ([]byte, error) {
+	return []byte(c.String()), nil
+}
+
+// Property is a property that differs. Old or New is "" when the property
+// is only set on one side. Icons keep both XPMs for drawing them.
+type Property struct {
+	Name    string          `json:"property"`
+	Old     string          `json:"old"`
+	New     string          `json:"new"`
+	Detail  string          `json:"detail,omitempty"` // what changed in an icon
+	OldIcon *parser.XPMIcon `json:"-"`
+	NewIcon *parser.XPMIcon `json:"-"`
+}
+
+// Icon reports whether the property is an XPM
+func (p Property) Icon() bool {
+	return p.OldIcon != nil || p.NewIcon != nil
+}
+
+// Type is a type that was added, removed or changed
+type Type struct {
+	Kind       string     `json:"kind"` // "point", "line" or "polygon"
+	Type       string     `json:"type"` // the type code in mkgmap form
+	Label      string     `json:"label,omitempty"`
+	Change     Change     `json:"change"`
+	Properties []Property `json:"properties,omitempty"`
+}
+
+// Summary counts the changed types
+type Summary struct {
+	Added   int `json:"added"`
+	Removed int `json:"removed"`
+	Changed int `json:"changed"`
+}
+
+// Diff is the difference between two files
+type Diff struct {
+	Old     string     `json:"old"`
+	New     string     `json:"new"`
+	Header  []Property `json:"header,omitempty"`
+	Types   []Type     `json:"types"`
+	Summary Summary    `json:"summary"`
+}
+
+// Empty reports whether the files are the same
+func (d *Diff) Empty() bool {
+	return len(d.Header) == 0 && len(d.Types) == 0
+}
+
+// entry is a type with its properties in comparable form
+type entry struct {
+	kind  string
+	code  string
+	label string
+	props []prop
+}
+
+// prop is a property value. Properties that are not set are left out.
+type prop struct {
+	name  string
+	value string
+	icon  *parser.XPMIcon
+}
+
+// Compare returns the differences between two files
+func Compare(a, b *parser.TYPFile) *Diff {
+	d := &Diff{Old: a.FilePath, New: b.FilePath, Types: []Type{}}
+	d.Header = compareProps(headerProps(a), headerProps(b))
+
+	// Types are matched by kind and code; repeated codes pair up in order
+	oldEntries := entries(a)
+	byKey := make(map[string][]entry)
+	for _, e := range oldEntries {
+		byKey[e.kind+" "+e.code] = append(byKey[e.kind+" "+e.code], e)
+	}
+	matched := make(map[string]int)
+	for _, e := range entries(b) {
+		key := e.kind + " " + e.code
+		if matched[key] == len(byKey[key]) {
+			d.Types = append(d.Types, Type{Kind: e.kind, Type: e.code, Label: e.label, Change: Added,
+				Properties: compareProps(nil, e.props)})
+			continue
+		}
+		old := byKey[key][matched[key]]
+		matched[key]++
+		if props := compareProps(old.props, e.props); len(props) > 0 {
+			d.Types = append(d.Types, Type{Kind: e.kind, Type: e.code, Label: e.label, Change: Changed, Properties: props})
+		}
+	}
+	for key, list := range byKey {
+		for _, e := range list[matched[key]:] {
+			d.Types = append(d.Types, Type{Kind: e.kind, Type: e.code, Label: e.label, Change: Removed,
+				Properties: compareProps(e.props, nil)})
+		}
+	}
+
+	sort.SliceStable(d.Types, func(i, j int) bool {
+		ti, tj := d.Types[i], d.Types[j]
+		if kindOrder[ti.Kind] != kindOrder[tj.Kind] {
+			return kindOrder[ti.Kind] < kindOrder[tj.Kind]
+		}
+		if typeOrder(ti.Type) != typeOrder(tj.Type) {
+			return typeOrder(ti.Type) < typeOrder(tj.Type)
+		}
+		if ti.Change != tj.Change {
+			return ti.Change < tj.Change
+		}
+		return ti.Label < tj.Label
+	})
+	for _, t := range d.Types {
+		switch t.Change {
+		case Added:
+			d.Summary.Added++
+		case Removed:
+			d.Summary.Removed++
+		case Changed:
+			d.Summary.Changed++
+		}
+	}
+	return d
+}
+
+// kindOrder sorts types the way the sections are written
+var kindOrder = map[string]int{"point": 0, "line": 1, "polygon": 2}
+
+// typeOrder returns the sort key of a type code
+func typeOrder(code string) int {
+	tc, err := parser.ParseTypeCode(code, "")
+	if err != nil {
+		return 1 << 30
+	}
+	key := tc.Type<<8 | tc.SubType
+	if tc.Extended {
+		key |= 0x10000
+	}
+	return key
+}
+
+// compareProps lists the properties that differ, in the order of b and then
+// those only in a
+func compareProps(a, b []prop) []Property {
+	old := make(map[string]prop, len(a))
+	for _, p := range a {
+		old[p.name] = p
+	}
+	var diffs []Property
+	seen := make(map[string]bool, len(b))
+	for _, p := range b {
+		seen[p.name] = true
+		o, ok := old[p.name]
+		if ok && o.value == p.value {
+			continue
+		}
+		diffs = append(diffs, Property{Name: p.name, Old: summary(o), New: summary(p),
+			Detail: iconDetail(o.icon, p.icon), OldIcon: o.icon, NewIcon: p.icon})
+	}
+	for _, p := range a {
+		if !seen[p.name] {
+			diffs = append(diffs, Property{Name: p.name, Old: summary(p), OldIcon: p.icon})
+		}
+	}
+	return diffs
+}
+
+// summary returns the value of a property for showing. Icons are
+// described, as their full value is only used for comparing.
+func summary(p prop) string {
+	if p.icon == nil {
+		return p.value
+	}
+	x := p.icon
+	if x.Width == 0 || x.Height == 0 {
+		colors := make([]string, len(x.Palette))
+		for i, entry := range x.Palette {
+			colors[i] = entry.Color.Hex
+		}
+		return "colours " + strings.Join(colors, " ")
+	}
+	return fmt.Sprintf("%dx%d, %d colours", x.Width, x.Height, len(x.Palette))
+}
+
+// headerProps lists the header of a file
+func headerProps(t *parser.TYPFile) []prop {
+	h := t.Header
+	return []prop{
+		{name: "FID", value: strconv.Itoa(h.FID)},
+		{name: "ProductCode", value: strconv.Itoa(h.ProductCode)},
+		{name: "CodePage", value: strconv.Itoa(h.CodePage)},
+		{name: "MapID", value: strconv.Itoa(h.MapID)},
+	}
+}
+
+// entries lists the types of a file with their properties
+func entries(t *parser.TYPFile) []entry {
+	var list []entry
+	add := func(kind, typ, subType string, labels map[string]string, props []prop) {
+		code := typ
+		if tc, err := parser.ParseTypeCode(typ, subType); err == nil {
+			code = tc.String()
+		} else if subType != "" {
+			code += "/" + subType
+		}
+		props = append(labelProps(labels), props...)
+		list = append(list, entry{kind: kind, code: code, label: mainLabel(labels), props: withoutEmpty(props)})
+	}
+
+	for _, p := range t.Points {
+		add("point", p.Type, p.SubType, p.Labels, []prop{
+			flagProp("ExtendedLabels", p.ExtendedLabels),
+			xpmProp("DayXpm", p.DayXpm),
+			xpmProp("NightXpm", p.NightXpm),
+			colorsProp("DayCustomColor", p.DayColors),
+			colorsProp("NightCustomColor", p.NightColors),
+			{name: "FontStyle", value: p.FontStyle},
+			colorProp("DayFontColor", p.DayFontColor),
+			colorProp("NightFontColor", p.NightFontColor),
+			colorProp("CustomColor", p.CustomColor),
+		})
+	}
+	for _, l := range t.Lines {
+		add("line", l.Type, "", l.Labels, []prop{
+			flagProp("ExtendedLabels", l.ExtendedLabels),
+			intProp("LineWidth", l.LineWidth),
+			intProp("BorderWidth", l.BorderWidth),
+			intProp("NightLineWidth", l.NightLineWidth),
+			intProp("NightBorderWidth", l.NightBorderWidth),
+			xpmProp("Xpm", l.DayXpm),
+			xpmProp("NightXpm", l.NightXpm),
+			flagProp("UseOrientation", l.UseOrientation),
+			{name: "LineStyle", value: l.LineStyle},
+			colorsProp("DayCustomColor", l.DayColors),
+			colorsProp("NightCustomColor", l.NightColors),
+			{name: "FontStyle", value: l.FontStyle},
+			colorProp("DayFontColor", l.DayFontColor),
+			colorProp("NightFontColor", l.NightFontColor),
+			colorProp("CustomColor", l.CustomColor),
+		})
+	}
+	for _, p := range t.Polygons {
+		add("polygon", p.Type, "", p.Labels, []prop{
+			flagProp("ExtendedLabels", p.ExtendedLabels),
+			intProp("DrawOrder", t.DrawOrder.Level(p.Type)),
+			xpmProp("Xpm", p.DayXpm),
+			xpmProp("NightXpm", p.NightXpm),
+			colorsProp("DayCustomColor", p.DayColors),
+			colorsProp("NightCustomColor", p.NightColors),
+			{name: "FontStyle", value: p.FontStyle},
+			colorProp("DayFontColor", p.DayFontColor),
+			colorProp("NightFontColor", p.NightFontColor),
+			colorProp("ContourColor", p.ContourColor),
+			colorProp("CustomColor", p.CustomColor),
+		})
+	}
+	return list
+}
+
+// withoutEmpty drops the properties that are not set
+func withoutEmpty(props []prop) []prop {
+	kept := props[:0]
+	for _, p := range props {
+		if p.value != "" {
+			kept = append(kept, p)
+		}
+	}
+	return kept
+}
+
+// labelProps returns one String property per language, in language order
+func labelProps(labels map[string]string) []prop {
+	codes := make([]string, 0, len(labels))
+	for code := range labels {
+		codes = append(codes, code)
+	}
+	sort.Slice(codes, func(i, j int) bool {
+		a, _ := strconv.ParseInt(codes[i], 0, 32)
+		b, _ := strconv.ParseInt(codes[j], 0, 32)
+		return a < b
+	})
+	props := make([]prop, len(codes))
+	for i, code := range codes {
+		props[i] = prop{name: "String " + code, value: strconv.Quote(labels[code])}
+	}
+	return props
+}
+
+// mainLabel returns the English label, or the first one
+func mainLabel(labels map[string]string) string {
+	if label, ok := labels["0x04"]; ok {
+		return label
+	}
+	for _, p := range labelProps(labels) {
+		label, _ := strconv.Unquote(p.value)
+		return label
+	}
+	return ""
+}
+
+func flagProp(name string, v bool) prop {
+	if !v {
+		return prop{name: name}
+	}
+	return prop{name: name, value: "Y"}
+}
+
+func intProp(name string, v int) prop {
+	if v == 0 {
+		return prop{name: name}
+	}
+	return prop{name: name, value: strconv.Itoa(v)}
+}
+
+func colorProp(name string, c parser.Color) prop {
+	return prop{name: name, value: strings.ToUpper(c.Hex)}
+}
+
+func colorsProp(name string, colors []parser.Color) prop {
+	hex := make([]string, len(colors))
+	for i, c := range colors {
+		hex[i] = strings.ToUpper(c.Hex)
+	}
+	return prop{name: name, value: strings.Join(hex, " ")}
+}
+
+// xpmProp compares icons by their header, palette colours and pixels
+func xpmProp(name string, x *parser.XPMIcon) prop {
+	if x == nil {
+		return prop{name: name}
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d %d %d %d", x.Width, x.Height, x.Colors, x.CharsPerPixel)
+	for _, entry := range x.Palette {
+		fmt.Fprintf(&b, "|%s=%s", entry.Key, strings.ToUpper(entry.Color.Hex))
+	}
+	for _, row := range x.Data {
+		b.WriteString("|")
+		b.WriteString(row)
+	}
+	return prop{name: name, value: b.String(), icon: x}
+}
@@ -0,0 +1,95 @@
+package typdiff
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/dyuri/typtui/internal/parser"
+)
+
+func TestCompare(t *testing.T) {
+	oldFile, err := parser.ParseFile("../../testdata/typdiff/old.typ")
+	if err != nil {
+		t.Fatalf("Failed to parse old.typ: %v", err)
+	}
+	newFile, err := parser.ParseFile("../../testdata/typdiff/new.typ")
+	if err != nil {
+		t.Fatalf("Failed to parse new.typ: %v", err)
+	}
+	d := Compare(oldFile, newFile)
+
+	if len(d.Header) != 1 || d.Header[0].Name != "FID" || d.Header[0].Old != "1" || d.Header[0].New != "2" {
+		t.Errorf("Unexpected header changes: %+v", d.Header)
+	}
+	if d.Summary != (Summary{Added: 1, Removed: 1, Changed: 1}) {
+		t.Errorf("Unexpected summary: %+v", d.Summary)
+	}
+
+	var got []string
+	for _, typ := range d.Types {
+		line := typ.Change.String() + " " + typ.Kind + " " + typ.Type
+		if typ.Change == Changed {
+			for _, p := range typ.Properties {
+				line += "; " + p.Name + ": " + p.Old + " -> " + p.New
+				if p.Detail != "" {
+					line += " (" + p.Detail + ")"
+				}
+			}
+		}
+		got = append(got, line)
+	}
+	want := []string{
+		`changed point 0x2f06; String 0x01:  -> "Banque"; String 0x04: "Bank" -> "Money"; DayXpm: 2x2, 2 colours -> 2x2, 2 colours (colour "a" #FF0000 → #00FF00, 1 pixel changed)`,
+		"removed line 0x01",
+		"added line 0x02",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Unexpected types:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	data, err := json.Marshal(d)
+	if err != nil {
+		t.Fatalf("Failed to marshal: %v", err)
+	}
+	if !strings.Contains(string(data), `"change":"removed"`) || strings.Contains(string(data), "OldIcon") {
+		t.Errorf("Unexpected JSON: %s", data)
+	}
+}
+
+func TestCompareSame(t *testing.T) {
+	a, err := parser.ParseFile("../../testdata/typdiff/old.typ")
+	if err != nil {
+		t.Fatalf("Failed to parse old.typ: %v", err)
+	}
+	b, err := parser.ParseFile("../../testdata/typdiff/old.typ")
+	if err != nil {
+		t.Fatalf("Failed to parse old.typ: %v", err)
+	}
+	d := Compare(a, b)
+	if !d.Empty() {
+		t.Errorf("Expected no differences, got %+v", d)
+	}
+}
+
+func TestSideBySide(t *testing.T) {
+	oldFile, err := parser.ParseFile("../../testdata/typdiff/old.typ")
+	if err != nil {
+		t.Fatalf("Failed to parse old.typ: %v", err)
+	}
+	newFile, err := parser.ParseFile("../../testdata/typdiff/new.typ")
+	if err != nil {
+		t.Fatalf("Failed to parse new.typ: %v", err)
+	}
+	d := Compare(oldFile, newFile)
+	icon := d.Types[0].Properties[2]
+
+	got := strings.Join(SideBySide(icon.OldIcon, icon.NewIcon, false), "\n")
+	want := "ab   ab\nba   bb"
+	if got != want {
+		t.Errorf("Unexpected icons:\n%s\nwant:\n%s", got, want)
+	}
+	if got := SideBySide(nil, icon.NewIcon, false); got[0] != "(none)   ab" || got[1] != "         bb" {
+		t.Errorf("Unexpected icons with one side missing: %q", got)
+	}
+}
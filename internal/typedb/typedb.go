@@ -0,0 +1,178 @@
+// Package typedb is a catalogue of the standard Garmin type codes: the
+// points, lines and polygons devices know, including the extended 0x1xxxx
+// codes, each with a canonical name, a category and the OSM tags the
+// mkgmap default style maps to it.
+//
+// The catalogue is embedded in the binary, so it works offline. It is a
+// guide rather than a rule: any code can be styled in a TYP file.
+package typedb
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"strings"
+
+	"github.com/dyuri/typtui/internal/parser"
+)
+
+//go:embed types.tsv
+var source string
+
+// TypeInfo is a type code in the catalogue
+type TypeInfo struct {
+	Kind     string   `json:"kind"`     // "point", "line" or "polygon"
+	Code     string   `json:"code"`     // in the form mkgmap writes it, such as 0x2f06
+	Category string   `json:"category"` // such as "Services"
+	Name     string   `json:"name"`     // such as "Bank or ATM"
+	OSMTags  []string `json:"osm_tags"` // key=value pairs, the most typical first
+}
+
+// Tags returns the OSM tags separated by spaces
+func (t TypeInfo) Tags() string {
+	return strings.Join(t.OSMTags, " ")
+}
+
+// types holds the catalogue in file order, which is by kind and code
+var types = mustLoad(source)
+
+// mustLoad parses the embedded catalogue; it panics on a broken line since
+// the data ships with the binary
+func mustLoad(data string) []TypeInfo {
+	var list []TypeInfo
+	scanner := bufio.NewScanner(strings.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Split(text, "\t")
+		if len(fields) != 5 {
+			panic(fmt.Sprintf("typedb: line %d: want 5 fields, got %d", line, len(fields)))
+		}
+		code, err := parser.ParseTypeCode(fields[1], "")
+		if err != nil {
+			panic(fmt.Sprintf("typedb: line %d: %v", line, err))
+		}
+		list = append(list, TypeInfo{
+			Kind:     fields[0],
+			Code:     code.String(),
+			Category: fields[2],
+			Name:     fields[3],
+			OSMTags:  strings.Fields(fields[4]),
+		})
+	}
+	return list
+}
+
+// Lookup returns the catalogue entry of a type given by its Type and
+// SubType values as written in a TYP file; subType may be empty
+func Lookup(kind, typ, subType string) (TypeInfo, bool) {
+	code, err := parser.ParseTypeCode(typ, subType)
+	if err != nil {
+		return TypeInfo{}, false
+	}
+	return lookup(kind, code.String())
+}
+
+// lookup finds an entry by kind and canonical code
+func lookup(kind, code string) (TypeInfo, bool) {
+	for _, t := range types {
+		if t.Kind == kind && t.Code == code {
+			return t, true
+		}
+	}
+	return TypeInfo{}, false
+}
+
+// Types returns the catalogue entries of a kind, by code
+func Types(kind string) []TypeInfo {
+	var list []TypeInfo
+	for _, t := range types {
+		if t.Kind == kind {
+			list = append(list, t)
+		}
+	}
+	return list
+}
+
+// ByOSMTag returns the entries typically used for an OSM tag, of every
+// kind. The tag is a key=value pair, or a bare key to match all its values.
+func ByOSMTag(tag string) []TypeInfo {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	if tag == "" {
+		return nil
+	}
+	var list []TypeInfo
+	for _, t := range types {
+		for _, osm := range t.OSMTags {
+			if osm == tag || !strings.Contains(tag, "=") && strings.HasPrefix(osm, tag+"=") {
+				list = append(list, t)
+				break
+			}
+		}
+	}
+	return list
+}
+
+// Search returns the entries of a kind matching a query, best matches
+// first. A query with "=" is an OSM tag; otherwise it matches the start of
+// a code, then words of names, then anywhere in names and categories. An
+// empty kind searches all kinds.
+func Search(kind, query string) []TypeInfo {
+	query = strings.ToLower(strings.TrimSpace(query))
+	if query == "" {
+		return nil
+	}
+
+	if strings.Contains(query, "=") {
+		var list []TypeInfo
+		for _, t := range ByOSMTag(query) {
+			if kind == "" || t.Kind == kind {
+				list = append(list, t)
+			}
+		}
+		return list
+	}
+
+	// exact code, code prefix, name word prefix, name or category
+	var ranks [4][]TypeInfo
+	exact := ""
+	if code, err := parser.ParseTypeCode(query, ""); err == nil && strings.HasPrefix(query, "0x") {
+		exact = code.String()
+	}
+	for _, t := range types {
+		if kind != "" && t.Kind != kind {
+			continue
+		}
+		name := strings.ToLower(t.Name)
+		switch {
+		case t.Code == exact:
+			ranks[0] = append(ranks[0], t)
+		case strings.HasPrefix(t.Code, query):
+			ranks[1] = append(ranks[1], t)
+		case hasWordPrefix(name, query):
+			ranks[2] = append(ranks[2], t)
+		case strings.Contains(name, query) || strings.Contains(strings.ToLower(t.Category), query):
+			ranks[3] = append(ranks[3], t)
+		}
+	}
+
+	var list []TypeInfo
+	for _, rank := range ranks {
+		list = append(list, rank...)
+	}
+	return list
+}
+
+// hasWordPrefix tells whether a word of s starts with prefix
+func hasWordPrefix(s, prefix string) bool {
+	for _, word := range strings.FieldsFunc(s, func(r rune) bool {
+		return r == ' ' || r == '(' || r == ')' || r == '-'
+	}) {
+		if strings.HasPrefix(word, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,100 @@
+package typedb
+
+import (
+	"testing"
+)
+
+func TestCatalogue(t *testing.T) {
+	seen := make(map[string]bool)
+	for _, typ := range types {
+		switch typ.Kind {
+		case "point", "line", "polygon":
+		default:
+			t.Errorf("%s %s: unknown kind", typ.Kind, typ.Code)
+		}
+		if typ.Name == "" || typ.Category == "" {
+			t.Errorf("%s %s: missing name or category", typ.Kind, typ.Code)
+		}
+		key := typ.Kind + " " + typ.Code
+		if seen[key] {
+			t.Errorf("%s: listed twice", key)
+		}
+		seen[key] = true
+	}
+	for _, kind := range []string{"point", "line", "polygon"} {
+		if len(Types(kind)) < 20 {
+			t.Errorf("Only %d %s types", len(Types(kind)), kind)
+		}
+	}
+}
+
+func TestLookup(t *testing.T) {
+	tests := []struct {
+		kind, typ, subType string
+		want               string
+	}{
+		{"point", "0x2f06", "", "Bank or ATM"},
+		{"point", "0x2f", "0x06", "Bank or ATM"},
+		{"point", "0x2a00", "", "Restaurant"},
+		{"point", "0x10101", "", "Major light"},
+		{"line", "0x01", "", "Major highway"},
+		{"polygon", "0x50", "", "Forest"},
+	}
+	for _, tt := range tests {
+		got, ok := Lookup(tt.kind, tt.typ, tt.subType)
+		if !ok || got.Name != tt.want {
+			t.Errorf("Lookup(%s, %s, %s) = %q, %v, want %q", tt.kind, tt.typ, tt.subType, got.Name, ok, tt.want)
+		}
+	}
+	if _, ok := Lookup("line", "0x2f06", ""); ok {
+		t.Error("Found a point code as a line")
+	}
+	if _, ok := Lookup("point", "bogus", ""); ok {
+		t.Error("Found an invalid code")
+	}
+}
+
+func TestByOSMTag(t *testing.T) {
+	got := ByOSMTag("amenity=bank")
+	if len(got) != 1 || got[0].Kind != "point" || got[0].Code != "0x2f06" {
+		t.Errorf("ByOSMTag(amenity=bank) = %+v", got)
+	}
+	if got := ByOSMTag("highway=motorway"); len(got) != 1 || got[0].Kind != "line" || got[0].Code != "0x01" {
+		t.Errorf("ByOSMTag(highway=motorway) = %+v", got)
+	}
+
+	kinds := make(map[string]bool)
+	for _, typ := range ByOSMTag("landuse") {
+		kinds[typ.Kind] = true
+	}
+	if !kinds["point"] || !kinds["polygon"] {
+		t.Errorf("A bare key matched kinds %v", kinds)
+	}
+}
+
+func TestSearch(t *testing.T) {
+	got := Search("point", "0x2f0")
+	if len(got) == 0 || got[0].Code != "0x2f01" {
+		t.Errorf("Search by code prefix = %+v", got)
+	}
+	if got := Search("point", "0x2f06"); got[0].Name != "Bank or ATM" {
+		t.Errorf("Search by code = %+v", got)
+	}
+
+	got = Search("polygon", "lake")
+	if len(got) == 0 || got[0].Code != "0x3c" {
+		t.Errorf("Search by name = %+v", got)
+	}
+
+	got = Search("point", "train")
+	if len(got) == 0 || got[0].Code != "0x2f08" {
+		t.Errorf("Search by word = %+v", got)
+	}
+
+	if got := Search("polygon", "natural=wood"); len(got) != 1 || got[0].Code != "0x50" {
+		t.Errorf("Search by tag = %+v", got)
+	}
+	if got := Search("", " "); got != nil {
+		t.Errorf("Empty search = %+v", got)
+	}
+}
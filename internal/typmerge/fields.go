@@ -0,0 +1,217 @@
+package typmerge
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/dyuri/typtui/internal/parser"
+)
+
+// field is a property of a T that merges on its own. key gives a value to
+// compare, show one to read, and set copies the property between values.
+type field[T any] struct {
+	name string
+	key  func(*T) string
+	show func(*T) string
+	set  func(dst, src *T)
+	icon func(*T) *parser.XPMIcon // nil unless the property is an XPM
+}
+
+// prop returns a field reached through a pointer to it, compared and shown
+// by format
+func prop[T, V any](name string, ptr func(*T) *V, format func(V) string) field[T] {
+	value := func(t *T) string { return format(*ptr(t)) }
+	return field[T]{
+		name: name,
+		key:  value,
+		show: value,
+		set:  func(dst, src *T) { *ptr(dst) = *ptr(src) },
+	}
+}
+
+// xpm returns an icon field. Icons are compared whole and shown by size.
+func xpm[T any](name string, ptr func(*T) **parser.XPMIcon) field[T] {
+	return field[T]{
+		name: name,
+		key:  func(t *T) string { return xpmKey(*ptr(t)) },
+		show: func(t *T) string { return xpmSummary(*ptr(t)) },
+		set:  func(dst, src *T) { *ptr(dst) = (*ptr(src)).Clone() },
+		icon: func(t *T) *parser.XPMIcon { return *ptr(t) },
+	}
+}
+
+// label returns the field of the label in one language
+func label[T any](code string, labels func(*T) *map[string]string) field[T] {
+	value := func(t *T) string {
+		if s, ok := (*labels(t))[code]; ok {
+			return strconv.Quote(s)
+		}
+		return ""
+	}
+	return field[T]{
+		name: "String " + code,
+		key:  value,
+		show: value,
+		set: func(dst, src *T) {
+			s, ok := (*labels(src))[code]
+			switch {
+			case ok && *labels(dst) == nil:
+				*labels(dst) = map[string]string{code: s}
+			case ok:
+				(*labels(dst))[code] = s
+			default:
+				delete(*labels(dst), code)
+			}
+		},
+	}
+}
+
+// labelFields returns a label field for every language used in any of the
+// versions
+func labelFields[T any](labels func(*T) *map[string]string, versions ...*T) []field[T] {
+	seen := make(map[string]bool)
+	var codes []string
+	for _, v := range versions {
+		if v == nil {
+			continue
+		}
+		for code := range *labels(v) {
+			if !seen[code] {
+				seen[code] = true
+				codes = append(codes, code)
+			}
+		}
+	}
+	sortCodes(codes)
+	fields := make([]field[T], len(codes))
+	for i, code := range codes {
+		fields[i] = label(code, labels)
+	}
+	return fields
+}
+
+// sortCodes sorts language codes by number
+func sortCodes(codes []string) {
+	number := func(code string) int64 {
+		n, _ := strconv.ParseInt(code, 0, 32)
+		return n
+	}
+	for i := 1; i < len(codes); i++ {
+		for j := i; j > 0 && number(codes[j]) < number(codes[j-1]); j-- {
+			codes[j], codes[j-1] = codes[j-1], codes[j]
+		}
+	}
+}
+
+func showString(s string) string { return s }
+
+func showInt(n int) string {
+	if n == 0 {
+		return ""
+	}
+	return strconv.Itoa(n)
+}
+
+func showFlag(b bool) string {
+	if b {
+		return "Y"
+	}
+	return ""
+}
+
+func showColor(c parser.Color) string { return strings.ToUpper(c.Hex) }
+
+func showColors(colors []parser.Color) string {
+	hex := make([]string, len(colors))
+	for i, c := range colors {
+		hex[i] = strings.ToUpper(c.Hex)
+	}
+	return strings.Join(hex, " ")
+}
+
+// xpmKey serialises an icon for comparing
+func xpmKey(x *parser.XPMIcon) string {
+	if x == nil {
+		return ""
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d %d %d %d", x.Width, x.Height, x.Colors, x.CharsPerPixel)
+	for _, entry := range x.Palette {
+		fmt.Fprintf(&b, "|%s=%s", entry.Key, strings.ToUpper(entry.Color.Hex))
+	}
+	for _, row := range x.Data {
+		b.WriteString("|")
+		b.WriteString(row)
+	}
+	return b.String()
+}
+
+// xpmSummary describes an icon in a few words
+func xpmSummary(x *parser.XPMIcon) string {
+	if x == nil {
+		return ""
+	}
+	if x.Width == 0 || x.Height == 0 {
+		colors := make([]string, len(x.Palette))
+		for i, entry := range x.Palette {
+			colors[i] = entry.Color.Hex
+		}
+		return "colours " + strings.Join(colors, " ")
+	}
+	return fmt.Sprintf("%dx%d, %d colours", x.Width, x.Height, len(x.Palette))
+}
+
+// headerFields are the merged properties of [_id]
+var headerFields = []field[parser.Header]{
+	prop("FID", func(h *parser.Header) *int { return &h.FID }, showInt),
+	prop("ProductCode", func(h *parser.Header) *int { return &h.ProductCode }, showInt),
+	prop("CodePage", func(h *parser.Header) *int { return &h.CodePage }, showInt),
+	prop("MapID", func(h *parser.Header) *int { return &h.MapID }, showInt),
+}
+
+// pointFields are the merged properties of a point, besides its labels
+var pointFields = []field[parser.PointType]{
+	prop("ExtendedLabels", func(p *parser.PointType) *bool { return &p.ExtendedLabels }, showFlag),
+	xpm("DayXpm", func(p *parser.PointType) **parser.XPMIcon { return &p.DayXpm }),
+	xpm("NightXpm", func(p *parser.PointType) **parser.XPMIcon { return &p.NightXpm }),
+	prop("DayCustomColor", func(p *parser.PointType) *[]parser.Color { return &p.DayColors }, showColors),
+	prop("NightCustomColor", func(p *parser.PointType) *[]parser.Color { return &p.NightColors }, showColors),
+	prop("FontStyle", func(p *parser.PointType) *string { return &p.FontStyle }, showString),
+	prop("DayFontColor", func(p *parser.PointType) *parser.Color { return &p.DayFontColor }, showColor),
+	prop("NightFontColor", func(p *parser.PointType) *parser.Color { return &p.NightFontColor }, showColor),
+	prop("CustomColor", func(p *parser.PointType) *parser.Color { return &p.CustomColor }, showColor),
+}
+
+// lineFields are the merged properties of a line, besides its labels
+var lineFields = []field[parser.LineType]{
+	prop("ExtendedLabels", func(l *parser.LineType) *bool { return &l.ExtendedLabels }, showFlag),
+	prop("LineWidth", func(l *parser.LineType) *int { return &l.LineWidth }, showInt),
+	prop("BorderWidth", func(l *parser.LineType) *int { return &l.BorderWidth }, showInt),
+	prop("NightLineWidth", func(l *parser.LineType) *int { return &l.NightLineWidth }, showInt),
+	prop("NightBorderWidth", func(l *parser.LineType) *int { return &l.NightBorderWidth }, showInt),
+	xpm("Xpm", func(l *parser.LineType) **parser.XPMIcon { return &l.DayXpm }),
+	xpm("NightXpm", func(l *parser.LineType) **parser.XPMIcon { return &l.NightXpm }),
+	prop("UseOrientation", func(l *parser.LineType) *bool { return &l.UseOrientation }, showFlag),
+	prop("LineStyle", func(l *parser.LineType) *string { return &l.LineStyle }, showString),
+	prop("DayCustomColor", func(l *parser.LineType) *[]parser.Color { return &l.DayColors }, showColors),
+	prop("NightCustomColor", func(l *parser.LineType) *[]parser.Color { return &l.NightColors }, showColors),
+	prop("FontStyle", func(l *parser.LineType) *string { return &l.FontStyle }, showString),
+	prop("DayFontColor", func(l *parser.LineType) *parser.Color { return &l.DayFontColor }, showColor),
+	prop("NightFontColor", func(l *parser.LineType) *parser.Color { return &l.NightFontColor }, showColor),
+	prop("CustomColor", func(l *parser.LineType) *parser.Color { return &l.CustomColor }, showColor),
+}
+
+// polygonFields are the merged properties of a polygon, besides its labels
+var polygonFields = []field[parser.PolygonType]{
+	prop("ExtendedLabels", func(p *parser.PolygonType) *bool { return &p.ExtendedLabels }, showFlag),
+	xpm("Xpm", func(p *parser.PolygonType) **parser.XPMIcon { return &p.DayXpm }),
+	xpm("NightXpm", func(p *parser.PolygonType) **parser.XPMIcon { return &p.NightXpm }),
+	prop("DayCustomColor", func(p *parser.PolygonType) *[]parser.Color { return &p.DayColors }, showColors),
+	prop("NightCustomColor", func(p *parser.PolygonType) *[]parser.Color { return &p.NightColors }, showColors),
+	prop("FontStyle", func(p *parser.PolygonType) *string { return &p.FontStyle }, showString),
+	prop("DayFontColor", func(p *parser.PolygonType) *parser.Color { return &p.DayFontColor }, showColor),
+	prop("NightFontColor", func(p *parser.PolygonType) *parser.Color { return &p.NightFontColor }, showColor),
+	prop("ContourColor", func(p *parser.PolygonType) *parser.Color { return &p.ContourColor }, showColor),
+	prop("CustomColor", func(p *parser.PolygonType) *parser.Color { return &p.CustomColor }, showColor),
+}
@@ -0,0 +1,349 @@
+// Package typmerge merges two TYP files that were changed from a common
+// base. Types are matched by kind and type code and merged property by
+// property, so edits to different types, or to different properties of one
+// type, combine cleanly; icons are merged whole, never line by line. Only
+// a property both sides changed differently is a conflict.
+//
+// Conflicts keep our version until they are resolved, so the merged file
+// is always complete and valid.
+package typmerge
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/dyuri/typtui/internal/parser"
+)
+
+// Side is a version a conflict can be resolved to
+type Side int
+
+const (
+	Ours Side = iota
+	Theirs
+)
+
+func (s Side) String() string {
+	if s == Theirs {
+		return "theirs"
+	}
+	return "ours"
+}
+
+// Conflict is a property both sides changed differently, or a type one
+// side removed and the other changed. The values are shown the way the
+// file writes them, icons by their size.
+type Conflict struct {
+	Kind       string          `json:"kind"` // "header", "point", "line" or "polygon"
+	Type       string          `json:"type,omitempty"`
+	Property   string          `json:"property,omitempty"` // "" for a removed type
+	Base       string          `json:"base"`
+	Ours       string          `json:"ours"`
+	Theirs     string          `json:"theirs"`
+	OursIcon   *parser.XPMIcon `json:"-"`
+	TheirsIcon *parser.XPMIcon `json:"-"`
+
+	// Choice is the version in the merged file, Ours until resolved
+	Choice   Side `json:"-"`
+	Resolved bool `json:"-"`
+
+	apply func(Side)
+}
+
+// Resolve puts one side's version into the merged file
+func (c *Conflict) Resolve(side Side) {
+	c.apply(side)
+	c.Choice = side
+	c.Resolved = true
+}
+
+// String describes the conflict on one line
+func (c *Conflict) String() string {
+	where := c.Kind
+	if c.Type != "" {
+		where += " " + c.Type
+	}
+	if c.Property == "" {
+		return fmt.Sprintf("%s: %s by us, %s by them", where, c.Ours, c.Theirs)
+	}
+	return fmt.Sprintf("%s %s: ours %s, theirs %s, base %s",
+		where, c.Property, quoteEmpty(c.Ours), quoteEmpty(c.Theirs), quoteEmpty(c.Base))
+}
+
+// quoteEmpty makes an unset value visible
+func quoteEmpty(s string) string {
+	if s == "" {
+		return "(unset)"
+	}
+	return s
+}
+
+// item is a type of the merged file. Types removed by the merge are kept
+// with keep false, as resolving a conflict may bring them back.
+type item[T any] struct {
+	value T
+	keep  bool
+}
+
+// Result is a merge in progress: the merged file and its conflicts
+type Result struct {
+	Conflicts []*Conflict
+
+	file      *parser.TYPFile // our file, for its layout
+	header    parser.Header
+	points    []*item[parser.PointType]
+	lines     []*item[parser.LineType]
+	polygons  []*item[parser.PolygonType]
+	drawOrder parser.DrawOrder
+}
+
+// File returns the merged file with the conflicts resolved so far. It keeps
+// the layout and comments of our file, and types only they added are
+// written at the end of their kind.
+func (r *Result) File() *parser.TYPFile {
+	c := *r.file
+	c.Header = r.header
+	c.Points = kept(r.points)
+	c.Lines = kept(r.lines)
+	c.Polygons = kept(r.polygons)
+	c.DrawOrder = parser.DrawOrder{Polygons: append([]parser.DrawOrderEntry(nil), r.drawOrder.Polygons...)}
+	return &c
+}
+
+// Unresolved counts the conflicts that were not resolved yet
+func (r *Result) Unresolved() int {
+	n := 0
+	for _, c := range r.Conflicts {
+		if !c.Resolved {
+			n++
+		}
+	}
+	return n
+}
+
+// kept returns the values of the items that stay in the file
+func kept[T any](items []*item[T]) []T {
+	values := make([]T, 0, len(items))
+	for _, it := range items {
+		if it.keep {
+			values = append(values, it.value)
+		}
+	}
+	return values
+}
+
+// Merge merges the changes ours and theirs made to base. The files are not
+// changed.
+func Merge(base, ours, theirs *parser.TYPFile) *Result {
+	base, theirs = base.Clone(), theirs.Clone()
+	work, ours := ours.Clone(), ours.Clone()
+
+	r := &Result{file: work, header: work.Header, drawOrder: work.DrawOrder}
+	mergeFields(r, "header", "", headerFields, &base.Header, &ours.Header, &theirs.Header, &r.header)
+
+	r.points = mergeKind(r, kind[parser.PointType]{
+		name:   "point",
+		fields: pointFields,
+		labels: func(p *parser.PointType) *map[string]string { return &p.Labels },
+		code:   func(p *parser.PointType) string { return typeCode(p.Type, p.SubType) },
+	}, base.Points, ours.Points, work.Points, theirs.Points)
+	r.lines = mergeKind(r, kind[parser.LineType]{
+		name:   "line",
+		fields: lineFields,
+		labels: func(l *parser.LineType) *map[string]string { return &l.Labels },
+		code:   func(l *parser.LineType) string { return typeCode(l.Type, "") },
+	}, base.Lines, ours.Lines, work.Lines, theirs.Lines)
+	r.polygons = mergeKind(r, kind[parser.PolygonType]{
+		name:   "polygon",
+		fields: polygonFields,
+		labels: func(p *parser.PolygonType) *map[string]string { return &p.Labels },
+		code:   func(p *parser.PolygonType) string { return typeCode(p.Type, "") },
+	}, base.Polygons, ours.Polygons, work.Polygons, theirs.Polygons)
+
+	mergeDrawOrder(r, base.DrawOrder, ours.DrawOrder, theirs.DrawOrder)
+	return r
+}
+
+// typeCode returns the type code in mkgmap form, so that all spellings of
+// a type match
+func typeCode(typ, subType string) string {
+	code, err := parser.ParseTypeCode(typ, subType)
+	if err != nil {
+		if subType != "" {
+			return typ + "/" + subType
+		}
+		return typ
+	}
+	return code.String()
+}
+
+// kind describes a category of types for merging
+type kind[T any] struct {
+	name   string
+	fields []field[T]
+	labels func(*T) *map[string]string
+	code   func(*T) string
+}
+
+// mergeKind merges the types of one kind. work holds the same types as
+// ours and becomes the merged version. Types are matched by code; when a
+// file has a code more than once, the occurrences pair up in order.
+func mergeKind[T any](r *Result, k kind[T], base, ours, work, theirs []T) []*item[T] {
+	index := func(types []T) map[string]int {
+		m := make(map[string]int, len(types))
+		seen := make(map[string]int)
+		for i := range types {
+			code := k.code(&types[i])
+			m[code+"#"+strconv.Itoa(seen[code])] = i
+			seen[code]++
+		}
+		return m
+	}
+	keys := func(types []T) []string {
+		list := make([]string, len(types))
+		seen := make(map[string]int)
+		for i := range types {
+			code := k.code(&types[i])
+			list[i] = code + "#" + strconv.Itoa(seen[code])
+			seen[code]++
+		}
+		return list
+	}
+	baseIdx, theirsIdx := index(base), index(theirs)
+	oursKeys := keys(ours)
+	inOurs := make(map[string]bool, len(ours))
+	for _, key := range oursKeys {
+		inOurs[key] = true
+	}
+
+	var items []*item[T]
+	for i, key := range oursKeys {
+		it := &item[T]{value: work[i], keep: true}
+		items = append(items, it)
+		code := k.code(&ours[i])
+
+		bi, inBase := baseIdx[key]
+		ti, inTheirs := theirsIdx[key]
+		switch {
+		case inTheirs:
+			var b T // both added the type when it is not in base
+			if inBase {
+				b = base[bi]
+			}
+			mergeFields(r, k.name, code, k.withLabels(&b, &ours[i], &theirs[ti]), &b, &ours[i], &theirs[ti], &it.value)
+		case inBase && k.equal(&base[bi], &ours[i]):
+			// Only they removed it
+			it.keep = false
+		case inBase:
+			// They removed what we changed
+			r.Conflicts = append(r.Conflicts, &Conflict{
+				Kind: k.name, Type: code, Ours: "changed", Theirs: "removed",
+				apply: func(side Side) { it.keep = side == Ours },
+			})
+		}
+	}
+
+	for i, key := range keys(theirs) {
+		if inOurs[key] {
+			continue
+		}
+		code := k.code(&theirs[i])
+		bi, inBase := baseIdx[key]
+		switch {
+		case !inBase:
+			// Only they added it
+			items = append(items, &item[T]{value: theirs[i], keep: true})
+		case !k.equal(&base[bi], &theirs[i]):
+			// We removed what they changed
+			it := &item[T]{value: theirs[i]}
+			items = append(items, it)
+			r.Conflicts = append(r.Conflicts, &Conflict{
+				Kind: k.name, Type: code, Ours: "removed", Theirs: "changed",
+				apply: func(side Side) { it.keep = side == Theirs },
+			})
+		}
+	}
+	return items
+}
+
+// withLabels returns the fields of a kind with one per label language
+func (k kind[T]) withLabels(versions ...*T) []field[T] {
+	return append(labelFields(k.labels, versions...), k.fields...)
+}
+
+// equal reports whether two versions of a type have the same properties
+func (k kind[T]) equal(a, b *T) bool {
+	for _, f := range k.withLabels(a, b) {
+		if f.key(a) != f.key(b) {
+			return false
+		}
+	}
+	return true
+}
+
+// mergeFields merges the properties of one type into merged, which starts
+// as a copy of ours
+func mergeFields[T any](r *Result, kindName, code string, fields []field[T], base, ours, theirs, merged *T) {
+	for _, f := range fields {
+		b, o, t := f.key(base), f.key(ours), f.key(theirs)
+		switch {
+		case o == t, t == b:
+			// Nothing to take from them
+		case o == b:
+			f.set(merged, theirs)
+		default:
+			c := &Conflict{
+				Kind: kindName, Type: code, Property: f.name,
+				Base: f.show(base), Ours: f.show(ours), Theirs: f.show(theirs),
+				apply: func(side Side) {
+					if side == Theirs {
+						f.set(merged, theirs)
+					} else {
+						f.set(merged, ours)
+					}
+				},
+			}
+			if f.icon != nil {
+				c.OursIcon, c.TheirsIcon = f.icon(ours), f.icon(theirs)
+			}
+			r.Conflicts = append(r.Conflicts, c)
+		}
+	}
+}
+
+// mergeDrawOrder merges the draw order level of every polygon type in any
+// of the versions
+func mergeDrawOrder(r *Result, base, ours, theirs parser.DrawOrder) {
+	var codes []string
+	seen := make(map[string]bool)
+	for _, d := range []parser.DrawOrder{ours, theirs, base} {
+		for _, entry := range d.Polygons {
+			code := typeCode(entry.Type, "")
+			if !seen[code] {
+				seen[code] = true
+				codes = append(codes, code)
+			}
+		}
+	}
+
+	for _, code := range codes {
+		b, o, t := base.Level(code), ours.Level(code), theirs.Level(code)
+		switch {
+		case o == t, t == b:
+		case o == b:
+			r.drawOrder.SetLevel(code, t)
+		default:
+			r.Conflicts = append(r.Conflicts, &Conflict{
+				Kind: "polygon", Type: code, Property: "DrawOrder",
+				Base: showInt(b), Ours: showInt(o), Theirs: showInt(t),
+				apply: func(side Side) {
+					if side == Theirs {
+						r.drawOrder.SetLevel(code, t)
+					} else {
+						r.drawOrder.SetLevel(code, o)
+					}
+				},
+			})
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package typmerge
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/dyuri/typtui/internal/parser"
+)
+
+func load(t *testing.T, name string) *parser.TYPFile {
+	t.Helper()
+	typFile, err := parser.ParseFile("../../testdata/typmerge/" + name)
+	if err != nil {
+		t.Fatalf("Failed to parse %s: %v", name, err)
+	}
+	return typFile
+}
+
+func write(t *testing.T, typFile *parser.TYPFile) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := parser.Write(&buf, typFile); err != nil {
+		t.Fatalf("Failed to write: %v", err)
+	}
+	return buf.String()
+}
+
+// Ours renames the bank, widens the road and removes the park
+// Theirs adds a French label, recolours the icon, removes the road, moves
+// the town up and adds a track
+func TestMergeConflicts(t *testing.T) {
+	base := load(t, "base.typ")
+	r := Merge(base, load(t, "ours.typ"), load(t, "theirs.typ"))
+
+	var got []string
+	for _, c := range r.Conflicts {
+		got = append(got, c.String())
+	}
+	want := []string{"line 0x01: changed by us, removed by them"}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("Unexpected conflicts:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	merged := r.File()
+	if len(merged.Points) != 1 {
+		t.Fatalf("Expected 1 point, got %d", len(merged.Points))
+	}
+	p := merged.Points[0]
+	if p.Labels["0x04"] != "Money" || p.Labels["0x01"] != "Banque" {
+		t.Errorf("Labels not merged: %v", p.Labels)
+	}
+	if color, _ := p.DayXpm.Palette.Lookup("a"); color.Hex != "#00FF00" {
+		t.Errorf("Icon not taken from theirs: %q", color.Hex)
+	}
+	if len(merged.Lines) != 2 || merged.Lines[0].LineWidth != 4 || merged.Lines[1].Type != "0x02" {
+		t.Errorf("Unexpected lines: %+v", merged.Lines)
+	}
+	if len(merged.Polygons) != 1 || merged.DrawOrder.Level("0x01") != 3 || merged.DrawOrder.Level("0x02") != 0 {
+		t.Errorf("Unexpected polygons %+v, draw order %+v", merged.Polygons, merged.DrawOrder)
+	}
+	if !strings.Contains(write(t, merged), "; Banks") {
+		t.Error("Comments of our file were lost")
+	}
+
+	r.Conflicts[0].Resolve(Theirs)
+	merged = r.File()
+	if len(merged.Lines) != 1 || merged.Lines[0].Type != "0x02" {
+		t.Errorf("Removed line kept after resolving to theirs: %+v", merged.Lines)
+	}
+	if r.Unresolved() != 0 {
+		t.Errorf("Expected all conflicts resolved, %d left", r.Unresolved())
+	}
+
+	if base.Points[0].Labels["0x04"] != "Bank" {
+		t.Error("Merge changed the base file")
+	}
+}
+
+func TestMergePropertyConflict(t *testing.T) {
+	base := load(t, "base.typ")
+	ours := load(t, "base.typ")
+	theirs := load(t, "base.typ")
+	ours.Points[0].DayXpm.Palette.Set("a", parser.Color{Hex: "#0000FF"})
+	theirs.Points[0].DayXpm.Palette.Set("a", parser.Color{Hex: "#00FF00"})
+	ours.DrawOrder.SetLevel("0x02", 4)
+	theirs.DrawOrder.SetLevel("0x02", 5)
+
+	r := Merge(base, ours, theirs)
+	if len(r.Conflicts) != 2 {
+		t.Fatalf("Expected 2 conflicts, got %v", r.Conflicts)
+	}
+
+	icon := r.Conflicts[0]
+	if icon.Kind != "point" || icon.Type != "0x2f06" || icon.Property != "DayXpm" || icon.OursIcon == nil || icon.TheirsIcon == nil {
+		t.Errorf("Unexpected icon conflict: %+v", icon)
+	}
+	order := r.Conflicts[1]
+	if order.Property != "DrawOrder" || order.Base != "2" || order.Ours != "4" || order.Theirs != "5" {
+		t.Errorf("Unexpected draw order conflict: %+v", order)
+	}
+
+	if color, _ := r.File().Points[0].DayXpm.Palette.Lookup("a"); color.Hex != "#0000FF" {
+		t.Errorf("Unresolved conflict does not keep ours: %q", color.Hex)
+	}
+	icon.Resolve(Theirs)
+	order.Resolve(Theirs)
+	merged := r.File()
+	if color, _ := merged.Points[0].DayXpm.Palette.Lookup("a"); color.Hex != "#00FF00" {
+		t.Errorf("Conflict not resolved to theirs: %q", color.Hex)
+	}
+	if merged.DrawOrder.Level("0x02") != 5 {
+		t.Errorf("Draw order not resolved to theirs: %d", merged.DrawOrder.Level("0x02"))
+	}
+	icon.Resolve(Ours)
+	if color, _ := r.File().Points[0].DayXpm.Palette.Lookup("a"); color.Hex != "#0000FF" {
+		t.Errorf("Conflict not resolved back to ours: %q", color.Hex)
+	}
+}
+
+func TestMergeSame(t *testing.T) {
+	base := load(t, "base.typ")
+	r := Merge(base, load(t, "base.typ"), load(t, "base.typ"))
+	if len(r.Conflicts) != 0 {
+		t.Errorf("Unexpected conflicts: %v", r.Conflicts)
+	}
+	source, err := os.ReadFile("../../testdata/typmerge/base.typ")
+	if err != nil {
+		t.Fatalf("Failed to read base.typ: %v", err)
+	}
+	if got := write(t, r.File()); got != string(source) {
+		t.Errorf("Unchanged merge rewrote the file:\n%s", got)
+	}
+}
@@ -0,0 +1,220 @@
+package validate
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/dyuri/typtui/internal/parser"
+)
+
+func init() {
+	Register(Rule{
+		Name:     "type-range",
+		Severity: SeverityError,
+		Summary:  "type codes must be valid and in the range of their kind",
+		Check:    checkTypeRange,
+	})
+	Register(Rule{
+		Name:     "point-subtype",
+		Severity: SeverityWarning,
+		Summary:  "SubType is only needed with an extended point Type",
+		Check:    checkPointSubType,
+	})
+	Register(Rule{
+		Name:     "duplicate-type",
+		Severity: SeverityError,
+		Summary:  "a type code is defined once per kind",
+		Check:    checkDuplicateTypes,
+	})
+	Register(Rule{
+		Name:     "xpm-size",
+		Severity: SeverityError,
+		Summary:  "XPM rows match the Width and Height of the header",
+		Check:    checkXPMSize,
+	})
+	Register(Rule{
+		Name:     "xpm-colors",
+		Severity: SeverityError,
+		Summary:  "the XPM colour count matches the palette",
+		Check:    checkXPMColors,
+	})
+	Register(Rule{
+		Name:     "xpm-pixels",
+		Severity: SeverityError,
+		Summary:  "every XPM pixel is in the palette",
+		Check:    checkXPMPixels,
+	})
+}
+
+// item is a point, line or polygon type in the form the rules check
+type item struct {
+	section string
+	index   int
+	typ     string
+	subType string
+	icons   []icon
+}
+
+// icon is an XPM of a type with the property it is written as
+type icon struct {
+	name string
+	xpm  *parser.XPMIcon
+}
+
+// items lists the types of a file
+func items(t *parser.TYPFile) []item {
+	var list []item
+	for i, point := range t.Points {
+		list = append(list, item{"_point", i, point.Type, point.SubType,
+			[]icon{{"DayXpm", point.DayXpm}, {"NightXpm", point.NightXpm}}})
+	}
+	for i, line := range t.Lines {
+		list = append(list, item{"_line", i, line.Type, "",
+			[]icon{{"Xpm", line.DayXpm}, {"NightXpm", line.NightXpm}}})
+	}
+	for i, polygon := range t.Polygons {
+		list = append(list, item{"_polygon", i, polygon.Type, "",
+			[]icon{{"Xpm", polygon.DayXpm}, {"NightXpm", polygon.NightXpm}}})
+	}
+	return list
+}
+
+// finding returns a finding about a type
+func (it item) finding(format string, args ...any) Finding {
+	typ := it.typ
+	if it.subType != "" {
+		typ += "/" + it.subType
+	}
+	return Finding{Section: it.section, Index: it.index, Type: typ, Message: fmt.Sprintf(format, args...)}
+}
+
+// maxTypes is the highest standard type code of each kind
+var maxTypes = map[string]int{"_point": 0x7f, "_line": 0x3f, "_polygon": 0x7f}
+
+func checkTypeRange(t *parser.TYPFile) []Finding {
+	var findings []Finding
+	for _, it := range items(t) {
+		code, err := parser.ParseTypeCode(it.typ, it.subType)
+		if err != nil {
+			findings = append(findings, it.finding("%v", err))
+			continue
+		}
+		kind := strings.TrimPrefix(it.section, "_")
+		switch {
+		case code.SubType > 0x1f:
+			findings = append(findings, it.finding("subtype 0x%02x is above 0x1f", code.SubType))
+		case code.Extended:
+			// Extended types use the whole type byte
+		case code.Type < 0x01 || code.Type > maxTypes[it.section]:
+			findings = append(findings, it.finding("type 0x%02x is outside 0x01-0x%02x for %ss, use an extended type (0x1xxyy) instead",
+				code.Type, maxTypes[it.section], kind))
+		case code.SubType != 0 && it.section != "_point":
+			findings = append(findings, it.finding("%ss have no subtypes, use an extended type (0x1xxyy) instead", kind))
+		}
+	}
+	return findings
+}
+
+func checkPointSubType(t *parser.TYPFile) []Finding {
+	var findings []Finding
+	for _, it := range items(t) {
+		if it.section != "_point" || it.subType == "" {
+			continue
+		}
+		code, err := parser.ParseTypeCode(it.typ, it.subType)
+		if err != nil || code.Extended {
+			continue
+		}
+		findings = append(findings, it.finding("SubType=%s with the standard type %s, write the type as %s instead",
+			it.subType, it.typ, code))
+	}
+	return findings
+}
+
+func checkDuplicateTypes(t *parser.TYPFile) []Finding {
+	var findings []Finding
+	seen := make(map[string]int)
+	for _, it := range items(t) {
+		code, err := parser.ParseTypeCode(it.typ, it.subType)
+		if err != nil {
+			continue // reported by type-range
+		}
+		key := it.section + " " + code.String()
+		if first, dup := seen[key]; dup {
+			findings = append(findings, it.finding("%s is already defined by %s #%d",
+				code, strings.TrimPrefix(it.section, "_"), first+1))
+			continue
+		}
+		seen[key] = it.index
+	}
+	return findings
+}
+
+func checkXPMSize(t *parser.TYPFile) []Finding {
+	var findings []Finding
+	for _, it := range items(t) {
+		for _, ic := range it.icons {
+			x := ic.xpm
+			if x == nil {
+				continue
+			}
+			if len(x.Data) != x.Height {
+				findings = append(findings, it.finding("%s has %d rows, its Height is %d", ic.name, len(x.Data), x.Height))
+			}
+			cpp := max(x.CharsPerPixel, 1)
+			for i, row := range x.Data {
+				if len(row)%cpp != 0 {
+					findings = append(findings, it.finding("%s row %d ends with a partial pixel of %d characters", ic.name, i+1, len(row)%cpp))
+					break
+				}
+				if n := len(row) / cpp; n != x.Width {
+					findings = append(findings, it.finding("%s row %d is %d pixels wide, its Width is %d", ic.name, i+1, n, x.Width))
+					break
+				}
+			}
+		}
+	}
+	return findings
+}
+
+func checkXPMColors(t *parser.TYPFile) []Finding {
+	var findings []Finding
+	for _, it := range items(t) {
+		for _, ic := range it.icons {
+			x := ic.xpm
+			if x != nil && x.Colors != len(x.Palette) {
+				findings = append(findings, it.finding("%s declares %d colours, its palette has %d", ic.name, x.Colors, len(x.Palette)))
+			}
+		}
+	}
+	return findings
+}
+
+func checkXPMPixels(t *parser.TYPFile) []Finding {
+	var findings []Finding
+	for _, it := range items(t) {
+		for _, ic := range it.icons {
+			x := ic.xpm
+			if x == nil || x.CharsPerPixel == 0 {
+				continue
+			}
+			known := make(map[string]bool, len(x.Palette))
+			for _, key := range x.Palette.Keys() {
+				known[key] = true
+			}
+			var missing []string
+			for _, row := range x.Data {
+				for _, pixel := range x.Pixels(row) {
+					if !known[pixel] && len(pixel) == x.CharsPerPixel {
+						known[pixel] = true // reported once
+						missing = append(missing, fmt.Sprintf("%q", pixel))
+					}
+				}
+			}
+			if len(missing) > 0 {
+				findings = append(findings, it.finding("%s uses pixels that are not in its palette: %s", ic.name, strings.Join(missing, ", ")))
+			}
+		}
+	}
+	return findings
+}
@@ -0,0 +1,144 @@
+// Package validate checks the semantics of a TYP file: things the parser
+// accepts but mkgmap or a Garmin device would reject or draw wrongly, such
+// as type codes out of range or XPM icons that do not match their header.
+//
+// Checks are rules in a registry. Each rule has a name, used to turn it off
+// in the config file, and a severity; errors keep a file from being saved
+// without confirmation.
+package validate
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/dyuri/typtui/internal/parser"
+)
+
+// Severity tells how serious a Finding is
+type Severity int
+
+const (
+	// SeverityError marks data mkgmap or a device cannot use
+	SeverityError Severity = iota
+	// SeverityWarning marks data that works but is likely a mistake
+	SeverityWarning
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityError:
+		return "error"
+	case SeverityWarning:
+		return "warning"
+	}
+	return fmt.Sprintf("Severity(%d)", int(s))
+}
+
+// Finding is a problem a rule found in a file
+type Finding struct {
+	Rule     string
+	Severity Severity
+	Section  string // "_point", "_line" or "_polygon", "" for the whole file
+	Index    int    // position of the type in its section
+	Type     string // the type code as written
+	Message  string
+}
+
+// String formats the finding as "point 0x2f06: error: message (rule)"
+func (f Finding) String() string {
+	where := ""
+	if f.Section != "" {
+		where = strings.TrimPrefix(f.Section, "_") + " " + f.Type + ": "
+	}
+	return fmt.Sprintf("%s%s: %s (%s)", where, f.Severity, f.Message, f.Rule)
+}
+
+// Rule is a check of a file. Check returns the problems found; their Rule
+// and Severity are filled in from the rule.
+type Rule struct {
+	Name     string
+	Severity Severity
+	Summary  string
+	Check    func(t *parser.TYPFile) []Finding
+}
+
+// registry holds the rules by name
+var registry = map[string]Rule{}
+
+// Register adds a rule. Names must be unique.
+func Register(rule Rule) {
+	if _, dup := registry[rule.Name]; dup {
+		panic("validate: rule " + rule.Name + " registered twice")
+	}
+	registry[rule.Name] = rule
+}
+
+// Rules returns the registered rules sorted by name
+func Rules() []Rule {
+	rules := make([]Rule, 0, len(registry))
+	for _, rule := range registry {
+		rules = append(rules, rule)
+	}
+	sort.Slice(rules, func(i, j int) bool { return rules[i].Name < rules[j].Name })
+	return rules
+}
+
+// CheckConfig reports rule names in the config that are not registered
+func CheckConfig(enabled map[string]bool) error {
+	var unknown []string
+	for name := range enabled {
+		if _, ok := registry[name]; !ok {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return fmt.Errorf("unknown validation rules: %s", strings.Join(unknown, ", "))
+	}
+	return nil
+}
+
+// Run checks a file with every rule that is not turned off in enabled, a
+// map from rule names to whether they run. Rules missing from it run.
+// Findings are ordered by section and position, errors first.
+func Run(t *parser.TYPFile, enabled map[string]bool) []Finding {
+	var findings []Finding
+	for _, rule := range Rules() {
+		if on, ok := enabled[rule.Name]; ok && !on {
+			continue
+		}
+		for _, f := range rule.Check(t) {
+			f.Rule = rule.Name
+			f.Severity = rule.Severity
+			findings = append(findings, f)
+		}
+	}
+
+	sort.SliceStable(findings, func(i, j int) bool {
+		a, b := findings[i], findings[j]
+		if a.Section != b.Section {
+			return sectionOrder[a.Section] < sectionOrder[b.Section]
+		}
+		if a.Index != b.Index {
+			return a.Index < b.Index
+		}
+		return a.Severity < b.Severity
+	})
+	return findings
+}
+
+// sectionOrder sorts findings the way the sections are shown
+var sectionOrder = map[string]int{"": 0, "_point": 1, "_line": 2, "_polygon": 3}
+
+// Count returns the number of errors and warnings
+func Count(findings []Finding) (errors, warnings int) {
+	for _, f := range findings {
+		if f.Severity == SeverityError {
+			errors++
+		} else {
+			warnings++
+		}
+	}
+	return errors, warnings
+}
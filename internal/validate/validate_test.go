@@ -0,0 +1,63 @@
+package validate
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/dyuri/typtui/internal/parser"
+)
+
+func TestRun(t *testing.T) {
+	typFile, err := parser.ParseFile("../../testdata/validate/problems.typ")
+	if err != nil {
+		t.Fatalf("Failed to parse problems.typ: %v", err)
+	}
+	findings := Run(typFile, nil)
+
+	var got []string
+	for _, f := range findings {
+		got = append(got, f.String())
+	}
+	want := []string{
+		"point 0x2f/0x06: warning: SubType=0x06 with the standard type 0x2f, write the type as 0x2f06 instead (point-subtype)",
+		"point 0x2f06: error: 0x2f06 is already defined by point #1 (duplicate-type)",
+		"line 0x45: error: type 0x45 is outside 0x01-0x3f for lines, use an extended type (0x1xxyy) instead (type-range)",
+		"polygon 0x13: error: Xpm uses pixels that are not in its palette: \"x\" (xpm-pixels)",
+		"polygon 0x13: error: Xpm row 1 is 3 pixels wide, its Width is 4 (xpm-size)",
+		"polygon 0x10f04: error: Xpm declares 2 colours, its palette has 1 (xpm-colors)",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Unexpected findings:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	errs, warnings := Count(findings)
+	if errs != 5 || warnings != 1 {
+		t.Errorf("Expected 5 errors and 1 warning, got %d and %d", errs, warnings)
+	}
+}
+
+func TestRunDisabled(t *testing.T) {
+	typFile, err := parser.ParseFile("../../testdata/validate/problems.typ")
+	if err != nil {
+		t.Fatalf("Failed to parse problems.typ: %v", err)
+	}
+	findings := Run(typFile, map[string]bool{"point-subtype": false, "type-range": true})
+	for _, f := range findings {
+		if f.Rule == "point-subtype" {
+			t.Errorf("Expected point-subtype to be off, got %v", f)
+		}
+	}
+	if len(findings) != 5 {
+		t.Errorf("Expected 5 findings, got %d", len(findings))
+	}
+}
+
+func TestCheckConfig(t *testing.T) {
+	if err := CheckConfig(map[string]bool{"xpm-size": false}); err != nil {
+		t.Errorf("Expected a known rule to pass, got %v", err)
+	}
+	if err := CheckConfig(map[string]bool{"xpm-size": false, "no-such-rule": true}); err == nil ||
+		!strings.Contains(err.Error(), "no-such-rule") {
+		t.Errorf("Expected an error naming the unknown rule, got %v", err)
+	}
+}
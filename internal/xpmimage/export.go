@@ -0,0 +1,247 @@
+package xpmimage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"math"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/dyuri/typtui/internal/atomicfile"
+	"github.com/dyuri/typtui/internal/parser"
+)
+
+// ToImage draws an icon, each pixel scale x scale large. Pixels that are
+// none or not in the palette are transparent.
+func ToImage(xpm *parser.XPMIcon, scale int) (*image.NRGBA, error) {
+	if scale < 1 {
+		return nil, fmt.Errorf("the scale must be at least 1, not %d", scale)
+	}
+	if xpm == nil || xpm.Width == 0 || xpm.Height == 0 || len(xpm.Data) == 0 {
+		return nil, errors.New("the icon has no pixels")
+	}
+
+	colors := make(map[string]color.NRGBA, len(xpm.Palette))
+	for _, entry := range xpm.Palette {
+		if c, ok := ParseColor(entry.Color.Hex); ok {
+			colors[entry.Key] = c
+		}
+	}
+
+	img := image.NewNRGBA(image.Rect(0, 0, xpm.Width*scale, xpm.Height*scale))
+	for y, row := range xpm.Data {
+		if y >= xpm.Height {
+			break
+		}
+		for x, key := range xpm.Pixels(row) {
+			if x >= xpm.Width {
+				break
+			}
+			c, ok := colors[key]
+			if !ok {
+				continue
+			}
+			draw.Draw(img, image.Rect(x*scale, y*scale, (x+1)*scale, (y+1)*scale), image.NewUniform(c), image.Point{}, draw.Src)
+		}
+	}
+	return img, nil
+}
+
+// ParseColor parses a "#RRGGBB" palette colour. none is not a colour.
+func ParseColor(hex string) (color.NRGBA, bool) {
+	if len(hex) != 7 || hex[0] != '#' {
+		return color.NRGBA{}, false
+	}
+	v, err := strconv.ParseUint(hex[1:], 16, 32)
+	if err != nil {
+		return color.NRGBA{}, false
+	}
+	return color.NRGBA{uint8(v >> 16), uint8(v >> 8), uint8(v), 0xFF}, true
+}
+
+// Encode writes an icon as a PNG
+func Encode(w io.Writer, xpm *parser.XPMIcon, scale int) error {
+	img, err := ToImage(xpm, scale)
+	if err != nil {
+		return err
+	}
+	return png.Encode(w, img)
+}
+
+// ExportFile writes an icon to a PNG file
+func ExportFile(path string, xpm *parser.XPMIcon, scale int) error {
+	img, err := ToImage(xpm, scale)
+	if err != nil {
+		return err
+	}
+	return atomicfile.Write(path, 0o644, func(w io.Writer) error {
+		return png.Encode(w, img)
+	})
+}
+
+// Entry is an icon of a type for a sprite sheet
+type Entry struct {
+	Type  string // type code in mkgmap form
+	Label string
+	Icon  *parser.XPMIcon
+}
+
+// Entries lists the day or night icons of the points, lines or polygons
+// of a file. Types without an icon are left out.
+func Entries(t *parser.TYPFile, kind string, night bool) ([]Entry, error) {
+	var entries []Entry
+	add := func(typ, subType string, labels map[string]string, day, nightIcon *parser.XPMIcon) {
+		icon := day
+		if night {
+			icon = nightIcon
+		}
+		if icon == nil || icon.Width == 0 || icon.Height == 0 {
+			return
+		}
+		code := typ
+		if c, err := parser.ParseTypeCode(typ, subType); err == nil {
+			code = c.String()
+		}
+		entries = append(entries, Entry{Type: code, Label: mainLabel(labels), Icon: icon})
+	}
+
+	switch kind {
+	case "point":
+		for _, p := range t.Points {
+			add(p.Type, p.SubType, p.Labels, p.DayXpm, p.NightXpm)
+		}
+	case "line":
+		for _, l := range t.Lines {
+			add(l.Type, "", l.Labels, l.DayXpm, l.NightXpm)
+		}
+	case "polygon":
+		for _, p := range t.Polygons {
+			add(p.Type, "", p.Labels, p.DayXpm, p.NightXpm)
+		}
+	default:
+		return nil, fmt.Errorf("unknown kind %q, use point, line or polygon", kind)
+	}
+	return entries, nil
+}
+
+// mainLabel returns the English label, or the one with the lowest
+// language code
+func mainLabel(labels map[string]string) string {
+	if label, ok := labels["0x04"]; ok {
+		return label
+	}
+	first := ""
+	for code := range labels {
+		if first == "" || code < first {
+			first = code
+		}
+	}
+	return labels[first]
+}
+
+// Sprite is where an icon is on a sprite sheet, in pixels
+type Sprite struct {
+	X      int    `json:"x"`
+	Y      int    `json:"y"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+	Label  string `json:"label,omitempty"`
+}
+
+// Index describes a sprite sheet, with the sprites by type code
+type Index struct {
+	Image   string            `json:"image"`
+	Scale   int               `json:"scale"`
+	Sprites map[string]Sprite `json:"sprites"`
+}
+
+// spritePadding is the transparent gap between sprites, so that scaled
+// sprites do not bleed into each other
+const spritePadding = 1
+
+// Sheet draws the icons on one image, row by row in a roughly square
+// sheet. A type code that is in the list more than once keeps its first
+// icon.
+func Sheet(entries []Entry, scale int) (*image.NRGBA, map[string]Sprite, error) {
+	var images []*image.NRGBA
+	var kept []Entry
+	seen := make(map[string]bool)
+	area, widest := 0, 0
+	for _, e := range entries {
+		if seen[e.Type] {
+			continue
+		}
+		img, err := ToImage(e.Icon, scale)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", e.Type, err)
+		}
+		seen[e.Type] = true
+		images = append(images, img)
+		kept = append(kept, e)
+		w, h := img.Bounds().Dx()+spritePadding, img.Bounds().Dy()+spritePadding
+		area += w * h
+		widest = max(widest, w)
+	}
+	if len(images) == 0 {
+		return nil, nil, errors.New("there are no icons to export")
+	}
+
+	// Fill rows up to the width of a square of the same area
+	sheetWidth := max(widest, int(math.Ceil(math.Sqrt(float64(area)))))
+	sprites := make(map[string]Sprite, len(images))
+	x, y, rowHeight, width := 0, 0, 0, 0
+	for i, img := range images {
+		w, h := img.Bounds().Dx(), img.Bounds().Dy()
+		if x > 0 && x+w > sheetWidth {
+			x, y, rowHeight = 0, y+rowHeight, 0
+		}
+		sprites[kept[i].Type] = Sprite{X: x, Y: y, Width: w, Height: h, Label: kept[i].Label}
+		x += w + spritePadding
+		rowHeight = max(rowHeight, h+spritePadding)
+		width = max(width, x-spritePadding)
+	}
+	height := y + rowHeight - spritePadding
+
+	sheet := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for i, img := range images {
+		s := sprites[kept[i].Type]
+		draw.Draw(sheet, image.Rect(s.X, s.Y, s.X+s.Width, s.Y+s.Height), img, image.Point{}, draw.Src)
+	}
+	return sheet, sprites, nil
+}
+
+// ExportSheet writes a sprite sheet to a PNG file and its index next to
+// it, with the extension replaced by .json
+func ExportSheet(path string, entries []Entry, scale int) (*Index, error) {
+	sheet, sprites, err := Sheet(entries, scale)
+	if err != nil {
+		return nil, err
+	}
+	index := &Index{Image: filepath.Base(path), Scale: scale, Sprites: sprites}
+	data, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	if err := atomicfile.Write(path, 0o644, func(w io.Writer) error {
+		return png.Encode(w, sheet)
+	}); err != nil {
+		return nil, err
+	}
+	if err := atomicfile.WriteFile(IndexPath(path), append(data, '\n'), 0o644); err != nil {
+		return nil, err
+	}
+	return index, nil
+}
+
+// IndexPath returns the path of the index of a sprite sheet
+func IndexPath(path string) string {
+	return strings.TrimSuffix(path, filepath.Ext(path)) + ".json"
+}
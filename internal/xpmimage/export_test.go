@@ -0,0 +1,119 @@
+package xpmimage
+
+import (
+	"encoding/json"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dyuri/typtui/internal/parser"
+)
+
+const sheetSource = `[_point]
+Type=0x2f
+SubType=0x06
+String=0x04,Bank
+DayXpm="2 2 2 1"
+"a c #FF0000"
+"b c none"
+"ab"
+"ba"
+[end]
+
+[_point]
+Type=0x2f07
+String=0x01,Poste
+DayXpm="3 1 1 1"
+"c c #0000FF"
+"ccc"
+[end]
+
+[_point]
+Type=0x2f08
+String=0x04,No icon
+[end]
+`
+
+func TestToImage(t *testing.T) {
+	typFile, err := parser.ParseReader(strings.NewReader(sheetSource), "sheet.typ")
+	if err != nil {
+		t.Fatalf("Failed to parse: %v", err)
+	}
+
+	img, err := ToImage(typFile.Points[0].DayXpm, 3)
+	if err != nil {
+		t.Fatalf("Failed to export: %v", err)
+	}
+	if img.Bounds().Dx() != 6 || img.Bounds().Dy() != 6 {
+		t.Fatalf("Expected 6x6, got %v", img.Bounds())
+	}
+	if got := img.NRGBAAt(2, 2); got != (color.NRGBA{0xFF, 0, 0, 0xFF}) {
+		t.Errorf("Unexpected colour %v", got)
+	}
+	if got := img.NRGBAAt(3, 2); got.A != 0 {
+		t.Errorf("Expected none to be transparent, got %v", got)
+	}
+
+	if _, err := ToImage(typFile.Points[0].DayXpm, 0); err == nil {
+		t.Error("Expected an error for scale 0")
+	}
+}
+
+func TestExportSheet(t *testing.T) {
+	typFile, err := parser.ParseReader(strings.NewReader(sheetSource), "sheet.typ")
+	if err != nil {
+		t.Fatalf("Failed to parse: %v", err)
+	}
+	entries, err := Entries(typFile, "point", false)
+	if err != nil {
+		t.Fatalf("Failed to list icons: %v", err)
+	}
+	if len(entries) != 2 || entries[0].Type != "0x2f06" || entries[1].Label != "Poste" {
+		t.Fatalf("Unexpected entries: %+v", entries)
+	}
+
+	path := filepath.Join(t.TempDir(), "points.png")
+	index, err := ExportSheet(path, entries, 2)
+	if err != nil {
+		t.Fatalf("Failed to export: %v", err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Sheet not written: %v", err)
+	}
+	defer f.Close()
+	sheet, err := png.Decode(f)
+	if err != nil {
+		t.Fatalf("Sheet is not a PNG: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(filepath.Dir(path), "points.json"))
+	if err != nil {
+		t.Fatalf("Index not written: %v", err)
+	}
+	var written Index
+	if err := json.Unmarshal(data, &written); err != nil {
+		t.Fatalf("Index is not JSON: %v", err)
+	}
+	if written.Image != "points.png" || written.Scale != 2 || len(written.Sprites) != 2 {
+		t.Errorf("Unexpected index %+v", written)
+	}
+
+	// Each sprite is inside the sheet and shows its icon
+	for code, s := range index.Sprites {
+		if s.X+s.Width > sheet.Bounds().Dx() || s.Y+s.Height > sheet.Bounds().Dy() {
+			t.Errorf("Sprite %s %+v is outside the %v sheet", code, s, sheet.Bounds())
+		}
+	}
+	bank, post := index.Sprites["0x2f06"], index.Sprites["0x2f07"]
+	if bank.Width != 4 || bank.Height != 4 || post.Width != 6 || post.Height != 2 {
+		t.Errorf("Unexpected sprites %+v %+v", bank, post)
+	}
+	if _, _, _, a := sheet.At(post.X, post.Y).RGBA(); a == 0 {
+		t.Error("Sprite 0x2f07 is not drawn where the index says")
+	}
+}
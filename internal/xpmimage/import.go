@@ -0,0 +1,202 @@
+// Package xpmimage converts between raster images and XPM icons. Images
+// are imported with the standard library decoders, resized, and quantised
+// to a palette small enough for a TYP file; icons are exported to PNG one
+// by one or as sprite sheets.
+package xpmimage
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif" // decoders for Decode
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"os"
+	"slices"
+
+	"github.com/dyuri/typtui/internal/parser"
+)
+
+// MaxColors is the largest palette an imported icon may have
+const MaxColors = 256
+
+// Options control how an image becomes an icon
+type Options struct {
+	// Width and Height are the size of the icon. With one of them 0 the
+	// other keeps the aspect ratio of the image; with both 0 the image
+	// keeps its size.
+	Width, Height int
+
+	// Colors is the most colours the palette may hold, not counting none
+	Colors int
+
+	// Alpha is the opacity, 0 to 255, below which a pixel becomes none
+	Alpha int
+}
+
+// DefaultOptions keeps the size of the image and reduces it to 16 colours,
+// making pixels that are less than half opaque transparent
+func DefaultOptions() Options {
+	return Options{Colors: 16, Alpha: 128}
+}
+
+// ImportFile reads an image file, usually a PNG, as an icon
+func ImportFile(path string, opts Options) (*parser.XPMIcon, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Decode(f, opts)
+}
+
+// Decode reads a PNG, GIF or JPEG image as an icon
+func Decode(r io.Reader, opts Options) (*parser.XPMIcon, error) {
+	img, _, err := image.Decode(r)
+	if err != nil {
+		return nil, fmt.Errorf("cannot decode the image: %w", err)
+	}
+	return FromImage(img, opts)
+}
+
+// FromImage converts an image to an icon. The palette holds the colours
+// in order of how many pixels use them, most used first, with none last.
+func FromImage(img image.Image, opts Options) (*parser.XPMIcon, error) {
+	if opts.Colors < 1 || opts.Colors > MaxColors {
+		return nil, fmt.Errorf("the palette must have 1 to %d colours, not %d", MaxColors, opts.Colors)
+	}
+	if opts.Alpha < 0 || opts.Alpha > 255 {
+		return nil, fmt.Errorf("the alpha threshold must be 0 to 255, not %d", opts.Alpha)
+	}
+	bounds := img.Bounds()
+	if bounds.Empty() {
+		return nil, errors.New("the image is empty")
+	}
+	width, height := targetSize(bounds.Dx(), bounds.Dy(), opts.Width, opts.Height)
+	if width < 1 || height < 1 {
+		return nil, fmt.Errorf("invalid size %dx%d", opts.Width, opts.Height)
+	}
+
+	pixels := resize(img, width, height, opts.Alpha)
+
+	// Count the opaque colours and reduce them to the palette
+	counts := make(map[rgb]int)
+	transparent := false
+	for _, p := range pixels {
+		if p.opaque {
+			counts[p.color]++
+		} else {
+			transparent = true
+		}
+	}
+	palette := quantize(counts, opts.Colors)
+
+	// Map every pixel to its nearest palette colour, then order the
+	// palette by use, dropping colours no pixel ended up with
+	nearest := make(map[rgb]int, len(counts))
+	for c := range counts {
+		nearest[c] = closest(palette, c)
+	}
+	used := make([]int, len(palette))
+	for _, p := range pixels {
+		if p.opaque {
+			used[nearest[p.color]]++
+		}
+	}
+	order := make([]int, 0, len(palette))
+	for i, n := range used {
+		if n > 0 {
+			order = append(order, i)
+		}
+	}
+	slices.SortFunc(order, func(a, b int) int {
+		if c := cmp.Compare(used[b], used[a]); c != 0 {
+			return c
+		}
+		return cmp.Compare(palette[a].hex(), palette[b].hex())
+	})
+
+	index := make([]int, len(palette))
+	colors := make([]parser.Color, len(order))
+	for i, p := range order {
+		index[p] = i
+		colors[i] = parser.Color{Hex: palette[p].hex()}
+	}
+	indexes := make([]int, len(pixels))
+	for i, p := range pixels {
+		if p.opaque {
+			indexes[i] = index[nearest[p.color]]
+		} else {
+			indexes[i] = len(colors)
+		}
+	}
+	return parser.NewIndexedXPM(width, height, colors, transparent, indexes), nil
+}
+
+// targetSize works out the icon size from the requested one
+func targetSize(srcWidth, srcHeight, width, height int) (int, int) {
+	switch {
+	case width == 0 && height == 0:
+		return srcWidth, srcHeight
+	case width == 0:
+		return max(1, (srcWidth*height+srcHeight/2)/srcHeight), height
+	case height == 0:
+		return width, max(1, (srcHeight*width+srcWidth/2)/srcWidth)
+	}
+	return width, height
+}
+
+// rgb is an opaque colour
+type rgb [3]uint8
+
+// hex formats the colour for a palette
+func (c rgb) hex() string {
+	return fmt.Sprintf("#%02X%02X%02X", c[0], c[1], c[2])
+}
+
+// pixel is a pixel of the resized image
+type pixel struct {
+	color  rgb
+	opaque bool
+}
+
+// resize scales the image to width x height. Each pixel is the average of
+// the block of source pixels it covers, weighted by their opacity, so
+// shrinking keeps thin details and transparent edges do not darken the
+// colours. Growing repeats pixels.
+func resize(img image.Image, width, height, alpha int) []pixel {
+	bounds := img.Bounds()
+	srcWidth, srcHeight := bounds.Dx(), bounds.Dy()
+
+	pixels := make([]pixel, 0, width*height)
+	for y := 0; y < height; y++ {
+		y0 := y * srcHeight / height
+		y1 := max(y0+1, (y+1)*srcHeight/height)
+		for x := 0; x < width; x++ {
+			x0 := x * srcWidth / width
+			x1 := max(x0+1, (x+1)*srcWidth/width)
+
+			// RGBA returns colours premultiplied by alpha, 16 bits each
+			var r, g, b, a uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					pr, pg, pb, pa := img.At(bounds.Min.X+sx, bounds.Min.Y+sy).RGBA()
+					r, g, b, a = r+uint64(pr), g+uint64(pg), b+uint64(pb), a+uint64(pa)
+				}
+			}
+			n := uint64((x1 - x0) * (y1 - y0))
+			if a == 0 || a/n < uint64(alpha)*0x101 {
+				pixels = append(pixels, pixel{})
+				continue
+			}
+			pixels = append(pixels, pixel{opaque: true, color: rgb{
+				uint8((r*255 + a/2) / a),
+				uint8((g*255 + a/2) / a),
+				uint8((b*255 + a/2) / a),
+			}})
+		}
+	}
+	return pixels
+}
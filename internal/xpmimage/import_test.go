@@ -0,0 +1,122 @@
+package xpmimage
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/dyuri/typtui/internal/parser"
+)
+
+// encode returns the image as a PNG
+func encode(t *testing.T, img image.Image) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("Failed to encode: %v", err)
+	}
+	return &buf
+}
+
+// palette lists the palette as "key=colour"
+func palette(xpm *parser.XPMIcon) string {
+	var entries []string
+	for _, e := range xpm.Palette {
+		entries = append(entries, e.Key+"="+e.Color.Hex)
+	}
+	return strings.Join(entries, " ")
+}
+
+func TestDecode(t *testing.T) {
+	red := color.NRGBA{0xFF, 0, 0, 0xFF}
+	blue := color.NRGBA{0, 0, 0xFF, 0xFF}
+	img := image.NewNRGBA(image.Rect(0, 0, 3, 2))
+	img.Set(0, 0, red)
+	img.Set(1, 0, red)
+	img.Set(2, 0, blue)
+	img.Set(0, 1, red)
+	img.Set(1, 1, color.NRGBA{0, 0xFF, 0, 0x40}) // below the threshold
+	img.Set(2, 1, color.NRGBA{0, 0, 0xFF, 0xC0})
+
+	xpm, err := Decode(encode(t, img), DefaultOptions())
+	if err != nil {
+		t.Fatalf("Failed to import: %v", err)
+	}
+	if xpm.Width != 3 || xpm.Height != 2 || xpm.Colors != 3 || xpm.CharsPerPixel != 1 {
+		t.Errorf("Unexpected header %dx%d, %d colours, %d chars", xpm.Width, xpm.Height, xpm.Colors, xpm.CharsPerPixel)
+	}
+	if got := palette(xpm); got != "!=#FF0000 $=#0000FF  =none" {
+		t.Errorf("Unexpected palette %q", got)
+	}
+	if got := strings.Join(xpm.Data, "|"); got != "!!$|! $" {
+		t.Errorf("Unexpected pixels %q", got)
+	}
+}
+
+func TestQuantize(t *testing.T) {
+	// A gradient of 64 greys reduced to 4 colours
+	img := image.NewGray(image.Rect(0, 0, 64, 1))
+	for x := 0; x < 64; x++ {
+		img.SetGray(x, 0, color.Gray{uint8(x * 4)})
+	}
+
+	xpm, err := FromImage(img, Options{Colors: 4})
+	if err != nil {
+		t.Fatalf("Failed to import: %v", err)
+	}
+	if xpm.Colors != 4 || len(xpm.Palette) != 4 {
+		t.Fatalf("Expected 4 colours, got %d: %s", xpm.Colors, palette(xpm))
+	}
+	row := xpm.Pixels(xpm.Data[0])
+	for x := 1; x < len(row); x++ {
+		prev, _ := xpm.Palette.Lookup(row[x-1])
+		cur, _ := xpm.Palette.Lookup(row[x])
+		if cur.Hex < prev.Hex {
+			t.Fatalf("Gradient is not kept in order at %d: %s after %s", x, cur.Hex, prev.Hex)
+		}
+	}
+}
+
+func TestResize(t *testing.T) {
+	// Each 2x2 block of the image is one colour, or half black and half
+	// transparent
+	img := image.NewNRGBA(image.Rect(0, 0, 4, 4))
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 4; x++ {
+			switch {
+			case x < 2 && y < 2:
+				img.Set(x, y, color.White)
+			case x >= 2 && y < 2:
+				img.Set(x, y, color.Black)
+			case x < 2 && x == y-2:
+				img.Set(x, y, color.Black)
+			}
+		}
+	}
+
+	xpm, err := FromImage(img, Options{Width: 2, Colors: 4, Alpha: 100})
+	if err != nil {
+		t.Fatalf("Failed to import: %v", err)
+	}
+	if xpm.Width != 2 || xpm.Height != 2 {
+		t.Fatalf("Expected 2x2, got %dx%d", xpm.Width, xpm.Height)
+	}
+	got := make([]string, 0, 4)
+	for y := 0; y < 2; y++ {
+		for _, key := range xpm.Pixels(xpm.Data[y]) {
+			c, _ := xpm.Palette.Lookup(key)
+			got = append(got, c.Hex)
+		}
+	}
+	// The half transparent block is 50% opaque and stays black
+	if strings.Join(got, " ") != "#FFFFFF #000000 #000000 none" {
+		t.Errorf("Unexpected pixels %v", got)
+	}
+
+	if _, err := FromImage(img, Options{Colors: 0}); err == nil {
+		t.Error("Expected an error for an empty palette")
+	}
+}
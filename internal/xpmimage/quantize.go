@@ -0,0 +1,124 @@
+package xpmimage
+
+import (
+	"cmp"
+	"slices"
+)
+
+// colorCount is a colour of the image and how many pixels have it
+type colorCount struct {
+	color rgb
+	n     int
+}
+
+// quantize reduces the colours to at most n with median cut: the colours
+// are split in two at the median of their widest channel until there are
+// n groups, and each group becomes its average colour. Images with few
+// enough colours keep them exactly.
+func quantize(counts map[rgb]int, n int) []rgb {
+	colors := make([]colorCount, 0, len(counts))
+	for c, count := range counts {
+		colors = append(colors, colorCount{c, count})
+	}
+	// Map order is random, sort for the same result every time
+	slices.SortFunc(colors, func(a, b colorCount) int {
+		return cmp.Compare(a.color.hex(), b.color.hex())
+	})
+
+	if len(colors) <= n {
+		palette := make([]rgb, len(colors))
+		for i, c := range colors {
+			palette[i] = c.color
+		}
+		return palette
+	}
+
+	boxes := [][]colorCount{colors}
+	for len(boxes) < n {
+		// Split the box with the widest channel
+		best, bestChannel, bestSpan := -1, 0, 0
+		for i, box := range boxes {
+			if len(box) < 2 {
+				continue
+			}
+			channel, span := widestChannel(box)
+			if span > bestSpan {
+				best, bestChannel, bestSpan = i, channel, span
+			}
+		}
+		if best < 0 {
+			break
+		}
+
+		box := boxes[best]
+		slices.SortStableFunc(box, func(a, b colorCount) int {
+			return cmp.Compare(a.color[bestChannel], b.color[bestChannel])
+		})
+		total := 0
+		for _, c := range box {
+			total += c.n
+		}
+		// Split where half of the pixels are on each side, keeping at least
+		// one colour in each half
+		split, seen := 1, box[0].n
+		for split < len(box)-1 && seen*2 < total {
+			seen += box[split].n
+			split++
+		}
+		boxes[best] = box[:split]
+		boxes = append(boxes, box[split:])
+	}
+
+	palette := make([]rgb, len(boxes))
+	for i, box := range boxes {
+		palette[i] = average(box)
+	}
+	return palette
+}
+
+// widestChannel returns the channel with the largest range in the box
+func widestChannel(box []colorCount) (channel, span int) {
+	for ch := 0; ch < 3; ch++ {
+		lo, hi := box[0].color[ch], box[0].color[ch]
+		for _, c := range box[1:] {
+			lo, hi = min(lo, c.color[ch]), max(hi, c.color[ch])
+		}
+		if int(hi-lo) > span {
+			channel, span = ch, int(hi-lo)
+		}
+	}
+	return channel, span
+}
+
+// average returns the mean colour of the box, weighted by pixel counts
+func average(box []colorCount) rgb {
+	var sum [3]int
+	total := 0
+	for _, c := range box {
+		for ch := range sum {
+			sum[ch] += int(c.color[ch]) * c.n
+		}
+		total += c.n
+	}
+	var avg rgb
+	for ch := range sum {
+		avg[ch] = uint8((sum[ch] + total/2) / total)
+	}
+	return avg
+}
+
+// closest returns the index of the palette colour nearest to c
+func closest(palette []rgb, c rgb) int {
+	best, bestDist := 0, -1
+	for i, p := range palette {
+		dist := 0
+		for ch := range p {
+			d := int(p[ch]) - int(c[ch])
+			dist += d * d
+		}
+		if bestDist < 0 || dist < bestDist {
+			best, bestDist = i, dist
+		}
+	}
+	return best
+}
@@ -0,0 +1,21 @@
+[_id]
+FID=1234
+ProductCode=1
+CodePage=1252
+[end]
+
+[_point]
+Type=0x2f
+SubType=0x06
+String=0x04,Bank
+[end]
+
+[_point]
+Type=0x2f07
+String=0x04,Car dealer
+[end]
+
+[_polygon]
+Type=0x13
+String=0x04,Building
+[end]
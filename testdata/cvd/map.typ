@@ -0,0 +1,37 @@
+[_id]
+FID=1234
+ProductCode=1
+CodePage=1252
+[end]
+
+[_line]
+Type=0x01
+Xpm="0 0 1 0"
+"1 c #FF0000"
+[end]
+
+[_polygon]
+Type=0x13
+Xpm="0 0 1 0"
+"1 c #D05050"
+[end]
+
+[_polygon]
+Type=0x14
+Xpm="2 2 2 1"
+"a c #5A8A20"
+"b c #0000FF"
+"aa"
+"ab"
+NightXpm="2 2 2 1"
+"a c #202020"
+"b c #0000FF"
+"aa"
+"ab"
+[end]
+
+[_polygon]
+Type=0x3c
+Xpm="0 0 1 0"
+"1 c #2040FF"
+[end]
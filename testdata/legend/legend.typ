@@ -0,0 +1,45 @@
+[_id]
+FID=1234
+CodePage=1252
+[end]
+
+[_point]
+Type=0x2f07
+String=0x04,Post <office>
+[end]
+
+[_point]
+Type=0x2f06
+String=0x04,Bank
+String=0x01,Banque
+DayXpm="2 1 2 1"
+"a c #FF0000"
+"b c none"
+"ab"
+NightXpm="2 1 1 1"
+"a c #800000"
+"aa"
+[end]
+
+[_point]
+Type=0x10101
+String=0x04,Peak
+[end]
+
+[_line]
+Type=0x01
+String=0x04,Road
+LineWidth=2
+BorderWidth=1
+Xpm="0 0 2 0"
+"a c #FF0000"
+"b c #000000"
+[end]
+
+[_polygon]
+Type=0x13
+Xpm="2 2 1 1"
+"a c #00FF00"
+"a."
+".a"
+[end]
@@ -0,0 +1,35 @@
+[_id]
+FID=1234
+ProductCode=1
+CodePage=1252
+[end]
+
+[_point]
+Type=0x2f06
+String=0x04,Bank
+DayXpm="2 2 2 1"
+"a c #FF0000"
+"b c none"
+"ab"
+"ba"
+DayFontColor=#202020
+[end]
+
+[_point]
+Type=0x2f07
+String=0x04,Car dealer
+DayXpm="1 1 1 1"
+"a c #FFFFFF"
+"a"
+NightXpm="1 1 1 1"
+"a c #000000"
+"a"
+[end]
+
+[_polygon]
+Type=0x13
+String=0x04,Building
+Xpm="0 0 2 0"
+"1 c #E0E0E0"
+"2 c #A0A0A0"
+[end]
@@ -0,0 +1,51 @@
+; Hand maintained style with comments and sections typtui does not know
+;
+; Keep the header first.
+[_id]
+CodePage=1252   ; western europe
+FID=1234
+ProductCode=1
+[end]
+
+[_comments]
+Free form notes for other editors.
+Type=0x99 is not a real type here.
+[end]
+
+; ---------------------------------------------------------------
+; Points
+; ---------------------------------------------------------------
+
+[_point]
+; the bank icon
+Type=0x2f06
+String1=0x04,Bank
+String2=0x01,Banque
+Author=someone
+DayXpm="4 2 2 1"
+"! c #778899"
+; transparent background
+"  c none"
+"!  !"
+" !! "
+FontStyle=SmallFont
+[end]
+
+[_line]
+Type=0x01
+String=0x04,Highway
+
+LineWidth=4
+BorderWidth=1
+Xpm="0 0 2 0"
+"1 c #FF0000"
+"2 c #000000"
+[end]
+
+[_polygon]
+Type=0x13
+String=0x04,Park
+Xpm="0 0 1 0"
+"a c #90EE90"
+[end]
+; trailing comment
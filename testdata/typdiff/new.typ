@@ -0,0 +1,20 @@
+[_id]
+FID=2
+CodePage=1252
+[end]
+
+[_line]
+Type=0x02
+String=0x04,Track
+[end]
+
+[_point]
+Type=0x2f06
+String=0x04,Money
+String=0x01,Banque
+DayXpm="2 2 2 1"
+"a c #00FF00"
+"b c none"
+"ab"
+"bb"
+[end]
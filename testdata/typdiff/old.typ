@@ -0,0 +1,21 @@
+[_id]
+FID=1
+CodePage=1252
+[end]
+
+[_point]
+Type=0x2f
+SubType=0x06
+String=0x04,Bank
+DayXpm="2 2 2 1"
+"a c #FF0000"
+"b c none"
+"ab"
+"ba"
+[end]
+
+[_line]
+Type=0x01
+String=0x04,Road
+LineWidth=3
+[end]
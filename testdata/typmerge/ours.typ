@@ -0,0 +1,29 @@
+[_id]
+FID=1
+CodePage=1252
+[end]
+
+[_drawOrder]
+Type=0x01,1
+[end]
+
+; Banks
+[_point]
+Type=0x2f06
+String=0x04,Money
+DayXpm="2 1 2 1"
+"a c #FF0000"
+"b c none"
+"ab"
+[end]
+
+[_line]
+Type=0x01
+String=0x04,Road
+LineWidth=4
+[end]
+
+[_polygon]
+Type=0x01
+String=0x04,Town
+[end]
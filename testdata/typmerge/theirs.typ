@@ -0,0 +1,35 @@
+[_id]
+FID=1
+CodePage=1252
+[end]
+
+[_drawOrder]
+Type=0x01,3
+Type=0x02,2
+[end]
+
+[_point]
+Type=0x2f
+SubType=0x06
+String=0x04,Bank
+String=0x01,Banque
+DayXpm="2 1 2 1"
+"a c #00FF00"
+"b c none"
+"ab"
+[end]
+
+[_polygon]
+Type=0x01
+String=0x04,Town
+[end]
+
+[_polygon]
+Type=0x02
+String=0x04,Park
+[end]
+
+[_line]
+Type=0x02
+String=0x04,Track
+[end]
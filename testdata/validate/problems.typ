@@ -0,0 +1,39 @@
+[_id]
+FID=1
+ProductCode=1
+CodePage=1252
+[end]
+
+[_point]
+Type=0x2f
+SubType=0x06
+DayXpm="2 2 2 1"
+"a c #FF0000"
+"b c none"
+"ab"
+"ba"
+[end]
+
+[_point]
+Type=0x2f06
+[end]
+
+[_line]
+Type=0x45
+Xpm="0 0 1 0"
+"a c #000000"
+[end]
+
+[_polygon]
+Type=0x13
+Xpm="4 2 1 1"
+"a c #00FF00"
+"aaa"
+"aaxa"
+[end]
+
+[_polygon]
+Type=0x10f04
+Xpm="0 0 2 0"
+"a c #00FF00"
+[end]